@@ -74,8 +74,8 @@ keychain_account = "default"
 	if err != nil {
 		t.Fatalf("load manifest: %v", err)
 	}
-	if len(manifest.Entries) != 1 {
-		t.Fatalf("expected 1 manifest entry, got %d", len(manifest.Entries))
+	if len(manifest.Entries) != 2 {
+		t.Fatalf("expected root directory and file manifest entries, got %d", len(manifest.Entries))
 	}
 
 	snapshots, err := os.ReadDir(snapshotsDir)
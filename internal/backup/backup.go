@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -16,18 +17,32 @@ import (
 )
 
 type ManifestEntry struct {
-	Path       string      `json:"path"`
-	Size       int64       `json:"size"`
-	Mode       fs.FileMode `json:"mode"`
-	ModTime    time.Time   `json:"mod_time"`
-	SHA256     string      `json:"sha256"`
-	ObjectKey  string      `json:"object_key,omitempty"`
-	SourceKind string      `json:"source_kind,omitempty"`
+	Path       string            `json:"path"`
+	Size       int64             `json:"size"`
+	Mode       fs.FileMode       `json:"mode"`
+	ModTime    time.Time         `json:"mod_time"`
+	SHA256     string            `json:"sha256"`
+	ObjectKey  string            `json:"object_key,omitempty"`
+	Chunks     []ChunkRef        `json:"chunks,omitempty"`
+	SourceKind string            `json:"source_kind,omitempty"`
+	LinkTarget string            `json:"link_target,omitempty"`
+	Owner      *Ownership        `json:"owner,omitempty"`
+	Xattrs     map[string][]byte `json:"xattrs,omitempty"`
+}
+
+type ChunkRef struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
 }
 
 type Manifest struct {
 	CreatedAt time.Time       `json:"created_at"`
 	Entries   []ManifestEntry `json:"entries"`
+	// Incomplete marks a snapshot saved by a run that stopped before every
+	// changed entry was stored; it lists only the entries that were.
+	Incomplete bool `json:"incomplete,omitempty"`
+	// Tags label the snapshot for keep_tags retention rules.
+	Tags []string `json:"tags,omitempty"`
 }
 
 type Plan struct {
@@ -48,8 +63,24 @@ func (e ManifestEntry) IsCloudPlaceholder() bool {
 	return e.effectiveSourceKind() == manifestSourceKindCloudPlaceholder
 }
 
+// IsDir reports whether the entry records a directory. Directories carry
+// only metadata; their contents are separate entries.
+func (e ManifestEntry) IsDir() bool {
+	return e.Mode.IsDir()
+}
+
+// IsSymlink reports whether the entry records a symbolic link. The link is
+// stored as its LinkTarget and is never followed.
+func (e ManifestEntry) IsSymlink() bool {
+	return e.Mode&fs.ModeSymlink != 0
+}
+
 func (e ManifestEntry) HasStoredContent() bool {
-	return !e.IsCloudPlaceholder()
+	return e.Mode.IsRegular() && !e.IsCloudPlaceholder()
+}
+
+func (e ManifestEntry) IsChunked() bool {
+	return len(e.Chunks) > 0
 }
 
 func LoadManifest(path string) (*Manifest, error) {
@@ -84,12 +115,15 @@ func SaveManifest(path string, m *Manifest) error {
 }
 
 func BuildManifest(roots []string) (*Manifest, error) {
-	return BuildManifestWithOptions(roots, BuildOptions{})
+	return BuildManifestWithOptions(context.Background(), roots, BuildOptions{})
 }
 
-func BuildManifestWithOptions(roots []string, opts BuildOptions) (*Manifest, error) {
+// BuildManifestWithOptions walks roots and records every entry that is not
+// excluded. The walk stops with ctx's error once ctx is done.
+func BuildManifestWithOptions(ctx context.Context, roots []string, opts BuildOptions) (*Manifest, error) {
 	entries := make([]ManifestEntry, 0)
 	matcher := newExclusionMatcher(opts)
+	scanStart := time.Now()
 
 	for _, root := range roots {
 		cleanRoot := filepath.Clean(root)
@@ -98,6 +132,9 @@ func BuildManifestWithOptions(roots []string, opts BuildOptions) (*Manifest, err
 		}
 
 		walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
 			if err != nil {
 				if shouldIgnoreManifestError(path, err) {
 					return nil
@@ -111,10 +148,7 @@ func BuildManifestWithOptions(roots []string, opts BuildOptions) (*Manifest, err
 				}
 				return nil
 			}
-			if d.IsDir() {
-				return nil
-			}
-			if shouldSkipManifestPath(cleanPath) {
+			if !d.IsDir() && shouldSkipManifestPath(cleanPath) {
 				return nil
 			}
 
@@ -125,30 +159,56 @@ func BuildManifestWithOptions(roots []string, opts BuildOptions) (*Manifest, err
 				}
 				return err
 			}
-			if !info.Mode().IsRegular() {
-				// Skip non-regular entries (for example symlinked framework dirs).
-				return nil
+			entry := ManifestEntry{
+				Path:    cleanPath,
+				Mode:    info.Mode(),
+				ModTime: info.ModTime().UTC(),
 			}
-			if placeholderEntry, ok := cloudPlaceholderManifestEntry(cleanPath, info); ok {
-				entries = append(entries, placeholderEntry)
+			switch {
+			case info.IsDir():
+			case info.Mode()&fs.ModeSymlink != 0:
+				// Symlinks are recorded as-is and never followed.
+				entry.LinkTarget, err = os.Readlink(path)
+				if err != nil {
+					if shouldIgnoreManifestError(cleanPath, err) {
+						return nil
+					}
+					return err
+				}
+			case !info.Mode().IsRegular():
+				// Skip sockets, devices and named pipes.
 				return nil
+			default:
+				if placeholderEntry, ok := cloudPlaceholderManifestEntry(cleanPath, info); ok {
+					entries = append(entries, placeholderEntry)
+					return nil
+				}
+
+				hash, cached := "", false
+				if !opts.Rehash {
+					hash, cached = opts.StatCache.lookup(cleanPath, info)
+				}
+				if !cached {
+					hash, err = fileSHA256(path)
+					if err != nil {
+						if shouldIgnoreManifestError(cleanPath, err) {
+							return nil
+						}
+						return err
+					}
+					opts.StatCache.record(cleanPath, info, hash, scanStart)
+				}
+				entry.Size = info.Size()
+				entry.SHA256 = hash
 			}
 
-			hash, err := fileSHA256(path)
-			if err != nil {
+			if err := captureEntryMetadata(&entry, path, info); err != nil {
 				if shouldIgnoreManifestError(cleanPath, err) {
 					return nil
 				}
 				return err
 			}
-
-			entries = append(entries, ManifestEntry{
-				Path:    cleanPath,
-				Size:    info.Size(),
-				Mode:    info.Mode(),
-				ModTime: info.ModTime().UTC(),
-				SHA256:  hash,
-			})
+			entries = append(entries, entry)
 			return nil
 		})
 		if walkErr != nil {
@@ -224,6 +284,15 @@ func manifestEntriesMatchForPlan(previous, current ManifestEntry) bool {
 	if previous.effectiveSourceKind() != current.effectiveSourceKind() {
 		return false
 	}
+	if previous.Mode.Type() != current.Mode.Type() {
+		return false
+	}
+	if current.IsDir() {
+		return previous.Mode == current.Mode && previous.ModTime.Equal(current.ModTime)
+	}
+	if current.IsSymlink() {
+		return previous.LinkTarget == current.LinkTarget
+	}
 	if current.IsCloudPlaceholder() {
 		return previous.Size == current.Size &&
 			previous.Mode == current.Mode &&
@@ -255,8 +324,12 @@ func ObjectKeyForContentSHA256(sha string) string {
 	return "sha256/" + strings.ToLower(strings.TrimSpace(sha)) + ".enc"
 }
 
+func ObjectKeyForChunkSHA256(sha string) string {
+	return "chunks/sha256/" + strings.ToLower(strings.TrimSpace(sha)) + ".enc"
+}
+
 func ResolveObjectKey(entry ManifestEntry) string {
-	if !entry.HasStoredContent() {
+	if !entry.HasStoredContent() || entry.IsChunked() {
 		return ""
 	}
 	if key := strings.TrimSpace(entry.ObjectKey); key != "" {
@@ -265,6 +338,20 @@ func ResolveObjectKey(entry ManifestEntry) string {
 	return ObjectKeyForPath(entry.Path)
 }
 
+func ResolveObjectKeys(entry ManifestEntry) []string {
+	if !entry.HasStoredContent() {
+		return nil
+	}
+	if !entry.IsChunked() {
+		return []string{ResolveObjectKey(entry)}
+	}
+	keys := make([]string, 0, len(entry.Chunks))
+	for _, chunk := range entry.Chunks {
+		keys = append(keys, ObjectKeyForChunkSHA256(chunk.SHA256))
+	}
+	return keys
+}
+
 func AssignObjectKeys(previous, current *Manifest) {
 	if current == nil {
 		return
@@ -281,11 +368,19 @@ func AssignObjectKeys(previous, current *Manifest) {
 		entry := &current.Entries[i]
 		if !entry.HasStoredContent() {
 			entry.ObjectKey = ""
+			entry.Chunks = nil
 			continue
 		}
 		prev, ok := prevMap[filepath.Clean(entry.Path)]
 		if ok && prev.HasStoredContent() && prev.SHA256 == entry.SHA256 && prev.Size == entry.Size {
 			entry.ObjectKey = ResolveObjectKey(prev)
+			entry.Chunks = append([]ChunkRef(nil), prev.Chunks...)
+			continue
+		}
+		entry.Chunks = nil
+		if shouldChunkEntry(*entry) {
+			// Chunk refs are recorded when the entry is uploaded.
+			entry.ObjectKey = ""
 			continue
 		}
 		entry.ObjectKey = ObjectKeyForContentSHA256(entry.SHA256)
@@ -342,7 +437,20 @@ func VerifyEntryContent(entry ManifestEntry, content []byte) error {
 	sum := sha256.Sum256(content)
 	got := hex.EncodeToString(sum[:])
 	if got != entry.SHA256 {
-		return fmt.Errorf("checksum mismatch for %s: got %s want %s", entry.Path, got, entry.SHA256)
+		return fmt.Errorf("%w for %s: got %s want %s", ErrChecksumMismatch, entry.Path, got, entry.SHA256)
+	}
+	return nil
+}
+
+// VerifyEntryFile checks a file on disk against the entry checksum without
+// loading it into memory.
+func VerifyEntryFile(entry ManifestEntry, path string) error {
+	got, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if got != entry.SHA256 {
+		return fmt.Errorf("%w for %s: got %s want %s", ErrChecksumMismatch, entry.Path, got, entry.SHA256)
 	}
 	return nil
 }
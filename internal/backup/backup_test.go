@@ -3,6 +3,7 @@ package backup
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"io/fs"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -36,6 +37,33 @@ func TestPlanChangesDetectsNewChangedRemoved(t *testing.T) {
 	}
 }
 
+func TestPlanChangesComparesEntryKinds(t *testing.T) {
+	modTime := time.Date(2026, time.March, 27, 12, 0, 0, 0, time.UTC)
+	prev := &Manifest{Entries: []ManifestEntry{
+		{Path: "/dir", Mode: fs.ModeDir | 0o755, ModTime: modTime},
+		{Path: "/link", Mode: fs.ModeSymlink | 0o777, LinkTarget: "a.txt"},
+		{Path: "/retargeted", Mode: fs.ModeSymlink | 0o777, LinkTarget: "a.txt"},
+		{Path: "/was-file", Size: 1, SHA256: "x"},
+	}}
+	curr := &Manifest{Entries: []ManifestEntry{
+		{Path: "/dir", Mode: fs.ModeDir | 0o755, ModTime: modTime},
+		{Path: "/link", Mode: fs.ModeSymlink | 0o777, LinkTarget: "a.txt"},
+		{Path: "/retargeted", Mode: fs.ModeSymlink | 0o777, LinkTarget: "b.txt"},
+		{Path: "/was-file", Mode: fs.ModeSymlink | 0o777, LinkTarget: "a.txt"},
+	}}
+
+	plan := PlanChanges(prev, curr)
+
+	got := make([]string, 0, len(plan.NewOrChanged))
+	for _, entry := range plan.NewOrChanged {
+		got = append(got, entry.Path)
+	}
+	want := []string{"/retargeted", "/was-file"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("new/changed mismatch: got %v want %v", got, want)
+	}
+}
+
 func TestFindEntryByPathCleansInput(t *testing.T) {
 	entry := ManifestEntry{Path: filepath.Clean("/tmp/test/file.txt"), SHA256: "x"}
 	m := &Manifest{Entries: []ManifestEntry{entry}}
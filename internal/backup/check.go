@@ -0,0 +1,324 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"baxter/internal/recovery"
+	"baxter/internal/repolock"
+	"baxter/internal/storage"
+)
+
+// Kinds of problems CheckRepository reports.
+const (
+	CheckMetadataMissing       = "metadata_missing"
+	CheckMetadataInvalid       = "metadata_invalid"
+	CheckLatestSnapshotMissing = "latest_snapshot_missing"
+	CheckManifestUnreadable    = "manifest_unreadable"
+	CheckObjectMissing         = "object_missing"
+	CheckObjectUnreadable      = "object_unreadable"
+	CheckObjectUndecryptable   = "object_undecryptable"
+	CheckObjectCorrupt         = "object_corrupt"
+)
+
+type CheckOptions struct {
+	Store storage.ObjectStore
+	Keys  [][]byte
+	// ReadData downloads and decrypts referenced objects and compares their
+	// plaintext against the checksums recorded in the snapshots.
+	ReadData bool
+	// ReadDataFraction limits ReadData to a random share of the referenced
+	// objects (0 or 1 for all of them).
+	ReadDataFraction float64
+	// Rand picks the ReadDataFraction subset (default: randomly seeded).
+	Rand        *rand.Rand
+	Concurrency int
+}
+
+type CheckIssue struct {
+	Kind     string `json:"kind"`
+	Key      string `json:"key,omitempty"`
+	Snapshot string `json:"snapshot,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// CheckReport reconciles the objects in a store with every snapshot stored
+// there. Problems make the backup set unrestorable in part; orphans and
+// unknown keys do not, and are listed for gc and for inspection.
+type CheckReport struct {
+	Snapshots         int          `json:"snapshots"`
+	StoredObjects     int          `json:"stored_objects"`
+	ReferencedObjects int          `json:"referenced_objects"`
+	ReadObjects       int          `json:"read_objects"`
+	Problems          []CheckIssue `json:"problems"`
+	// Orphans are data objects no snapshot references. They are not listed
+	// when a snapshot manifest could not be read.
+	Orphans     []string `json:"orphans"`
+	UnknownKeys []string `json:"unknown_keys"`
+}
+
+func (r CheckReport) HasProblems() bool {
+	return len(r.Problems) > 0
+}
+
+// objectReference records where a data object is first referenced, and the
+// SHA-256 its plaintext must have.
+type objectReference struct {
+	snapshot string
+	path     string
+	sha256   string
+}
+
+// CheckRepository loads every snapshot manifest in opts.Store and reconciles
+// the objects they reference with the keys the store lists. Once ctx is done
+// it stops and returns ctx's error.
+func CheckRepository(ctx context.Context, opts CheckOptions) (CheckReport, error) {
+	if opts.Store == nil {
+		return CheckReport{}, errors.New("object store is required")
+	}
+	store := storage.WithContext(ctx, opts.Store)
+	report := CheckReport{Problems: []CheckIssue{}, UnknownKeys: []string{}}
+
+	keys, err := store.ListKeys()
+	if err != nil {
+		return CheckReport{}, fmt.Errorf("list object keys: %w", err)
+	}
+	stored := make(map[string]struct{}, len(keys))
+	var snapshotIDs, dataKeys []string
+	for _, key := range keys {
+		switch {
+		case key == recovery.MetadataObjectKey(), repolock.IsLockKey(key):
+		case IsSystemObjectKey(key):
+			if id, ok := RemoteSnapshotManifestIDFromObjectKey(key); ok {
+				snapshotIDs = append(snapshotIDs, id)
+			} else {
+				report.UnknownKeys = append(report.UnknownKeys, key)
+			}
+		case isDataObjectKey(key):
+			stored[key] = struct{}{}
+			dataKeys = append(dataKeys, key)
+		default:
+			report.UnknownKeys = append(report.UnknownKeys, key)
+		}
+	}
+	sort.Strings(snapshotIDs)
+	report.Snapshots = len(snapshotIDs)
+	report.StoredObjects = len(dataKeys)
+
+	metadata, err := recovery.ReadMetadata(store)
+	switch {
+	case err == nil:
+		latest := strings.TrimSpace(metadata.LatestSnapshotID)
+		if latest != "" && !slices.Contains(snapshotIDs, latest) {
+			report.Problems = append(report.Problems, CheckIssue{Kind: CheckLatestSnapshotMissing, Snapshot: latest})
+		}
+	case errors.Is(err, recovery.ErrMetadataNotFound):
+		report.Problems = append(report.Problems, CheckIssue{Kind: CheckMetadataMissing, Key: recovery.MetadataObjectKey()})
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return report, err
+	default:
+		report.Problems = append(report.Problems, CheckIssue{Kind: CheckMetadataInvalid, Key: recovery.MetadataObjectKey(), Detail: err.Error()})
+	}
+
+	references := make(map[string]objectReference)
+	manifestsReadable := true
+	for _, id := range snapshotIDs {
+		manifest, err := ReadEncryptedSnapshotManifest(store, id, opts.Keys)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return report, ctxErr
+			}
+			manifestsReadable = false
+			objectKey, _ := RemoteSnapshotManifestObjectKey(id)
+			report.Problems = append(report.Problems, CheckIssue{Kind: CheckManifestUnreadable, Key: objectKey, Snapshot: id, Detail: err.Error()})
+			continue
+		}
+		addObjectReferences(references, id, manifest)
+	}
+	report.ReferencedObjects = len(references)
+
+	present := make([]string, 0, len(references))
+	for key, ref := range references {
+		if _, ok := stored[key]; !ok {
+			report.Problems = append(report.Problems, CheckIssue{Kind: CheckObjectMissing, Key: key, Snapshot: ref.snapshot, Path: ref.path})
+			continue
+		}
+		present = append(present, key)
+	}
+	if manifestsReadable {
+		report.Orphans = []string{}
+		for _, key := range dataKeys {
+			if _, ok := references[key]; !ok {
+				report.Orphans = append(report.Orphans, key)
+			}
+		}
+	}
+
+	if opts.ReadData {
+		sort.Strings(present)
+		selected := selectCheckSubset(present, opts.ReadDataFraction, opts.Rand)
+		issues, err := readCheckObjects(ctx, store, opts, selected, references)
+		if err != nil {
+			return report, err
+		}
+		report.ReadObjects = len(selected)
+		report.Problems = append(report.Problems, issues...)
+	}
+
+	sort.Slice(report.Problems, func(i, j int) bool {
+		a, b := report.Problems[i], report.Problems[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Snapshot < b.Snapshot
+	})
+	return report, nil
+}
+
+func addObjectReferences(references map[string]objectReference, snapshotID string, manifest *Manifest) {
+	for _, entry := range manifest.Entries {
+		if !entry.HasStoredContent() {
+			continue
+		}
+		if !entry.IsChunked() {
+			key := ResolveObjectKey(entry)
+			if _, ok := references[key]; !ok {
+				references[key] = objectReference{snapshot: snapshotID, path: entry.Path, sha256: entry.SHA256}
+			}
+			continue
+		}
+		for _, chunk := range entry.Chunks {
+			key := ObjectKeyForChunkSHA256(chunk.SHA256)
+			if _, ok := references[key]; !ok {
+				references[key] = objectReference{snapshot: snapshotID, path: entry.Path, sha256: chunk.SHA256}
+			}
+		}
+	}
+}
+
+// isDataObjectKey reports whether key has the shape of a content, chunk or
+// legacy path-derived object key.
+func isDataObjectKey(key string) bool {
+	name, ok := strings.CutSuffix(key, ".enc")
+	if !ok {
+		return false
+	}
+	if rest, ok := strings.CutPrefix(name, "chunks/sha256/"); ok {
+		return isSHA256Hex(rest)
+	}
+	if rest, ok := strings.CutPrefix(name, "sha256/"); ok {
+		return isSHA256Hex(rest)
+	}
+	return isSHA256Hex(name)
+}
+
+func isSHA256Hex(value string) bool {
+	if len(value) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+func selectCheckSubset(keys []string, fraction float64, rng *rand.Rand) []string {
+	if fraction <= 0 || fraction >= 1 || len(keys) == 0 {
+		return keys
+	}
+	count := int(math.Ceil(fraction * float64(len(keys))))
+	perm := rand.Perm
+	if rng != nil {
+		perm = rng.Perm
+	}
+	selected := make([]string, 0, count)
+	for _, index := range perm(len(keys))[:count] {
+		selected = append(selected, keys[index])
+	}
+	sort.Strings(selected)
+	return selected
+}
+
+func readCheckObjects(ctx context.Context, store storage.ObjectStore, opts CheckOptions, keys []string, references map[string]objectReference) ([]CheckIssue, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	workerCount := opts.Concurrency
+	if workerCount <= 0 {
+		workerCount = defaultCopyConcurrency
+	}
+	if workerCount > len(keys) {
+		workerCount = len(keys)
+	}
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var issues []CheckIssue
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				ref := references[key]
+				issue, ok := checkObjectContent(store, opts.Keys, key, ref)
+				if !ok && ctx.Err() == nil {
+					mu.Lock()
+					issues = append(issues, issue)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, key := range keys {
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return nil, ctx.Err()
+		case jobs <- key:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+func checkObjectContent(store storage.ObjectStore, keys [][]byte, key string, ref objectReference) (CheckIssue, bool) {
+	hash := sha256.New()
+	err := copyObjectPlaintext(hash, store, keys, key)
+	issue := CheckIssue{Key: key, Snapshot: ref.snapshot, Path: ref.path}
+	switch {
+	case errors.Is(err, ErrObjectDecrypt):
+		issue.Kind = CheckObjectUndecryptable
+	case err != nil:
+		issue.Kind = CheckObjectUnreadable
+	default:
+		got := hex.EncodeToString(hash.Sum(nil))
+		// Old snapshots may not record a checksum; decrypting is all that
+		// can be checked then.
+		if ref.sha256 == "" || strings.EqualFold(got, strings.TrimSpace(ref.sha256)) {
+			return CheckIssue{}, true
+		}
+		issue.Kind = CheckObjectCorrupt
+		issue.Detail = fmt.Sprintf("got sha256 %s want %s", got, ref.sha256)
+		return issue, false
+	}
+	issue.Detail = err.Error()
+	return issue, false
+}
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"context"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+
+	"baxter/internal/crypto"
+	"baxter/internal/storage"
+)
+
+func checkSeededSource(t *testing.T, store *storage.LocalClient, opts CheckOptions) CheckReport {
+	t.Helper()
+	opts.Store = store
+	opts.Keys = [][]byte{testCopyKey}
+	report, err := CheckRepository(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("check repository: %v", err)
+	}
+	return report
+}
+
+func seededDataKeys(t *testing.T, store *storage.LocalClient) []string {
+	t.Helper()
+	keys, err := store.ListKeys()
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	return FilterDataObjectKeys(keys)
+}
+
+func TestCheckRepositoryReportsConsistentRepository(t *testing.T) {
+	source, _ := seedCopySource(t)
+
+	report := checkSeededSource(t, source, CheckOptions{ReadData: true})
+	if report.HasProblems() {
+		t.Fatalf("unexpected problems: %+v", report.Problems)
+	}
+	if report.Snapshots != 2 || report.StoredObjects != 3 || report.ReferencedObjects != 3 || report.ReadObjects != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(report.Orphans) != 0 || len(report.UnknownKeys) != 0 {
+		t.Fatalf("unexpected orphans or unknown keys: %+v", report)
+	}
+}
+
+func TestCheckRepositoryReportsMissingOrphanedAndUnknownObjects(t *testing.T) {
+	source, _ := seedCopySource(t)
+	dataKeys := seededDataKeys(t, source)
+	missing := dataKeys[0]
+	if err := source.DeleteObject(missing); err != nil {
+		t.Fatalf("delete object: %v", err)
+	}
+	orphan := ObjectKeyForContentSHA256("0000000000000000000000000000000000000000000000000000000000000000")
+	for _, key := range []string{orphan, "notes.txt", "system/other.json"} {
+		if err := source.PutObject(key, []byte("x")); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+
+	report := checkSeededSource(t, source, CheckOptions{})
+	if len(report.Problems) != 1 {
+		t.Fatalf("expected one problem, got %+v", report.Problems)
+	}
+	problem := report.Problems[0]
+	if problem.Kind != CheckObjectMissing || problem.Key != missing || problem.Snapshot == "" || problem.Path == "" {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+	if !reflect.DeepEqual(report.Orphans, []string{orphan}) {
+		t.Fatalf("unexpected orphans: %v", report.Orphans)
+	}
+	if !reflect.DeepEqual(report.UnknownKeys, []string{"notes.txt", "system/other.json"}) {
+		t.Fatalf("unexpected unknown keys: %v", report.UnknownKeys)
+	}
+}
+
+func TestCheckRepositoryReportsUnreadableManifestAndSkipsOrphans(t *testing.T) {
+	source, ids := seedCopySource(t)
+	manifestKey, err := RemoteSnapshotManifestObjectKey(ids[0])
+	if err != nil {
+		t.Fatalf("manifest key: %v", err)
+	}
+	if err := source.PutObject(manifestKey, []byte("garbage")); err != nil {
+		t.Fatalf("overwrite manifest: %v", err)
+	}
+
+	report := checkSeededSource(t, source, CheckOptions{})
+	if len(report.Problems) != 1 || report.Problems[0].Kind != CheckManifestUnreadable || report.Problems[0].Snapshot != ids[0] {
+		t.Fatalf("unexpected problems: %+v", report.Problems)
+	}
+	// The first version of a.txt is only referenced by the unreadable
+	// snapshot; it must not be reported as an orphan.
+	if report.Orphans != nil {
+		t.Fatalf("expected orphans to be skipped, got %v", report.Orphans)
+	}
+}
+
+func TestCheckRepositoryReadDataFindsCorruptObjects(t *testing.T) {
+	source, _ := seedCopySource(t)
+	dataKeys := seededDataKeys(t, source)
+	undecryptable, corrupt := dataKeys[0], dataKeys[1]
+	if err := source.PutObject(undecryptable, []byte("not encrypted")); err != nil {
+		t.Fatalf("overwrite object: %v", err)
+	}
+	// Well encrypted, but not the content the snapshot recorded.
+	payload, err := crypto.EncryptBytes(testCopyKey, []byte("tampered"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if err := source.PutObject(corrupt, payload); err != nil {
+		t.Fatalf("overwrite object: %v", err)
+	}
+
+	if report := checkSeededSource(t, source, CheckOptions{}); report.HasProblems() {
+		t.Fatalf("expected structural check to pass, got %+v", report.Problems)
+	}
+	report := checkSeededSource(t, source, CheckOptions{ReadData: true, Concurrency: 1})
+	kinds := map[string]string{}
+	for _, problem := range report.Problems {
+		kinds[problem.Key] = problem.Kind
+	}
+	want := map[string]string{undecryptable: CheckObjectUndecryptable, corrupt: CheckObjectCorrupt}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("unexpected problems: %+v", report.Problems)
+	}
+}
+
+func TestCheckRepositoryReadsDataSubset(t *testing.T) {
+	source, _ := seedCopySource(t)
+
+	report := checkSeededSource(t, source, CheckOptions{
+		ReadData:         true,
+		ReadDataFraction: 0.5,
+		Rand:             rand.New(rand.NewPCG(1, 2)),
+	})
+	// ceil(0.5 * 3)
+	if report.ReadObjects != 2 || report.HasProblems() {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
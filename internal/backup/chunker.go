@@ -0,0 +1,117 @@
+package backup
+
+import (
+	"errors"
+	"io"
+)
+
+// Content-defined chunking uses FastCDC-style gear hashing with normalized
+// chunk sizes so an edit only reshapes the chunks around the changed bytes.
+const (
+	chunkedEntryMinSize int64  = 1 << 20
+	chunkMinSize               = 256 * 1024
+	chunkAvgSize               = 1 << 20
+	chunkMaxSize               = 4 << 20
+	chunkMaskSmall      uint64 = 0xFFFFFC0000000000 // 22 bits, used below the average size
+	chunkMaskLarge      uint64 = 0xFFFFC00000000000 // 18 bits, used past the average size
+	gearTableSeed       uint64 = 0x62617874657263dc
+)
+
+var gearTable = newGearTable(gearTableSeed)
+
+func newGearTable(seed uint64) [256]uint64 {
+	var table [256]uint64
+	state := seed
+	for i := range table {
+		state += 0x9E3779B97F4A7C15
+		z := state
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}
+
+func chunkBoundary(data []byte) int {
+	n := len(data)
+	if n <= chunkMinSize {
+		return n
+	}
+	if n > chunkMaxSize {
+		n = chunkMaxSize
+	}
+	normal := chunkAvgSize
+	if n < normal {
+		normal = n
+	}
+
+	var fingerprint uint64
+	i := chunkMinSize
+	for ; i < normal; i++ {
+		fingerprint = (fingerprint << 1) + gearTable[data[i]]
+		if fingerprint&chunkMaskSmall == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fingerprint = (fingerprint << 1) + gearTable[data[i]]
+		if fingerprint&chunkMaskLarge == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+type chunker struct {
+	r     io.Reader
+	buf   []byte
+	start int
+	end   int
+	eof   bool
+}
+
+func newChunker(r io.Reader) *chunker {
+	return &chunker{r: r, buf: make([]byte, 2*chunkMaxSize)}
+}
+
+// Next returns the next chunk of the stream. The returned slice is only valid
+// until the following call to Next.
+func (c *chunker) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+
+	n := chunkBoundary(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+func (c *chunker) fill() error {
+	if c.eof || c.end-c.start >= chunkMaxSize {
+		return nil
+	}
+
+	copy(c.buf, c.buf[c.start:c.end])
+	c.end -= c.start
+	c.start = 0
+	for c.end < len(c.buf) {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func shouldChunkEntry(entry ManifestEntry) bool {
+	return entry.HasStoredContent() && entry.Size >= chunkedEntryMinSize
+}
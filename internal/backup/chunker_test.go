@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func chunkTestPayload(size int, seed int64) []byte {
+	data := make([]byte, size)
+	rng := rand.New(rand.NewSource(seed))
+	_, _ = rng.Read(data)
+	return data
+}
+
+func collectChunks(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	c := newChunker(bytes.NewReader(data))
+	chunks := make([][]byte, 0)
+	for {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("next chunk: %v", err)
+		}
+		chunks = append(chunks, append([]byte(nil), chunk...))
+	}
+}
+
+func TestChunkerRespectsSizeBoundsAndReassembles(t *testing.T) {
+	data := chunkTestPayload(12<<20, 1)
+	chunks := collectChunks(t, data)
+	if len(chunks) < 2 {
+		t.Fatalf("expected multiple chunks, got %d", len(chunks))
+	}
+
+	var joined []byte
+	for i, chunk := range chunks {
+		if len(chunk) > chunkMaxSize {
+			t.Fatalf("chunk %d exceeds max size: %d", i, len(chunk))
+		}
+		if i < len(chunks)-1 && len(chunk) < chunkMinSize {
+			t.Fatalf("chunk %d below min size: %d", i, len(chunk))
+		}
+		joined = append(joined, chunk...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Fatal("reassembled chunks do not match input")
+	}
+}
+
+func TestChunkerIsDeterministicAndLocalizesEdits(t *testing.T) {
+	original := chunkTestPayload(16<<20, 2)
+	edited := append([]byte(nil), original...)
+	copy(edited[8<<20:], []byte("a small in-place edit"))
+
+	first := collectChunks(t, original)
+	again := collectChunks(t, original)
+	if len(first) != len(again) {
+		t.Fatalf("chunking is not deterministic: %d vs %d chunks", len(first), len(again))
+	}
+
+	known := make(map[string]struct{}, len(first))
+	for _, chunk := range first {
+		known[string(chunk)] = struct{}{}
+	}
+	changed := 0
+	for _, chunk := range collectChunks(t, edited) {
+		if _, ok := known[string(chunk)]; !ok {
+			changed++
+		}
+	}
+	if changed == 0 || changed > 2 {
+		t.Fatalf("expected edit to touch 1-2 chunks, got %d of %d", changed, len(first))
+	}
+}
+
+func TestChunkerHandlesEmptyAndSmallInput(t *testing.T) {
+	if chunks := collectChunks(t, nil); len(chunks) != 0 {
+		t.Fatalf("expected no chunks for empty input, got %d", len(chunks))
+	}
+	small := []byte("tiny")
+	chunks := collectChunks(t, small)
+	if len(chunks) != 1 || !bytes.Equal(chunks[0], small) {
+		t.Fatalf("unexpected chunks for small input: %q", chunks)
+	}
+}
@@ -0,0 +1,267 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	"baxter/internal/crypto"
+	"baxter/internal/storage"
+)
+
+var (
+	ErrObjectRead       = errors.New("read object")
+	ErrObjectDecrypt    = errors.New("decrypt object")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrRestoreWrite     = errors.New("write restore target")
+)
+
+// ReadStoredEntryContent fetches, decrypts and checksum-verifies the stored
+// content of an entry into memory. Prefer CopyStoredEntryContent for entries
+// of unbounded size.
+func ReadStoredEntryContent(store storage.ObjectStore, keys [][]byte, entry ManifestEntry) ([]byte, error) {
+	var content bytes.Buffer
+	if entry.Size > 0 {
+		content.Grow(int(entry.Size))
+	}
+	if err := CopyStoredEntryContent(&content, store, keys, entry); err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
+
+// CopyStoredEntryContent streams the decrypted content of an entry to w,
+// reassembling chunked entries in order. The whole-entry checksum is only
+// known after the last byte has been written, so callers writing to a final
+// destination must stage the output until this returns nil.
+func CopyStoredEntryContent(w io.Writer, store storage.ObjectStore, keys [][]byte, entry ManifestEntry) error {
+	if err := cloudPlaceholderRestoreError(entry); err != nil {
+		return err
+	}
+	if store == nil {
+		return errors.New("object store is required")
+	}
+
+	hash := sha256.New()
+	out := &contentWriter{w: io.MultiWriter(w, hash)}
+	if !entry.IsChunked() {
+		if err := copyObjectPlaintext(out, store, keys, ResolveObjectKey(entry)); err != nil {
+			return err
+		}
+	} else {
+		for index, chunk := range entry.Chunks {
+			plain, err := readObjectPlaintext(store, keys, ObjectKeyForChunkSHA256(chunk.SHA256))
+			if err != nil {
+				return err
+			}
+			if err := verifyChunkContent(entry, index, chunk, plain); err != nil {
+				return err
+			}
+			if _, err := out.Write(plain); err != nil {
+				return err
+			}
+		}
+	}
+
+	got := hex.EncodeToString(hash.Sum(nil))
+	if got != entry.SHA256 {
+		return fmt.Errorf("%w for %s: got %s want %s", ErrChecksumMismatch, entry.Path, got, entry.SHA256)
+	}
+	return nil
+}
+
+// ReadStoredChunk fetches, decrypts and checksum-verifies chunk index of a
+// chunked entry, for callers that read entries piecemeal.
+func ReadStoredChunk(store storage.ObjectStore, keys [][]byte, entry ManifestEntry, index int) ([]byte, error) {
+	if index < 0 || index >= len(entry.Chunks) {
+		return nil, fmt.Errorf("chunk %d out of range for %s", index, entry.Path)
+	}
+	chunk := entry.Chunks[index]
+	plain, err := readObjectPlaintext(store, keys, ObjectKeyForChunkSHA256(chunk.SHA256))
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChunkContent(entry, index, chunk, plain); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+// OpenStoredEntryContent streams the decrypted content of an unchunked entry,
+// for callers that read large entries piecemeal. Each part is authenticated
+// as it is decrypted, but the whole-entry checksum is only known at the end:
+// the reader reports ErrChecksumMismatch instead of io.EOF when the content
+// does not match the entry.
+func OpenStoredEntryContent(store storage.ObjectStore, keys [][]byte, entry ManifestEntry) (io.ReadCloser, error) {
+	if err := cloudPlaceholderRestoreError(entry); err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.New("object store is required")
+	}
+	if entry.IsChunked() {
+		return nil, fmt.Errorf("%s is chunked; read it a chunk at a time", entry.Path)
+	}
+
+	objectKey := ResolveObjectKey(entry)
+	body, err := storage.GetObjectStream(store, objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrObjectRead, objectKey, err)
+	}
+	plain, err := crypto.NewDecryptReader(keys, &objectBodyReader{r: body, key: objectKey})
+	if err != nil {
+		_ = body.Close()
+		if errors.Is(err, ErrObjectRead) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w %s: %w", ErrObjectDecrypt, objectKey, err)
+	}
+	return &verifiedContentReader{r: plain, body: body, entry: entry, objectKey: objectKey, hash: sha256.New()}, nil
+}
+
+type verifiedContentReader struct {
+	r         io.Reader
+	body      io.Closer
+	entry     ManifestEntry
+	objectKey string
+	hash      hash.Hash
+	size      int64
+}
+
+func (r *verifiedContentReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.hash.Write(p[:n])
+	r.size += int64(n)
+	switch {
+	case err == io.EOF:
+		got := hex.EncodeToString(r.hash.Sum(nil))
+		if got != r.entry.SHA256 || r.size != r.entry.Size {
+			return n, fmt.Errorf("%w for %s: got %s want %s", ErrChecksumMismatch, r.entry.Path, got, r.entry.SHA256)
+		}
+	case err != nil && !errors.Is(err, ErrObjectRead):
+		err = fmt.Errorf("%w %s: %w", ErrObjectDecrypt, r.objectKey, err)
+	}
+	return n, err
+}
+
+func (r *verifiedContentReader) Close() error {
+	return r.body.Close()
+}
+
+// RestoreEntryFile streams an entry into targetPath. Content is staged in a
+// temporary file next to the target and only renamed into place once it has
+// been verified, synced and had its metadata applied, so neither a failed
+// restore nor a crash leaves partial content behind.
+func RestoreEntryFile(store storage.ObjectStore, keys [][]byte, entry ManifestEntry, targetPath string, opts RestoreMetadataOptions) error {
+	dir := filepath.Dir(targetPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(targetPath)+".baxter-restore-*")
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+	}
+	tmpPath := tmp.Name()
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+	}
+
+	if err := CopyStoredEntryContent(tmp, store, keys, entry); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+	}
+	if err := applyRestoredMetadata(entry, tmpPath, opts); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, targetPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+func copyObjectPlaintext(w io.Writer, store storage.ObjectStore, keys [][]byte, objectKey string) error {
+	body, err := storage.GetObjectStream(store, objectKey)
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrObjectRead, objectKey, err)
+	}
+	defer body.Close()
+
+	plain, err := crypto.NewDecryptReader(keys, &objectBodyReader{r: body, key: objectKey})
+	if err == nil {
+		_, err = io.Copy(w, plain)
+	}
+	if err != nil {
+		if errors.Is(err, ErrObjectRead) || errors.Is(err, ErrRestoreWrite) {
+			return err
+		}
+		return fmt.Errorf("%w %s: %w", ErrObjectDecrypt, objectKey, err)
+	}
+	return nil
+}
+
+func readObjectPlaintext(store storage.ObjectStore, keys [][]byte, objectKey string) ([]byte, error) {
+	payload, err := store.GetObject(objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrObjectRead, objectKey, err)
+	}
+	plain, err := crypto.DecryptBytesWithAnyKey(keys, payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrObjectDecrypt, objectKey, err)
+	}
+	return plain, nil
+}
+
+func verifyChunkContent(entry ManifestEntry, index int, chunk ChunkRef, content []byte) error {
+	sum := sha256.Sum256(content)
+	got := hex.EncodeToString(sum[:])
+	if got != chunk.SHA256 || int64(len(content)) != chunk.Size {
+		return fmt.Errorf("%w for %s chunk %d: got %s want %s", ErrChecksumMismatch, entry.Path, index, got, chunk.SHA256)
+	}
+	return nil
+}
+
+// objectBodyReader tags storage read failures so they are not mistaken for
+// decryption failures once they surface through the decrypting reader.
+type objectBodyReader struct {
+	r   io.Reader
+	key string
+}
+
+func (r *objectBodyReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("%w %s: %w", ErrObjectRead, r.key, err)
+	}
+	return n, err
+}
+
+// contentWriter tags destination write failures.
+type contentWriter struct {
+	w io.Writer
+}
+
+func (w *contentWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrRestoreWrite, err)
+	}
+	return n, err
+}
@@ -0,0 +1,84 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"baxter/internal/crypto"
+	"baxter/internal/storage"
+)
+
+func putStreamedTestObject(t *testing.T, store storage.ObjectStore, key []byte, objectKey string, plain []byte) {
+	t.Helper()
+	var payload bytes.Buffer
+	if err := crypto.EncryptStream(key, &payload, bytes.NewReader(plain)); err != nil {
+		t.Fatalf("encrypt stream: %v", err)
+	}
+	if err := store.PutObject(objectKey, payload.Bytes()); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+}
+
+func TestRestoreEntryFileStreamsVerifiedContent(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	plain := chunkTestPayload(3<<20, 7)
+	sum := sha256.Sum256(plain)
+	entry := ManifestEntry{
+		Path:   "/Users/me/Movies/clip.mov",
+		Size:   int64(len(plain)),
+		Mode:   0o640,
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+	entry.ObjectKey = ObjectKeyForContentSHA256(entry.SHA256)
+	putStreamedTestObject(t, store, key, entry.ObjectKey, plain)
+
+	targetPath := filepath.Join(t.TempDir(), "restore", "clip.mov")
+	if err := RestoreEntryFile(store, [][]byte{key}, entry, targetPath, RestoreMetadataOptions{}); err != nil {
+		t.Fatalf("restore entry file: %v", err)
+	}
+	restored, err := os.ReadFile(targetPath)
+	if err != nil {
+		t.Fatalf("read restored file: %v", err)
+	}
+	if !bytes.Equal(restored, plain) {
+		t.Fatal("restored content mismatch")
+	}
+	info, err := os.Stat(targetPath)
+	if err != nil {
+		t.Fatalf("stat restored file: %v", err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Fatalf("unexpected restored mode: %v", info.Mode().Perm())
+	}
+}
+
+func TestRestoreEntryFileLeavesNothingOnChecksumMismatch(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	entry := ManifestEntry{
+		Path:      "/Users/me/Documents/doc.txt",
+		Mode:      0o600,
+		SHA256:    "0000",
+		ObjectKey: ObjectKeyForContentSHA256("0000"),
+	}
+	putStreamedTestObject(t, store, key, entry.ObjectKey, []byte("not the recorded content"))
+
+	targetDir := t.TempDir()
+	err := RestoreEntryFile(store, [][]byte{key}, entry, filepath.Join(targetDir, "doc.txt"), RestoreMetadataOptions{})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got: %v", err)
+	}
+	leftovers, err := os.ReadDir(targetDir)
+	if err != nil {
+		t.Fatalf("read target dir: %v", err)
+	}
+	if len(leftovers) != 0 {
+		t.Fatalf("expected no files after failed restore, got %d", len(leftovers))
+	}
+}
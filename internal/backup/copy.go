@@ -0,0 +1,216 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"baxter/internal/crypto"
+	"baxter/internal/storage"
+)
+
+const defaultCopyConcurrency = 4
+
+type CopyOptions struct {
+	Source storage.ObjectStore
+	Target storage.ObjectStore
+	// SourceKeys decrypt the snapshot manifests in Source, and its data
+	// objects when TargetKey is set.
+	SourceKeys [][]byte
+	// TargetKey re-encrypts every copied object and snapshot manifest under
+	// a different key. When empty, objects are copied byte for byte.
+	TargetKey []byte
+	// SnapshotIDs lists the snapshots to copy, as stored in Source.
+	SnapshotIDs []string
+	Concurrency int
+	// Progress is called after each data object is copied or skipped.
+	Progress func(CopyProgress)
+}
+
+type CopyProgress struct {
+	Done  int
+	Total int
+	Key   string
+}
+
+type CopyResult struct {
+	Snapshots int
+	// Objects counts the data objects the selected snapshots reference.
+	Objects int
+	Copied  int
+	// Skipped counts objects the target already had.
+	Skipped int
+}
+
+// CopySnapshots copies the selected snapshots and the data objects they
+// reference from opts.Source to opts.Target, skipping objects the target
+// already has. Snapshot manifests are written only after all of their
+// objects, so the target never lists a snapshot it cannot restore. Recovery
+// metadata is left to the caller.
+func CopySnapshots(ctx context.Context, opts CopyOptions) (CopyResult, error) {
+	if opts.Source == nil || opts.Target == nil {
+		return CopyResult{}, errors.New("source and target object stores are required")
+	}
+	if len(opts.SnapshotIDs) == 0 {
+		return CopyResult{}, errors.New("no snapshots selected")
+	}
+	source := storage.WithContext(ctx, opts.Source)
+	target := storage.WithContext(ctx, opts.Target)
+
+	manifests := make([]*Manifest, len(opts.SnapshotIDs))
+	required := make(map[string]struct{})
+	for i, id := range opts.SnapshotIDs {
+		manifest, err := ReadEncryptedSnapshotManifest(source, id, opts.SourceKeys)
+		if err != nil {
+			return CopyResult{}, fmt.Errorf("read snapshot %s: %w", id, err)
+		}
+		manifests[i] = manifest
+		addManifestObjectKeys(required, manifest)
+	}
+
+	existingKeys, err := target.ListKeys()
+	if err != nil {
+		return CopyResult{}, fmt.Errorf("list target object keys: %w", err)
+	}
+	existing := make(map[string]struct{}, len(existingKeys))
+	for _, key := range existingKeys {
+		existing[key] = struct{}{}
+	}
+
+	result := CopyResult{Snapshots: len(opts.SnapshotIDs), Objects: len(required)}
+	pending := make([]string, 0, len(required))
+	for key := range required {
+		if _, ok := existing[key]; ok {
+			result.Skipped++
+			continue
+		}
+		pending = append(pending, key)
+	}
+	sort.Strings(pending)
+
+	if err := copyObjects(ctx, source, target, pending, result.Skipped, opts); err != nil {
+		return result, err
+	}
+	result.Copied = len(pending)
+
+	for i, id := range opts.SnapshotIDs {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		objectKey, err := RemoteSnapshotManifestObjectKey(id)
+		if err != nil {
+			return result, err
+		}
+		if _, ok := existing[objectKey]; ok {
+			continue
+		}
+		if len(opts.TargetKey) > 0 {
+			err = WriteEncryptedSnapshotManifest(target, id, manifests[i], opts.TargetKey)
+		} else {
+			err = storage.CopyObject(target, source, objectKey)
+		}
+		if err != nil {
+			return result, fmt.Errorf("copy snapshot manifest %s: %w", id, err)
+		}
+	}
+	return result, nil
+}
+
+func copyObjects(ctx context.Context, source, target storage.ObjectStore, keys []string, skipped int, opts CopyOptions) error {
+	total := skipped + len(keys)
+	if opts.Progress != nil {
+		opts.Progress(CopyProgress{Done: skipped, Total: total})
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	jobs := make(chan string)
+	errCh := make(chan error, 1)
+	var done atomic.Int32
+	done.Store(int32(skipped))
+	var once sync.Once
+	workerCount := opts.Concurrency
+	if workerCount <= 0 {
+		workerCount = defaultCopyConcurrency
+	}
+	if workerCount > len(keys) {
+		workerCount = len(keys)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				var err error
+				if len(opts.TargetKey) > 0 {
+					err = reencryptObject(target, source, key, opts.SourceKeys, opts.TargetKey)
+				} else {
+					err = storage.CopyObject(target, source, key)
+				}
+				if err != nil {
+					once.Do(func() { errCh <- fmt.Errorf("copy object %s: %w", key, err) })
+					return
+				}
+				if opts.Progress != nil {
+					opts.Progress(CopyProgress{Done: int(done.Add(1)), Total: total, Key: key})
+				}
+			}
+		}()
+	}
+
+	for _, key := range keys {
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return ctx.Err()
+		case err := <-errCh:
+			close(jobs)
+			wg.Wait()
+			return err
+		case jobs <- key:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errCh:
+		return err
+	default:
+	}
+	return nil
+}
+
+// reencryptObject streams key from source to target, decrypting it with
+// sourceKeys and encrypting it again with targetKey.
+func reencryptObject(target, source storage.ObjectStore, key string, sourceKeys [][]byte, targetKey []byte) error {
+	body, err := storage.GetObjectStream(source, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	plain, err := crypto.NewDecryptReader(sourceKeys, body)
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	encoded := make(chan struct{})
+	go func() {
+		defer close(encoded)
+		_ = pw.CloseWithError(crypto.EncryptStream(targetKey, pw, plain))
+	}()
+	err = storage.PutObjectStream(target, key, pr)
+	// Stop the encoder if the target gave up before reading everything.
+	_ = pr.Close()
+	<-encoded
+	return err
+}
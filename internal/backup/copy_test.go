@@ -0,0 +1,183 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"baxter/internal/config"
+	"baxter/internal/crypto"
+	"baxter/internal/storage"
+)
+
+var testCopyKey = []byte("01234567890123456789012345678901")
+
+// seedCopySource runs two backups into a local store and returns it with
+// the ids of the snapshots it holds.
+func seedCopySource(t *testing.T) (*storage.LocalClient, []string) {
+	t.Helper()
+	root := t.TempDir()
+	stateDir := t.TempDir()
+	source := storage.NewLocalClient(filepath.Join(t.TempDir(), "source"))
+	cfg := &config.Config{BackupRoots: []string{root}}
+	run := func() {
+		t.Helper()
+		if _, err := Run(context.Background(), cfg, RunOptions{
+			ManifestPath:      filepath.Join(stateDir, "manifest.json"),
+			SnapshotDir:       filepath.Join(stateDir, "manifests"),
+			SnapshotRetention: 30,
+			EncryptionKey:     testCopyKey,
+			KDFSalt:           testKDFSalt,
+			BackupSetID:       "local-test",
+			Store:             source,
+		}); err != nil {
+			t.Fatalf("run backup: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("first version"), 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+	run()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("second version"), 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "b.txt"), []byte("another file"), 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+	run()
+
+	ids, err := ListRemoteSnapshotIDs(source)
+	if err != nil {
+		t.Fatalf("list remote snapshots: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("expected two remote snapshots, got %v", ids)
+	}
+	return source, ids
+}
+
+func TestCopySnapshotsCopiesObjectsAndSkipsExisting(t *testing.T) {
+	source, ids := seedCopySource(t)
+	target := storage.NewLocalClient(filepath.Join(t.TempDir(), "target"))
+	opts := CopyOptions{
+		Source:      source,
+		Target:      target,
+		SourceKeys:  [][]byte{testCopyKey},
+		SnapshotIDs: ids,
+	}
+
+	result, err := CopySnapshots(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("copy snapshots: %v", err)
+	}
+	// a.txt twice plus b.txt.
+	if result.Snapshots != 2 || result.Objects != 3 || result.Copied != 3 || result.Skipped != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	sourceKeys, _ := source.ListKeys()
+	targetKeys, _ := target.ListKeys()
+	// Everything but the recovery metadata, which is left to the caller.
+	if want := FilterDataObjectKeys(sourceKeys); !reflect.DeepEqual(FilterDataObjectKeys(targetKeys), want) {
+		t.Fatalf("unexpected target data objects: got %v want %v", targetKeys, want)
+	}
+	for _, id := range ids {
+		if _, err := ReadEncryptedSnapshotManifest(target, id, [][]byte{testCopyKey}); err != nil {
+			t.Fatalf("read copied snapshot %s: %v", id, err)
+		}
+	}
+
+	result, err = CopySnapshots(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("copy snapshots again: %v", err)
+	}
+	if result.Copied != 0 || result.Skipped != 3 {
+		t.Fatalf("expected every object to be skipped, got %+v", result)
+	}
+}
+
+func TestCopySnapshotsReencryptsUnderTargetKey(t *testing.T) {
+	source, ids := seedCopySource(t)
+	target := storage.NewLocalClient(filepath.Join(t.TempDir(), "target"))
+	targetKey := []byte("abcdefghijklmnopqrstuvwxyz012345")
+
+	if _, err := CopySnapshots(context.Background(), CopyOptions{
+		Source:      source,
+		Target:      target,
+		SourceKeys:  [][]byte{testCopyKey},
+		TargetKey:   targetKey,
+		SnapshotIDs: ids[1:],
+	}); err != nil {
+		t.Fatalf("copy snapshots: %v", err)
+	}
+
+	if _, err := ReadEncryptedSnapshotManifest(target, ids[0], [][]byte{targetKey}); err == nil {
+		t.Fatal("copied a snapshot that was not selected")
+	}
+	manifest, err := ReadEncryptedSnapshotManifest(target, ids[1], [][]byte{targetKey})
+	if err != nil {
+		t.Fatalf("read reencrypted snapshot: %v", err)
+	}
+	if _, err := ReadEncryptedSnapshotManifest(target, ids[1], [][]byte{testCopyKey}); err == nil {
+		t.Fatal("reencrypted snapshot still opens with the source key")
+	}
+	for _, entry := range manifest.Entries {
+		if !entry.HasStoredContent() {
+			continue
+		}
+		payload, err := target.GetObject(ResolveObjectKey(entry))
+		if err != nil {
+			t.Fatalf("get copied object: %v", err)
+		}
+		if _, err := crypto.DecryptBytes(testCopyKey, payload); err == nil {
+			t.Fatalf("%s still opens with the source key", entry.Path)
+		}
+		plain, err := crypto.DecryptBytes(targetKey, payload)
+		if err != nil {
+			t.Fatalf("decrypt %s with the target key: %v", entry.Path, err)
+		}
+		if err := VerifyEntryContent(entry, plain); err != nil {
+			t.Fatalf("verify %s: %v", entry.Path, err)
+		}
+	}
+}
+
+func TestSelectRemoteSnapshotIDs(t *testing.T) {
+	first := SnapshotID(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+	second := SnapshotID(time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC))
+	third := second + "-1"
+	available := []string{first, second, third}
+
+	tests := []struct {
+		name      string
+		selectors []string
+		want      []string
+	}{
+		{"all by default", nil, available},
+		{"latest", []string{"latest"}, []string{third}},
+		{"id", []string{first}, []string{first}},
+		{"as of time", []string{"2026-01-15T00:00:00Z"}, []string{first}},
+		{"deduplicated", []string{"latest", third, first}, []string{first, third}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := SelectRemoteSnapshotIDs(available, third, tc.selectors)
+			if err != nil {
+				t.Fatalf("select snapshots: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+
+	for _, selector := range []string{"20250101T000000.000000000Z", "2025-12-31T00:00:00Z"} {
+		if _, err := SelectRemoteSnapshotIDs(available, third, []string{selector}); !errors.Is(err, ErrSnapshotNotFound) {
+			t.Fatalf("expected snapshot not found for %q, got %v", selector, err)
+		}
+	}
+}
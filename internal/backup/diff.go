@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"sort"
+	"time"
+)
+
+// SnapshotDiff lists what changed from one manifest to another. Each list is
+// sorted by path.
+type SnapshotDiff struct {
+	Added    []DiffEntry  `json:"added"`
+	Removed  []DiffEntry  `json:"removed"`
+	Modified []DiffChange `json:"modified"`
+	// SizeDelta is the change in total size of the compared entries.
+	SizeDelta int64 `json:"size_delta"`
+}
+
+// DiffEntry is a path present on one side of a diff only.
+type DiffEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	Dir  bool   `json:"dir,omitempty"`
+}
+
+// DiffChange is a path present on both sides whose content or metadata
+// changed. Mode and modification time are only set when they changed.
+type DiffChange struct {
+	Path           string     `json:"path"`
+	OldSize        int64      `json:"old_size"`
+	NewSize        int64      `json:"new_size"`
+	SizeDelta      int64      `json:"size_delta"`
+	ContentChanged bool       `json:"content_changed"`
+	OldMode        string     `json:"old_mode,omitempty"`
+	NewMode        string     `json:"new_mode,omitempty"`
+	OldModTime     *time.Time `json:"old_mod_time,omitempty"`
+	NewModTime     *time.Time `json:"new_mod_time,omitempty"`
+}
+
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// DiffManifests compares the entries of from and to under prefix (every entry
+// when prefix is empty). Entries that PlanChanges would upload again are
+// added or content changes; entries whose mode or modification time alone
+// changed are reported as metadata changes.
+func DiffManifests(from, to *Manifest, prefix string) SnapshotDiff {
+	fromFiltered := &Manifest{Entries: entriesUnderPrefix(from.Entries, prefix)}
+	toFiltered := &Manifest{Entries: entriesUnderPrefix(to.Entries, prefix)}
+	plan := PlanChanges(fromFiltered, toFiltered)
+
+	previous := make(map[string]ManifestEntry, len(fromFiltered.Entries))
+	for _, entry := range fromFiltered.Entries {
+		previous[entry.Path] = entry
+	}
+
+	diff := SnapshotDiff{
+		Added:    []DiffEntry{},
+		Removed:  []DiffEntry{},
+		Modified: []DiffChange{},
+	}
+	planned := make(map[string]bool, len(plan.NewOrChanged))
+	for _, entry := range plan.NewOrChanged {
+		planned[entry.Path] = true
+		old, ok := previous[entry.Path]
+		if !ok {
+			diff.Added = append(diff.Added, DiffEntry{Path: entry.Path, Size: entry.Size, Dir: entry.IsDir()})
+			diff.SizeDelta += entry.Size
+			continue
+		}
+		diff.Modified = append(diff.Modified, diffChange(old, entry, !entryContentMatches(old, entry)))
+	}
+	for _, entry := range toFiltered.Entries {
+		old, ok := previous[entry.Path]
+		if !ok || planned[entry.Path] {
+			continue
+		}
+		if old.Mode != entry.Mode || !old.ModTime.Equal(entry.ModTime) {
+			diff.Modified = append(diff.Modified, diffChange(old, entry, false))
+		}
+	}
+	for _, path := range plan.RemovedPaths {
+		old := previous[path]
+		diff.Removed = append(diff.Removed, DiffEntry{Path: path, Size: old.Size, Dir: old.IsDir()})
+		diff.SizeDelta -= old.Size
+	}
+
+	sort.Slice(diff.Modified, func(i, j int) bool {
+		return diff.Modified[i].Path < diff.Modified[j].Path
+	})
+	for _, change := range diff.Modified {
+		diff.SizeDelta += change.SizeDelta
+	}
+	return diff
+}
+
+func diffChange(old, current ManifestEntry, contentChanged bool) DiffChange {
+	change := DiffChange{
+		Path:           current.Path,
+		OldSize:        old.Size,
+		NewSize:        current.Size,
+		SizeDelta:      current.Size - old.Size,
+		ContentChanged: contentChanged,
+	}
+	if old.Mode != current.Mode {
+		change.OldMode = old.Mode.String()
+		change.NewMode = current.Mode.String()
+	}
+	if !old.ModTime.Equal(current.ModTime) {
+		oldModTime, newModTime := old.ModTime.UTC(), current.ModTime.UTC()
+		change.OldModTime = &oldModTime
+		change.NewModTime = &newModTime
+	}
+	return change
+}
+
+// entryContentMatches reports whether two entries for the same path hold the
+// same content, ignoring metadata.
+func entryContentMatches(old, current ManifestEntry) bool {
+	if old.Mode.Type() != current.Mode.Type() || old.effectiveSourceKind() != current.effectiveSourceKind() {
+		return false
+	}
+	switch {
+	case current.IsDir():
+		return true
+	case current.IsSymlink():
+		return old.LinkTarget == current.LinkTarget
+	case current.IsCloudPlaceholder():
+		return old.Size == current.Size
+	default:
+		return old.SHA256 == current.SHA256 && old.Size == current.Size
+	}
+}
+
+func entriesUnderPrefix(entries []ManifestEntry, prefix string) []ManifestEntry {
+	filtered := make([]ManifestEntry, 0, len(entries))
+	for _, entry := range entries {
+		if PathHasPrefix(entry.Path, prefix) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
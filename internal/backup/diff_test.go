@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffManifestsReportsAddedRemovedAndModified(t *testing.T) {
+	oldTime := time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+	newTime := oldTime.Add(time.Hour)
+	from := &Manifest{Entries: []ManifestEntry{
+		{Path: "/docs/edited.txt", Size: 10, SHA256: "old", Mode: 0o644, ModTime: oldTime},
+		{Path: "/docs/chmod.sh", Size: 4, SHA256: "same", Mode: 0o644, ModTime: oldTime},
+		{Path: "/docs/same.txt", Size: 7, SHA256: "same", Mode: 0o644, ModTime: oldTime},
+		{Path: "/docs/gone.txt", Size: 30, SHA256: "gone", Mode: 0o644, ModTime: oldTime},
+		{Path: "/music/song.mp3", Size: 500, SHA256: "song", Mode: 0o644, ModTime: oldTime},
+	}}
+	to := &Manifest{Entries: []ManifestEntry{
+		{Path: "/docs", Mode: fs.ModeDir | 0o755, ModTime: newTime},
+		{Path: "/docs/chmod.sh", Size: 4, SHA256: "same", Mode: 0o755, ModTime: oldTime},
+		{Path: "/docs/edited.txt", Size: 25, SHA256: "new", Mode: 0o644, ModTime: newTime},
+		{Path: "/docs/same.txt", Size: 7, SHA256: "same", Mode: 0o644, ModTime: oldTime},
+	}}
+
+	diff := DiffManifests(from, to, "/docs")
+
+	wantAdded := []DiffEntry{{Path: "/docs", Dir: true}}
+	if !reflect.DeepEqual(diff.Added, wantAdded) {
+		t.Fatalf("added mismatch: got %+v want %+v", diff.Added, wantAdded)
+	}
+	wantRemoved := []DiffEntry{{Path: "/docs/gone.txt", Size: 30}}
+	if !reflect.DeepEqual(diff.Removed, wantRemoved) {
+		t.Fatalf("removed mismatch: got %+v want %+v", diff.Removed, wantRemoved)
+	}
+	if len(diff.Modified) != 2 {
+		t.Fatalf("expected two modified entries, got %+v", diff.Modified)
+	}
+
+	chmod := diff.Modified[0]
+	if chmod.Path != "/docs/chmod.sh" || chmod.ContentChanged || chmod.SizeDelta != 0 {
+		t.Fatalf("unexpected metadata-only change: %+v", chmod)
+	}
+	if chmod.OldMode != "-rw-r--r--" || chmod.NewMode != "-rwxr-xr-x" || chmod.NewModTime != nil {
+		t.Fatalf("unexpected metadata fields: %+v", chmod)
+	}
+
+	edited := diff.Modified[1]
+	if edited.Path != "/docs/edited.txt" || !edited.ContentChanged || edited.SizeDelta != 15 {
+		t.Fatalf("unexpected content change: %+v", edited)
+	}
+	if edited.OldMode != "" || edited.NewModTime == nil || !edited.NewModTime.Equal(newTime) {
+		t.Fatalf("unexpected content change metadata: %+v", edited)
+	}
+
+	if diff.SizeDelta != -15 {
+		t.Fatalf("size delta: got %d want -15", diff.SizeDelta)
+	}
+}
+
+func TestDiffManifestsIdenticalSnapshotsAreEmpty(t *testing.T) {
+	m := &Manifest{Entries: []ManifestEntry{
+		{Path: "/a.txt", Size: 1, SHA256: "a"},
+		{Path: "/link", Mode: fs.ModeSymlink | 0o777, LinkTarget: "a.txt"},
+	}}
+
+	diff := DiffManifests(m, m, "")
+	if !diff.Empty() || diff.SizeDelta != 0 {
+		t.Fatalf("expected empty diff, got %+v", diff)
+	}
+}
@@ -8,6 +8,10 @@ import (
 type BuildOptions struct {
 	ExcludePaths []string
 	ExcludeGlobs []string
+	// StatCache, when set, lets unchanged files reuse their previous hash.
+	StatCache *StatCache
+	// Rehash hashes every file even when the stat cache has a match.
+	Rehash bool
 }
 
 type exclusionMatcher struct {
@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"os"
@@ -23,7 +24,7 @@ func TestBuildManifestWithOptionsExcludesPathsAndGlobs(t *testing.T) {
 	mustWriteTestFile(t, excludedByGlob, []byte("ignore"))
 	mustWriteTestFile(t, excludedByDirGlob, []byte("ignore"))
 
-	manifest, err := BuildManifestWithOptions([]string{root}, BuildOptions{
+	manifest, err := BuildManifestWithOptions(context.Background(), []string{root}, BuildOptions{
 		ExcludePaths: []string{excludedDir},
 		ExcludeGlobs: []string{"*.log", "node_modules"},
 	})
@@ -35,7 +36,7 @@ func TestBuildManifestWithOptionsExcludesPathsAndGlobs(t *testing.T) {
 	for _, entry := range manifest.Entries {
 		got = append(got, entry.Path)
 	}
-	want := []string{included}
+	want := []string{root, filepath.Join(root, "deps"), included}
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected manifest paths: got %#v want %#v", got, want)
 	}
@@ -45,7 +46,7 @@ func TestBuildManifestWithOptionsExcludesRootPath(t *testing.T) {
 	root := t.TempDir()
 	mustWriteTestFile(t, filepath.Join(root, "keep.txt"), []byte("keep"))
 
-	manifest, err := BuildManifestWithOptions([]string{root}, BuildOptions{
+	manifest, err := BuildManifestWithOptions(context.Background(), []string{root}, BuildOptions{
 		ExcludePaths: []string{root},
 	})
 	if err != nil {
@@ -56,7 +57,7 @@ func TestBuildManifestWithOptionsExcludesRootPath(t *testing.T) {
 	}
 }
 
-func TestBuildManifestWithOptionsSkipsSymlinkedDirectories(t *testing.T) {
+func TestBuildManifestWithOptionsRecordsSymlinksWithoutFollowing(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("symlink behavior differs on windows")
 	}
@@ -69,7 +70,7 @@ func TestBuildManifestWithOptionsSkipsSymlinkedDirectories(t *testing.T) {
 		t.Skipf("symlink unsupported in this environment: %v", err)
 	}
 
-	manifest, err := BuildManifestWithOptions([]string{root}, BuildOptions{})
+	manifest, err := BuildManifestWithOptions(context.Background(), []string{root}, BuildOptions{})
 	if err != nil {
 		t.Fatalf("build manifest with symlinked directory: %v", err)
 	}
@@ -78,10 +79,18 @@ func TestBuildManifestWithOptionsSkipsSymlinkedDirectories(t *testing.T) {
 	for _, entry := range manifest.Entries {
 		got = append(got, entry.Path)
 	}
-	want := []string{filepath.Join(targetDir, "file.txt")}
+	want := []string{root, linkPath, targetDir, filepath.Join(targetDir, "file.txt")}
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected manifest paths: got %#v want %#v", got, want)
 	}
+
+	link := manifest.Entries[1]
+	if !link.IsSymlink() || link.LinkTarget != targetDir || link.HasStoredContent() {
+		t.Fatalf("unexpected symlink entry: %#v", link)
+	}
+	if !manifest.Entries[0].IsDir() || !manifest.Entries[2].IsDir() || manifest.Entries[2].HasStoredContent() {
+		t.Fatalf("expected directory entries without stored content: %#v", manifest.Entries)
+	}
 }
 
 func TestBuildManifestWithOptionsSkipsLocalizedMetadataFiles(t *testing.T) {
@@ -92,7 +101,7 @@ func TestBuildManifestWithOptionsSkipsLocalizedMetadataFiles(t *testing.T) {
 	mustWriteTestFile(t, realFile, []byte("keep"))
 	mustWriteTestFile(t, localizedFile, []byte("skip"))
 
-	manifest, err := BuildManifestWithOptions([]string{root}, BuildOptions{})
+	manifest, err := BuildManifestWithOptions(context.Background(), []string{root}, BuildOptions{})
 	if err != nil {
 		t.Fatalf("build manifest: %v", err)
 	}
@@ -101,7 +110,7 @@ func TestBuildManifestWithOptionsSkipsLocalizedMetadataFiles(t *testing.T) {
 	for _, entry := range manifest.Entries {
 		got = append(got, entry.Path)
 	}
-	want := []string{realFile}
+	want := []string{root, realFile}
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected manifest paths: got %#v want %#v", got, want)
 	}
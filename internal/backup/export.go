@@ -0,0 +1,207 @@
+package backup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"baxter/internal/storage"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ArchiveFormat names an archive layout ExportArchive can write.
+type ArchiveFormat string
+
+const (
+	ArchiveTar     ArchiveFormat = "tar"
+	ArchiveTarZstd ArchiveFormat = "tar.zst"
+	ArchiveZip     ArchiveFormat = "zip"
+)
+
+func ParseArchiveFormat(value string) (ArchiveFormat, error) {
+	switch format := ArchiveFormat(strings.ToLower(strings.TrimSpace(value))); format {
+	case ArchiveTar, ArchiveTarZstd, ArchiveZip:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported archive format %q (want tar, tar.zst or zip)", value)
+	}
+}
+
+// ContentType is the media type of archives in format.
+func (f ArchiveFormat) ContentType() string {
+	switch f {
+	case ArchiveTarZstd:
+		return "application/zstd"
+	case ArchiveZip:
+		return "application/zip"
+	default:
+		return "application/x-tar"
+	}
+}
+
+type ExportArchiveOptions struct {
+	Format ArchiveFormat
+	Store  storage.ObjectStore
+	Keys   [][]byte
+}
+
+type ExportArchiveResult struct {
+	Files int
+	Bytes int64
+}
+
+// ExportArchive writes entries to w as an archive. Each entry is stored
+// under its path relative to the filesystem root, so extracting the archive
+// lays files out as restore --to would. Recorded modes, modification times
+// and ownership go into the archive headers, symlinks keep their recorded
+// target, and file content is decrypted and checksum-verified as it is
+// streamed.
+//
+// Entries with no stored content are rejected before anything is written.
+// A failure after that leaves a truncated archive in w; callers writing to
+// a file should discard it.
+func ExportArchive(w io.Writer, entries []ManifestEntry, opts ExportArchiveOptions) (ExportArchiveResult, error) {
+	for _, entry := range entries {
+		if err := cloudPlaceholderRestoreError(entry); err != nil {
+			return ExportArchiveResult{}, err
+		}
+		if _, err := archiveEntryName(entry); err != nil {
+			return ExportArchiveResult{}, err
+		}
+	}
+
+	var archive archiveWriter
+	var zstdOut *zstd.Encoder
+	switch opts.Format {
+	case ArchiveTar:
+		archive = &tarArchive{w: tar.NewWriter(w)}
+	case ArchiveTarZstd:
+		encoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return ExportArchiveResult{}, fmt.Errorf("create zstd encoder: %w", err)
+		}
+		zstdOut = encoder
+		archive = &tarArchive{w: tar.NewWriter(zstdOut)}
+	case ArchiveZip:
+		archive = &zipArchive{w: zip.NewWriter(w)}
+	default:
+		return ExportArchiveResult{}, fmt.Errorf("unsupported archive format %q", opts.Format)
+	}
+
+	var result ExportArchiveResult
+	for _, entry := range entries {
+		name, _ := archiveEntryName(entry)
+		if err := archive.add(name, entry, opts); err != nil {
+			return result, err
+		}
+		if entry.Mode.IsRegular() {
+			result.Files++
+			result.Bytes += entry.Size
+		}
+	}
+	if err := archive.close(); err != nil {
+		return result, fmt.Errorf("%w: %w", ErrRestoreWrite, err)
+	}
+	if zstdOut != nil {
+		if err := zstdOut.Close(); err != nil {
+			return result, fmt.Errorf("%w: %w", ErrRestoreWrite, err)
+		}
+	}
+	return result, nil
+}
+
+// archiveEntryName returns the slash-separated, root-relative name of entry.
+func archiveEntryName(entry ManifestEntry) (string, error) {
+	name := strings.TrimLeft(filepath.ToSlash(filepath.Clean(entry.Path)), "/")
+	if name == "" || !fs.ValidPath(name) {
+		return "", fmt.Errorf("cannot archive entry path %q", entry.Path)
+	}
+	return name, nil
+}
+
+type archiveWriter interface {
+	add(name string, entry ManifestEntry, opts ExportArchiveOptions) error
+	close() error
+}
+
+type tarArchive struct {
+	w *tar.Writer
+}
+
+func (a *tarArchive) add(name string, entry ManifestEntry, opts ExportArchiveOptions) error {
+	header, err := tar.FileInfoHeader(snapshotFileInfo{&snapshotNode{name: name, entry: entry}}, entry.LinkTarget)
+	if err != nil {
+		return fmt.Errorf("archive %s: %w", entry.Path, err)
+	}
+	header.Name = name
+	if entry.IsDir() {
+		header.Name += "/"
+	}
+	if entry.Owner != nil {
+		header.Uid, header.Gid = int(entry.Owner.UID), int(entry.Owner.GID)
+	}
+	// PAX keeps sub-second modification times.
+	header.Format = tar.FormatPAX
+	if err := a.w.WriteHeader(header); err != nil {
+		return fmt.Errorf("%w: %w", ErrRestoreWrite, err)
+	}
+	if !entry.Mode.IsRegular() {
+		return nil
+	}
+	return copyArchivedContent(a.w, entry, opts)
+}
+
+func (a *tarArchive) close() error {
+	return a.w.Close()
+}
+
+type zipArchive struct {
+	w *zip.Writer
+}
+
+func (a *zipArchive) add(name string, entry ManifestEntry, opts ExportArchiveOptions) error {
+	header, err := zip.FileInfoHeader(snapshotFileInfo{&snapshotNode{name: name, entry: entry}})
+	if err != nil {
+		return fmt.Errorf("archive %s: %w", entry.Path, err)
+	}
+	header.Name = name
+	if entry.IsDir() {
+		header.Name += "/"
+	}
+	if !entry.Mode.IsRegular() {
+		header.Method = zip.Store
+	}
+	out, err := a.w.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRestoreWrite, err)
+	}
+	switch {
+	case entry.IsSymlink():
+		// Zip stores a symlink as a file whose content is the target.
+		if _, err := io.WriteString(out, entry.LinkTarget); err != nil {
+			return fmt.Errorf("%w: %w", ErrRestoreWrite, err)
+		}
+		return nil
+	case !entry.Mode.IsRegular():
+		return nil
+	}
+	return copyArchivedContent(out, entry, opts)
+}
+
+// copyArchivedContent streams the verified content of a regular entry.
+// Empty files are checked without a fetch.
+func copyArchivedContent(w io.Writer, entry ManifestEntry, opts ExportArchiveOptions) error {
+	if entry.Size == 0 && !entry.IsChunked() {
+		return VerifyEntryContent(entry, nil)
+	}
+	return CopyStoredEntryContent(w, opts.Store, opts.Keys, entry)
+}
+
+func (a *zipArchive) close() error {
+	return a.w.Close()
+}
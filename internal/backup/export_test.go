@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"baxter/internal/storage"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func exportTestEntries(t *testing.T, store storage.ObjectStore, key []byte) ([]ManifestEntry, []byte) {
+	t.Helper()
+	modTime := time.Date(2026, time.March, 1, 9, 15, 30, 250_000_000, time.UTC)
+	content := append(chunkTestPayload(5000, 11), make([]byte, 4000)...)
+	sum := sha256.Sum256(content)
+	file := ManifestEntry{
+		Path:    "/Users/me/Documents/report.bin",
+		Size:    int64(len(content)),
+		Mode:    0o640,
+		ModTime: modTime,
+		SHA256:  hex.EncodeToString(sum[:]),
+		Owner:   &Ownership{UID: 501, GID: 20},
+	}
+	file.ObjectKey = ObjectKeyForContentSHA256(file.SHA256)
+	putStreamedTestObject(t, store, key, file.ObjectKey, content)
+	return []ManifestEntry{
+		{Path: "/Users/me/Documents", Mode: fs.ModeDir | 0o750, ModTime: modTime},
+		file,
+		{Path: "/Users/me/Documents/latest", Mode: fs.ModeSymlink | 0o777, ModTime: modTime, LinkTarget: "report.bin"},
+	}, content
+}
+
+func TestExportArchiveWritesTarWithRecordedMetadata(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	entries, content := exportTestEntries(t, store, key)
+
+	for _, format := range []ArchiveFormat{ArchiveTar, ArchiveTarZstd} {
+		t.Run(string(format), func(t *testing.T) {
+			var out bytes.Buffer
+			result, err := ExportArchive(&out, entries, ExportArchiveOptions{Format: format, Store: store, Keys: [][]byte{key}})
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			if result.Files != 1 || result.Bytes != int64(len(content)) {
+				t.Fatalf("unexpected result: %+v", result)
+			}
+			var archive io.Reader = &out
+			if format == ArchiveTarZstd {
+				decoder, err := zstd.NewReader(&out)
+				if err != nil {
+					t.Fatalf("open zstd stream: %v", err)
+				}
+				defer decoder.Close()
+				archive = decoder
+			}
+
+			tr := tar.NewReader(archive)
+			var headers []*tar.Header
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("read tar: %v", err)
+				}
+				headers = append(headers, header)
+				if header.Typeflag == tar.TypeReg {
+					body, err := io.ReadAll(tr)
+					if err != nil || !bytes.Equal(body, content) {
+						t.Fatalf("archived content mismatch: %v", err)
+					}
+				}
+			}
+			if len(headers) != 3 {
+				t.Fatalf("expected 3 archive entries, got %d", len(headers))
+			}
+			dir, file, link := headers[0], headers[1], headers[2]
+			if dir.Name != "Users/me/Documents/" || dir.Typeflag != tar.TypeDir || dir.Mode&0o777 != 0o750 {
+				t.Fatalf("unexpected dir header: %+v", dir)
+			}
+			if file.Name != "Users/me/Documents/report.bin" || file.Mode&0o777 != 0o640 || file.Uid != 501 || file.Gid != 20 {
+				t.Fatalf("unexpected file header: %+v", file)
+			}
+			if !file.ModTime.Equal(entries[1].ModTime) {
+				t.Fatalf("expected mtime %v, got %v", entries[1].ModTime, file.ModTime)
+			}
+			if link.Typeflag != tar.TypeSymlink || link.Linkname != "report.bin" {
+				t.Fatalf("unexpected symlink header: %+v", link)
+			}
+		})
+	}
+}
+
+func TestExportArchiveRejectsPlaceholdersBeforeWriting(t *testing.T) {
+	entries := []ManifestEntry{
+		{Path: "/Users/me/cloud.txt", Mode: 0o644, SourceKind: manifestSourceKindCloudPlaceholder},
+	}
+	var out bytes.Buffer
+	_, err := ExportArchive(&out, entries, ExportArchiveOptions{Format: ArchiveZip})
+	var placeholderErr *CloudPlaceholderRestoreError
+	if !errors.As(err, &placeholderErr) {
+		t.Fatalf("expected placeholder error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected nothing written, got %d bytes", out.Len())
+	}
+}
+
+func TestExportArchiveFailsOnTamperedContent(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	entries, _ := exportTestEntries(t, store, key)
+	putStreamedTestObject(t, store, key, entries[1].ObjectKey, make([]byte, entries[1].Size))
+
+	_, err := ExportArchive(io.Discard, entries, ExportArchiveOptions{Format: ArchiveZip, Store: store, Keys: [][]byte{key}})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
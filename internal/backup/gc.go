@@ -1,18 +1,35 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
+	"baxter/internal/recovery"
 	"baxter/internal/storage"
 )
 
+// ErrRemoteHistoryUnreadable is returned by GarbageCollectObjects when a
+// snapshot manifest in the store cannot be decrypted: the objects it
+// references are unknown, so nothing can safely be deleted.
+var ErrRemoteHistoryUnreadable = errors.New("remote snapshot history cannot be decrypted")
+
 type GCOptions struct {
 	LatestManifestPath string
 	SnapshotDir        string
 	Store              storage.ObjectStore
 	DryRun             bool
+	// UploadCheckpointPath keeps the objects an interrupted backup recorded
+	// in its upload checkpoint, so the run can still resume from them.
+	UploadCheckpointPath string
+	// SnapshotKeys returns the keys that decrypt the snapshot manifests in
+	// Store. It is only called when the store holds any.
+	SnapshotKeys func() ([][]byte, error)
+	// SnapshotPolicy decides which snapshot manifests in Store are pruned;
+	// the zero policy keeps them all. The snapshot named by the recovery
+	// metadata is always kept.
+	SnapshotPolicy SnapshotPrunePolicy
 }
 
 type GCResult struct {
@@ -22,8 +39,14 @@ type GCResult struct {
 	RetainedObjects   int
 	CandidateDeletes  int
 	DeletedObjects    int
-	DryRun            bool
-	Skipped           bool
+	// RemoteManifests counts the snapshot manifests in the store that are
+	// kept, and PrunedRemoteManifests those deleted (or, in a dry run, that
+	// would be).
+	RemoteManifests         int
+	PrunedRemoteManifests   int
+	RemoteSnapshotDecisions []SnapshotRetentionDecision
+	DryRun                  bool
+	Skipped                 bool
 }
 
 func GarbageCollectObjects(opts GCOptions) (GCResult, error) {
@@ -41,6 +64,30 @@ func GarbageCollectObjects(opts GCOptions) (GCResult, error) {
 	if err != nil {
 		return GCResult{}, err
 	}
+	if strings.TrimSpace(opts.UploadCheckpointPath) != "" {
+		checkpointKeys, err := uploadCheckpointObjectKeys(opts.UploadCheckpointPath)
+		if err != nil {
+			return GCResult{}, err
+		}
+		for _, key := range checkpointKeys {
+			reachableKeys[key] = struct{}{}
+		}
+	}
+
+	decisions, remoteManifests, err := planRemoteSnapshotRetention(opts.Store, opts.SnapshotKeys, opts.SnapshotPolicy)
+	if err != nil {
+		return GCResult{}, err
+	}
+	var prunedRemote []ManifestSnapshot
+	remoteKept := 0
+	for _, decision := range decisions {
+		if !decision.Keep {
+			prunedRemote = append(prunedRemote, decision.Snapshot)
+			continue
+		}
+		remoteKept++
+		addManifestObjectKeys(reachableKeys, remoteManifests[decision.Snapshot.ID])
+	}
 
 	existingKeys, err := opts.Store.ListKeys()
 	if err != nil {
@@ -49,18 +96,32 @@ func GarbageCollectObjects(opts GCOptions) (GCResult, error) {
 	existingKeys = FilterDataObjectKeys(existingKeys)
 
 	result := GCResult{
-		SourceManifests:   sourceManifestCount,
-		ReferencedObjects: len(reachableKeys),
-		ExistingObjects:   len(existingKeys),
-		DryRun:            opts.DryRun,
+		SourceManifests:         sourceManifestCount,
+		ReferencedObjects:       len(reachableKeys),
+		ExistingObjects:         len(existingKeys),
+		RemoteManifests:         remoteKept,
+		RemoteSnapshotDecisions: decisions,
+		DryRun:                  opts.DryRun,
 	}
-	if sourceManifestCount == 0 {
+	if sourceManifestCount == 0 && remoteKept == 0 {
 		// Safety rail: without manifest sources we should not delete any objects.
 		result.RetainedObjects = len(existingKeys)
 		result.Skipped = true
 		return result, nil
 	}
 
+	// Snapshot manifests go first, so an interrupted run never leaves one
+	// referencing deleted objects.
+	for _, snapshot := range prunedRemote {
+		result.PrunedRemoteManifests++
+		if opts.DryRun {
+			continue
+		}
+		if err := opts.Store.DeleteObject(snapshot.Path); err != nil {
+			return result, fmt.Errorf("delete snapshot manifest %s: %w", snapshot.ID, err)
+		}
+	}
+
 	for _, key := range existingKeys {
 		if _, ok := reachableKeys[key]; ok {
 			result.RetainedObjects++
@@ -111,13 +172,75 @@ func reachableObjectKeys(latestManifestPath, snapshotDir string) (map[string]str
 	return keys, sourceManifestCount, nil
 }
 
+// planRemoteSnapshotRetention applies policy to the snapshot manifests in
+// store, newest first. The returned snapshots' Path is their object key.
+func planRemoteSnapshotRetention(store storage.ObjectStore, snapshotKeys func() ([][]byte, error), policy SnapshotPrunePolicy) ([]SnapshotRetentionDecision, map[string]*Manifest, error) {
+	ids, err := ListRemoteSnapshotIDs(store)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+	if snapshotKeys == nil {
+		return nil, nil, fmt.Errorf("%w: no keys available", ErrRemoteHistoryUnreadable)
+	}
+	keys, err := snapshotKeys()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrRemoteHistoryUnreadable, err)
+	}
+
+	manifests := make(map[string]*Manifest, len(ids))
+	snapshots := make([]ManifestSnapshot, 0, len(ids))
+	for _, id := range ids {
+		manifest, err := ReadEncryptedSnapshotManifest(store, id, keys)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: snapshot %s: %w", ErrRemoteHistoryUnreadable, id, err)
+		}
+		objectKey, err := RemoteSnapshotManifestObjectKey(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifests[id] = manifest
+		snapshots = append(snapshots, ManifestSnapshot{
+			ID:         id,
+			Path:       objectKey,
+			CreatedAt:  manifest.CreatedAt.UTC(),
+			Entries:    len(manifest.Entries),
+			Incomplete: manifest.Incomplete,
+			Tags:       manifest.Tags,
+		})
+	}
+	sortSnapshotsNewestFirst(snapshots)
+
+	latest := ""
+	metadata, err := recovery.ReadMetadata(store)
+	switch {
+	case err == nil:
+		latest = strings.TrimSpace(metadata.LatestSnapshotID)
+	case !errors.Is(err, recovery.ErrMetadataNotFound):
+		return nil, nil, fmt.Errorf("read recovery metadata: %w", err)
+	}
+
+	decisions := planSnapshotRetention(snapshots, policy)
+	for i := range decisions {
+		if !decisions[i].Keep && decisions[i].Snapshot.ID == latest {
+			decisions[i].Keep = true
+			decisions[i].Reasons = []string{retentionReasonLatest}
+		}
+	}
+	return decisions, manifests, nil
+}
+
 func addManifestObjectKeys(target map[string]struct{}, m *Manifest) {
 	if m == nil {
 		return
 	}
 	for _, entry := range m.Entries {
-		if key := ResolveObjectKey(entry); key != "" {
-			target[key] = struct{}{}
+		for _, key := range ResolveObjectKeys(entry) {
+			if key != "" {
+				target[key] = struct{}{}
+			}
 		}
 	}
 }
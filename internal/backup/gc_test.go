@@ -1,8 +1,10 @@
 package backup
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -105,3 +107,183 @@ func TestGarbageCollectObjectsSkipsWithoutManifestSources(t *testing.T) {
 		t.Fatalf("object should not be deleted when gc is skipped, err=%v", err)
 	}
 }
+
+func TestGarbageCollectObjectsKeepsReferencedChunks(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	snapshotDir := filepath.Join(t.TempDir(), "manifests")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+
+	keepKey := ObjectKeyForChunkSHA256("aaaa")
+	orphanKey := ObjectKeyForChunkSHA256("bbbb")
+	for _, key := range []string{keepKey, orphanKey} {
+		if err := store.PutObject(key, []byte("chunk")); err != nil {
+			t.Fatalf("put chunk %s: %v", key, err)
+		}
+	}
+	if err := SaveManifest(manifestPath, &Manifest{
+		CreatedAt: time.Now().UTC(),
+		Entries: []ManifestEntry{{
+			Path:   "/Users/me/Movies/clip.mov",
+			Chunks: []ChunkRef{{SHA256: "aaaa", Size: 5}},
+		}},
+	}); err != nil {
+		t.Fatalf("save latest manifest: %v", err)
+	}
+
+	result, err := GarbageCollectObjects(GCOptions{
+		LatestManifestPath: manifestPath,
+		SnapshotDir:        snapshotDir,
+		Store:              store,
+	})
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if result.DeletedObjects != 1 || result.RetainedObjects != 1 {
+		t.Fatalf("unexpected gc result: %+v", result)
+	}
+	if _, err := store.GetObject(keepKey); err != nil {
+		t.Fatalf("referenced chunk should remain: %v", err)
+	}
+	if _, err := store.GetObject(orphanKey); !os.IsNotExist(err) {
+		t.Fatalf("orphan chunk should be deleted, err=%v", err)
+	}
+}
+
+func TestGarbageCollectObjectsKeepsUploadCheckpointObjects(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	snapshotDir := filepath.Join(t.TempDir(), "manifests")
+	checkpointPath := filepath.Join(t.TempDir(), "upload_checkpoint.journal")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+
+	pendingKey := ObjectKeyForContentSHA256("cccc")
+	orphanKey := ObjectKeyForContentSHA256("dddd")
+	for _, key := range []string{pendingKey, orphanKey} {
+		if err := store.PutObject(key, []byte("object")); err != nil {
+			t.Fatalf("put object %s: %v", key, err)
+		}
+	}
+	if err := SaveManifest(manifestPath, &Manifest{CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatalf("save latest manifest: %v", err)
+	}
+	checkpoint, err := OpenUploadCheckpoint(checkpointPath, "local-test")
+	if err != nil {
+		t.Fatalf("open upload checkpoint: %v", err)
+	}
+	if err := checkpoint.Record(ManifestEntry{Path: "/Users/me/pending.txt", SHA256: "cccc", Size: 6, ObjectKey: pendingKey}); err != nil {
+		t.Fatalf("record checkpoint entry: %v", err)
+	}
+	if err := checkpoint.Close(); err != nil {
+		t.Fatalf("close upload checkpoint: %v", err)
+	}
+
+	result, err := GarbageCollectObjects(GCOptions{
+		LatestManifestPath:   manifestPath,
+		SnapshotDir:          snapshotDir,
+		Store:                store,
+		UploadCheckpointPath: checkpointPath,
+	})
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if result.DeletedObjects != 1 {
+		t.Fatalf("unexpected gc result: %+v", result)
+	}
+	if _, err := store.GetObject(pendingKey); err != nil {
+		t.Fatalf("checkpointed object should remain: %v", err)
+	}
+	if _, err := store.GetObject(orphanKey); !os.IsNotExist(err) {
+		t.Fatalf("orphan object should be deleted, err=%v", err)
+	}
+}
+
+func remoteGCOptions(t *testing.T, store storage.ObjectStore, keys [][]byte) GCOptions {
+	t.Helper()
+	// Local state is empty, as on a machine set up from recovery bootstrap.
+	return GCOptions{
+		LatestManifestPath: filepath.Join(t.TempDir(), "manifest.json"),
+		SnapshotDir:        filepath.Join(t.TempDir(), "manifests"),
+		Store:              store,
+		SnapshotKeys:       func() ([][]byte, error) { return keys, nil },
+	}
+}
+
+func TestGarbageCollectObjectsKeepsObjectsOfRemoteSnapshots(t *testing.T) {
+	source, ids := seedCopySource(t)
+	before := seededDataKeys(t, source)
+	orphanKey := ObjectKeyForContentSHA256("eeee")
+	if err := source.PutObject(orphanKey, []byte("orphan")); err != nil {
+		t.Fatalf("put orphan: %v", err)
+	}
+
+	result, err := GarbageCollectObjects(remoteGCOptions(t, source, [][]byte{testCopyKey}))
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if result.Skipped || result.RemoteManifests != 2 || result.DeletedObjects != 1 || result.PrunedRemoteManifests != 0 {
+		t.Fatalf("unexpected gc result: %+v", result)
+	}
+	if after := seededDataKeys(t, source); !reflect.DeepEqual(after, before) {
+		t.Fatalf("unexpected objects after gc: got %v want %v", after, before)
+	}
+
+	opts := remoteGCOptions(t, source, [][]byte{testCopyKey})
+	opts.SnapshotPolicy = SnapshotPrunePolicy{Retain: 1}
+	result, err = GarbageCollectObjects(opts)
+	if err != nil {
+		t.Fatalf("gc with retention: %v", err)
+	}
+	// The first version of a.txt was only referenced by the pruned snapshot.
+	if result.RemoteManifests != 1 || result.PrunedRemoteManifests != 1 || result.DeletedObjects != 1 {
+		t.Fatalf("unexpected gc result: %+v", result)
+	}
+	remaining, err := ListRemoteSnapshotIDs(source)
+	if err != nil {
+		t.Fatalf("list remote snapshots: %v", err)
+	}
+	if !reflect.DeepEqual(remaining, ids[1:]) {
+		t.Fatalf("unexpected remote snapshots: got %v want %v", remaining, ids[1:])
+	}
+}
+
+func TestGarbageCollectObjectsKeepsLatestRemoteSnapshot(t *testing.T) {
+	source, ids := seedCopySource(t)
+
+	opts := remoteGCOptions(t, source, [][]byte{testCopyKey})
+	opts.DryRun = true
+	opts.SnapshotPolicy = SnapshotPrunePolicy{MaxAgeDays: 1, Now: time.Now().AddDate(1, 0, 0)}
+	result, err := GarbageCollectObjects(opts)
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if len(result.RemoteSnapshotDecisions) != 2 {
+		t.Fatalf("unexpected decisions: %+v", result.RemoteSnapshotDecisions)
+	}
+	latest := result.RemoteSnapshotDecisions[0]
+	if latest.Snapshot.ID != ids[1] || !latest.Keep || !reflect.DeepEqual(latest.Reasons, []string{"latest"}) {
+		t.Fatalf("expected latest snapshot to be kept, got %+v", latest)
+	}
+	if result.PrunedRemoteManifests != 1 || result.DeletedObjects != 0 {
+		t.Fatalf("unexpected dry-run result: %+v", result)
+	}
+	if remaining, _ := ListRemoteSnapshotIDs(source); len(remaining) != 2 {
+		t.Fatalf("dry-run pruned remote snapshots: %v", remaining)
+	}
+}
+
+func TestGarbageCollectObjectsRefusesUnreadableRemoteHistory(t *testing.T) {
+	source, _ := seedCopySource(t)
+	before := seededDataKeys(t, source)
+
+	wrongKey := []byte("abcdefghijklmnopqrstuvwxyz012345")
+	if _, err := GarbageCollectObjects(remoteGCOptions(t, source, [][]byte{wrongKey})); !errors.Is(err, ErrRemoteHistoryUnreadable) {
+		t.Fatalf("expected unreadable remote history, got %v", err)
+	}
+	opts := remoteGCOptions(t, source, nil)
+	opts.SnapshotKeys = func() ([][]byte, error) { return nil, errors.New("no passphrase") }
+	if _, err := GarbageCollectObjects(opts); !errors.Is(err, ErrRemoteHistoryUnreadable) {
+		t.Fatalf("expected unreadable remote history without keys, got %v", err)
+	}
+	if after := seededDataKeys(t, source); !reflect.DeepEqual(after, before) {
+		t.Fatalf("gc deleted objects: got %v want %v", after, before)
+	}
+}
@@ -2,7 +2,9 @@ package backup
 
 import (
 	"os"
+	"strings"
 
+	"baxter/internal/repolock"
 	"baxter/internal/storage"
 )
 
@@ -21,7 +23,16 @@ func AllowCreateWrappedKeyWithoutMetadata(manifestPath, snapshotDir, saltPath st
 	hasSalt := fileExists(saltPath)
 	hasRemoteData := len(FilterDataObjectKeys(keys)) > 0
 
-	isFresh := !hasManifest && !hasSnapshots && len(keys) == 0
+	// Lock objects do not make a repository used: the caller holds one.
+	hasObjects := false
+	for _, key := range keys {
+		if !strings.HasPrefix(key, repolock.KeyPrefix) {
+			hasObjects = true
+			break
+		}
+	}
+
+	isFresh := !hasManifest && !hasSnapshots && !hasObjects
 	canRepairRemoteData := hasSalt && hasRemoteData
 	return isFresh || canRepairRemoteData, nil
 }
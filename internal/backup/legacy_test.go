@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"baxter/internal/repolock"
 	"baxter/internal/storage"
 )
 
@@ -77,3 +78,24 @@ func TestAllowCreateWrappedKeyWithoutMetadataAllowsRepairWhenSaltAndRemoteDataEx
 		t.Fatal("expected salt + remote data state to allow repair")
 	}
 }
+
+func TestAllowCreateWrappedKeyWithoutMetadataIgnoresLockObjects(t *testing.T) {
+	rootDir := t.TempDir()
+	store := storage.NewLocalClient(filepath.Join(rootDir, "objects"))
+	if err := store.PutObject(repolock.KeyPrefix+"backup.json", []byte("lock")); err != nil {
+		t.Fatalf("put lock: %v", err)
+	}
+
+	allowed, err := AllowCreateWrappedKeyWithoutMetadata(
+		filepath.Join(rootDir, "manifest.json"),
+		filepath.Join(rootDir, "manifests"),
+		filepath.Join(rootDir, "kdf_salt.bin"),
+		store,
+	)
+	if err != nil {
+		t.Fatalf("AllowCreateWrappedKeyWithoutMetadata() error = %v", err)
+	}
+	if !allowed {
+		t.Fatal("expected a store holding only the caller's lock to count as fresh")
+	}
+}
@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+)
+
+// Ownership is the numeric owner of a manifest entry.
+type Ownership struct {
+	UID uint32 `json:"uid"`
+	GID uint32 `json:"gid"`
+}
+
+// RestoreMetadataOptions selects which recorded metadata restore re-applies.
+// The zero value re-applies everything the platform supports; ownership is
+// only applied when running privileged.
+type RestoreMetadataOptions struct {
+	SkipModTime bool `json:"skip_mod_time,omitempty"`
+	SkipMode    bool `json:"skip_mode,omitempty"`
+	SkipOwner   bool `json:"skip_owner,omitempty"`
+	SkipXattrs  bool `json:"skip_xattrs,omitempty"`
+}
+
+// captureEntryMetadata records ownership and extended attributes for path.
+// Attributes are best-effort: filesystems without xattr support or entries
+// we are not allowed to inspect are recorded without them.
+func captureEntryMetadata(entry *ManifestEntry, path string, info fs.FileInfo) error {
+	entry.Owner = ownerForFileInfo(info)
+	xattrs, err := readXattrs(path)
+	if err != nil {
+		return err
+	}
+	entry.Xattrs = xattrs
+	return nil
+}
+
+// applyRestoredMetadata re-applies the recorded metadata of entry to path.
+// Ownership goes first because chown may clear setuid bits, and mtime goes
+// last because every other change touches the inode.
+func applyRestoredMetadata(entry ManifestEntry, path string, opts RestoreMetadataOptions) error {
+	if !opts.SkipOwner && entry.Owner != nil {
+		if err := applyOwner(path, *entry.Owner); err != nil {
+			return fmt.Errorf("%w %s: set owner: %w", ErrRestoreWrite, path, err)
+		}
+	}
+	if !opts.SkipMode && !entry.IsSymlink() {
+		if err := os.Chmod(path, entry.Mode.Perm()); err != nil {
+			return fmt.Errorf("%w %s: %w", ErrRestoreWrite, path, err)
+		}
+	}
+	if !opts.SkipXattrs && len(entry.Xattrs) > 0 {
+		names := make([]string, 0, len(entry.Xattrs))
+		for name := range entry.Xattrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := writeXattr(path, name, entry.Xattrs[name]); err != nil {
+				return fmt.Errorf("%w %s: set xattr %s: %w", ErrRestoreWrite, path, name, err)
+			}
+		}
+	}
+	if !opts.SkipModTime && !entry.ModTime.IsZero() {
+		if err := setModTime(path, entry.ModTime, entry.IsSymlink()); err != nil {
+			return fmt.Errorf("%w %s: set mtime: %w", ErrRestoreWrite, path, err)
+		}
+	}
+	return nil
+}
+
+func setModTime(path string, modTime time.Time, symlink bool) error {
+	if symlink {
+		return setSymlinkModTime(path, modTime)
+	}
+	return os.Chtimes(path, modTime, modTime)
+}
@@ -0,0 +1,13 @@
+//go:build darwin
+
+package backup
+
+import "golang.org/x/sys/unix"
+
+// errXattrMissing is returned when an attribute disappears between listing
+// and reading it.
+var errXattrMissing error = unix.ENOATTR
+
+func xattrNameCaptured(name string) bool {
+	return true
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package backup
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// errXattrMissing is returned when an attribute disappears between listing
+// and reading it.
+var errXattrMissing error = unix.ENODATA
+
+// Only the user namespace is captured on Linux; security, trusted and system
+// attributes are policy-managed and usually cannot be restored unprivileged.
+func xattrNameCaptured(name string) bool {
+	return strings.HasPrefix(name, "user.")
+}
@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func requireUserXattrs(t *testing.T, path string) {
+	t.Helper()
+	if err := unix.Setxattr(path, "user.baxter.probe", []byte("1"), 0); err != nil {
+		if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skipf("user xattrs unsupported here: %v", err)
+		}
+		t.Fatalf("set probe xattr: %v", err)
+	}
+	if err := unix.Removexattr(path, "user.baxter.probe"); err != nil {
+		t.Fatalf("remove probe xattr: %v", err)
+	}
+}
+
+func TestBuildManifestCapturesOwnerAndUserXattrs(t *testing.T) {
+	root := t.TempDir()
+	filePath := filepath.Join(root, "photo.jpg")
+	mustWriteTestFile(t, filePath, []byte("pixels"))
+	requireUserXattrs(t, filePath)
+	if err := unix.Setxattr(filePath, "user.rating", []byte("5"), 0); err != nil {
+		t.Fatalf("set xattr: %v", err)
+	}
+
+	manifest, err := BuildManifest([]string{root})
+	if err != nil {
+		t.Fatalf("build manifest: %v", err)
+	}
+	entry, err := FindEntryByPath(manifest, filePath)
+	if err != nil {
+		t.Fatalf("find entry: %v", err)
+	}
+	if entry.Owner == nil || entry.Owner.UID != uint32(os.Getuid()) || entry.Owner.GID != uint32(os.Getgid()) {
+		t.Fatalf("unexpected owner: %+v", entry.Owner)
+	}
+	if got := string(entry.Xattrs["user.rating"]); got != "5" || len(entry.Xattrs) != 1 {
+		t.Fatalf("unexpected xattrs: %#v", entry.Xattrs)
+	}
+}
+
+func TestApplyRestoredMetadataHonorsSkipOptions(t *testing.T) {
+	root := t.TempDir()
+	modTime := time.Date(2024, time.May, 6, 7, 8, 9, 0, time.UTC)
+	entry := ManifestEntry{
+		Path:    "/src/photo.jpg",
+		Mode:    0o640,
+		ModTime: modTime,
+		Owner:   &Ownership{UID: uint32(os.Getuid()), GID: uint32(os.Getgid())},
+		Xattrs:  map[string][]byte{"user.rating": []byte("5")},
+	}
+
+	restored := filepath.Join(root, "restored.jpg")
+	mustWriteTestFile(t, restored, []byte("pixels"))
+	requireUserXattrs(t, restored)
+	if err := applyRestoredMetadata(entry, restored, RestoreMetadataOptions{}); err != nil {
+		t.Fatalf("apply metadata: %v", err)
+	}
+	info, err := os.Stat(restored)
+	if err != nil {
+		t.Fatalf("stat restored: %v", err)
+	}
+	if info.Mode().Perm() != 0o640 || !info.ModTime().Equal(modTime) {
+		t.Fatalf("unexpected restored metadata: mode=%v mtime=%v", info.Mode().Perm(), info.ModTime())
+	}
+	value := make([]byte, 8)
+	if n, err := unix.Getxattr(restored, "user.rating", value); err != nil || string(value[:n]) != "5" {
+		t.Fatalf("unexpected restored xattr: %q err=%v", value[:n], err)
+	}
+
+	skipped := filepath.Join(root, "skipped.jpg")
+	mustWriteTestFile(t, skipped, []byte("pixels"))
+	before, err := os.Stat(skipped)
+	if err != nil {
+		t.Fatalf("stat skipped: %v", err)
+	}
+	opts := RestoreMetadataOptions{SkipModTime: true, SkipMode: true, SkipOwner: true, SkipXattrs: true}
+	if err := applyRestoredMetadata(entry, skipped, opts); err != nil {
+		t.Fatalf("apply skipped metadata: %v", err)
+	}
+	after, err := os.Stat(skipped)
+	if err != nil {
+		t.Fatalf("stat skipped: %v", err)
+	}
+	if after.Mode() != before.Mode() || !after.ModTime().Equal(before.ModTime()) {
+		t.Fatalf("expected metadata to be left alone: before=%v/%v after=%v/%v", before.Mode(), before.ModTime(), after.Mode(), after.ModTime())
+	}
+	if _, err := unix.Getxattr(skipped, "user.rating", value); !errors.Is(err, unix.ENODATA) {
+		t.Fatalf("expected no xattr on skipped target, got err=%v", err)
+	}
+}
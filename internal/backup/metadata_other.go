@@ -0,0 +1,28 @@
+//go:build !darwin && !linux
+
+package backup
+
+import (
+	"io/fs"
+	"time"
+)
+
+func ownerForFileInfo(info fs.FileInfo) *Ownership {
+	return nil
+}
+
+func readXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func writeXattr(path string, name string, value []byte) error {
+	return nil
+}
+
+func applyOwner(path string, owner Ownership) error {
+	return nil
+}
+
+func setSymlinkModTime(path string, modTime time.Time) error {
+	return nil
+}
@@ -0,0 +1,131 @@
+//go:build darwin || linux
+
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func ownerForFileInfo(info fs.FileInfo) *Ownership {
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return &Ownership{UID: sys.Uid, GID: sys.Gid}
+}
+
+func readXattrs(path string) (map[string][]byte, error) {
+	names, err := listXattrNames(path)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+
+	xattrs := make(map[string][]byte, len(names))
+	for _, name := range names {
+		if !xattrNameCaptured(name) {
+			continue
+		}
+		value, err := readXattr(path, name)
+		if err != nil {
+			if errors.Is(err, errXattrMissing) || ignorableXattrError(err) {
+				continue
+			}
+			return nil, err
+		}
+		xattrs[name] = value
+	}
+	if len(xattrs) == 0 {
+		return nil, nil
+	}
+	return xattrs, nil
+}
+
+func listXattrNames(path string) ([]string, error) {
+	for {
+		size, err := unix.Llistxattr(path, nil)
+		if err != nil {
+			if ignorableXattrError(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := unix.Llistxattr(path, buf)
+		if errors.Is(err, unix.ERANGE) {
+			// The attribute list grew between the two calls.
+			continue
+		}
+		if err != nil {
+			if ignorableXattrError(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		names := make([]string, 0)
+		for _, name := range bytes.Split(buf[:n], []byte{0}) {
+			if len(name) > 0 {
+				names = append(names, string(name))
+			}
+		}
+		return names, nil
+	}
+}
+
+func readXattr(path string, name string) ([]byte, error) {
+	for {
+		size, err := unix.Lgetxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, size)
+		if size == 0 {
+			return value, nil
+		}
+		n, err := unix.Lgetxattr(path, name, value)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return value[:n], nil
+	}
+}
+
+func writeXattr(path string, name string, value []byte) error {
+	err := unix.Lsetxattr(path, name, value, 0)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		// The destination filesystem cannot hold extended attributes.
+		return nil
+	}
+	return err
+}
+
+func ignorableXattrError(err error) bool {
+	return errors.Is(err, unix.ENOTSUP) ||
+		errors.Is(err, unix.EOPNOTSUPP) ||
+		errors.Is(err, unix.EPERM) ||
+		errors.Is(err, unix.EACCES)
+}
+
+func applyOwner(path string, owner Ownership) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Lchown(path, int(owner.UID), int(owner.GID))
+}
+
+func setSymlinkModTime(path string, modTime time.Time) error {
+	ts := unix.NsecToTimespec(modTime.UnixNano())
+	return unix.UtimesNanoAt(unix.AT_FDCWD, path, []unix.Timespec{ts, ts}, unix.AT_SYMLINK_NOFOLLOW)
+}
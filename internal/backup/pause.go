@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"context"
+	"sync"
+)
+
+// PauseGate parks upload workers between objects while a run is paused;
+// chunks of a large file are separate objects.
+// Uploads already in flight finish first; nothing is uploaded until Resume.
+// The zero value is not paused.
+type PauseGate struct {
+	mu      sync.Mutex
+	resumed chan struct{}
+}
+
+// Pause stops workers from starting new uploads. It reports false when the
+// gate was already paused.
+func (g *PauseGate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return false
+	}
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// Resume releases parked workers. It reports false when the gate was not
+// paused.
+func (g *PauseGate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return false
+	}
+	close(g.resumed)
+	g.resumed = nil
+	return true
+}
+
+func (g *PauseGate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// Wait blocks while the gate is paused, returning early with ctx's error when
+// ctx is done. A nil gate never blocks.
+func (g *PauseGate) Wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resumed:
+		return nil
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"baxter/internal/crypto"
 	"baxter/internal/storage"
@@ -86,3 +88,123 @@ func WriteEncryptedSnapshotManifest(store storage.ObjectStore, snapshotID string
 	}
 	return nil
 }
+
+// ReadEncryptedSnapshotManifest reads the snapshot manifest that
+// WriteEncryptedSnapshotManifest stored, trying each key in order.
+func ReadEncryptedSnapshotManifest(store storage.ObjectStore, snapshotID string, keys [][]byte) (*Manifest, error) {
+	if store == nil {
+		return nil, errors.New("object store is required")
+	}
+	objectKey, err := RemoteSnapshotManifestObjectKey(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := store.GetObject(objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("get remote snapshot manifest: %w", err)
+	}
+	plain, err := crypto.DecryptBytesWithAnyKey(keys, payload)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt remote snapshot manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(plain, &manifest); err != nil {
+		return nil, fmt.Errorf("decode remote snapshot manifest: %w", err)
+	}
+	if manifest.Entries == nil {
+		manifest.Entries = []ManifestEntry{}
+	}
+	return &manifest, nil
+}
+
+// ListRemoteSnapshotIDs lists the snapshot manifests stored in store, oldest
+// first.
+func ListRemoteSnapshotIDs(store storage.ObjectStore) ([]string, error) {
+	if store == nil {
+		return nil, errors.New("object store is required")
+	}
+	var keys []string
+	var err error
+	if lister, ok := store.(storage.PrefixKeyLister); ok {
+		keys, err = lister.ListKeysWithPrefix(remoteSnapshotManifestPrefix)
+	} else {
+		keys, err = store.ListKeys()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list remote snapshot manifests: %w", err)
+	}
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if id, ok := RemoteSnapshotManifestIDFromObjectKey(key); ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// SnapshotIDTime returns the creation time encoded in a snapshot id.
+func SnapshotIDTime(snapshotID string) (time.Time, bool) {
+	trimmed := strings.TrimSpace(snapshotID)
+	// Ids reserved next to an existing one carry a "-N" suffix.
+	if end := strings.IndexByte(trimmed, 'Z'); end >= 0 {
+		trimmed = trimmed[:end+1]
+	}
+	createdAt, err := time.Parse(snapshotIDLayout, trimmed)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
+
+// SelectRemoteSnapshotIDs resolves selectors against the snapshot ids stored
+// remotely. A selector is "latest" (latestSnapshotID), a snapshot id, or an
+// RFC3339 time naming the newest snapshot taken at or before it. No
+// selectors select every snapshot.
+func SelectRemoteSnapshotIDs(available []string, latestSnapshotID string, selectors []string) ([]string, error) {
+	if len(selectors) == 0 {
+		return append([]string(nil), available...), nil
+	}
+
+	stored := make(map[string]struct{}, len(available))
+	for _, id := range available {
+		stored[id] = struct{}{}
+	}
+	selected := make(map[string]struct{}, len(selectors))
+	for _, selector := range selectors {
+		trimmed := strings.TrimSpace(selector)
+		id := trimmed
+		switch {
+		case trimmed == "" || strings.EqualFold(trimmed, latestSnapshotSelector):
+			id = strings.TrimSpace(latestSnapshotID)
+		default:
+			asOf, err := time.Parse(time.RFC3339, trimmed)
+			if err != nil {
+				break
+			}
+			id = ""
+			var newest time.Time
+			for _, candidate := range available {
+				createdAt, ok := SnapshotIDTime(candidate)
+				if !ok || createdAt.After(asOf.UTC()) || createdAt.Before(newest) {
+					continue
+				}
+				id, newest = candidate, createdAt
+			}
+		}
+		if _, ok := stored[id]; !ok || id == "" {
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, trimmed)
+		}
+		selected[id] = struct{}{}
+	}
+
+	ids := make([]string, 0, len(selected))
+	for id := range selected {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
@@ -2,7 +2,9 @@ package backup
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -37,7 +39,7 @@ func TestRunWritesEncryptedRemoteSnapshotAndRecoveryMetadata(t *testing.T) {
 	}
 	store := storage.NewLocalClient(objectsDir)
 
-	if _, err := Run(cfg, RunOptions{
+	if _, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -118,7 +120,7 @@ func TestRunUpdatesRecoveryMetadataLatestSnapshotID(t *testing.T) {
 	store := storage.NewLocalClient(objectsDir)
 	run := func() string {
 		t.Helper()
-		if _, err := Run(cfg, RunOptions{
+		if _, err := Run(context.Background(), cfg, RunOptions{
 			ManifestPath:      manifestPath,
 			SnapshotDir:       snapshotDir,
 			SnapshotRetention: 30,
@@ -164,3 +166,147 @@ func TestRunUpdatesRecoveryMetadataLatestSnapshotID(t *testing.T) {
 		t.Fatalf("expected latest snapshot id to change from %q", firstSnapshotID)
 	}
 }
+
+type cancellingStore struct {
+	storage.ObjectStore
+	cancel context.CancelFunc
+}
+
+// PutObject cancels the run and fails the upload in flight, as a request
+// bound to the run's context would.
+func (s *cancellingStore) PutObject(key string, data []byte) error {
+	s.cancel()
+	return context.Canceled
+}
+
+func TestRunCancelledLeavesPreviousSnapshotAndRecoveryMetadata(t *testing.T) {
+	root := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	snapshotDir := filepath.Join(t.TempDir(), "manifests")
+	objectsDir := filepath.Join(t.TempDir(), "objects")
+	key := []byte("01234567890123456789012345678901")
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("v1 "+name), 0o600); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+	}
+
+	cfg := &config.Config{BackupRoots: []string{root}}
+	store := storage.NewLocalClient(objectsDir)
+	opts := RunOptions{
+		ManifestPath:      manifestPath,
+		SnapshotDir:       snapshotDir,
+		SnapshotRetention: 30,
+		EncryptionKey:     key,
+		KDFSalt:           testKDFSalt,
+		BackupSetID:       "local-test",
+		Store:             store,
+	}
+	if _, err := Run(context.Background(), cfg, opts); err != nil {
+		t.Fatalf("run backup: %v", err)
+	}
+	manifestBefore, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	metadataBefore, err := recovery.ReadMetadata(store)
+	if err != nil {
+		t.Fatalf("read recovery metadata: %v", err)
+	}
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("v2 "+name), 0o600); err != nil {
+			t.Fatalf("update source file: %v", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts.Store = &cancellingStore{ObjectStore: store, cancel: cancel}
+	if _, err := Run(ctx, cfg, opts); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled run, got %v", err)
+	}
+
+	manifestAfter, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("read manifest after cancel: %v", err)
+	}
+	if !bytes.Equal(manifestAfter, manifestBefore) {
+		t.Fatal("cancelled run replaced the local manifest")
+	}
+	snapshots, err := ListSnapshotManifests(snapshotDir)
+	if err != nil {
+		t.Fatalf("list snapshots: %v", err)
+	}
+	if latest, ok := LatestCompleteSnapshot(snapshots); !ok || latest.ID != metadataBefore.LatestSnapshotID {
+		t.Fatalf("cancelled run changed the latest complete snapshot: %+v", snapshots)
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ID != metadataBefore.LatestSnapshotID && !snapshot.Incomplete {
+			t.Fatalf("cancelled run wrote a complete snapshot: %+v", snapshot)
+		}
+	}
+	metadataAfter, err := recovery.ReadMetadata(store)
+	if err != nil {
+		t.Fatalf("read recovery metadata after cancel: %v", err)
+	}
+	if metadataAfter.LatestSnapshotID != metadataBefore.LatestSnapshotID {
+		t.Fatalf("latest snapshot id changed: got %q want %q", metadataAfter.LatestSnapshotID, metadataBefore.LatestSnapshotID)
+	}
+}
+
+func TestRunCommitsWhenCancelledAfterTheLastUpload(t *testing.T) {
+	root := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	snapshotDir := filepath.Join(t.TempDir(), "manifests")
+	key := []byte("01234567890123456789012345678901")
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("v1 "+name), 0o600); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := &config.Config{BackupRoots: []string{root}}
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	result, err := Run(ctx, cfg, RunOptions{
+		ManifestPath:      manifestPath,
+		SnapshotDir:       snapshotDir,
+		SnapshotRetention: 30,
+		EncryptionKey:     key,
+		KDFSalt:           testKDFSalt,
+		BackupSetID:       "local-test",
+		Store:             store,
+		Progress: func(update ProgressUpdate) {
+			if update.Total > 0 && update.Uploaded == update.Total {
+				cancel()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected the run to commit after its last upload, got %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("expected the run to have been cancelled")
+	}
+	if result.Uploaded != 3 || result.SnapshotID == "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	snapshots, err := ListSnapshotManifests(snapshotDir)
+	if err != nil {
+		t.Fatalf("list snapshots: %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Incomplete || snapshots[0].ID != result.SnapshotID {
+		t.Fatalf("expected one complete snapshot, got %+v", snapshots)
+	}
+	metadata, err := recovery.ReadMetadata(store)
+	if err != nil {
+		t.Fatalf("read recovery metadata: %v", err)
+	}
+	if metadata.LatestSnapshotID != result.SnapshotID {
+		t.Fatalf("unexpected latest snapshot id: got %q want %q", metadata.LatestSnapshotID, result.SnapshotID)
+	}
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"baxter/internal/recovery"
+	"baxter/internal/storage"
+)
+
+// catchUpReplicas copies the objects current references to destinations that
+// missed earlier runs, so every destination that records the new snapshot can
+// restore it. A destination lags when its recovery metadata is missing or
+// older than another's: it was added after the first backup, or was marked
+// failed during a run. Unchanged files are not uploaded again, so without this
+// such a destination would keep missing their objects.
+//
+// A destination that cannot be caught up is marked failed and so receives
+// neither the snapshot manifest nor the recovery metadata of this run.
+func catchUpReplicas(ctx context.Context, replicated *storage.ReplicatedStore, current *Manifest) error {
+	destinations := replicated.Destinations()
+	if len(destinations) < 2 {
+		return nil
+	}
+
+	updated := make(map[string]time.Time, len(destinations))
+	var newest time.Time
+	for _, dest := range destinations {
+		metadata, err := recovery.ReadMetadata(dest.Store)
+		switch {
+		case err == nil:
+			updated[dest.Name] = metadata.UpdatedAt
+			if metadata.UpdatedAt.After(newest) {
+				newest = metadata.UpdatedAt
+			}
+		case errors.Is(err, recovery.ErrMetadataNotFound):
+		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+			return err
+		default:
+			replicated.MarkFailed(dest.Name, fmt.Errorf("read recovery metadata: %w", err))
+		}
+	}
+	if newest.IsZero() {
+		return nil
+	}
+
+	var sources, lagging []storage.Destination
+	for _, dest := range replicated.Destinations() {
+		if updated[dest.Name].Equal(newest) {
+			sources = append(sources, dest)
+		} else {
+			lagging = append(lagging, dest)
+		}
+	}
+	if len(lagging) == 0 {
+		return nil
+	}
+
+	required := make(map[string]struct{})
+	addManifestObjectKeys(required, current)
+	for _, dest := range lagging {
+		if err := copyMissingObjects(ctx, dest, sources, required); err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return err
+			}
+			replicated.MarkFailed(dest.Name, fmt.Errorf("catch up replica: %w", err))
+		}
+	}
+	return nil
+}
+
+func copyMissingObjects(ctx context.Context, dest storage.Destination, sources []storage.Destination, required map[string]struct{}) error {
+	existingKeys, err := dest.Store.ListKeys()
+	if err != nil {
+		return fmt.Errorf("list object keys: %w", err)
+	}
+	existing := make(map[string]struct{}, len(existingKeys))
+	for _, key := range existingKeys {
+		existing[key] = struct{}{}
+	}
+
+	for key := range required {
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var copyErr error
+		for _, source := range sources {
+			if copyErr = storage.CopyObject(dest.Store, source.Store, key); copyErr == nil {
+				break
+			}
+		}
+		if copyErr != nil {
+			return fmt.Errorf("copy object %s: %w", key, copyErr)
+		}
+	}
+	return nil
+}
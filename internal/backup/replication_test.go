@@ -0,0 +1,134 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"baxter/internal/config"
+	"baxter/internal/recovery"
+	"baxter/internal/storage"
+)
+
+func runReplicationBackup(t *testing.T, cfg *config.Config, stateDir string, store storage.ObjectStore) (*Manifest, error) {
+	t.Helper()
+	manifestPath := filepath.Join(stateDir, "manifest.json")
+	_, err := Run(context.Background(), cfg, RunOptions{
+		ManifestPath:      manifestPath,
+		SnapshotDir:       filepath.Join(stateDir, "manifests"),
+		SnapshotRetention: 30,
+		EncryptionKey:     []byte("01234567890123456789012345678901"),
+		KDFSalt:           testKDFSalt,
+		BackupSetID:       "local-test",
+		Store:             store,
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifest, loadErr := LoadManifest(manifestPath)
+	if loadErr != nil {
+		t.Fatalf("load manifest: %v", loadErr)
+	}
+	return manifest, nil
+}
+
+func assertReplicaHoldsSnapshot(t *testing.T, name string, store storage.ObjectStore, manifest *Manifest) {
+	t.Helper()
+	required := make(map[string]struct{})
+	addManifestObjectKeys(required, manifest)
+	if len(required) == 0 {
+		t.Fatal("manifest references no objects")
+	}
+	for key := range required {
+		if exists, err := store.(storage.ObjectExistsStore).ObjectExists(key); err != nil || !exists {
+			t.Fatalf("%s is missing object %s: exists=%t err=%v", name, key, exists, err)
+		}
+	}
+	metadata, err := recovery.ReadMetadata(store)
+	if err != nil {
+		t.Fatalf("read %s recovery metadata: %v", name, err)
+	}
+	if metadata.LatestSnapshotID == "" {
+		t.Fatalf("%s recovery metadata has no latest snapshot", name)
+	}
+	manifestKey, err := RemoteSnapshotManifestObjectKey(metadata.LatestSnapshotID)
+	if err != nil {
+		t.Fatalf("snapshot manifest key: %v", err)
+	}
+	if exists, err := store.(storage.ObjectExistsStore).ObjectExists(manifestKey); err != nil || !exists {
+		t.Fatalf("%s is missing the latest snapshot manifest: exists=%t err=%v", name, exists, err)
+	}
+}
+
+func TestRunCatchesUpReplicaAddedAfterFirstBackup(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("content of "+name), 0o600); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+	}
+	cfg := &config.Config{BackupRoots: []string{root}}
+	stateDir := t.TempDir()
+	primary := storage.NewLocalClient(filepath.Join(t.TempDir(), "primary"))
+	replica := storage.NewLocalClient(filepath.Join(t.TempDir(), "drive"))
+
+	if _, err := runReplicationBackup(t, cfg, stateDir, primary); err != nil {
+		t.Fatalf("first backup: %v", err)
+	}
+
+	// No file changed, so only catch-up can bring the new replica in line.
+	replicated := storage.NewReplicatedStore([]storage.Destination{
+		{Name: config.PrimaryDestinationName, Store: primary},
+		{Name: "drive", Store: replica},
+	})
+	manifest, err := runReplicationBackup(t, cfg, stateDir, replicated)
+	if err != nil {
+		t.Fatalf("second backup: %v", err)
+	}
+	for _, status := range replicated.Statuses() {
+		if status.Failed {
+			t.Fatalf("unexpected failed destination: %+v", status)
+		}
+	}
+	assertReplicaHoldsSnapshot(t, "primary", primary, manifest)
+	assertReplicaHoldsSnapshot(t, "drive", replica, manifest)
+}
+
+func TestRunDropsFailingReplicaAndCatchesItUpNextRun(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("content of a.txt"), 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+	cfg := &config.Config{BackupRoots: []string{root}}
+	stateDir := t.TempDir()
+	primary := storage.NewLocalClient(filepath.Join(t.TempDir(), "primary"))
+	replica := storage.NewLocalClient(filepath.Join(t.TempDir(), "nas"))
+
+	failing := storage.NewReplicatedStore([]storage.Destination{
+		{Name: config.PrimaryDestinationName, Store: primary},
+		{Name: "nas", Store: &keyFailingStore{inner: replica, failPrefix: "sha256/"}},
+	})
+	manifest, err := runReplicationBackup(t, cfg, stateDir, failing)
+	if err != nil {
+		t.Fatalf("backup should succeed while the primary takes every write: %v", err)
+	}
+	statuses := failing.Statuses()
+	if len(statuses) != 2 || statuses[0].Failed || !statuses[1].Failed {
+		t.Fatalf("expected only the replica to fail, got %+v", statuses)
+	}
+	assertReplicaHoldsSnapshot(t, "primary", primary, manifest)
+	if _, err := recovery.ReadMetadata(replica); err == nil {
+		t.Fatal("failed replica recorded a snapshot it does not hold")
+	}
+
+	healthy := storage.NewReplicatedStore([]storage.Destination{
+		{Name: config.PrimaryDestinationName, Store: primary},
+		{Name: "nas", Store: replica},
+	})
+	manifest, err = runReplicationBackup(t, cfg, stateDir, healthy)
+	if err != nil {
+		t.Fatalf("second backup: %v", err)
+	}
+	assertReplicaHoldsSnapshot(t, "nas", replica, manifest)
+}
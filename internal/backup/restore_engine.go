@@ -0,0 +1,235 @@
+package backup
+
+import (
+	"io"
+	"sync"
+
+	"baxter/internal/storage"
+)
+
+const defaultRestoreConcurrency = 4
+
+// RestoreJob is one manifest entry and the path it is restored to.
+type RestoreJob struct {
+	Entry      ManifestEntry
+	TargetPath string
+}
+
+// RestoreProgress reports the outcome of one job. Updates are delivered in
+// job order from a single goroutine, whatever order the workers finish in.
+type RestoreProgress struct {
+	Index   int
+	Done    int
+	Total   int
+	Path    string
+	Skipped bool
+	Err     error
+}
+
+type RestoreFailure struct {
+	Path string
+	Err  error
+}
+
+type RestoreJobsOptions struct {
+	Store    storage.ObjectStore
+	Keys     [][]byte
+	Metadata RestoreMetadataOptions
+	// VerifyOnly fetches and checksums stored content without writing it.
+	VerifyOnly bool
+	// Concurrency bounds the number of entries in flight (0 = default).
+	Concurrency int
+	// ContinueOnError records failed entries and keeps going instead of
+	// stopping at the first failure.
+	ContinueOnError bool
+	// Journal, when set, skips entries it already records as completed and
+	// durably records each entry that finishes.
+	Journal  *RestoreJournal
+	Progress func(RestoreProgress)
+	// Restore replaces the per-entry action; it defaults to RestoreEntry, or
+	// to a content check when VerifyOnly is set.
+	Restore func(RestoreJob) error
+}
+
+type RestoreJobsResult struct {
+	Restored int
+	Skipped  int
+	Failures []RestoreFailure
+}
+
+// RestoreEntryError is returned by RestoreJobs for the first entry that
+// failed. It reads like the underlying error and carries the entry path.
+type RestoreEntryError struct {
+	Path string
+	Err  error
+}
+
+func (e *RestoreEntryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RestoreEntryError) Unwrap() error {
+	return e.Err
+}
+
+func (o RestoreJobsOptions) effectiveConcurrency() int {
+	if o.Concurrency <= 0 {
+		return defaultRestoreConcurrency
+	}
+	return o.Concurrency
+}
+
+// RestoreJobs restores jobs with a bounded pool of workers. Once every entry
+// has been written, directory metadata is applied deepest first. Without
+// ContinueOnError no further entries are started after the first failure and
+// directory metadata is left alone; either way the first failure is returned
+// as a *RestoreEntryError.
+func RestoreJobs(jobs []RestoreJob, opts RestoreJobsOptions) (RestoreJobsResult, error) {
+	var result RestoreJobsResult
+	total := len(jobs)
+
+	// Completed entries are looked up before any worker starts, since the
+	// journal is only written from the delivering goroutine.
+	skip := make([]bool, total)
+	if opts.Journal != nil {
+		for i, job := range jobs {
+			skip[i] = opts.Journal.Completed(job.Entry.Path)
+		}
+	}
+
+	workerCount := min(opts.effectiveConcurrency(), total)
+	indexes := make(chan int)
+	outcomes := make(chan restoreOutcome)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+
+	go func() {
+		defer close(indexes)
+		for i := range jobs {
+			select {
+			case <-stop:
+				return
+			case indexes <- i:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if skip[index] {
+					outcomes <- restoreOutcome{index: index, skipped: true}
+					continue
+				}
+				ran, err := runRestoreJob(jobs[index], opts)
+				outcomes <- restoreOutcome{index: index, ran: ran, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	pending := make(map[int]restoreOutcome)
+	next := 0
+	var fatal error
+	for outcome := range outcomes {
+		if fatal != nil {
+			continue
+		}
+		pending[outcome.index] = outcome
+		for fatal == nil {
+			current, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			fatal = recordRestoreOutcome(jobs[current.index], current, next, total, opts, &result)
+			if fatal != nil {
+				stopOnce.Do(func() { close(stop) })
+			}
+		}
+	}
+	if fatal != nil {
+		return result, fatal
+	}
+
+	if !opts.VerifyOnly {
+		for i := total - 1; i >= 0; i-- {
+			if err := ApplyRestoredDirectoryMetadata(jobs[i].Entry, jobs[i].TargetPath, opts.Metadata); err != nil {
+				return result, &RestoreEntryError{Path: jobs[i].Entry.Path, Err: err}
+			}
+		}
+	}
+	if len(result.Failures) > 0 {
+		first := result.Failures[0]
+		return result, &RestoreEntryError{Path: first.Path, Err: first.Err}
+	}
+	return result, nil
+}
+
+type restoreOutcome struct {
+	index   int
+	ran     bool
+	skipped bool
+	err     error
+}
+
+// recordRestoreOutcome accounts for a finished job and reports whether the
+// run has to stop.
+func recordRestoreOutcome(job RestoreJob, outcome restoreOutcome, done int, total int, opts RestoreJobsOptions, result *RestoreJobsResult) error {
+	switch {
+	case outcome.err != nil:
+		result.Failures = append(result.Failures, RestoreFailure{Path: job.Entry.Path, Err: outcome.err})
+	case outcome.skipped:
+		result.Skipped++
+	case outcome.ran:
+		result.Restored++
+		// Directories are finalized after their contents, so they are
+		// recreated on resume rather than journaled.
+		if opts.Journal != nil && !job.Entry.IsDir() {
+			if journalErr := opts.Journal.MarkCompleted(job.Entry.Path); journalErr != nil {
+				return journalErr
+			}
+		}
+	}
+	if opts.Progress != nil {
+		opts.Progress(RestoreProgress{
+			Index:   outcome.index,
+			Done:    done,
+			Total:   total,
+			Path:    job.Entry.Path,
+			Skipped: outcome.skipped,
+			Err:     outcome.err,
+		})
+	}
+	if outcome.err != nil && !opts.ContinueOnError {
+		return &RestoreEntryError{Path: job.Entry.Path, Err: outcome.err}
+	}
+	return nil
+}
+
+// runRestoreJob performs the action for one job and reports whether it did
+// anything; verify-only runs have nothing to check for entries without
+// stored content.
+func runRestoreJob(job RestoreJob, opts RestoreJobsOptions) (bool, error) {
+	if err := CloudPlaceholderRestoreErrorForEntry(job.Entry); err != nil {
+		return false, err
+	}
+	switch {
+	case opts.Restore != nil:
+		return true, opts.Restore(job)
+	case opts.VerifyOnly:
+		if !job.Entry.HasStoredContent() {
+			return false, nil
+		}
+		return true, CopyStoredEntryContent(io.Discard, opts.Store, opts.Keys, job.Entry)
+	default:
+		return true, RestoreEntry(opts.Store, opts.Keys, job.Entry, job.TargetPath, opts.Metadata)
+	}
+}
@@ -0,0 +1,134 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"baxter/internal/storage"
+)
+
+func TestRestoreJobsRestoresInParallelAndReportsInOrder(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	root := t.TempDir()
+
+	jobs := []RestoreJob{{
+		Entry:      ManifestEntry{Path: "/src", Mode: fs.ModeDir | 0o750, ModTime: time.Now().UTC()},
+		TargetPath: filepath.Join(root, "src"),
+	}}
+	for i := 0; i < 12; i++ {
+		plain := []byte(fmt.Sprintf("file %d", i))
+		sum := sha256.Sum256(plain)
+		entry := ManifestEntry{
+			Path:   fmt.Sprintf("/src/file-%02d.txt", i),
+			Size:   int64(len(plain)),
+			Mode:   0o640,
+			SHA256: hex.EncodeToString(sum[:]),
+		}
+		entry.ObjectKey = ObjectKeyForContentSHA256(entry.SHA256)
+		putStreamedTestObject(t, store, key, entry.ObjectKey, plain)
+		jobs = append(jobs, RestoreJob{Entry: entry, TargetPath: filepath.Join(root, entry.Path)})
+	}
+
+	position := make(map[string]int, len(jobs))
+	for i, job := range jobs {
+		position[job.Entry.Path] = i
+	}
+	var inFlight, maxInFlight atomic.Int32
+	var delivered []int
+	result, err := RestoreJobs(jobs, RestoreJobsOptions{
+		Store:       store,
+		Keys:        [][]byte{key},
+		Concurrency: 3,
+		Restore: func(job RestoreJob) error {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				seen := maxInFlight.Load()
+				if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+					break
+				}
+			}
+			// Earlier jobs finish last, so ordered delivery has to buffer.
+			time.Sleep(time.Duration(len(jobs)-position[job.Entry.Path]) * time.Millisecond)
+			return RestoreEntry(store, [][]byte{key}, job.Entry, job.TargetPath, RestoreMetadataOptions{})
+		},
+		Progress: func(update RestoreProgress) {
+			delivered = append(delivered, update.Index)
+			if update.Done != len(delivered) || update.Total != len(jobs) {
+				t.Errorf("progress counters mismatch: done=%d total=%d after %d updates", update.Done, update.Total, len(delivered))
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("restore jobs: %v", err)
+	}
+	if result.Restored != len(jobs) || len(result.Failures) != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := maxInFlight.Load(); got < 2 || got > 3 {
+		t.Fatalf("expected between 2 and 3 jobs in flight, got %d", got)
+	}
+	for i, index := range delivered {
+		if index != i {
+			t.Fatalf("progress delivered out of order: %v", delivered)
+		}
+	}
+	for _, job := range jobs[1:] {
+		if _, err := os.Stat(job.TargetPath); err != nil {
+			t.Fatalf("restored file missing: %v", err)
+		}
+	}
+	if info, err := os.Stat(jobs[0].TargetPath); err != nil {
+		t.Fatalf("stat restored directory: %v", err)
+	} else if info.Mode().Perm() != 0o750 {
+		t.Fatalf("directory metadata not applied: got %v", info.Mode().Perm())
+	}
+}
+
+func TestRestoreJobsFailFastAndContinueOnError(t *testing.T) {
+	errBroken := errors.New("broken entry")
+	jobs := make([]RestoreJob, 0, 20)
+	for i := 0; i < 20; i++ {
+		jobs = append(jobs, RestoreJob{Entry: ManifestEntry{Path: fmt.Sprintf("/src/%02d", i), Mode: 0o644}})
+	}
+	restore := func(ran *atomic.Int32) func(RestoreJob) error {
+		return func(job RestoreJob) error {
+			ran.Add(1)
+			if job.Entry.Path == "/src/02" || job.Entry.Path == "/src/07" {
+				return errBroken
+			}
+			return nil
+		}
+	}
+
+	var failFastRan atomic.Int32
+	result, err := RestoreJobs(jobs, RestoreJobsOptions{Concurrency: 2, Restore: restore(&failFastRan)})
+	var entryErr *RestoreEntryError
+	if !errors.As(err, &entryErr) || entryErr.Path != "/src/02" || !errors.Is(err, errBroken) {
+		t.Fatalf("expected entry error for /src/02, got %v", err)
+	}
+	if len(result.Failures) != 1 || int(failFastRan.Load()) == len(jobs) {
+		t.Fatalf("fail-fast kept going: failures=%d ran=%d", len(result.Failures), failFastRan.Load())
+	}
+
+	var continueRan atomic.Int32
+	result, err = RestoreJobs(jobs, RestoreJobsOptions{Concurrency: 2, ContinueOnError: true, Restore: restore(&continueRan)})
+	if !errors.As(err, &entryErr) || entryErr.Path != "/src/02" {
+		t.Fatalf("expected first failure to be reported, got %v", err)
+	}
+	if int(continueRan.Load()) != len(jobs) || result.Restored != len(jobs)-2 {
+		t.Fatalf("continue-on-error stopped early: ran=%d restored=%d", continueRan.Load(), result.Restored)
+	}
+	if len(result.Failures) != 2 || result.Failures[0].Path != "/src/02" || result.Failures[1].Path != "/src/07" {
+		t.Fatalf("unexpected failures: %+v", result.Failures)
+	}
+}
@@ -0,0 +1,115 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"baxter/internal/storage"
+)
+
+var errRestoreLinkEscapes = errors.New("restore symlink target escapes destination root")
+
+// RestoreEntry recreates entry at targetPath according to its kind, applying
+// the metadata selected by opts. Directories are created owner-writable so
+// their contents can be restored into them; call ApplyRestoredDirectoryMetadata
+// for each directory once everything below it has been written, deepest first.
+func RestoreEntry(store storage.ObjectStore, keys [][]byte, entry ManifestEntry, targetPath string, opts RestoreMetadataOptions) error {
+	switch {
+	case entry.IsDir():
+		if err := os.MkdirAll(targetPath, 0o755); err != nil {
+			return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+		}
+		return nil
+	case entry.IsSymlink():
+		return restoreSymlink(entry, targetPath, opts)
+	default:
+		return RestoreEntryFile(store, keys, entry, targetPath, opts)
+	}
+}
+
+// ApplyRestoredDirectoryMetadata applies the recorded metadata of a directory
+// entry to targetPath. It is a no-op for other entry kinds.
+func ApplyRestoredDirectoryMetadata(entry ManifestEntry, targetPath string, opts RestoreMetadataOptions) error {
+	if !entry.IsDir() {
+		return nil
+	}
+	return applyRestoredMetadata(entry, targetPath, opts)
+}
+
+// RestoreLinkTarget returns the link target to write for a symlink entry
+// restored at targetPath. Without toDir the recorded target is kept. With toDir,
+// absolute targets are rebased under toDir like entry paths are, and any target
+// that would resolve outside toDir is rejected.
+func RestoreLinkTarget(entry ManifestEntry, targetPath string, toDir string) (string, error) {
+	if strings.TrimSpace(toDir) == "" {
+		return entry.LinkTarget, nil
+	}
+	if strings.TrimSpace(entry.LinkTarget) == "" {
+		return "", fmt.Errorf("symlink %s has no target", entry.Path)
+	}
+
+	cleanToDir := filepath.Clean(toDir)
+	linkTarget := filepath.Clean(entry.LinkTarget)
+	var resolved string
+	if filepath.IsAbs(linkTarget) {
+		linkTarget = filepath.Join(cleanToDir, strings.TrimPrefix(linkTarget, string(filepath.Separator)))
+		resolved = linkTarget
+	} else {
+		resolved = filepath.Join(filepath.Dir(targetPath), linkTarget)
+	}
+
+	relToRoot, err := filepath.Rel(cleanToDir, resolved)
+	if err != nil {
+		return "", err
+	}
+	if relToRoot == ".." || strings.HasPrefix(relToRoot, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s -> %s", errRestoreLinkEscapes, entry.Path, entry.LinkTarget)
+	}
+	return linkTarget, nil
+}
+
+// restoreSymlink creates the link under a temporary name and renames it into
+// place, so an existing target is replaced atomically.
+func restoreSymlink(entry ManifestEntry, targetPath string, opts RestoreMetadataOptions) error {
+	dir := filepath.Dir(targetPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+	}
+	placeholder, err := os.CreateTemp(dir, "."+filepath.Base(targetPath)+".baxter-restore-*")
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+	}
+	tmpPath := placeholder.Name()
+	_ = placeholder.Close()
+	if err := os.Remove(tmpPath); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+	}
+
+	if err := os.Symlink(entry.LinkTarget, tmpPath); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+	}
+	if err := applyRestoredMetadata(entry, tmpPath, opts); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, targetPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("%w %s: %w", ErrRestoreWrite, targetPath, err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes a rename into dir durable. Not every platform can sync a
+// directory, so failures are ignored; the entry itself is already in place.
+func syncDir(dir string) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = f.Sync()
+	_ = f.Close()
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRestoreLinkTargetConfinesTargetsToDestinationRoot(t *testing.T) {
+	toDir := "/restore"
+	targetPath := "/restore/Users/me/docs/link"
+	cases := []struct {
+		name       string
+		linkTarget string
+		toDir      string
+		want       string
+		wantErr    bool
+	}{
+		{name: "in place keeps target", linkTarget: "/etc/hosts", toDir: "", want: "/etc/hosts"},
+		{name: "relative sibling", linkTarget: "notes.txt", toDir: toDir, want: "notes.txt"},
+		{name: "relative parent inside root", linkTarget: "../../me/docs/../notes.txt", toDir: toDir, want: "../../me/notes.txt"},
+		{name: "absolute rebased", linkTarget: "/Users/me/shared", toDir: toDir, want: "/restore/Users/me/shared"},
+		{name: "relative escape", linkTarget: "../../../../etc/passwd", toDir: toDir, wantErr: true},
+	}
+	for _, tc := range cases {
+		entry := ManifestEntry{Path: "/Users/me/docs/link", Mode: fs.ModeSymlink | 0o777, LinkTarget: tc.linkTarget}
+		got, err := RestoreLinkTarget(entry, targetPath, tc.toDir)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("%s: expected escape error, got %q", tc.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: link target mismatch: got %q want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRestoreEntryRecreatesDirectoriesAndSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink behavior differs on windows")
+	}
+
+	root := t.TempDir()
+	dirPath := filepath.Join(root, "locked")
+	linkPath := filepath.Join(root, "link")
+	dirEntry := ManifestEntry{Path: "/src/locked", Mode: fs.ModeDir | 0o500, ModTime: time.Now().UTC()}
+	linkEntry := ManifestEntry{Path: "/src/link", Mode: fs.ModeSymlink | 0o777, LinkTarget: "locked"}
+
+	if err := RestoreEntry(nil, nil, dirEntry, dirPath, RestoreMetadataOptions{}); err != nil {
+		t.Fatalf("restore directory: %v", err)
+	}
+	// The directory stays writable until its metadata is applied.
+	mustWriteTestFile(t, filepath.Join(dirPath, "child.txt"), []byte("child"))
+	if err := ApplyRestoredDirectoryMetadata(dirEntry, dirPath, RestoreMetadataOptions{}); err != nil {
+		t.Fatalf("apply directory metadata: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(dirPath, 0o700) })
+	if info, err := os.Stat(dirPath); err != nil {
+		t.Fatalf("stat restored directory: %v", err)
+	} else if info.Mode().Perm() != 0o500 {
+		t.Fatalf("directory mode mismatch: got %v want %v", info.Mode().Perm(), fs.FileMode(0o500))
+	}
+
+	if err := os.WriteFile(linkPath, []byte("stale"), 0o600); err != nil {
+		t.Fatalf("write existing target: %v", err)
+	}
+	if err := RestoreEntry(nil, nil, linkEntry, linkPath, RestoreMetadataOptions{}); err != nil {
+		t.Fatalf("restore symlink: %v", err)
+	}
+	if target, err := os.Readlink(linkPath); err != nil {
+		t.Fatalf("read restored link: %v", err)
+	} else if target != "locked" {
+		t.Fatalf("link target mismatch: got %q", target)
+	}
+}
+
+func TestResolveRestoreSelectionExpandsDirectoryEntries(t *testing.T) {
+	m := &Manifest{Entries: []ManifestEntry{
+		{Path: "/src/docs", Mode: fs.ModeDir | 0o755},
+		{Path: "/src/docs/a.txt"},
+		{Path: "/src/docs-other.txt"},
+	}}
+
+	selection, err := ResolveRestoreSelection(m, "/src/docs")
+	if err != nil {
+		t.Fatalf("resolve selection: %v", err)
+	}
+	if !selection.IsDirectory || len(selection.Entries) != 2 {
+		t.Fatalf("expected directory selection with two entries, got %#v", selection)
+	}
+	if selection.Entries[0].Path != "/src/docs" || selection.Entries[1].Path != "/src/docs/a.txt" {
+		t.Fatalf("unexpected selection entries: %#v", selection.Entries)
+	}
+}
@@ -0,0 +1,198 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrRestoreJournalNotFound = errors.New("restore journal not found")
+
+// RestoreJournal records the progress of a restore so an interrupted run can
+// resume without re-downloading entries that are already in place. On disk it
+// is an append-only JSON lines file: the header record followed by one record
+// per completed entry, each synced before the next entry starts.
+type RestoreJournal struct {
+	ID                string                 `json:"id"`
+	SourcePath        string                 `json:"source_path"`
+	ToDir             string                 `json:"to_dir,omitempty"`
+	Overwrite         bool                   `json:"overwrite,omitempty"`
+	Metadata          RestoreMetadataOptions `json:"metadata"`
+	ManifestCreatedAt time.Time              `json:"manifest_created_at"`
+	CreatedAt         time.Time              `json:"created_at"`
+
+	path      string
+	file      *os.File
+	completed map[string]bool
+}
+
+type restoreJournalRecord struct {
+	Done string `json:"done"`
+}
+
+// CreateRestoreJournal starts a new journal in dir for the restore described
+// by header. The ID and creation time are assigned here.
+func CreateRestoreJournal(dir string, header RestoreJournal) (*RestoreJournal, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, errors.New("restore journal directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	header.CreatedAt = time.Now().UTC()
+	header.ID = header.CreatedAt.Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+	header.ManifestCreatedAt = header.ManifestCreatedAt.UTC()
+
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, header.ID+".journal")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	journal := header
+	journal.path = path
+	journal.file = f
+	journal.completed = make(map[string]bool)
+	return &journal, nil
+}
+
+// OpenRestoreJournal loads the journal with the given ID from dir and reopens
+// it for appending. A trailing record torn by a crash is discarded.
+func OpenRestoreJournal(dir string, id string) (*RestoreJournal, error) {
+	id = strings.TrimSpace(id)
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid restore journal id %q", id)
+	}
+	path := filepath.Join(dir, id+".journal")
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrRestoreJournalNotFound, id)
+		}
+		return nil, err
+	}
+
+	journal, validLen, err := readRestoreJournal(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("read restore journal %s: %w", id, err)
+	}
+	if err := f.Truncate(validLen); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(validLen, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	journal.path = path
+	journal.file = f
+	return journal, nil
+}
+
+func readRestoreJournal(r io.Reader) (*RestoreJournal, int64, error) {
+	reader := bufio.NewReader(r)
+	var validLen int64
+	var journal *RestoreJournal
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			trimmed := bytes.TrimSpace(line)
+			if journal == nil {
+				var header RestoreJournal
+				if err := json.Unmarshal(trimmed, &header); err != nil {
+					return nil, 0, fmt.Errorf("decode header: %w", err)
+				}
+				header.completed = make(map[string]bool)
+				journal = &header
+			} else {
+				var record restoreJournalRecord
+				if err := json.Unmarshal(trimmed, &record); err != nil {
+					return nil, 0, fmt.Errorf("decode record: %w", err)
+				}
+				journal.completed[record.Done] = true
+			}
+			validLen += int64(len(line))
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, 0, err
+		}
+	}
+	if journal == nil {
+		return nil, 0, errors.New("missing header")
+	}
+	return journal, validLen, nil
+}
+
+// ManifestSelector returns a snapshot selector that resolves to the manifest
+// the journal was started from.
+func (j *RestoreJournal) ManifestSelector() string {
+	return j.ManifestCreatedAt.UTC().Format(time.RFC3339Nano)
+}
+
+// Completed reports whether the entry at path was fully restored.
+func (j *RestoreJournal) Completed(path string) bool {
+	return j.completed[filepath.Clean(path)]
+}
+
+// MarkCompleted durably records that the entry at path has been restored.
+func (j *RestoreJournal) MarkCompleted(path string) error {
+	cleanPath := filepath.Clean(path)
+	line, err := json.Marshal(restoreJournalRecord{Done: cleanPath})
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write restore journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("sync restore journal: %w", err)
+	}
+	j.completed[cleanPath] = true
+	return nil
+}
+
+// Close releases the journal file and keeps it for a later resume.
+func (j *RestoreJournal) Close() error {
+	return j.file.Close()
+}
+
+// Remove closes and deletes the journal once its restore has finished.
+func (j *RestoreJournal) Remove() error {
+	_ = j.file.Close()
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
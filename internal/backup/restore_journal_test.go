@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRestoreJournalRoundTripAndTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	manifestCreatedAt := time.Date(2026, time.January, 2, 3, 4, 5, 600, time.UTC)
+	journal, err := CreateRestoreJournal(dir, RestoreJournal{
+		SourcePath:        "/src",
+		ToDir:             "/restore",
+		Metadata:          RestoreMetadataOptions{SkipOwner: true},
+		ManifestCreatedAt: manifestCreatedAt,
+	})
+	if err != nil {
+		t.Fatalf("create journal: %v", err)
+	}
+	if err := journal.MarkCompleted("/src/a.txt"); err != nil {
+		t.Fatalf("mark completed: %v", err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatalf("close journal: %v", err)
+	}
+
+	// Simulate a crash in the middle of appending a record.
+	path := filepath.Join(dir, journal.ID+".journal")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open journal file: %v", err)
+	}
+	if _, err := f.WriteString(`{"done":"/src/b.t`); err != nil {
+		t.Fatalf("write torn record: %v", err)
+	}
+	_ = f.Close()
+
+	reopened, err := OpenRestoreJournal(dir, journal.ID)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	if reopened.SourcePath != "/src" || reopened.ToDir != "/restore" || !reopened.Metadata.SkipOwner {
+		t.Fatalf("unexpected journal header: %+v", reopened)
+	}
+	if !reopened.Completed("/src/a.txt") || reopened.Completed("/src/b.txt") {
+		t.Fatalf("unexpected completed set: %v", reopened.completed)
+	}
+	if got, err := time.Parse(time.RFC3339, reopened.ManifestSelector()); err != nil || !got.Equal(manifestCreatedAt) {
+		t.Fatalf("manifest selector does not round-trip: %q err=%v", reopened.ManifestSelector(), err)
+	}
+	if err := reopened.MarkCompleted("/src/b.txt"); err != nil {
+		t.Fatalf("mark after reopen: %v", err)
+	}
+	_ = reopened.Close()
+
+	again, err := OpenRestoreJournal(dir, journal.ID)
+	if err != nil {
+		t.Fatalf("reopen journal: %v", err)
+	}
+	if !again.Completed("/src/a.txt") || !again.Completed("/src/b.txt") {
+		t.Fatalf("expected both entries completed: %v", again.completed)
+	}
+	if err := again.Remove(); err != nil {
+		t.Fatalf("remove journal: %v", err)
+	}
+	if _, err := OpenRestoreJournal(dir, journal.ID); !errors.Is(err, ErrRestoreJournalNotFound) {
+		t.Fatalf("expected not found after remove, got %v", err)
+	}
+}
+
+func TestOpenRestoreJournalRejectsPathLikeIDs(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"", "../outside", "nested/id", ".hidden"} {
+		if _, err := OpenRestoreJournal(dir, id); err == nil {
+			t.Fatalf("expected invalid id error for %q", id)
+		}
+	}
+}
@@ -17,7 +17,7 @@ type RestoreSelection struct {
 func ResolveRestoreSelection(m *Manifest, requestedPath string) (RestoreSelection, error) {
 	for _, candidate := range restoreLookupCandidates(requestedPath) {
 		entry, err := FindEntryByPath(m, candidate)
-		if err == nil {
+		if err == nil && !entry.IsDir() {
 			return RestoreSelection{
 				SourcePath: filepath.Clean(entry.Path),
 				Entries:    []ManifestEntry{entry},
@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"baxter/internal/config"
+)
+
+// SnapshotKeepRules are generational retention rules in the style of restic's
+// keep-* options. Each bucket rule keeps the newest snapshot of each of the
+// last N hours, days, ISO weeks, months or years that have a snapshot.
+type SnapshotKeepRules struct {
+	Hourly  int
+	Daily   int
+	Weekly  int
+	Monthly int
+	Yearly  int
+	// Within keeps every snapshot taken within this long of the newest one.
+	Within time.Duration
+	// Tags keeps every snapshot carrying one of these tags.
+	Tags []string
+}
+
+func (r SnapshotKeepRules) isZero() bool {
+	return r.Hourly <= 0 && r.Daily <= 0 && r.Weekly <= 0 && r.Monthly <= 0 && r.Yearly <= 0 &&
+		r.Within <= 0 && len(r.Tags) == 0
+}
+
+// SnapshotRetentionDecision explains what a retention policy does with one
+// snapshot. Reasons names every rule that keeps it, or why it is pruned.
+type SnapshotRetentionDecision struct {
+	Snapshot ManifestSnapshot
+	Keep     bool
+	Reasons  []string
+}
+
+const (
+	retentionReasonKeepAll    = "keep-all"
+	retentionReasonLast       = "last"
+	retentionReasonWithin     = "within"
+	retentionReasonIncomplete = "incomplete"
+	retentionReasonMaxAge     = "max-age"
+	retentionReasonNoRule     = "no-rule"
+	retentionReasonLatest     = "latest"
+)
+
+// SnapshotKeepRulesFromConfig converts the generational settings of cfg.
+func SnapshotKeepRulesFromConfig(cfg config.RetentionConfig) SnapshotKeepRules {
+	var tags []string
+	for _, tag := range cfg.KeepTags {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return SnapshotKeepRules{
+		Hourly:  cfg.KeepHourly,
+		Daily:   cfg.KeepDaily,
+		Weekly:  cfg.KeepWeekly,
+		Monthly: cfg.KeepMonthly,
+		Yearly:  cfg.KeepYearly,
+		Within:  cfg.KeepWithinDuration(),
+		Tags:    tags,
+	}
+}
+
+// SnapshotPrunePolicyFromConfig builds the prune policy described by cfg.
+func SnapshotPrunePolicyFromConfig(cfg config.RetentionConfig) SnapshotPrunePolicy {
+	return SnapshotPrunePolicy{
+		Retain:     cfg.ManifestSnapshots,
+		MaxAgeDays: cfg.ManifestMaxAgeDays,
+		Keep:       SnapshotKeepRulesFromConfig(cfg),
+	}
+}
+
+type retentionBucketRule struct {
+	name   string
+	keep   int
+	bucket func(time.Time) string
+}
+
+// planSnapshotRetention decides the fate of each snapshot, newest first as
+// listed by ListSnapshotManifests. A snapshot is kept when Retain or any keep
+// rule selects it; with no rules at all every snapshot is kept. MaxAgeDays
+// then prunes older snapshots whatever kept them, except tagged ones.
+func planSnapshotRetention(snapshots []ManifestSnapshot, policy SnapshotPrunePolicy) []SnapshotRetentionDecision {
+	now := policy.Now.UTC()
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+	var cutoff time.Time
+	if policy.MaxAgeDays > 0 {
+		cutoff = now.AddDate(0, 0, -policy.MaxAgeDays)
+	}
+	loc := policy.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	rules := policy.Keep
+	buckets := []retentionBucketRule{
+		{name: "hourly", keep: rules.Hourly, bucket: func(t time.Time) string { return t.Format("2006-01-02T15") }},
+		{name: "daily", keep: rules.Daily, bucket: func(t time.Time) string { return t.Format("2006-01-02") }},
+		{name: "weekly", keep: rules.Weekly, bucket: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%04d-W%02d", year, week)
+		}},
+		{name: "monthly", keep: rules.Monthly, bucket: func(t time.Time) string { return t.Format("2006-01") }},
+		{name: "yearly", keep: rules.Yearly, bucket: func(t time.Time) string { return t.Format("2006") }},
+	}
+	remaining := make([]int, len(buckets))
+	lastBucket := make([]string, len(buckets))
+	for i, rule := range buckets {
+		remaining[i] = rule.keep
+	}
+	keepAll := policy.Retain <= 0 && rules.isZero()
+
+	var newest time.Time
+	if latest, ok := LatestCompleteSnapshot(snapshots); ok {
+		newest = latest.CreatedAt
+	}
+
+	decisions := make([]SnapshotRetentionDecision, 0, len(snapshots))
+	complete := 0
+	for _, snapshot := range snapshots {
+		decision := SnapshotRetentionDecision{Snapshot: snapshot}
+		// Incomplete snapshots hold objects an interrupted run will resume
+		// from; the next committed run removes them.
+		if snapshot.Incomplete {
+			decision.Keep = true
+			decision.Reasons = []string{retentionReasonIncomplete}
+			decisions = append(decisions, decision)
+			continue
+		}
+
+		var reasons []string
+		if keepAll {
+			reasons = append(reasons, retentionReasonKeepAll)
+		}
+		if policy.Retain > 0 && complete < policy.Retain {
+			reasons = append(reasons, retentionReasonLast)
+		}
+		complete++
+		local := snapshot.CreatedAt.In(loc)
+		for i, rule := range buckets {
+			if remaining[i] <= 0 {
+				continue
+			}
+			if bucket := rule.bucket(local); bucket != lastBucket[i] {
+				lastBucket[i] = bucket
+				remaining[i]--
+				reasons = append(reasons, rule.name)
+			}
+		}
+		if rules.Within > 0 && !snapshot.CreatedAt.Before(newest.Add(-rules.Within)) {
+			reasons = append(reasons, retentionReasonWithin)
+		}
+		tagged := false
+		for _, tag := range rules.Tags {
+			if slices.Contains(snapshot.Tags, tag) {
+				reasons = append(reasons, "tag:"+tag)
+				tagged = true
+			}
+		}
+
+		switch {
+		case len(reasons) == 0:
+			decision.Reasons = []string{retentionReasonNoRule}
+		case !tagged && !cutoff.IsZero() && snapshot.CreatedAt.Before(cutoff):
+			decision.Reasons = []string{retentionReasonMaxAge}
+		default:
+			decision.Keep = true
+			decision.Reasons = reasons
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions
+}
@@ -2,9 +2,13 @@ package backup
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"os"
 	"strings"
 	"sync"
@@ -14,6 +18,7 @@ import (
 	"baxter/internal/config"
 	"baxter/internal/crypto"
 	"baxter/internal/recovery"
+	"baxter/internal/repolock"
 	"baxter/internal/storage"
 )
 
@@ -40,38 +45,58 @@ type RunOptions struct {
 	BackupSetID        string
 	Store              storage.ObjectStore
 	Progress           func(ProgressUpdate)
+	// StatCachePath enables hash reuse for files whose stat identity is
+	// unchanged. Rehash forces a full hash of every file for this run, and
+	// RehashInterval forces one whenever the last full rehash is older.
+	StatCachePath  string
+	Rehash         bool
+	RehashInterval time.Duration
+	// CheckpointPath enables an upload checkpoint, so a run that stops
+	// partway resumes without uploading the same content again.
+	CheckpointPath string
+	// SnapshotKeep adds generational retention rules to SnapshotRetention.
+	SnapshotKeep SnapshotKeepRules
+	// Tags are recorded on the snapshot this run writes.
+	Tags []string
+	// Pause, when set, lets another goroutine park the upload workers
+	// between objects, including between the chunks of a large file.
+	Pause *PauseGate
+	// Lock is the repository lock the run holds. A run whose lock was lost
+	// fails before committing: gc may have removed what it stored.
+	Lock *repolock.Lock
 }
 
 type RunResult struct {
 	Uploaded int
-	Removed  int
-	Total    int
+	// Reused counts changed entries whose content was already stored, by an
+	// interrupted earlier run or under the same content key.
+	Reused  int
+	Removed int
+	Total   int
+	// SnapshotID names the snapshot the run committed.
+	SnapshotID string
 }
 
-func Run(cfg *config.Config, opts RunOptions) (RunResult, error) {
+// Run backs up the configured roots. Cancelling ctx stops the scan, the
+// uploads and the catch-up of replicas; a run stopped that way leaves the
+// previous manifest and recovery metadata as they were, keeps its upload
+// checkpoint and saves what it stored as an incomplete local snapshot. Once
+// every object is stored the snapshot is committed even if ctx was cancelled
+// meanwhile.
+//
+// With a storage.ReplicatedStore every destination receives the objects, the
+// snapshot manifest and the recovery metadata. A destination that fails is
+// skipped for the rest of the run, and one that missed earlier runs is caught
+// up; see catchUpReplicas.
+func Run(ctx context.Context, cfg *config.Config, opts RunOptions) (RunResult, error) {
 	if cfg == nil {
 		return RunResult{}, fmt.Errorf("config is required")
 	}
 	if len(cfg.BackupRoots) == 0 {
 		return RunResult{}, fmt.Errorf("no backup_roots configured")
 	}
-	if opts.ManifestPath == "" {
-		return RunResult{}, fmt.Errorf("manifest path is required")
-	}
-	if opts.Store == nil {
-		return RunResult{}, fmt.Errorf("object store is required")
-	}
-	if opts.SnapshotDir == "" {
-		return RunResult{}, fmt.Errorf("snapshot directory is required")
-	}
-	if len(opts.EncryptionKey) == 0 {
-		return RunResult{}, fmt.Errorf("encryption key is required")
-	}
-	if len(opts.KDFSalt) == 0 {
-		return RunResult{}, fmt.Errorf("kdf salt is required")
-	}
-	if opts.BackupSetID == "" {
-		return RunResult{}, fmt.Errorf("backup set id is required")
+	if err := opts.validate(); err != nil {
+		return RunResult{}, err
 	}
 
 	previous, err := LoadManifest(opts.ManifestPath)
@@ -79,49 +104,154 @@ func Run(cfg *config.Config, opts RunOptions) (RunResult, error) {
 		return RunResult{}, fmt.Errorf("load manifest: %w", err)
 	}
 
-	current, err := BuildManifestWithOptions(cfg.BackupRoots, BuildOptions{
+	var statCache *StatCache
+	if opts.StatCachePath != "" {
+		statCache, err = LoadStatCache(opts.StatCachePath)
+		if err != nil {
+			return RunResult{}, fmt.Errorf("load stat cache: %w", err)
+		}
+	}
+	scanStart := time.Now().UTC()
+	rehash := opts.Rehash || statCache.RehashDue(opts.RehashInterval, scanStart)
+
+	current, err := BuildManifestWithOptions(ctx, cfg.BackupRoots, BuildOptions{
 		ExcludePaths: cfg.ExcludePaths,
 		ExcludeGlobs: cfg.ExcludeGlobs,
+		StatCache:    statCache,
+		Rehash:       rehash,
 	})
 	if err != nil {
 		return RunResult{}, fmt.Errorf("build manifest: %w", err)
 	}
+	if statCache != nil {
+		if rehash {
+			statCache.LastFullRehash = scanStart
+		}
+		if err := statCache.Save(); err != nil {
+			return RunResult{}, fmt.Errorf("save stat cache: %w", err)
+		}
+	}
+	// Stream entries have no file under the roots; they stay until replaced.
+	current.Entries = append(current.Entries, streamEntries(previous)...)
+	sortManifestEntries(current)
 	AssignObjectKeys(previous, current)
+	current.Tags = opts.Tags
+
+	checkpoint, err := OpenUploadCheckpoint(opts.CheckpointPath, opts.BackupSetID)
+	if err != nil {
+		return RunResult{}, fmt.Errorf("open upload checkpoint: %w", err)
+	}
+	defer checkpoint.Close()
 
 	plan := PlanChanges(previous, current)
-	if err := uploadChangedEntries(plan.NewOrChanged, opts); err != nil {
+	uploadOpts := opts
+	uploadOpts.Store = storage.WithContext(ctx, opts.Store)
+	// A cancel that arrives once every upload succeeded does not stop the
+	// commit: uploadChangedEntries only returns nil when all are stored.
+	reused, err := uploadChangedEntries(ctx, plan.NewOrChanged, uploadOpts, newChunkIndex(previous), checkpoint)
+	if err == nil {
+		applyUploadedChunks(current, plan.NewOrChanged)
+		if replicated, ok := uploadOpts.Store.(*storage.ReplicatedStore); ok {
+			err = catchUpReplicas(ctx, replicated, current)
+		}
+	}
+	if lockErr := opts.Lock.Err(); lockErr != nil {
+		// What this run stored may be gone, so a later run must not resume
+		// from the checkpoint or an incomplete snapshot.
+		if removeErr := checkpoint.Remove(); removeErr != nil {
+			return RunResult{}, errors.Join(lockErr, fmt.Errorf("remove upload checkpoint: %w", removeErr))
+		}
+		return RunResult{}, lockErr
+	}
+	if err != nil {
+		if saveErr := saveIncompleteSnapshot(opts.SnapshotDir, current, plan.NewOrChanged, checkpoint); saveErr != nil {
+			return RunResult{}, errors.Join(err, fmt.Errorf("save incomplete snapshot: %w", saveErr))
+		}
+		return RunResult{}, err
+	}
+
+	snapshot, err := commitSnapshot(opts, current)
+	if err != nil {
 		return RunResult{}, err
 	}
+	if err := removeIncompleteSnapshots(opts.SnapshotDir); err != nil {
+		return RunResult{}, fmt.Errorf("remove incomplete snapshots: %w", err)
+	}
+	if err := checkpoint.Remove(); err != nil {
+		return RunResult{}, fmt.Errorf("remove upload checkpoint: %w", err)
+	}
+	if err := pruneSnapshots(opts); err != nil {
+		return RunResult{}, err
+	}
+
+	return RunResult{
+		Uploaded:   countStoredContentEntries(plan.NewOrChanged) - reused,
+		Reused:     reused,
+		Removed:    len(plan.RemovedPaths),
+		Total:      len(current.Entries),
+		SnapshotID: snapshot.ID,
+	}, nil
+}
+
+func (o RunOptions) validate() error {
+	if o.ManifestPath == "" {
+		return fmt.Errorf("manifest path is required")
+	}
+	if o.Store == nil {
+		return fmt.Errorf("object store is required")
+	}
+	if o.SnapshotDir == "" {
+		return fmt.Errorf("snapshot directory is required")
+	}
+	if len(o.EncryptionKey) == 0 {
+		return fmt.Errorf("encryption key is required")
+	}
+	if len(o.KDFSalt) == 0 {
+		return fmt.Errorf("kdf salt is required")
+	}
+	if o.BackupSetID == "" {
+		return fmt.Errorf("backup set id is required")
+	}
+	return nil
+}
 
+// commitSnapshot records current as the latest snapshot: its encrypted
+// manifest and the recovery metadata go to the store first, then the local
+// manifest and snapshot copy are written. Nothing is written once opts.Lock
+// is lost.
+func commitSnapshot(opts RunOptions, current *Manifest) (ManifestSnapshot, error) {
+	if err := opts.Lock.Err(); err != nil {
+		return ManifestSnapshot{}, err
+	}
 	snapshot, err := ReserveSnapshotManifest(opts.SnapshotDir, current)
 	if err != nil {
-		return RunResult{}, fmt.Errorf("reserve snapshot manifest: %w", err)
+		return ManifestSnapshot{}, fmt.Errorf("reserve snapshot manifest: %w", err)
 	}
 	if err := WriteEncryptedSnapshotManifest(opts.Store, snapshot.ID, current, opts.EncryptionKey); err != nil {
-		return RunResult{}, err
+		return ManifestSnapshot{}, err
 	}
 	if err := writeRecoveryMetadata(opts, snapshot.ID, current.CreatedAt); err != nil {
-		return RunResult{}, err
+		return ManifestSnapshot{}, err
 	}
 	if err := SaveManifest(opts.ManifestPath, current); err != nil {
-		return RunResult{}, fmt.Errorf("save manifest: %w", err)
+		return ManifestSnapshot{}, fmt.Errorf("save manifest: %w", err)
 	}
 	if err := SaveSnapshotManifestAt(snapshot, current); err != nil {
-		return RunResult{}, fmt.Errorf("save snapshot manifest: %w", err)
+		return ManifestSnapshot{}, fmt.Errorf("save snapshot manifest: %w", err)
 	}
+	return snapshot, nil
+}
+
+func pruneSnapshots(opts RunOptions) error {
 	if _, err := PruneSnapshotManifestsWithPolicy(opts.SnapshotDir, SnapshotPrunePolicy{
 		Retain:     opts.SnapshotRetention,
 		MaxAgeDays: opts.SnapshotMaxAgeDays,
 		Now:        opts.SnapshotPruneNow,
+		Keep:       opts.SnapshotKeep,
 	}); err != nil {
-		return RunResult{}, fmt.Errorf("prune snapshot manifests: %w", err)
+		return fmt.Errorf("prune snapshot manifests: %w", err)
 	}
-
-	return RunResult{
-		Uploaded: countStoredContentEntries(plan.NewOrChanged),
-		Removed:  len(plan.RemovedPaths),
-		Total:    len(current.Entries),
-	}, nil
+	return nil
 }
 
 func (o RunOptions) effectiveUploadMaxAttempts() int {
@@ -138,11 +268,19 @@ func (o RunOptions) effectiveUploadConcurrency() int {
 	return o.UploadConcurrency
 }
 
-func uploadChangedEntries(entries []ManifestEntry, opts RunOptions) error {
-	uploadable := make([]ManifestEntry, 0, len(entries))
-	for _, entry := range entries {
+// uploadChangedEntries stores the content of entries and reports how many of
+// them were already stored. Each stored entry is recorded in checkpoint.
+func uploadChangedEntries(ctx context.Context, entries []ManifestEntry, opts RunOptions, chunks *chunkIndex, checkpoint *UploadCheckpoint) (int, error) {
+	if chunks == nil {
+		chunks = newChunkIndex(nil)
+	}
+	if checkpoint == nil {
+		checkpoint, _ = OpenUploadCheckpoint("", opts.BackupSetID)
+	}
+	uploadable := make([]int, 0, len(entries))
+	for i, entry := range entries {
 		if entry.HasStoredContent() {
-			uploadable = append(uploadable, entry)
+			uploadable = append(uploadable, i)
 		}
 	}
 
@@ -151,16 +289,16 @@ func uploadChangedEntries(entries []ManifestEntry, opts RunOptions) error {
 		opts.Progress(ProgressUpdate{Total: total})
 	}
 	if total == 0 {
-		return nil
+		return 0, nil
 	}
 
 	type uploadJob struct {
-		entry ManifestEntry
+		index int
 	}
 
 	jobs := make(chan uploadJob)
 	errCh := make(chan error, 1)
-	var uploaded atomic.Int32
+	var uploaded, reused atomic.Int32
 	var once sync.Once
 	workerCount := opts.effectiveUploadConcurrency()
 	if workerCount > total {
@@ -173,20 +311,18 @@ func uploadChangedEntries(entries []ManifestEntry, opts RunOptions) error {
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				entry := job.entry
-				plain, err := readEntryContent(entry)
-				if err != nil {
+				if err := opts.Pause.Wait(ctx); err != nil {
 					once.Do(func() { errCh <- err })
 					return
 				}
-				encrypted, err := crypto.EncryptBytes(opts.EncryptionKey, plain)
+				entry := &entries[job.index]
+				alreadyStored, err := uploadEntry(ctx, entry, opts, chunks, checkpoint)
 				if err != nil {
-					once.Do(func() { errCh <- fmt.Errorf("encrypt file %s: %w", entry.Path, err) })
+					once.Do(func() { errCh <- err })
 					return
 				}
-				if err := putObjectWithRetry(opts.Store, entry.ObjectKey, encrypted, opts.effectiveUploadMaxAttempts()); err != nil {
-					once.Do(func() { errCh <- fmt.Errorf("store object %s: %w", entry.Path, err) })
-					return
+				if alreadyStored {
+					reused.Add(1)
 				}
 				if opts.Progress != nil {
 					opts.Progress(ProgressUpdate{
@@ -199,13 +335,17 @@ func uploadChangedEntries(entries []ManifestEntry, opts RunOptions) error {
 		}()
 	}
 
-	for _, entry := range uploadable {
+	for _, index := range uploadable {
 		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return int(reused.Load()), ctx.Err()
 		case err := <-errCh:
 			close(jobs)
 			wg.Wait()
-			return err
-		case jobs <- uploadJob{entry: entry}:
+			return int(reused.Load()), err
+		case jobs <- uploadJob{index: index}:
 		}
 	}
 	close(jobs)
@@ -213,13 +353,306 @@ func uploadChangedEntries(entries []ManifestEntry, opts RunOptions) error {
 
 	select {
 	case err := <-errCh:
-		return err
+		return int(reused.Load()), err
 	default:
 	}
 
+	return int(reused.Load()), nil
+}
+
+// uploadEntry stores the content of entry unless checkpoint or the store
+// already has it, reporting which was the case.
+func uploadEntry(ctx context.Context, entry *ManifestEntry, opts RunOptions, chunks *chunkIndex, checkpoint *UploadCheckpoint) (bool, error) {
+	if stored, ok := checkpoint.Lookup(*entry); ok && storedObjectsExist(opts.Store, stored) {
+		*entry = stored
+		return true, nil
+	}
+	if shouldChunkEntry(*entry) && strings.TrimSpace(entry.ObjectKey) == "" {
+		if err := uploadChunkedEntry(ctx, entry, opts, chunks); err != nil {
+			return false, err
+		}
+		return false, checkpoint.Record(*entry)
+	}
+	// Content keys name their plaintext, so an object already under the key
+	// holds this content and need not be sent again.
+	if entry.ObjectKey == ObjectKeyForContentSHA256(entry.SHA256) {
+		if exists, err := storage.ObjectExists(opts.Store, entry.ObjectKey); err == nil && exists {
+			return true, checkpoint.Record(*entry)
+		}
+	}
+
+	maxAttempts := opts.effectiveUploadMaxAttempts()
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		retryable, err := streamEntryObject(*entry, opts)
+		if err == nil {
+			return false, checkpoint.Record(*entry)
+		}
+		if !retryable {
+			return false, err
+		}
+		lastErr = err
+	}
+	return false, fmt.Errorf("store object %s: %w", entry.Path, lastErr)
+}
+
+// storedObjectsExist reports whether every object holding the content of
+// entry is in the store. A checkpoint record outlives its objects when they
+// are pruned or lost between runs, so it is only trusted after this check.
+func storedObjectsExist(store storage.ObjectStore, entry ManifestEntry) bool {
+	for _, key := range ResolveObjectKeys(entry) {
+		if exists, err := storage.ObjectExists(store, key); err != nil || !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// streamEntryObject encrypts the source file straight into the store. The
+// source is checked against the manifest before the final segment is sealed,
+// so a file that changed since the scan never lands under its content key.
+// Store failures are reported as retryable; the source is reopened per attempt.
+func streamEntryObject(entry ManifestEntry, opts RunOptions) (bool, error) {
+	f, err := openEntrySource(entry)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	source := &verifyingEntryReader{r: f, entry: entry, hash: sha256.New()}
+	pr, pw := io.Pipe()
+	encryptErr := make(chan error, 1)
+	go func() {
+		err := crypto.EncryptStream(opts.EncryptionKey, pw, source)
+		_ = pw.CloseWithError(err)
+		encryptErr <- err
+	}()
+
+	putErr := storage.PutObjectStream(opts.Store, entry.ObjectKey, pr)
+	_ = pr.Close()
+	if err := <-encryptErr; err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		if source.err != nil {
+			return false, source.err
+		}
+		return false, fmt.Errorf("encrypt file %s: %w", entry.Path, err)
+	}
+	if putErr != nil {
+		return true, putErr
+	}
+	return false, nil
+}
+
+// verifyingEntryReader hashes the source as it is read and replaces EOF with
+// an error when the content no longer matches the manifest entry.
+type verifyingEntryReader struct {
+	r     io.Reader
+	entry ManifestEntry
+	hash  hash.Hash
+	read  int64
+	err   error
+}
+
+func (r *verifyingEntryReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	r.hash.Write(p[:n])
+	switch {
+	case r.read > r.entry.Size:
+		r.err = fmt.Errorf("source file changed during backup: %s size mismatch", r.entry.Path)
+	case errors.Is(err, io.EOF):
+		if r.read != r.entry.Size {
+			r.err = fmt.Errorf("source file changed during backup: %s size mismatch", r.entry.Path)
+		} else if got := hex.EncodeToString(r.hash.Sum(nil)); got != r.entry.SHA256 {
+			r.err = fmt.Errorf("source file changed during backup: %w for %s: got %s want %s", ErrChecksumMismatch, r.entry.Path, got, r.entry.SHA256)
+		}
+	case err != nil:
+		r.err = fmt.Errorf("read file %s: %w", r.entry.Path, err)
+	}
+	if r.err != nil {
+		return n, r.err
+	}
+	return n, err
+}
+
+func uploadChunkedEntry(ctx context.Context, entry *ManifestEntry, opts RunOptions, chunks *chunkIndex) error {
+	f, err := openEntrySource(*entry)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	refs, size, _, err := storeChunks(ctx, io.TeeReader(f, hash), entry.Path, opts, chunks)
+	if err != nil {
+		return err
+	}
+	if size != entry.Size {
+		return fmt.Errorf("source file changed during backup: %s size mismatch", entry.Path)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != entry.SHA256 {
+		return fmt.Errorf("source file changed during backup: %w for %s: got %s want %s", ErrChecksumMismatch, entry.Path, got, entry.SHA256)
+	}
+	entry.Chunks = refs
+	entry.ObjectKey = ""
 	return nil
 }
 
+// storeChunks splits r into content-defined chunks and stores each one that
+// neither the previous manifest nor the store already has. A chunk another
+// worker is storing is waited for before storeChunks returns, so the refs it
+// returns all name stored chunks. It returns the chunk refs in order, the
+// number of bytes read and the number of chunks stored.
+func storeChunks(ctx context.Context, r io.Reader, path string, opts RunOptions, chunks *chunkIndex) ([]ChunkRef, int64, int, error) {
+	source := newChunker(r)
+	var refs []ChunkRef
+	var pending []*chunkClaim
+	var size int64
+	stored := 0
+	for {
+		if err := opts.Pause.Wait(ctx); err != nil {
+			return nil, 0, 0, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, 0, 0, err
+		}
+		data, err := source.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("read %s: %w", path, err)
+		}
+
+		sum := sha256.Sum256(data)
+		ref := ChunkRef{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data))}
+		size += ref.Size
+		refs = append(refs, ref)
+		claim, owner := chunks.claim(ref.SHA256)
+		if !owner {
+			pending = append(pending, claim)
+			continue
+		}
+		uploaded, err := storeChunk(ObjectKeyForChunkSHA256(ref.SHA256), data, opts)
+		claim.finish(err)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("store chunk of %s: %w", path, err)
+		}
+		if uploaded {
+			stored++
+		}
+	}
+
+	for _, claim := range pending {
+		if err := claim.wait(ctx); err != nil {
+			return nil, 0, 0, fmt.Errorf("store chunk of %s: %w", path, err)
+		}
+	}
+	return refs, size, stored, nil
+}
+
+// storeChunk encrypts data under chunkKey unless the store already has it,
+// reporting whether it was uploaded.
+func storeChunk(chunkKey string, data []byte, opts RunOptions) (bool, error) {
+	if exists, err := storage.ObjectExists(opts.Store, chunkKey); err == nil && exists {
+		return false, nil
+	}
+	encrypted, err := crypto.EncryptBytes(opts.EncryptionKey, data)
+	if err != nil {
+		return false, fmt.Errorf("encrypt chunk: %w", err)
+	}
+	if err := putObjectWithRetry(opts.Store, chunkKey, encrypted, opts.effectiveUploadMaxAttempts()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func applyUploadedChunks(current *Manifest, uploaded []ManifestEntry) {
+	chunked := make(map[string][]ChunkRef)
+	for _, entry := range uploaded {
+		if entry.IsChunked() {
+			chunked[entry.Path] = entry.Chunks
+		}
+	}
+	if len(chunked) == 0 {
+		return
+	}
+	for i := range current.Entries {
+		if refs, ok := chunked[current.Entries[i].Path]; ok {
+			current.Entries[i].Chunks = refs
+			current.Entries[i].ObjectKey = ""
+		}
+	}
+}
+
+// chunkIndex tracks the chunks of a run: those the previous manifest
+// references and those claimed by a worker during the run.
+type chunkIndex struct {
+	mu    sync.Mutex
+	known map[string]*chunkClaim
+}
+
+// chunkClaim is the outcome of storing one chunk, known once done is closed.
+type chunkClaim struct {
+	done chan struct{}
+	err  error
+}
+
+// storedChunkClaim stands for chunks the previous manifest references.
+var storedChunkClaim = func() *chunkClaim {
+	claim := &chunkClaim{done: make(chan struct{})}
+	close(claim.done)
+	return claim
+}()
+
+func newChunkIndex(previous *Manifest) *chunkIndex {
+	index := &chunkIndex{known: make(map[string]*chunkClaim)}
+	if previous == nil {
+		return index
+	}
+	for _, entry := range previous.Entries {
+		for _, chunk := range entry.Chunks {
+			index.known[chunk.SHA256] = storedChunkClaim
+		}
+	}
+	return index
+}
+
+// claim returns the claim on the chunk and whether the caller owns it. The
+// owner stores the chunk and calls finish; everyone else waits for that
+// outcome before relying on the chunk.
+func (i *chunkIndex) claim(sha string) (*chunkClaim, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if claim, ok := i.known[sha]; ok {
+		return claim, false
+	}
+	claim := &chunkClaim{done: make(chan struct{})}
+	i.known[sha] = claim
+	return claim, true
+}
+
+// finish records the outcome of storing the chunk. A failed claim stays
+// failed, so every entry sharing the chunk fails with it.
+func (c *chunkClaim) finish(err error) {
+	c.err = err
+	close(c.done)
+}
+
+func (c *chunkClaim) wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func countStoredContentEntries(entries []ManifestEntry) int {
 	count := 0
 	for _, entry := range entries {
@@ -246,27 +679,21 @@ func putObjectWithRetry(store storage.ObjectStore, key string, data []byte, maxA
 	return lastErr
 }
 
-func readEntryContent(entry ManifestEntry) ([]byte, error) {
+func openEntrySource(entry ManifestEntry) (*os.File, error) {
 	if err := cloudPlaceholderRestoreError(entry); err != nil {
 		return nil, err
 	}
 	if err := cloudPlaceholderErrorForPath(entry.Path); err != nil {
 		return nil, err
 	}
-	plain, err := os.ReadFile(entry.Path)
+	f, err := os.Open(entry.Path)
 	if err != nil {
 		if placeholderErr := cloudPlaceholderErrorForPath(entry.Path); placeholderErr != nil {
 			return nil, placeholderErr
 		}
 		return nil, fmt.Errorf("read file %s: %w", entry.Path, err)
 	}
-	if int64(len(plain)) != entry.Size {
-		return nil, fmt.Errorf("source file changed during backup: %s size mismatch", entry.Path)
-	}
-	if err := VerifyEntryContent(entry, plain); err != nil {
-		return nil, fmt.Errorf("source file changed during backup: %w", err)
-	}
-	return plain, nil
+	return f, nil
 }
 
 func writeRecoveryMetadata(opts RunOptions, latestSnapshotID string, now time.Time) error {
@@ -1,6 +1,10 @@
 package backup
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"os"
 	"path/filepath"
@@ -10,6 +14,7 @@ import (
 	"time"
 
 	"baxter/internal/config"
+	"baxter/internal/repolock"
 	"baxter/internal/storage"
 )
 
@@ -84,7 +89,7 @@ func TestRunUploadsAndSavesManifest(t *testing.T) {
 	}
 	store := storage.NewLocalClient(objectsDir)
 
-	result, err := Run(cfg, RunOptions{
+	result, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -96,7 +101,8 @@ func TestRunUploadsAndSavesManifest(t *testing.T) {
 	if err != nil {
 		t.Fatalf("run backup: %v", err)
 	}
-	if result.Uploaded != 1 || result.Removed != 0 || result.Total != 1 {
+	// Total counts the root directory entry alongside the file.
+	if result.Uploaded != 1 || result.Removed != 0 || result.Total != 2 {
 		t.Fatalf("unexpected result: %+v", result)
 	}
 
@@ -147,7 +153,7 @@ func TestRunReportsProgress(t *testing.T) {
 
 	var mu sync.Mutex
 	var updates []ProgressUpdate
-	_, err := Run(cfg, RunOptions{
+	_, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -206,7 +212,7 @@ func TestRunDoesNotWriteLocalSuccessMarkersWhenRemoteSnapshotWriteFails(t *testi
 		failPrefix: RemoteSnapshotManifestKeyPrefix(),
 	}
 
-	if _, err := Run(cfg, RunOptions{
+	if _, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -249,7 +255,7 @@ func TestRunKeepsRemovedFileObjectsForSnapshotRestore(t *testing.T) {
 	}
 	store := storage.NewLocalClient(objectsDir)
 
-	if _, err := Run(cfg, RunOptions{
+	if _, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -265,7 +271,7 @@ func TestRunKeepsRemovedFileObjectsForSnapshotRestore(t *testing.T) {
 		t.Fatalf("remove source file: %v", err)
 	}
 
-	result, err := Run(cfg, RunOptions{
+	result, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -277,7 +283,7 @@ func TestRunKeepsRemovedFileObjectsForSnapshotRestore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("second run backup: %v", err)
 	}
-	if result.Removed != 1 || result.Uploaded != 0 || result.Total != 0 {
+	if result.Removed != 1 || result.Uploaded != 0 || result.Total != 1 {
 		t.Fatalf("unexpected result: %+v", result)
 	}
 
@@ -323,7 +329,7 @@ func TestRunRespectsExcludePathsAndGlobs(t *testing.T) {
 	}
 	store := storage.NewLocalClient(objectsDir)
 
-	result, err := Run(cfg, RunOptions{
+	result, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -335,7 +341,7 @@ func TestRunRespectsExcludePathsAndGlobs(t *testing.T) {
 	if err != nil {
 		t.Fatalf("run backup with exclusions: %v", err)
 	}
-	if result.Uploaded != 1 || result.Removed != 0 || result.Total != 1 {
+	if result.Uploaded != 1 || result.Removed != 0 || result.Total != 2 {
 		t.Fatalf("unexpected result: %+v", result)
 	}
 
@@ -343,7 +349,7 @@ func TestRunRespectsExcludePathsAndGlobs(t *testing.T) {
 	if err != nil {
 		t.Fatalf("load manifest: %v", err)
 	}
-	if len(manifest.Entries) != 1 || manifest.Entries[0].Path != includedPath {
+	if len(manifest.Entries) != 2 || manifest.Entries[0].Path != root || manifest.Entries[1].Path != includedPath {
 		t.Fatalf("unexpected manifest entries: %#v", manifest.Entries)
 	}
 }
@@ -372,7 +378,7 @@ func TestRunPrunesSnapshotsByRetention(t *testing.T) {
 		if err := os.WriteFile(filePath, payload, 0o600); err != nil {
 			t.Fatalf("update source file: %v", err)
 		}
-		if _, err := Run(cfg, RunOptions{
+		if _, err := Run(context.Background(), cfg, RunOptions{
 			ManifestPath:      manifestPath,
 			SnapshotDir:       snapshotDir,
 			SnapshotRetention: 2,
@@ -427,7 +433,7 @@ func TestRunPrunesSnapshotsByMaxAgeDays(t *testing.T) {
 	}
 	store := storage.NewLocalClient(objectsDir)
 
-	if _, err := Run(cfg, RunOptions{
+	if _, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:       manifestPath,
 		SnapshotDir:        snapshotDir,
 		SnapshotRetention:  0,
@@ -478,7 +484,7 @@ func TestRunRetriesTransientPutFailures(t *testing.T) {
 		failPuts: 2,
 	}
 
-	if _, err := Run(cfg, RunOptions{
+	if _, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -518,7 +524,7 @@ func TestRunFailsWhenPutRetriesExhausted(t *testing.T) {
 		failPuts: 3,
 	}
 
-	_, err := Run(cfg, RunOptions{
+	_, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -557,7 +563,7 @@ func TestRunStoresVersionedCompressedPayload(t *testing.T) {
 	}
 	store := storage.NewLocalClient(objectsDir)
 
-	if _, err := Run(cfg, RunOptions{
+	if _, err := Run(context.Background(), cfg, RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -585,15 +591,15 @@ func TestRunStoresVersionedCompressedPayload(t *testing.T) {
 	if len(payload) < 2 {
 		t.Fatalf("payload too short: %d", len(payload))
 	}
-	if payload[0] != 3 {
-		t.Fatalf("unexpected payload version: got %d want 3", payload[0])
+	if payload[0] != 5 {
+		t.Fatalf("unexpected payload version: got %d want 5", payload[0])
 	}
 	if payload[1] != 1 {
 		t.Fatalf("unexpected compression marker: got %d want 1", payload[1])
 	}
 }
 
-func TestReadEntryContentRejectsChangedFile(t *testing.T) {
+func TestStreamEntryObjectRejectsChangedFile(t *testing.T) {
 	root := t.TempDir()
 	filePath := filepath.Join(root, "doc.txt")
 	original := []byte("original")
@@ -614,21 +620,37 @@ func TestReadEntryContentRejectsChangedFile(t *testing.T) {
 		t.Fatalf("update file: %v", err)
 	}
 
-	if _, err := readEntryContent(entry); err == nil {
-		t.Fatal("expected changed file to be rejected")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	entry.ObjectKey = ObjectKeyForContentSHA256(entry.SHA256)
+	retryable, err := streamEntryObject(entry, RunOptions{
+		EncryptionKey: []byte("01234567890123456789012345678901"),
+		Store:         store,
+	})
+	if err == nil || !strings.Contains(err.Error(), "source file changed during backup") {
+		t.Fatalf("expected changed file to be rejected, got: %v", err)
+	}
+	if retryable {
+		t.Fatal("source changes should not be retried")
+	}
+	keys, err := store.ListKeys()
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected no object for changed file, got %v", keys)
 	}
 }
 
 func TestUploadChangedEntriesSkipsCloudPlaceholderEntries(t *testing.T) {
 	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
 
-	err := uploadChangedEntries([]ManifestEntry{{
+	_, err := uploadChangedEntries(context.Background(), []ManifestEntry{{
 		Path:       "/Users/me/Documents/cloud.pdf",
 		SourceKind: manifestSourceKindCloudPlaceholder,
 	}}, RunOptions{
 		EncryptionKey: []byte("01234567890123456789012345678901"),
 		Store:         store,
-	})
+	}, nil, nil)
 	if err != nil {
 		t.Fatalf("upload changed entries: %v", err)
 	}
@@ -641,3 +663,600 @@ func TestUploadChangedEntriesSkipsCloudPlaceholderEntries(t *testing.T) {
 		t.Fatalf("expected no uploaded objects, got %v", keys)
 	}
 }
+
+func TestRunChunksLargeFilesAndUploadsOnlyChangedChunks(t *testing.T) {
+	root := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	snapshotDir := filepath.Join(t.TempDir(), "manifests")
+	objectsDir := filepath.Join(t.TempDir(), "objects")
+	key := []byte("01234567890123456789012345678901")
+
+	filePath := filepath.Join(root, "disk.img")
+	original := chunkTestPayload(12<<20, 3)
+	if err := os.WriteFile(filePath, original, 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	cfg := &config.Config{
+		BackupRoots: []string{root},
+		S3:          config.S3Config{},
+		Encryption:  config.EncryptionConfig{},
+	}
+	store := storage.NewLocalClient(objectsDir)
+	opts := RunOptions{
+		ManifestPath:      manifestPath,
+		SnapshotDir:       snapshotDir,
+		SnapshotRetention: 30,
+		EncryptionKey:     key,
+		KDFSalt:           testKDFSalt,
+		BackupSetID:       "local-test",
+		Store:             store,
+	}
+
+	if _, err := Run(context.Background(), cfg, opts); err != nil {
+		t.Fatalf("first run backup: %v", err)
+	}
+	first, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("load manifest: %v", err)
+	}
+	firstEntry, err := FindEntryByPath(first, filePath)
+	if err != nil {
+		t.Fatalf("find manifest entry: %v", err)
+	}
+	if !firstEntry.IsChunked() || firstEntry.ObjectKey != "" {
+		t.Fatalf("expected chunked entry without whole-file key, got %+v", firstEntry)
+	}
+	chunkKeysBefore, err := store.ListKeysWithPrefix("chunks/")
+	if err != nil {
+		t.Fatalf("list chunk keys: %v", err)
+	}
+	if len(chunkKeysBefore) != len(firstEntry.Chunks) {
+		t.Fatalf("chunk object count mismatch: got %d want %d", len(chunkKeysBefore), len(firstEntry.Chunks))
+	}
+
+	edited := append([]byte(nil), original...)
+	copy(edited[6<<20:], []byte("patched region"))
+	if err := os.WriteFile(filePath, edited, 0o600); err != nil {
+		t.Fatalf("rewrite source file: %v", err)
+	}
+	if _, err := Run(context.Background(), cfg, opts); err != nil {
+		t.Fatalf("second run backup: %v", err)
+	}
+
+	chunkKeysAfter, err := store.ListKeysWithPrefix("chunks/")
+	if err != nil {
+		t.Fatalf("list chunk keys after edit: %v", err)
+	}
+	added := len(chunkKeysAfter) - len(chunkKeysBefore)
+	if added < 1 || added > 2 {
+		t.Fatalf("expected edit to upload 1-2 new chunks, got %d", added)
+	}
+
+	second, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("load second manifest: %v", err)
+	}
+	secondEntry, err := FindEntryByPath(second, filePath)
+	if err != nil {
+		t.Fatalf("find second manifest entry: %v", err)
+	}
+	restored, err := ReadStoredEntryContent(store, [][]byte{key}, secondEntry)
+	if err != nil {
+		t.Fatalf("read chunked content: %v", err)
+	}
+	if !bytes.Equal(restored, edited) {
+		t.Fatal("restored chunked content mismatch")
+	}
+
+	if err := store.DeleteObject(ObjectKeyForChunkSHA256(secondEntry.Chunks[0].SHA256)); err != nil {
+		t.Fatalf("delete chunk: %v", err)
+	}
+	result, err := VerifyManifestEntries(second.Entries, key, store)
+	if err != nil {
+		t.Fatalf("verify entries: %v", err)
+	}
+	if result.Missing != 1 {
+		t.Fatalf("expected missing chunk to be reported, got %+v", result)
+	}
+}
+
+type countingPutStore struct {
+	storage.ObjectStore
+	mu   sync.Mutex
+	puts map[string]int
+}
+
+func (s *countingPutStore) PutObject(key string, data []byte) error {
+	s.mu.Lock()
+	s.puts[key]++
+	s.mu.Unlock()
+	return s.ObjectStore.PutObject(key, data)
+}
+
+func (s *countingPutStore) ObjectExists(key string) (bool, error) {
+	return storage.ObjectExists(s.ObjectStore, key)
+}
+
+func (s *countingPutStore) dataPuts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for key, n := range s.puts {
+		if len(FilterDataObjectKeys([]string{key})) == 1 {
+			count += n
+		}
+	}
+	return count
+}
+
+func TestRunResumesFromUploadCheckpointAfterFailure(t *testing.T) {
+	root := t.TempDir()
+	stateDir := t.TempDir()
+	manifestPath := filepath.Join(stateDir, "manifest.json")
+	snapshotDir := filepath.Join(stateDir, "manifests")
+	checkpointPath := filepath.Join(stateDir, "upload_checkpoint.journal")
+	local := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("content of "+name), 0o600); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+	}
+	sum := sha256.Sum256([]byte("content of d.txt"))
+	failingKey := ObjectKeyForContentSHA256(hex.EncodeToString(sum[:]))
+
+	cfg := &config.Config{BackupRoots: []string{root}}
+	opts := RunOptions{
+		ManifestPath:      manifestPath,
+		SnapshotDir:       snapshotDir,
+		SnapshotRetention: 30,
+		UploadConcurrency: 1,
+		EncryptionKey:     []byte("01234567890123456789012345678901"),
+		KDFSalt:           testKDFSalt,
+		BackupSetID:       "local-test",
+		CheckpointPath:    checkpointPath,
+		Store:             &keyFailingStore{inner: local, failPrefix: failingKey},
+	}
+	if _, err := Run(context.Background(), cfg, opts); err == nil {
+		t.Fatal("expected first run to fail on d.txt")
+	}
+	if _, err := os.Stat(manifestPath); !os.IsNotExist(err) {
+		t.Fatalf("failed run wrote the manifest: %v", err)
+	}
+	snapshots, err := ListSnapshotManifests(snapshotDir)
+	if err != nil {
+		t.Fatalf("list snapshots: %v", err)
+	}
+	if len(snapshots) != 1 || !snapshots[0].Incomplete {
+		t.Fatalf("expected one incomplete snapshot, got %+v", snapshots)
+	}
+	partial, err := LoadManifest(snapshots[0].Path)
+	if err != nil {
+		t.Fatalf("load incomplete snapshot: %v", err)
+	}
+	for _, entry := range partial.Entries {
+		if filepath.Base(entry.Path) == "d.txt" {
+			t.Fatal("incomplete snapshot lists an entry that was never stored")
+		}
+	}
+	if len(partial.Entries) != 4 {
+		t.Fatalf("incomplete snapshot should list the root and three stored files, got %d entries", len(partial.Entries))
+	}
+
+	checkpoint, err := OpenUploadCheckpoint(checkpointPath, "local-test")
+	if err != nil {
+		t.Fatalf("open upload checkpoint: %v", err)
+	}
+	if got := len(checkpoint.records); got != 3 {
+		t.Fatalf("checkpoint recorded %d entries, want 3", got)
+	}
+	if err := checkpoint.Close(); err != nil {
+		t.Fatalf("close upload checkpoint: %v", err)
+	}
+
+	counting := &countingPutStore{ObjectStore: local, puts: make(map[string]int)}
+	opts.Store = counting
+	result, err := Run(context.Background(), cfg, opts)
+	if err != nil {
+		t.Fatalf("resume run: %v", err)
+	}
+	if result.Uploaded != 1 || result.Reused != 3 {
+		t.Fatalf("unexpected resume result: %+v", result)
+	}
+	if got := counting.dataPuts(); got != 1 {
+		t.Fatalf("resume uploaded %d data objects, want 1", got)
+	}
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Fatalf("upload checkpoint should be removed after commit: %v", err)
+	}
+	snapshots, err = ListSnapshotManifests(snapshotDir)
+	if err != nil {
+		t.Fatalf("list snapshots: %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Incomplete {
+		t.Fatalf("expected the incomplete snapshot to be replaced, got %+v", snapshots)
+	}
+}
+
+func TestRunReuploadsCheckpointedObjectsMissingFromTheStore(t *testing.T) {
+	root := t.TempDir()
+	stateDir := t.TempDir()
+	checkpointPath := filepath.Join(stateDir, "upload_checkpoint.journal")
+	local := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("content of "+name), 0o600); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+	}
+	failing := sha256.Sum256([]byte("content of d.txt"))
+	lost := sha256.Sum256([]byte("content of a.txt"))
+	lostKey := ObjectKeyForContentSHA256(hex.EncodeToString(lost[:]))
+
+	key := []byte("01234567890123456789012345678901")
+	cfg := &config.Config{BackupRoots: []string{root}}
+	opts := RunOptions{
+		ManifestPath:      filepath.Join(stateDir, "manifest.json"),
+		SnapshotDir:       filepath.Join(stateDir, "manifests"),
+		SnapshotRetention: 30,
+		UploadConcurrency: 1,
+		EncryptionKey:     key,
+		KDFSalt:           testKDFSalt,
+		BackupSetID:       "local-test",
+		CheckpointPath:    checkpointPath,
+		Store:             &keyFailingStore{inner: local, failPrefix: ObjectKeyForContentSHA256(hex.EncodeToString(failing[:]))},
+	}
+	if _, err := Run(context.Background(), cfg, opts); err == nil {
+		t.Fatal("expected first run to fail on d.txt")
+	}
+
+	// The checkpoint still lists a.txt, but its object is gone.
+	if err := local.DeleteObject(lostKey); err != nil {
+		t.Fatalf("delete recorded object: %v", err)
+	}
+
+	counting := &countingPutStore{ObjectStore: local, puts: make(map[string]int)}
+	opts.Store = counting
+	result, err := Run(context.Background(), cfg, opts)
+	if err != nil {
+		t.Fatalf("resume run: %v", err)
+	}
+	if result.Uploaded != 2 || result.Reused != 2 {
+		t.Fatalf("unexpected resume result: %+v", result)
+	}
+	if got := counting.puts[lostKey]; got != 1 {
+		t.Fatalf("missing object was put %d times on resume, want 1", got)
+	}
+
+	manifest, err := LoadManifest(opts.ManifestPath)
+	if err != nil {
+		t.Fatalf("load manifest: %v", err)
+	}
+	entry, err := FindEntryByPath(manifest, filepath.Join(root, "a.txt"))
+	if err != nil {
+		t.Fatalf("find manifest entry: %v", err)
+	}
+	restored, err := ReadStoredEntryContent(local, [][]byte{key}, entry)
+	if err != nil {
+		t.Fatalf("read re-uploaded content: %v", err)
+	}
+	if string(restored) != "content of a.txt" {
+		t.Fatalf("restored content mismatch: %q", restored)
+	}
+}
+
+// slowFailingStore fails puts of one key after a delay, leaving time for
+// other workers to reach the same chunk.
+type slowFailingStore struct {
+	storage.ObjectStore
+	failKey string
+	delay   time.Duration
+}
+
+func (s *slowFailingStore) PutObject(key string, data []byte) error {
+	if key == s.failKey {
+		time.Sleep(s.delay)
+		return errors.New("forced put failure")
+	}
+	return s.ObjectStore.PutObject(key, data)
+}
+
+func (s *slowFailingStore) ObjectExists(key string) (bool, error) {
+	return storage.ObjectExists(s.ObjectStore, key)
+}
+
+func TestRunDoesNotCheckpointEntriesSharingAFailedChunk(t *testing.T) {
+	root := t.TempDir()
+	stateDir := t.TempDir()
+	checkpointPath := filepath.Join(stateDir, "upload_checkpoint.journal")
+
+	// Both files open with the same chunks; only their tails differ.
+	shared := chunkTestPayload(6<<20, 11)
+	for i, name := range []string{"a.img", "b.img"} {
+		content := append(append([]byte(nil), shared...), chunkTestPayload(2<<20, int64(20+i))...)
+		if err := os.WriteFile(filepath.Join(root, name), content, 0o600); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+	}
+	firstChunk := sha256.Sum256(collectChunks(t, shared)[0])
+
+	cfg := &config.Config{BackupRoots: []string{root}}
+	opts := RunOptions{
+		ManifestPath:      filepath.Join(stateDir, "manifest.json"),
+		SnapshotDir:       filepath.Join(stateDir, "manifests"),
+		SnapshotRetention: 30,
+		UploadConcurrency: 2,
+		UploadMaxAttempts: 1,
+		EncryptionKey:     []byte("01234567890123456789012345678901"),
+		KDFSalt:           testKDFSalt,
+		BackupSetID:       "local-test",
+		CheckpointPath:    checkpointPath,
+		Store: &slowFailingStore{
+			ObjectStore: storage.NewLocalClient(filepath.Join(t.TempDir(), "objects")),
+			failKey:     ObjectKeyForChunkSHA256(hex.EncodeToString(firstChunk[:])),
+			delay:       200 * time.Millisecond,
+		},
+	}
+	if _, err := Run(context.Background(), cfg, opts); err == nil || !strings.Contains(err.Error(), "forced put failure") {
+		t.Fatalf("expected the shared chunk failure, got %v", err)
+	}
+
+	checkpoint, err := OpenUploadCheckpoint(checkpointPath, "local-test")
+	if err != nil {
+		t.Fatalf("open upload checkpoint: %v", err)
+	}
+	defer checkpoint.Close()
+	if len(checkpoint.records) != 0 {
+		t.Fatalf("checkpoint recorded entries whose chunk was never stored: %+v", checkpoint.records)
+	}
+	snapshots, err := ListSnapshotManifests(opts.SnapshotDir)
+	if err != nil {
+		t.Fatalf("list snapshots: %v", err)
+	}
+	if len(snapshots) != 0 {
+		t.Fatalf("expected no incomplete snapshot, got %+v", snapshots)
+	}
+}
+
+func TestRunDoesNotCommitAfterLosingItsLock(t *testing.T) {
+	root := t.TempDir()
+	stateDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("content of a.txt"), 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	lockedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	lock, err := repolock.Acquire(repolock.Options{
+		Store:           store,
+		Mode:            repolock.Shared,
+		Operation:       "backup",
+		TTL:             time.Minute,
+		RefreshInterval: time.Hour,
+		Now:             func() time.Time { return lockedAt },
+	})
+	if err != nil {
+		t.Fatalf("acquire lock: %v", err)
+	}
+	defer lock.Release()
+	// The lock was written a minute ago and never refreshed.
+	lockedAt = lockedAt.Add(time.Minute)
+
+	opts := RunOptions{
+		ManifestPath:      filepath.Join(stateDir, "manifest.json"),
+		SnapshotDir:       filepath.Join(stateDir, "manifests"),
+		SnapshotRetention: 30,
+		EncryptionKey:     []byte("01234567890123456789012345678901"),
+		KDFSalt:           testKDFSalt,
+		BackupSetID:       "local-test",
+		CheckpointPath:    filepath.Join(stateDir, "upload_checkpoint.journal"),
+		Store:             store,
+		Lock:              lock,
+	}
+	if _, err := Run(context.Background(), &config.Config{BackupRoots: []string{root}}, opts); !errors.Is(err, repolock.ErrLockLost) {
+		t.Fatalf("expected lost lock error, got %v", err)
+	}
+	if _, err := os.Stat(opts.ManifestPath); !os.IsNotExist(err) {
+		t.Fatalf("run committed a manifest after losing its lock: %v", err)
+	}
+	if _, err := os.Stat(opts.CheckpointPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the upload checkpoint to be dropped: %v", err)
+	}
+	if snapshots, err := ListSnapshotManifests(opts.SnapshotDir); err != nil || len(snapshots) != 0 {
+		t.Fatalf("expected no snapshots, got %+v (%v)", snapshots, err)
+	}
+}
+
+func TestRunSkipsContentAlreadyInStore(t *testing.T) {
+	root := t.TempDir()
+	objects := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("content of "+name), 0o600); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+	}
+
+	cfg := &config.Config{BackupRoots: []string{root}}
+	run := func(store storage.ObjectStore) RunResult {
+		t.Helper()
+		stateDir := t.TempDir()
+		result, err := Run(context.Background(), cfg, RunOptions{
+			ManifestPath:      filepath.Join(stateDir, "manifest.json"),
+			SnapshotDir:       filepath.Join(stateDir, "manifests"),
+			SnapshotRetention: 30,
+			EncryptionKey:     []byte("01234567890123456789012345678901"),
+			KDFSalt:           testKDFSalt,
+			BackupSetID:       "local-test",
+			Store:             store,
+		})
+		if err != nil {
+			t.Fatalf("run backup: %v", err)
+		}
+		return result
+	}
+
+	run(objects)
+	// A fresh manifest plans every file again; the existence check finds
+	// their content already stored.
+	counting := &countingPutStore{ObjectStore: objects, puts: make(map[string]int)}
+	result := run(counting)
+	if result.Uploaded != 0 || result.Reused != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := counting.dataPuts(); got != 0 {
+		t.Fatalf("uploaded %d data objects already in the store", got)
+	}
+}
+
+// pauseTestEntries returns manifest entries for new files with the given
+// names, ready to upload.
+func pauseTestEntries(t *testing.T, names ...string) []ManifestEntry {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0o600); err != nil {
+			t.Fatalf("write source file: %v", err)
+		}
+	}
+	manifest, err := BuildManifest([]string{root})
+	if err != nil {
+		t.Fatalf("build manifest: %v", err)
+	}
+	for i, entry := range manifest.Entries {
+		if entry.HasStoredContent() {
+			manifest.Entries[i].ObjectKey = ObjectKeyForContentSHA256(entry.SHA256)
+		}
+	}
+	return manifest.Entries
+}
+
+func TestUploadChangedEntriesParksWhilePaused(t *testing.T) {
+	entries := pauseTestEntries(t, "a.txt", "b.txt")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	gate := &PauseGate{}
+	if !gate.Pause() || gate.Pause() {
+		t.Fatal("expected only the first pause to take effect")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := uploadChangedEntries(context.Background(), entries, RunOptions{
+			EncryptionKey: []byte("01234567890123456789012345678901"),
+			Store:         store,
+			Pause:         gate,
+		}, nil, nil)
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if keys, _ := store.ListKeys(); len(keys) != 0 {
+		t.Fatalf("expected no uploads while paused, got %v", keys)
+	}
+	if !gate.Resume() || gate.Resume() {
+		t.Fatal("expected only the first resume to take effect")
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("upload changed entries: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("uploads did not continue after resume")
+	}
+	if keys, _ := store.ListKeys(); len(keys) != 2 {
+		t.Fatalf("expected both files uploaded after resume, got %v", keys)
+	}
+}
+
+func TestUploadChangedEntriesCancelsWhilePaused(t *testing.T) {
+	entries := pauseTestEntries(t, "a.txt")
+	gate := &PauseGate{}
+	gate.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := uploadChangedEntries(ctx, entries, RunOptions{
+		EncryptionKey: []byte("01234567890123456789012345678901"),
+		Store:         storage.NewLocalClient(filepath.Join(t.TempDir(), "objects")),
+		Pause:         gate,
+	}, nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected paused upload to stop with its context, got %v", err)
+	}
+}
+
+// pausingStore pauses gate once the first chunk is stored.
+type pausingStore struct {
+	*storage.LocalClient
+	gate   *PauseGate
+	mu     sync.Mutex
+	chunks int
+}
+
+func (s *pausingStore) PutObject(key string, data []byte) error {
+	if err := s.LocalClient.PutObject(key, data); err != nil {
+		return err
+	}
+	if strings.HasPrefix(key, "chunks/") {
+		s.mu.Lock()
+		s.chunks++
+		if s.chunks == 1 {
+			s.gate.Pause()
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *pausingStore) storedChunks() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chunks
+}
+
+func TestUploadChangedEntriesParksBetweenChunks(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "disk.img"), chunkTestPayload(12<<20, 5), 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+	manifest, err := BuildManifest([]string{root})
+	if err != nil {
+		t.Fatalf("build manifest: %v", err)
+	}
+	gate := &PauseGate{}
+	store := &pausingStore{LocalClient: storage.NewLocalClient(filepath.Join(t.TempDir(), "objects")), gate: gate}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := uploadChangedEntries(context.Background(), manifest.Entries, RunOptions{
+			EncryptionKey: []byte("01234567890123456789012345678901"),
+			Store:         store,
+			Pause:         gate,
+		}, nil, nil)
+		done <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !gate.Paused() {
+		if time.Now().After(deadline) {
+			t.Fatal("first chunk was never stored")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := store.storedChunks(); got != 1 {
+		t.Fatalf("expected the file to park after its first chunk, stored %d", got)
+	}
+	gate.Resume()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("upload changed entries: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("uploads did not continue after resume")
+	}
+	if got := store.storedChunks(); got < 2 {
+		t.Fatalf("expected the rest of the file after resume, stored %d chunks", got)
+	}
+}
@@ -0,0 +1,268 @@
+package backup
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"baxter/internal/storage"
+)
+
+// snapshotDirMode is the mode of directories that only exist as path
+// components of entries.
+const snapshotDirMode = fs.ModeDir | 0o555
+
+// SnapshotFS is a read-only fs.FS over the entries of one manifest, rooted
+// at "/" of the backed-up machine: entry /Users/me/notes.txt is opened as
+// "Users/me/notes.txt". Directories that were not recorded themselves are
+// synthesized from entry paths and dated at the manifest's creation time.
+// Opened files stream their content from the store, decrypting as they go,
+// and the last Read reports ErrChecksumMismatch when the content does not
+// match the entry as VerifyEntryContent would. FileInfo.Sys returns the
+// ManifestEntry behind a file.
+type SnapshotFS struct {
+	root  *snapshotNode
+	store storage.ObjectStore
+	keys  [][]byte
+}
+
+var (
+	_ fs.FS        = (*SnapshotFS)(nil)
+	_ fs.ReadDirFS = (*SnapshotFS)(nil)
+	_ fs.StatFS    = (*SnapshotFS)(nil)
+)
+
+// NewSnapshotFS returns an FS over the entries of m whose content is read
+// from store with any of keys. Entries whose path cannot be expressed in an
+// fs.FS, or that sit below a file, are left out.
+func NewSnapshotFS(m *Manifest, store storage.ObjectStore, keys [][]byte) *SnapshotFS {
+	root := newSnapshotDir(".", m.CreatedAt)
+	for _, entry := range m.Entries {
+		rel := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(entry.Path, "\\", "/")), "/")
+		if rel == "" || !fs.ValidPath(rel) {
+			continue
+		}
+		parent := root
+		parts := strings.Split(rel, "/")
+		for _, part := range parts[:len(parts)-1] {
+			next := parent.children[part]
+			if next == nil {
+				next = newSnapshotDir(part, m.CreatedAt)
+				parent.children[part] = next
+			}
+			if !next.entry.IsDir() {
+				parent = nil
+				break
+			}
+			parent = next
+		}
+		if parent == nil {
+			continue
+		}
+		leaf := parts[len(parts)-1]
+		if existing := parent.children[leaf]; existing != nil && existing.entry.IsDir() {
+			// Keep the children placed so far; only take the recorded
+			// metadata.
+			if entry.IsDir() {
+				existing.entry = entry
+			}
+			continue
+		}
+		n := &snapshotNode{name: leaf, entry: entry}
+		if entry.IsDir() {
+			n.children = make(map[string]*snapshotNode)
+		}
+		parent.children[leaf] = n
+	}
+	return &SnapshotFS{root: root, store: store, keys: keys}
+}
+
+func (s *SnapshotFS) Open(name string) (fs.File, error) {
+	n, err := s.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.entry.IsDir() {
+		return &snapshotDirFile{node: n}, nil
+	}
+	return &snapshotFile{fsys: s, node: n}, nil
+}
+
+func (s *SnapshotFS) Stat(name string) (fs.FileInfo, error) {
+	n, err := s.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return snapshotFileInfo{n}, nil
+}
+
+func (s *SnapshotFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := s.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.entry.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return n.dirEntries(), nil
+}
+
+// ReadLink returns the recorded target of a symlink entry. Open does not
+// follow symlinks; reading one fails.
+func (s *SnapshotFS) ReadLink(name string) (string, error) {
+	n, err := s.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if !n.entry.IsSymlink() {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return n.entry.LinkTarget, nil
+}
+
+func (s *SnapshotFS) lookup(op, name string) (*snapshotNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n := s.root
+	if name == "." {
+		return n, nil
+	}
+	for _, part := range strings.Split(name, "/") {
+		if n = n.children[part]; n == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	return n, nil
+}
+
+type snapshotNode struct {
+	name     string
+	entry    ManifestEntry
+	children map[string]*snapshotNode
+}
+
+func newSnapshotDir(name string, modTime time.Time) *snapshotNode {
+	return &snapshotNode{
+		name:     name,
+		entry:    ManifestEntry{Mode: snapshotDirMode, ModTime: modTime},
+		children: make(map[string]*snapshotNode),
+	}
+}
+
+func (n *snapshotNode) dirEntries() []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for _, child := range n.children {
+		entries = append(entries, fs.FileInfoToDirEntry(snapshotFileInfo{child}))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+type snapshotFileInfo struct {
+	n *snapshotNode
+}
+
+func (i snapshotFileInfo) Name() string { return i.n.name }
+
+func (i snapshotFileInfo) Size() int64 {
+	switch {
+	case i.n.entry.IsDir():
+		return 0
+	case i.n.entry.IsSymlink():
+		return int64(len(i.n.entry.LinkTarget))
+	default:
+		return i.n.entry.Size
+	}
+}
+
+func (i snapshotFileInfo) Mode() fs.FileMode  { return i.n.entry.Mode }
+func (i snapshotFileInfo) ModTime() time.Time { return i.n.entry.ModTime }
+func (i snapshotFileInfo) IsDir() bool        { return i.n.entry.IsDir() }
+func (i snapshotFileInfo) Sys() any           { return i.n.entry }
+
+type snapshotDirFile struct {
+	node    *snapshotNode
+	entries []fs.DirEntry
+}
+
+func (d *snapshotDirFile) Stat() (fs.FileInfo, error) { return snapshotFileInfo{d.node}, nil }
+
+func (d *snapshotDirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.node.name, Err: errors.New("is a directory")}
+}
+
+func (d *snapshotDirFile) Close() error { return nil }
+
+func (d *snapshotDirFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		d.entries = d.node.dirEntries()
+	}
+	if count <= 0 {
+		remaining := d.entries
+		d.entries = d.entries[len(d.entries):]
+		return remaining, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(d.entries))
+	remaining := d.entries[:count]
+	d.entries = d.entries[count:]
+	return remaining, nil
+}
+
+// snapshotFile streams the content of an entry. The fetch starts on the
+// first Read and runs in a goroutine feeding a pipe, which Close tears down.
+type snapshotFile struct {
+	fsys    *SnapshotFS
+	node    *snapshotNode
+	content *io.PipeReader
+	closed  bool
+}
+
+func (f *snapshotFile) Stat() (fs.FileInfo, error) { return snapshotFileInfo{f.node}, nil }
+
+func (f *snapshotFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, fs.ErrClosed
+	}
+	entry := f.node.entry
+	if entry.IsSymlink() {
+		return 0, &fs.PathError{Op: "read", Path: f.node.name, Err: fs.ErrInvalid}
+	}
+	if entry.Size == 0 && !entry.IsChunked() && !entry.IsCloudPlaceholder() {
+		// Empty files are checked without a fetch.
+		if err := VerifyEntryContent(entry, nil); err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.node.name, Err: err}
+		}
+		return 0, io.EOF
+	}
+	if f.content == nil {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(CopyStoredEntryContent(pw, f.fsys.store, f.fsys.keys, entry))
+		}()
+		f.content = pr
+	}
+	n, err := f.content.Read(p)
+	if err != nil && err != io.EOF {
+		err = &fs.PathError{Op: "read", Path: f.node.name, Err: err}
+	}
+	return n, err
+}
+
+func (f *snapshotFile) Close() error {
+	if f.closed {
+		return fs.ErrClosed
+	}
+	f.closed = true
+	if f.content != nil {
+		f.content.CloseWithError(fs.ErrClosed)
+	}
+	return nil
+}
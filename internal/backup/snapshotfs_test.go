@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"baxter/internal/crypto"
+	"baxter/internal/storage"
+)
+
+func TestSnapshotFSServesManifestEntries(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	created := time.Date(2026, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	notes := []byte("remember the milk")
+	notesSum := sha256.Sum256(notes)
+	notesEntry := ManifestEntry{Path: "/Users/me/notes.txt", Size: int64(len(notes)), Mode: 0o644, ModTime: created, SHA256: hex.EncodeToString(notesSum[:])}
+	notesEntry.ObjectKey = ObjectKeyForContentSHA256(notesEntry.SHA256)
+	putStreamedTestObject(t, store, key, notesEntry.ObjectKey, notes)
+
+	big := chunkTestPayload(20000, 3)
+	bigSum := sha256.Sum256(big)
+	bigEntry := ManifestEntry{Path: "/Users/me/big.bin", Size: int64(len(big)), Mode: 0o600, ModTime: created, SHA256: hex.EncodeToString(bigSum[:])}
+	for start := 0; start < len(big); start += 8192 {
+		chunk := big[start:min(start+8192, len(big))]
+		chunkSum := sha256.Sum256(chunk)
+		ref := ChunkRef{SHA256: hex.EncodeToString(chunkSum[:]), Size: int64(len(chunk))}
+		payload, err := crypto.EncryptBytes(key, chunk)
+		if err != nil {
+			t.Fatalf("encrypt chunk: %v", err)
+		}
+		if err := store.PutObject(ObjectKeyForChunkSHA256(ref.SHA256), payload); err != nil {
+			t.Fatalf("put chunk: %v", err)
+		}
+		bigEntry.Chunks = append(bigEntry.Chunks, ref)
+	}
+
+	emptySum := sha256.Sum256(nil)
+	fsys := NewSnapshotFS(&Manifest{CreatedAt: created, Entries: []ManifestEntry{
+		{Path: "/Users/me", Mode: fs.ModeDir | 0o750, ModTime: created.Add(-time.Hour)},
+		notesEntry,
+		bigEntry,
+		{Path: "/Users/me/empty.txt", Mode: 0o600, ModTime: created, SHA256: hex.EncodeToString(emptySum[:])},
+		{Path: "/Users/me/link", Mode: fs.ModeSymlink | 0o777, LinkTarget: "notes.txt"},
+	}}, store, [][]byte{key})
+
+	if err := fstest.TestFS(fsys, "Users/me/notes.txt", "Users/me/big.bin", "Users/me/empty.txt"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := fs.ReadFile(fsys, "Users/me/big.bin")
+	if err != nil || !bytes.Equal(got, big) {
+		t.Fatalf("read chunked file: %v", err)
+	}
+	info, err := fs.Stat(fsys, "Users/me")
+	if err != nil || info.Mode() != fs.ModeDir|0o750 || !info.ModTime().Equal(created.Add(-time.Hour)) {
+		t.Fatalf("expected recorded dir metadata, got %v %v", info, err)
+	}
+	info, err = fs.Stat(fsys, "Users")
+	if err != nil || !info.IsDir() || !info.ModTime().Equal(created) {
+		t.Fatalf("expected synthesized dir at snapshot time, got %v %v", info, err)
+	}
+	if entry, ok := info.Sys().(ManifestEntry); !ok || !entry.IsDir() {
+		t.Fatalf("expected Sys to return a manifest entry, got %#v", info.Sys())
+	}
+	target, err := fsys.ReadLink("Users/me/link")
+	if err != nil || target != "notes.txt" {
+		t.Fatalf("readlink: %q %v", target, err)
+	}
+	if _, err := fsys.Open("Users/me/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected missing file to be reported, got %v", err)
+	}
+}
+
+func TestSnapshotFSReportsTamperedContent(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	store := storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+	sum := sha256.Sum256([]byte("original"))
+	entry := ManifestEntry{Path: "/doc.txt", Size: 8, Mode: 0o600, SHA256: hex.EncodeToString(sum[:])}
+	entry.ObjectKey = ObjectKeyForContentSHA256(entry.SHA256)
+	putStreamedTestObject(t, store, key, entry.ObjectKey, []byte("tampered"))
+	fsys := NewSnapshotFS(&Manifest{Entries: []ManifestEntry{entry}}, store, [][]byte{key})
+
+	if _, err := fs.ReadFile(fsys, "doc.txt"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	// Closing part way through stops the fetch instead of leaving it
+	// blocked on the pipe.
+	f, err := fsys.Open("doc.txt")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := f.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("first read: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("expected read after close to fail, got %v", err)
+	}
+}
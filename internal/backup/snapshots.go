@@ -16,16 +16,22 @@ const latestSnapshotSelector = "latest"
 var ErrSnapshotNotFound = errors.New("snapshot not found")
 
 type ManifestSnapshot struct {
-	ID        string
-	Path      string
-	CreatedAt time.Time
-	Entries   int
+	ID         string
+	Path       string
+	CreatedAt  time.Time
+	Entries    int
+	Incomplete bool
+	Tags       []string
 }
 
 type SnapshotPrunePolicy struct {
 	Retain     int
 	MaxAgeDays int
 	Now        time.Time
+	Keep       SnapshotKeepRules
+	// Location sets the calendar the generational buckets follow
+	// (default: local time).
+	Location *time.Location
 }
 
 func SaveSnapshotManifest(snapshotDir string, m *Manifest) (ManifestSnapshot, error) {
@@ -63,10 +69,12 @@ func ReserveSnapshotManifest(snapshotDir string, m *Manifest) (ManifestSnapshot,
 	}
 
 	return ManifestSnapshot{
-		ID:        id,
-		Path:      path,
-		CreatedAt: m.CreatedAt.UTC(),
-		Entries:   len(m.Entries),
+		ID:         id,
+		Path:       path,
+		CreatedAt:  m.CreatedAt.UTC(),
+		Entries:    len(m.Entries),
+		Incomplete: m.Incomplete,
+		Tags:       m.Tags,
 	}, nil
 }
 
@@ -116,13 +124,20 @@ func ListSnapshotManifests(snapshotDir string) ([]ManifestSnapshot, error) {
 			return nil, fmt.Errorf("load snapshot %s: %w", name, err)
 		}
 		snapshots = append(snapshots, ManifestSnapshot{
-			ID:        id,
-			Path:      path,
-			CreatedAt: manifest.CreatedAt.UTC(),
-			Entries:   len(manifest.Entries),
+			ID:         id,
+			Path:       path,
+			CreatedAt:  manifest.CreatedAt.UTC(),
+			Entries:    len(manifest.Entries),
+			Incomplete: manifest.Incomplete,
+			Tags:       manifest.Tags,
 		})
 	}
 
+	sortSnapshotsNewestFirst(snapshots)
+	return snapshots, nil
+}
+
+func sortSnapshotsNewestFirst(snapshots []ManifestSnapshot) {
 	sort.Slice(snapshots, func(i, j int) bool {
 		a := snapshots[i]
 		b := snapshots[j]
@@ -131,7 +146,68 @@ func ListSnapshotManifests(snapshotDir string) ([]ManifestSnapshot, error) {
 		}
 		return a.CreatedAt.After(b.CreatedAt)
 	})
-	return snapshots, nil
+}
+
+// LatestCompleteSnapshot returns the newest snapshot in snapshots, as sorted by
+// ListSnapshotManifests, that is not incomplete.
+func LatestCompleteSnapshot(snapshots []ManifestSnapshot) (ManifestSnapshot, bool) {
+	for _, snapshot := range snapshots {
+		if !snapshot.Incomplete {
+			return snapshot, true
+		}
+	}
+	return ManifestSnapshot{}, false
+}
+
+// saveIncompleteSnapshot records what a stopped run managed to store: current
+// without the changed entries whose content is not in checkpoint. Nothing is
+// saved when none of them are. Older incomplete snapshots are superseded.
+func saveIncompleteSnapshot(snapshotDir string, current *Manifest, changed []ManifestEntry, checkpoint *UploadCheckpoint) error {
+	changedPaths := make(map[string]struct{}, len(changed))
+	for _, entry := range changed {
+		if entry.HasStoredContent() {
+			changedPaths[filepath.Clean(entry.Path)] = struct{}{}
+		}
+	}
+
+	partial := &Manifest{CreatedAt: current.CreatedAt, Incomplete: true}
+	stored := 0
+	for _, entry := range current.Entries {
+		if _, ok := changedPaths[filepath.Clean(entry.Path)]; ok {
+			storedEntry, ok := checkpoint.Lookup(entry)
+			if !ok {
+				continue
+			}
+			entry = storedEntry
+			stored++
+		}
+		partial.Entries = append(partial.Entries, entry)
+	}
+	if stored == 0 {
+		return nil
+	}
+
+	if err := removeIncompleteSnapshots(snapshotDir); err != nil {
+		return err
+	}
+	_, err := SaveSnapshotManifest(snapshotDir, partial)
+	return err
+}
+
+func removeIncompleteSnapshots(snapshotDir string) error {
+	snapshots, err := ListSnapshotManifests(snapshotDir)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if !snapshot.Incomplete {
+			continue
+		}
+		if err := os.Remove(snapshot.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
 }
 
 func PruneSnapshotManifests(snapshotDir string, retain int) (int, error) {
@@ -149,10 +225,12 @@ func PruneSnapshotManifestsWithPolicy(snapshotDir string, policy SnapshotPrunePo
 		return 0, nil
 	}
 
-	candidates := snapshotPruneCandidates(snapshots, policy)
 	removed := 0
-	for _, snapshot := range candidates {
-		if err := os.Remove(snapshot.Path); err != nil && !os.IsNotExist(err) {
+	for _, decision := range planSnapshotRetention(snapshots, policy) {
+		if decision.Keep {
+			continue
+		}
+		if err := os.Remove(decision.Snapshot.Path); err != nil && !os.IsNotExist(err) {
 			return removed, err
 		}
 		removed++
@@ -160,44 +238,15 @@ func PruneSnapshotManifestsWithPolicy(snapshotDir string, policy SnapshotPrunePo
 	return removed, nil
 }
 
-func PlanSnapshotPruneManifestsWithPolicy(snapshotDir string, policy SnapshotPrunePolicy) ([]ManifestSnapshot, error) {
+// PlanSnapshotPruneManifestsWithPolicy reports, newest first, whether policy
+// keeps or prunes each snapshot and which rules decided it, without removing
+// anything.
+func PlanSnapshotPruneManifestsWithPolicy(snapshotDir string, policy SnapshotPrunePolicy) ([]SnapshotRetentionDecision, error) {
 	snapshots, err := ListSnapshotManifests(snapshotDir)
 	if err != nil {
 		return nil, err
 	}
-	return snapshotPruneCandidates(snapshots, policy), nil
-}
-
-func snapshotPruneCandidates(snapshots []ManifestSnapshot, policy SnapshotPrunePolicy) []ManifestSnapshot {
-	if len(snapshots) == 0 {
-		return []ManifestSnapshot{}
-	}
-
-	now := policy.Now.UTC()
-	if now.IsZero() {
-		now = time.Now().UTC()
-	}
-
-	var cutoff time.Time
-	if policy.MaxAgeDays > 0 {
-		cutoff = now.AddDate(0, 0, -policy.MaxAgeDays)
-	}
-
-	candidates := make([]ManifestSnapshot, 0)
-	for index, snapshot := range snapshots {
-		keep := true
-		if policy.Retain > 0 && index >= policy.Retain {
-			keep = false
-		}
-		if !cutoff.IsZero() && snapshot.CreatedAt.Before(cutoff) {
-			keep = false
-		}
-		if keep {
-			continue
-		}
-		candidates = append(candidates, snapshot)
-	}
-	return candidates
+	return planSnapshotRetention(snapshots, policy), nil
 }
 
 func LoadManifestForRestore(latestManifestPath, snapshotDir, selector string) (*Manifest, error) {
@@ -212,7 +261,7 @@ func LoadManifestForRestore(latestManifestPath, snapshotDir, selector string) (*
 			return nil, listErr
 		}
 		for _, snapshot := range snapshots {
-			if !snapshot.CreatedAt.After(asOf.UTC()) {
+			if !snapshot.Incomplete && !snapshot.CreatedAt.After(asOf.UTC()) {
 				return LoadManifest(snapshot.Path)
 			}
 		}
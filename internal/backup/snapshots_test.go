@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -114,13 +115,19 @@ func TestPlanSnapshotPruneManifestsWithPolicyBoundaryCutoff(t *testing.T) {
 	atCutoff := mustSaveSnapshot(t, snapshotDir, cutoffAge)
 	older := mustSaveSnapshot(t, snapshotDir, cutoffAge.Add(-time.Second))
 
-	candidates, err := PlanSnapshotPruneManifestsWithPolicy(snapshotDir, SnapshotPrunePolicy{
+	decisions, err := PlanSnapshotPruneManifestsWithPolicy(snapshotDir, SnapshotPrunePolicy{
 		MaxAgeDays: 30,
 		Now:        now,
 	})
 	if err != nil {
 		t.Fatalf("plan prune snapshots: %v", err)
 	}
+	var candidates []ManifestSnapshot
+	for _, decision := range decisions {
+		if !decision.Keep {
+			candidates = append(candidates, decision.Snapshot)
+		}
+	}
 	if len(candidates) != 1 {
 		t.Fatalf("unexpected candidate count: got %d want 1", len(candidates))
 	}
@@ -137,6 +144,89 @@ func TestPlanSnapshotPruneManifestsWithPolicyBoundaryCutoff(t *testing.T) {
 	}
 }
 
+func TestPlanSnapshotPruneManifestsWithPolicyExplainsKeepRules(t *testing.T) {
+	snapshotDir := filepath.Join(t.TempDir(), "manifests")
+	now := time.Date(2026, time.March, 18, 12, 0, 0, 0, time.UTC)
+
+	newest := mustSaveSnapshot(t, snapshotDir, now.Add(-time.Hour))
+	sameDay := mustSaveSnapshot(t, snapshotDir, now.Add(-2*time.Hour))
+	yesterday := mustSaveSnapshot(t, snapshotDir, now.AddDate(0, 0, -1))
+	lastWeek := mustSaveSnapshot(t, snapshotDir, now.AddDate(0, 0, -8))
+	lastMonth := mustSaveSnapshot(t, snapshotDir, now.AddDate(0, -1, 0))
+	tagged := mustSaveTaggedSnapshot(t, snapshotDir, now.AddDate(0, -6, 0), "release")
+	untagged := mustSaveSnapshot(t, snapshotDir, now.AddDate(0, -7, 0))
+
+	decisions, err := PlanSnapshotPruneManifestsWithPolicy(snapshotDir, SnapshotPrunePolicy{
+		MaxAgeDays: 90,
+		Now:        now,
+		Location:   time.UTC,
+		Keep: SnapshotKeepRules{
+			Daily:   2,
+			Weekly:  2,
+			Monthly: 3,
+			Within:  30 * time.Minute,
+			Tags:    []string{"release"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("plan prune snapshots: %v", err)
+	}
+
+	want := map[string]struct {
+		keep    bool
+		reasons string
+	}{
+		newest.ID:    {true, "daily,weekly,monthly,within"},
+		sameDay.ID:   {false, "no-rule"},
+		yesterday.ID: {true, "daily"},
+		lastWeek.ID:  {true, "weekly"},
+		lastMonth.ID: {true, "monthly"},
+		tagged.ID:    {true, "monthly,tag:release"},
+		untagged.ID:  {false, "no-rule"},
+	}
+	if len(decisions) != len(want) {
+		t.Fatalf("unexpected decision count: got %d want %d", len(decisions), len(want))
+	}
+	for _, decision := range decisions {
+		expected := want[decision.Snapshot.ID]
+		if decision.Keep != expected.keep || strings.Join(decision.Reasons, ",") != expected.reasons {
+			t.Fatalf("unexpected decision for %s: keep=%t reasons=%v, want keep=%t reasons=%s",
+				decision.Snapshot.CreatedAt.Format(time.RFC3339), decision.Keep, decision.Reasons, expected.keep, expected.reasons)
+		}
+	}
+}
+
+func TestPlanSnapshotPruneManifestsWithPolicyMaxAgeOverridesBucketsButNotTags(t *testing.T) {
+	snapshotDir := filepath.Join(t.TempDir(), "manifests")
+	now := time.Date(2026, time.March, 18, 12, 0, 0, 0, time.UTC)
+
+	_ = mustSaveSnapshot(t, snapshotDir, now.AddDate(0, 0, -1))
+	oldMonthly := mustSaveSnapshot(t, snapshotDir, now.AddDate(0, -3, 0))
+	oldTagged := mustSaveTaggedSnapshot(t, snapshotDir, now.AddDate(-1, 0, 0), "keep")
+
+	decisions, err := PlanSnapshotPruneManifestsWithPolicy(snapshotDir, SnapshotPrunePolicy{
+		MaxAgeDays: 30,
+		Now:        now,
+		Location:   time.UTC,
+		Keep:       SnapshotKeepRules{Monthly: 12, Tags: []string{"keep"}},
+	})
+	if err != nil {
+		t.Fatalf("plan prune snapshots: %v", err)
+	}
+	for _, decision := range decisions {
+		switch decision.Snapshot.ID {
+		case oldMonthly.ID:
+			if decision.Keep || strings.Join(decision.Reasons, ",") != "max-age" {
+				t.Fatalf("expected old monthly snapshot to be pruned by max-age, got %+v", decision)
+			}
+		case oldTagged.ID:
+			if !decision.Keep {
+				t.Fatalf("expected tagged snapshot to survive max-age, got %+v", decision)
+			}
+		}
+	}
+}
+
 func mustSaveSnapshot(t *testing.T, dir string, createdAt time.Time) ManifestSnapshot {
 	t.Helper()
 
@@ -149,3 +239,17 @@ func mustSaveSnapshot(t *testing.T, dir string, createdAt time.Time) ManifestSna
 	}
 	return snapshot
 }
+
+func mustSaveTaggedSnapshot(t *testing.T, dir string, createdAt time.Time, tags ...string) ManifestSnapshot {
+	t.Helper()
+
+	snapshot, err := SaveSnapshotManifest(dir, &Manifest{
+		CreatedAt: createdAt,
+		Tags:      tags,
+		Entries:   []ManifestEntry{{Path: createdAt.Format(time.RFC3339)}},
+	})
+	if err != nil {
+		t.Fatalf("save snapshot: %v", err)
+	}
+	return snapshot
+}
@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const statCacheVersion = 1
+
+// StatCache remembers the content hash of files by path, keyed on the stat
+// identity observed when they were hashed. A hash is reused only when device,
+// inode, size, mtime and ctime all still match.
+type StatCache struct {
+	path           string
+	entries        map[string]statCacheEntry
+	seen           map[string]statCacheEntry
+	LastFullRehash time.Time
+}
+
+type statCacheFile struct {
+	Version        int                       `json:"version"`
+	LastFullRehash time.Time                 `json:"last_full_rehash"`
+	Entries        map[string]statCacheEntry `json:"entries"`
+}
+
+type statCacheEntry struct {
+	fileStat
+	SHA256 string `json:"sha256"`
+}
+
+type fileStat struct {
+	Device       uint64 `json:"dev"`
+	Inode        uint64 `json:"ino"`
+	Size         int64  `json:"size"`
+	ModTimeNS    int64  `json:"mtime_ns"`
+	ChangeTimeNS int64  `json:"ctime_ns"`
+}
+
+// LoadStatCache reads the cache at path. A missing, unreadable or
+// incompatible cache yields an empty one, since every entry can be rebuilt.
+func LoadStatCache(path string) (*StatCache, error) {
+	cache := &StatCache{
+		path:    path,
+		entries: make(map[string]statCacheEntry),
+		seen:    make(map[string]statCacheEntry),
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, err
+	}
+
+	var file statCacheFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != statCacheVersion {
+		return cache, nil
+	}
+	if file.Entries != nil {
+		cache.entries = file.Entries
+	}
+	cache.LastFullRehash = file.LastFullRehash
+	return cache, nil
+}
+
+// Save writes the entries recorded during the latest scan, dropping paths
+// that were not seen.
+func (c *StatCache) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(statCacheFile{
+		Version:        statCacheVersion,
+		LastFullRehash: c.LastFullRehash,
+		Entries:        c.seen,
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// RehashDue reports whether a full rehash is owed under the given interval.
+// A zero interval disables periodic rehashing.
+func (c *StatCache) RehashDue(interval time.Duration, now time.Time) bool {
+	if c == nil || interval <= 0 {
+		return false
+	}
+	return c.LastFullRehash.IsZero() || now.Sub(c.LastFullRehash) >= interval
+}
+
+func (c *StatCache) lookup(path string, info fs.FileInfo) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	st, ok := statForFileInfo(info)
+	if !ok {
+		return "", false
+	}
+	cached, ok := c.entries[path]
+	if !ok || cached.fileStat != st {
+		return "", false
+	}
+	c.seen[path] = cached
+	return cached.SHA256, true
+}
+
+// record stores a freshly computed hash. Files whose mtime or ctime falls in
+// or after the second the scan started are left out: a later write within the
+// same timestamp granularity would be invisible to the stat comparison.
+func (c *StatCache) record(path string, info fs.FileInfo, sha string, scanStart time.Time) {
+	if c == nil {
+		return
+	}
+	st, ok := statForFileInfo(info)
+	if !ok {
+		return
+	}
+	racyAfter := scanStart.Truncate(time.Second).UnixNano()
+	if st.ModTimeNS >= racyAfter || st.ChangeTimeNS >= racyAfter {
+		delete(c.seen, path)
+		return
+	}
+	c.seen[path] = statCacheEntry{fileStat: st, SHA256: sha}
+}
@@ -0,0 +1,22 @@
+//go:build darwin
+
+package backup
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func statForFileInfo(info fs.FileInfo) (fileStat, bool) {
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileStat{}, false
+	}
+	return fileStat{
+		Device:       uint64(sys.Dev),
+		Inode:        sys.Ino,
+		Size:         info.Size(),
+		ModTimeNS:    info.ModTime().UnixNano(),
+		ChangeTimeNS: sys.Ctimespec.Nano(),
+	}, true
+}
@@ -0,0 +1,147 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func writeStatCacheTestFile(t *testing.T, path string, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("set file times: %v", err)
+	}
+}
+
+func requireStatCacheSupport(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS != "darwin" && runtime.GOOS != "linux" {
+		t.Skip("stat cache requires inode and ctime support")
+	}
+}
+
+func TestBuildManifestReusesCachedHashForUnchangedFiles(t *testing.T) {
+	requireStatCacheSupport(t)
+	root := t.TempDir()
+	cachePath := filepath.Join(t.TempDir(), "stat_cache.json")
+	filePath := filepath.Join(root, "doc.txt")
+	writeStatCacheTestFile(t, filePath, "original", time.Now().Add(-time.Hour))
+
+	cache, err := LoadStatCache(cachePath)
+	if err != nil {
+		t.Fatalf("load stat cache: %v", err)
+	}
+	// ctime is set by the write above, so scan as if a second has passed.
+	time.Sleep(1100 * time.Millisecond)
+	first, err := BuildManifestWithOptions(context.Background(), []string{root}, BuildOptions{StatCache: cache})
+	if err != nil {
+		t.Fatalf("build manifest: %v", err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("save stat cache: %v", err)
+	}
+
+	reloaded, err := LoadStatCache(cachePath)
+	if err != nil {
+		t.Fatalf("reload stat cache: %v", err)
+	}
+	if len(reloaded.entries) != 1 {
+		t.Fatalf("expected one cached entry, got %d", len(reloaded.entries))
+	}
+	// Poison the cached hash to observe whether the file is re-read.
+	cached := reloaded.entries[filePath]
+	cached.SHA256 = "cached"
+	reloaded.entries[filePath] = cached
+
+	second, err := BuildManifestWithOptions(context.Background(), []string{root}, BuildOptions{StatCache: reloaded})
+	if err != nil {
+		t.Fatalf("build manifest with cache: %v", err)
+	}
+	if got := second.Entries[1].SHA256; got != "cached" {
+		t.Fatalf("expected cached hash to be reused, got %s (fresh %s)", got, first.Entries[1].SHA256)
+	}
+
+	rehashed, err := BuildManifestWithOptions(context.Background(), []string{root}, BuildOptions{StatCache: reloaded, Rehash: true})
+	if err != nil {
+		t.Fatalf("build manifest with rehash: %v", err)
+	}
+	if got := rehashed.Entries[1].SHA256; got != first.Entries[1].SHA256 {
+		t.Fatalf("expected rehash to recompute hash, got %s want %s", got, first.Entries[1].SHA256)
+	}
+}
+
+func TestBuildManifestRehashesChangedFiles(t *testing.T) {
+	requireStatCacheSupport(t)
+	root := t.TempDir()
+	filePath := filepath.Join(root, "doc.txt")
+	modTime := time.Now().Add(-time.Hour)
+	writeStatCacheTestFile(t, filePath, "original", modTime)
+
+	cache, err := LoadStatCache(filepath.Join(t.TempDir(), "stat_cache.json"))
+	if err != nil {
+		t.Fatalf("load stat cache: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := BuildManifestWithOptions(context.Background(), []string{root}, BuildOptions{StatCache: cache}); err != nil {
+		t.Fatalf("build manifest: %v", err)
+	}
+	cache.entries = cache.seen
+	cache.seen = make(map[string]statCacheEntry)
+
+	// Same size and mtime: only ctime (and content) give the change away.
+	writeStatCacheTestFile(t, filePath, "modified", modTime)
+	current, err := BuildManifestWithOptions(context.Background(), []string{root}, BuildOptions{StatCache: cache})
+	if err != nil {
+		t.Fatalf("build manifest after change: %v", err)
+	}
+	want, err := fileSHA256(filePath)
+	if err != nil {
+		t.Fatalf("hash file: %v", err)
+	}
+	if got := current.Entries[1].SHA256; got != want {
+		t.Fatalf("expected changed file to be rehashed, got %s want %s", got, want)
+	}
+}
+
+func TestStatCacheSkipsFilesModifiedDuringScanSecond(t *testing.T) {
+	requireStatCacheSupport(t)
+	root := t.TempDir()
+	filePath := filepath.Join(root, "doc.txt")
+	writeStatCacheTestFile(t, filePath, "fresh", time.Now())
+
+	cache, err := LoadStatCache(filepath.Join(t.TempDir(), "stat_cache.json"))
+	if err != nil {
+		t.Fatalf("load stat cache: %v", err)
+	}
+	if _, err := BuildManifestWithOptions(context.Background(), []string{root}, BuildOptions{StatCache: cache}); err != nil {
+		t.Fatalf("build manifest: %v", err)
+	}
+	if _, ok := cache.seen[filePath]; ok {
+		t.Fatal("expected racily modified file to be left out of the cache")
+	}
+}
+
+func TestStatCacheRehashDue(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := &StatCache{}
+	if cache.RehashDue(0, now) {
+		t.Fatal("zero interval should disable periodic rehash")
+	}
+	if !cache.RehashDue(24*time.Hour, now) {
+		t.Fatal("expected rehash when no full rehash was recorded")
+	}
+	cache.LastFullRehash = now.Add(-12 * time.Hour)
+	if cache.RehashDue(24*time.Hour, now) {
+		t.Fatal("rehash should not be due inside the interval")
+	}
+	cache.LastFullRehash = now.Add(-25 * time.Hour)
+	if !cache.RehashDue(24*time.Hour, now) {
+		t.Fatal("expected rehash once the interval elapsed")
+	}
+}
@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"baxter/internal/crypto"
+	"baxter/internal/storage"
+)
+
+// manifestSourceKindStream marks an entry whose content was read from a
+// stream, such as a database dump piped to baxter backup stdin, rather than
+// from a file under the backup roots. Its Path is the name it was given.
+const manifestSourceKindStream = "stream"
+
+// streamEntryMode is recorded for stream entries, which have no file mode of
+// their own.
+const streamEntryMode fs.FileMode = 0o600
+
+func (e ManifestEntry) IsStream() bool {
+	return e.effectiveSourceKind() == manifestSourceKindStream
+}
+
+// ValidateStreamName checks a name for BackupStream. Names are relative,
+// slash-separated paths such as db/prod.sql, so they can never collide with
+// the absolute paths of files under the backup roots.
+func ValidateStreamName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("stream name is required")
+	}
+	if strings.Contains(name, "\\") || !fs.ValidPath(name) || name == "." || path.Clean(name) != name {
+		return fmt.Errorf("invalid stream name %q: want a relative path like db/prod.sql", name)
+	}
+	return nil
+}
+
+type StreamResult struct {
+	Entry    ManifestEntry
+	Snapshot ManifestSnapshot
+	// Uploaded counts the chunks that were not already stored.
+	Uploaded int
+}
+
+// BackupStream stores everything read from r as a stream entry called name
+// and commits a snapshot holding the previous snapshot's entries plus this
+// one, replacing any earlier entry of that name. Content is chunked and
+// encrypted like large files, so successive dumps share unchanged chunks.
+// Later runs of Run keep the entry until it is replaced.
+func BackupStream(ctx context.Context, r io.Reader, name string, opts RunOptions) (StreamResult, error) {
+	if err := ValidateStreamName(name); err != nil {
+		return StreamResult{}, err
+	}
+	if err := opts.validate(); err != nil {
+		return StreamResult{}, err
+	}
+	previous, err := LoadManifest(opts.ManifestPath)
+	if err != nil {
+		return StreamResult{}, fmt.Errorf("load manifest: %w", err)
+	}
+
+	uploadOpts := opts
+	uploadOpts.Store = storage.WithContext(ctx, opts.Store)
+	hash := sha256.New()
+	refs, size, uploaded, err := storeChunks(ctx, io.TeeReader(r, hash), name, uploadOpts, newChunkIndex(previous))
+	if err != nil {
+		return StreamResult{}, err
+	}
+	entry := ManifestEntry{
+		Path:       name,
+		Size:       size,
+		Mode:       streamEntryMode,
+		ModTime:    time.Now().UTC(),
+		SHA256:     hex.EncodeToString(hash.Sum(nil)),
+		Chunks:     refs,
+		SourceKind: manifestSourceKindStream,
+	}
+	if size == 0 {
+		// An empty stream has no chunks; store it like an empty file.
+		entry.ObjectKey = ObjectKeyForContentSHA256(entry.SHA256)
+		if err := storeEmptyObject(uploadOpts, entry.ObjectKey); err != nil {
+			return StreamResult{}, err
+		}
+	}
+
+	current := &Manifest{CreatedAt: time.Now().UTC(), Tags: opts.Tags}
+	for _, existing := range previous.Entries {
+		if existing.Path != name {
+			current.Entries = append(current.Entries, existing)
+		}
+	}
+	current.Entries = append(current.Entries, entry)
+	sortManifestEntries(current)
+
+	if replicated, ok := uploadOpts.Store.(*storage.ReplicatedStore); ok {
+		if err := catchUpReplicas(ctx, replicated, current); err != nil {
+			return StreamResult{}, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return StreamResult{}, err
+	}
+	snapshot, err := commitSnapshot(opts, current)
+	if err != nil {
+		return StreamResult{}, err
+	}
+	if err := pruneSnapshots(opts); err != nil {
+		return StreamResult{}, err
+	}
+	return StreamResult{Entry: entry, Snapshot: snapshot, Uploaded: uploaded}, nil
+}
+
+func storeEmptyObject(opts RunOptions, objectKey string) error {
+	if exists, err := storage.ObjectExists(opts.Store, objectKey); err == nil && exists {
+		return nil
+	}
+	encrypted, err := crypto.EncryptBytes(opts.EncryptionKey, nil)
+	if err != nil {
+		return fmt.Errorf("encrypt empty stream: %w", err)
+	}
+	if err := putObjectWithRetry(opts.Store, objectKey, encrypted, opts.effectiveUploadMaxAttempts()); err != nil {
+		return fmt.Errorf("store empty stream: %w", err)
+	}
+	return nil
+}
+
+// streamEntries returns the stream entries of m.
+func streamEntries(m *Manifest) []ManifestEntry {
+	if m == nil {
+		return nil
+	}
+	var entries []ManifestEntry
+	for _, entry := range m.Entries {
+		if entry.IsStream() {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func sortManifestEntries(m *Manifest) {
+	sort.Slice(m.Entries, func(i, j int) bool {
+		return m.Entries[i].Path < m.Entries[j].Path
+	})
+}
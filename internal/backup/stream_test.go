@@ -0,0 +1,125 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"baxter/internal/config"
+	"baxter/internal/storage"
+)
+
+func streamTestOptions(t *testing.T) RunOptions {
+	t.Helper()
+	return RunOptions{
+		ManifestPath:      filepath.Join(t.TempDir(), "manifest.json"),
+		SnapshotDir:       filepath.Join(t.TempDir(), "manifests"),
+		SnapshotRetention: 30,
+		EncryptionKey:     []byte("01234567890123456789012345678901"),
+		KDFSalt:           testKDFSalt,
+		BackupSetID:       "local-test",
+		Store:             storage.NewLocalClient(filepath.Join(t.TempDir(), "objects")),
+	}
+}
+
+func TestBackupStreamDeduplicatesAndSurvivesRun(t *testing.T) {
+	root := t.TempDir()
+	filePath := filepath.Join(root, "doc.txt")
+	if err := os.WriteFile(filePath, []byte("hello"), 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+	cfg := &config.Config{BackupRoots: []string{root}}
+	opts := streamTestOptions(t)
+	if _, err := Run(context.Background(), cfg, opts); err != nil {
+		t.Fatalf("run backup: %v", err)
+	}
+
+	dump := chunkTestPayload(3<<20, 7)
+	first, err := BackupStream(context.Background(), bytes.NewReader(dump), "db/prod.sql", opts)
+	if err != nil {
+		t.Fatalf("backup stream: %v", err)
+	}
+	if !first.Entry.IsStream() || !first.Entry.IsChunked() || first.Entry.Size != int64(len(dump)) {
+		t.Fatalf("unexpected stream entry: %+v", first.Entry)
+	}
+	if first.Uploaded != len(first.Entry.Chunks) {
+		t.Fatalf("expected every chunk uploaded, got %d of %d", first.Uploaded, len(first.Entry.Chunks))
+	}
+	m, err := LoadManifest(opts.ManifestPath)
+	if err != nil {
+		t.Fatalf("load manifest: %v", err)
+	}
+	if _, err := FindEntryByPath(m, filePath); err != nil {
+		t.Fatalf("expected file from the previous snapshot: %v", err)
+	}
+	var restored bytes.Buffer
+	if err := CopyStoredEntryContent(&restored, opts.Store, [][]byte{opts.EncryptionKey}, first.Entry); err != nil {
+		t.Fatalf("read stream back: %v", err)
+	}
+	if !bytes.Equal(restored.Bytes(), dump) {
+		t.Fatal("restored stream does not match")
+	}
+
+	second, err := BackupStream(context.Background(), bytes.NewReader(dump), "db/prod.sql", opts)
+	if err != nil {
+		t.Fatalf("repeat backup stream: %v", err)
+	}
+	if second.Uploaded != 0 {
+		t.Fatalf("expected unchanged dump to reuse its chunks, uploaded %d", second.Uploaded)
+	}
+
+	if _, err := Run(context.Background(), cfg, opts); err != nil {
+		t.Fatalf("rerun backup: %v", err)
+	}
+	m, err = LoadManifest(opts.ManifestPath)
+	if err != nil {
+		t.Fatalf("load manifest: %v", err)
+	}
+	kept, err := FindEntryByPath(m, "db/prod.sql")
+	if err != nil {
+		t.Fatalf("expected stream entry to survive a run: %v", err)
+	}
+	if kept.SHA256 != first.Entry.SHA256 || !kept.IsStream() {
+		t.Fatalf("unexpected kept entry: %+v", kept)
+	}
+	snapshots, err := ListSnapshotManifests(opts.SnapshotDir)
+	if err != nil {
+		t.Fatalf("list snapshots: %v", err)
+	}
+	if len(snapshots) != 4 {
+		t.Fatalf("expected 4 snapshots, got %d", len(snapshots))
+	}
+}
+
+func TestBackupStreamStoresEmptyStream(t *testing.T) {
+	opts := streamTestOptions(t)
+	result, err := BackupStream(context.Background(), bytes.NewReader(nil), "empty.log", opts)
+	if err != nil {
+		t.Fatalf("backup stream: %v", err)
+	}
+	if result.Entry.Size != 0 || result.Entry.ObjectKey == "" {
+		t.Fatalf("unexpected empty stream entry: %+v", result.Entry)
+	}
+	var restored bytes.Buffer
+	if err := CopyStoredEntryContent(&restored, opts.Store, [][]byte{opts.EncryptionKey}, result.Entry); err != nil {
+		t.Fatalf("read empty stream back: %v", err)
+	}
+	if restored.Len() != 0 {
+		t.Fatalf("expected empty content, got %d bytes", restored.Len())
+	}
+}
+
+func TestValidateStreamName(t *testing.T) {
+	for _, name := range []string{"db/prod.sql", "dump.tar"} {
+		if err := ValidateStreamName(name); err != nil {
+			t.Fatalf("expected %q to be valid: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", " ", ".", "/db/prod.sql", "../prod.sql", "db//prod.sql", "db/./prod.sql", `db\prod.sql`} {
+		if err := ValidateStreamName(name); err == nil {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UploadCheckpoint records the entries whose content a backup run has stored,
+// so a run that fails or is interrupted can be resumed without re-encrypting
+// and re-uploading them. On disk it is an append-only JSON lines file like
+// RestoreJournal: a header naming the backup set followed by one record per
+// stored entry, each synced before the upload is reported as done.
+type UploadCheckpoint struct {
+	BackupSetID string    `json:"backup_set_id"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	records map[string]uploadCheckpointRecord
+}
+
+type uploadCheckpointRecord struct {
+	Path      string     `json:"path"`
+	SHA256    string     `json:"sha256"`
+	Size      int64      `json:"size"`
+	ObjectKey string     `json:"object_key,omitempty"`
+	Chunks    []ChunkRef `json:"chunks,omitempty"`
+}
+
+// OpenUploadCheckpoint loads the checkpoint at path for appending. A missing
+// or unreadable checkpoint, or one left by another backup set, is replaced by
+// an empty one; a trailing record torn by a crash is discarded. An empty path
+// keeps the checkpoint in memory only.
+func OpenUploadCheckpoint(path string, backupSetID string) (*UploadCheckpoint, error) {
+	checkpoint := &UploadCheckpoint{
+		BackupSetID: backupSetID,
+		CreatedAt:   time.Now().UTC(),
+		path:        path,
+		records:     make(map[string]uploadCheckpointRecord),
+	}
+	if strings.TrimSpace(path) == "" {
+		return checkpoint, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	existing, validLen, readErr := readUploadCheckpoint(f)
+	if readErr == nil && existing.BackupSetID == backupSetID {
+		checkpoint.CreatedAt = existing.CreatedAt
+		checkpoint.records = existing.records
+	} else {
+		header, err := json.Marshal(checkpoint)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		header = append(header, '\n')
+		if _, err := f.WriteAt(header, 0); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		validLen = int64(len(header))
+	}
+	if err := f.Truncate(validLen); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(validLen, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	checkpoint.file = f
+	return checkpoint, nil
+}
+
+func readUploadCheckpoint(r io.Reader) (*UploadCheckpoint, int64, error) {
+	reader := bufio.NewReader(r)
+	var validLen int64
+	var checkpoint *UploadCheckpoint
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			trimmed := bytes.TrimSpace(line)
+			if checkpoint == nil {
+				var header UploadCheckpoint
+				if err := json.Unmarshal(trimmed, &header); err != nil {
+					return nil, 0, fmt.Errorf("decode header: %w", err)
+				}
+				header.records = make(map[string]uploadCheckpointRecord)
+				checkpoint = &header
+			} else {
+				var record uploadCheckpointRecord
+				if err := json.Unmarshal(trimmed, &record); err != nil {
+					return nil, 0, fmt.Errorf("decode record: %w", err)
+				}
+				checkpoint.records[record.Path] = record
+			}
+			validLen += int64(len(line))
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, 0, err
+		}
+	}
+	if checkpoint == nil {
+		return nil, 0, errors.New("missing header")
+	}
+	return checkpoint, validLen, nil
+}
+
+// Lookup returns entry with its stored object key or chunk list filled in
+// when the checkpoint records the same content for its path. The caller
+// checks that the recorded objects still exist before reusing them.
+func (c *UploadCheckpoint) Lookup(entry ManifestEntry) (ManifestEntry, bool) {
+	c.mu.Lock()
+	record, ok := c.records[filepath.Clean(entry.Path)]
+	c.mu.Unlock()
+	if !ok || record.SHA256 != entry.SHA256 || record.Size != entry.Size {
+		return entry, false
+	}
+	if len(record.Chunks) == 0 && record.ObjectKey != entry.ObjectKey {
+		return entry, false
+	}
+	entry.ObjectKey = record.ObjectKey
+	entry.Chunks = append([]ChunkRef(nil), record.Chunks...)
+	return entry, true
+}
+
+// Record durably notes that the content of entry is in the store. It is safe
+// for concurrent use.
+func (c *UploadCheckpoint) Record(entry ManifestEntry) error {
+	record := uploadCheckpointRecord{
+		Path:      filepath.Clean(entry.Path),
+		SHA256:    entry.SHA256,
+		Size:      entry.Size,
+		ObjectKey: entry.ObjectKey,
+		Chunks:    entry.Chunks,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file != nil {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := c.file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("write upload checkpoint: %w", err)
+		}
+		if err := c.file.Sync(); err != nil {
+			return fmt.Errorf("sync upload checkpoint: %w", err)
+		}
+	}
+	c.records[record.Path] = record
+	return nil
+}
+
+// Close releases the checkpoint file and keeps it for the next run.
+func (c *UploadCheckpoint) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// Remove closes and deletes the checkpoint once its run has committed.
+func (c *UploadCheckpoint) Remove() error {
+	_ = c.Close()
+	if strings.TrimSpace(c.path) == "" {
+		return nil
+	}
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// uploadCheckpointObjectKeys lists the object keys recorded in the checkpoint
+// at path without opening it for writing.
+func uploadCheckpointObjectKeys(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	checkpoint, _, err := readUploadCheckpoint(f)
+	if err != nil {
+		return nil, fmt.Errorf("read upload checkpoint: %w", err)
+	}
+	keys := make([]string, 0, len(checkpoint.records))
+	for _, record := range checkpoint.records {
+		if len(record.Chunks) == 0 {
+			keys = append(keys, record.ObjectKey)
+			continue
+		}
+		for _, chunk := range record.Chunks {
+			keys = append(keys, ObjectKeyForChunkSHA256(chunk.SHA256))
+		}
+	}
+	return keys, nil
+}
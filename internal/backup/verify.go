@@ -1,11 +1,13 @@
 package backup
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"io/fs"
 	"strings"
 
-	"baxter/internal/crypto"
 	"baxter/internal/storage"
 )
 
@@ -23,10 +25,12 @@ func (r VerifyResult) HasFailures() bool {
 }
 
 func VerifyManifestEntries(entries []ManifestEntry, key []byte, store storage.ObjectStore) (VerifyResult, error) {
-	return VerifyManifestEntriesWithKeys(entries, [][]byte{key}, store)
+	return VerifyManifestEntriesWithKeys(context.Background(), entries, [][]byte{key}, store)
 }
 
-func VerifyManifestEntriesWithKeys(entries []ManifestEntry, keys [][]byte, store storage.ObjectStore) (VerifyResult, error) {
+// VerifyManifestEntriesWithKeys checks the stored content of every entry.
+// Once ctx is done it stops and returns the counts so far with ctx's error.
+func VerifyManifestEntriesWithKeys(ctx context.Context, entries []ManifestEntry, keys [][]byte, store storage.ObjectStore) (VerifyResult, error) {
 	if len(keys) == 0 {
 		return VerifyResult{}, fmt.Errorf("at least one encryption key is required")
 	}
@@ -45,31 +49,30 @@ func VerifyManifestEntriesWithKeys(entries []ManifestEntry, keys [][]byte, store
 		return VerifyResult{}, fmt.Errorf("at least one non-empty encryption key is required")
 	}
 
+	store = storage.WithContext(ctx, store)
 	result := VerifyResult{}
 	for _, entry := range entries {
 		if !entry.HasStoredContent() {
 			continue
 		}
+		err := CopyStoredEntryContent(io.Discard, store, validKeys, entry)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return result, ctxErr
+		}
 		result.Checked++
-		payload, err := store.GetObject(ResolveObjectKey(entry))
 		if err != nil {
-			if isMissingObjectError(err) {
+			switch {
+			case errors.Is(err, ErrObjectRead) && isMissingObjectError(err):
 				result.Missing++
-			} else {
+			case errors.Is(err, ErrObjectDecrypt):
+				result.DecryptErrors++
+			case errors.Is(err, ErrChecksumMismatch):
+				result.ChecksumErrors++
+			default:
 				result.ReadErrors++
 			}
 			continue
 		}
-
-		plain, err := crypto.DecryptBytesWithAnyKey(validKeys, payload)
-		if err != nil {
-			result.DecryptErrors++
-			continue
-		}
-		if err := VerifyEntryContent(entry, plain); err != nil {
-			result.ChecksumErrors++
-			continue
-		}
 		result.OK++
 	}
 
@@ -80,7 +83,7 @@ func isMissingObjectError(err error) bool {
 	if err == nil {
 		return false
 	}
-	if os.IsNotExist(err) {
+	if errors.Is(err, fs.ErrNotExist) {
 		return true
 	}
 	msg := strings.ToLower(err.Error())
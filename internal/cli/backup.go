@@ -1,15 +1,111 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"baxter/internal/backup"
 	"baxter/internal/config"
 	"baxter/internal/recovery"
+	"baxter/internal/repolock"
 	"baxter/internal/state"
+	"baxter/internal/storage"
 )
 
-func runBackup(cfg *config.Config) error {
+func runBackup(cfg *config.Config, opts backupRunOptions) error {
+	manifestPath, err := state.ManifestPath()
+	if err != nil {
+		return err
+	}
+	statCachePath, err := state.StatCachePath()
+	if err != nil {
+		return err
+	}
+	checkpointPath, err := state.UploadCheckpointPath()
+	if err != nil {
+		return err
+	}
+	snapshotDir, err := state.ManifestSnapshotsDir()
+	if err != nil {
+		return err
+	}
+
+	store, err := objectStoreFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	defer storage.Close(store)
+	// The lock is taken before the keys are resolved, which may create the
+	// wrapped key in the recovery metadata.
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "backup")
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	saltPath, err := state.KDFSaltPath()
+	if err != nil {
+		return err
+	}
+	allowCreateWrappedIfMissing, err := backup.AllowCreateWrappedKeyWithoutMetadata(manifestPath, snapshotDir, saltPath, store)
+	if err != nil {
+		return err
+	}
+	keys, err := backupEncryptionKeys(cfg, store, allowCreateWrappedIfMissing)
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C stops the scan and uploads; the previous snapshot stays current.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, _, err := hookRunner(cfg).Backup(ctx, func(ctx context.Context) (backup.RunResult, error) {
+		return backup.Run(ctx, cfg, backup.RunOptions{
+			ManifestPath:       manifestPath,
+			SnapshotDir:        snapshotDir,
+			SnapshotRetention:  cfg.Retention.ManifestSnapshots,
+			SnapshotMaxAgeDays: cfg.Retention.ManifestMaxAgeDays,
+			EncryptionKey:      keys.primary,
+			KDFSalt:            keys.salt,
+			WrappedMasterKey:   keys.wrapped,
+			BackupSetID:        recovery.BackupSetID(cfg),
+			Store:              store,
+			StatCachePath:      statCachePath,
+			Rehash:             opts.Rehash,
+			RehashInterval:     time.Duration(cfg.RehashIntervalDays) * 24 * time.Hour,
+			CheckpointPath:     checkpointPath,
+			SnapshotKeep:       backup.SnapshotKeepRulesFromConfig(cfg.Retention),
+			Tags:               opts.Tags,
+			Lock:               lock,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return errors.New("backup cancelled; previous snapshot left unchanged, next run resumes the upload")
+		}
+		return err
+	}
+
+	fmt.Printf("backup complete: uploaded=%d reused=%d removed=%d total=%d\n", result.Uploaded, result.Reused, result.Removed, result.Total)
+	for _, status := range storage.DestinationStatuses(store) {
+		if status.Failed {
+			fmt.Fprintf(os.Stderr, "destination %s: failed, snapshot not written there (caught up on the next run): %v\n", status.Name, status.Err)
+			continue
+		}
+		fmt.Printf("destination %s: ok\n", status.Name)
+	}
+	return nil
+}
+
+// backupStdin stores everything read from in as the stream entry
+// opts.Name in a new snapshot.
+func backupStdin(cfg *config.Config, opts backupStdinOptions, in io.Reader) error {
 	manifestPath, err := state.ManifestPath()
 	if err != nil {
 		return err
@@ -23,6 +119,16 @@ func runBackup(cfg *config.Config) error {
 	if err != nil {
 		return err
 	}
+	defer storage.Close(store)
+	// The new snapshot is the previous manifest plus the stream entry; an
+	// exclusive lock keeps a concurrent backup from committing in between
+	// and dropping one or the other. It is taken before the keys are
+	// resolved, which may create the wrapped key in the recovery metadata.
+	lock, err := repolock.AcquireForOperation(store, repolock.Exclusive, "backup stdin")
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
 	saltPath, err := state.KDFSaltPath()
 	if err != nil {
 		return err
@@ -36,22 +142,49 @@ func runBackup(cfg *config.Config) error {
 		return err
 	}
 
-	result, err := backup.Run(cfg, backup.RunOptions{
-		ManifestPath:       manifestPath,
-		SnapshotDir:        snapshotDir,
-		SnapshotRetention:  cfg.Retention.ManifestSnapshots,
-		SnapshotMaxAgeDays: cfg.Retention.ManifestMaxAgeDays,
-		EncryptionKey:      keys.primary,
-		KDFSalt:            keys.salt,
-		WrappedMasterKey:   keys.wrapped,
-		BackupSetID:        recovery.BackupSetID(cfg),
-		Store:              store,
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The stream backup runs between the same hooks as a backup run; the
+	// hooks see the stream's chunk uploads and the new snapshot.
+	var result backup.StreamResult
+	_, _, err = hookRunner(cfg).Backup(ctx, func(ctx context.Context) (backup.RunResult, error) {
+		var err error
+		result, err = backup.BackupStream(ctx, in, opts.Name, backup.RunOptions{
+			ManifestPath:       manifestPath,
+			SnapshotDir:        snapshotDir,
+			SnapshotRetention:  cfg.Retention.ManifestSnapshots,
+			SnapshotMaxAgeDays: cfg.Retention.ManifestMaxAgeDays,
+			EncryptionKey:      keys.primary,
+			KDFSalt:            keys.salt,
+			WrappedMasterKey:   keys.wrapped,
+			BackupSetID:        recovery.BackupSetID(cfg),
+			Store:              store,
+			SnapshotKeep:       backup.SnapshotKeepRulesFromConfig(cfg.Retention),
+			Tags:               opts.Tags,
+			Lock:               lock,
+		})
+		return backup.RunResult{
+			Uploaded:   result.Uploaded,
+			Total:      result.Snapshot.Entries,
+			SnapshotID: result.Snapshot.ID,
+		}, err
 	})
 	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return errors.New("backup cancelled; previous snapshot left unchanged")
+		}
 		return err
 	}
 
-	fmt.Printf("backup complete: uploaded=%d removed=%d total=%d\n", result.Uploaded, result.Removed, result.Total)
+	fmt.Printf("backup stdin complete: name=%s size=%d chunks=%d uploaded=%d snapshot=%s\n", result.Entry.Path, result.Entry.Size, len(result.Entry.Chunks), result.Uploaded, result.Snapshot.ID)
+	for _, status := range storage.DestinationStatuses(store) {
+		if status.Failed {
+			fmt.Fprintf(os.Stderr, "destination %s: failed, snapshot not written there (caught up on the next run): %v\n", status.Name, status.Err)
+			continue
+		}
+		fmt.Printf("destination %s: ok\n", status.Name)
+	}
 	return nil
 }
 
@@ -78,6 +211,7 @@ func backupStatus(cfg *config.Config) error {
 	if err != nil {
 		return err
 	}
+	defer storage.Close(store)
 	keys, err := store.ListKeys()
 	if err != nil {
 		return fmt.Errorf("list objects: %w", err)
@@ -85,8 +219,8 @@ func backupStatus(cfg *config.Config) error {
 	keys = backup.FilterDataObjectKeys(keys)
 
 	latestSnapshot := ""
-	if len(snapshots) > 0 {
-		latestSnapshot = snapshots[0].ID
+	if latest, ok := backup.LatestCompleteSnapshot(snapshots); ok {
+		latestSnapshot = latest.ID
 	}
 	fmt.Printf(
 		"manifest entries=%d objects=%d snapshots=%d latest_snapshot=%s created_at=%s\n",
@@ -98,3 +232,39 @@ func backupStatus(cfg *config.Config) error {
 	)
 	return nil
 }
+
+type backupPauseRequest struct {
+	ResumeAfter string `json:"resume_after,omitempty"`
+}
+
+type backupPauseResponse struct {
+	Status   string `json:"status"`
+	ResumeAt string `json:"resume_at,omitempty"`
+}
+
+// pauseBackup parks the uploads of the backup baxterd is running. The run
+// keeps its place and continues on resume.
+func pauseBackup(opts backupPauseOptions) error {
+	var req backupPauseRequest
+	if opts.ResumeAfter > 0 {
+		req.ResumeAfter = opts.ResumeAfter.String()
+	}
+	var resp backupPauseResponse
+	if err := postDaemon(opts.IPCAddr, "/v1/backup/pause", req, &resp); err != nil {
+		return fmt.Errorf("pause backup: %w", err)
+	}
+	if resp.ResumeAt != "" {
+		fmt.Printf("backup paused until %s\n", resp.ResumeAt)
+		return nil
+	}
+	fmt.Println("backup paused; run baxter backup resume to continue")
+	return nil
+}
+
+func resumeBackup(opts backupPauseOptions) error {
+	if err := postDaemon(opts.IPCAddr, "/v1/backup/resume", struct{}{}, nil); err != nil {
+		return fmt.Errorf("resume backup: %w", err)
+	}
+	fmt.Println("backup resumed")
+	return nil
+}
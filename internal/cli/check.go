@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"baxter/internal/backup"
+	"baxter/internal/config"
+	"baxter/internal/repolock"
+	"baxter/internal/storage"
+)
+
+type checkSummary struct {
+	Destination string `json:"destination"`
+	backup.CheckReport
+}
+
+// runCheck reconciles each destination on its own, so a destination missing
+// objects is reported even when another one holds them, and prints a JSON
+// report per destination.
+func runCheck(cfg *config.Config, opts checkOptions) error {
+	destinations := cfg.DestinationNames()
+	if opts.Destination != "" {
+		destinations = []string{opts.Destination}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var failed []string
+	for _, name := range destinations {
+		report, err := checkDestination(ctx, cfg, name, opts)
+		if err != nil {
+			return fmt.Errorf("check destination %s: %w", name, err)
+		}
+		encoded, err := json.Marshal(checkSummary{Destination: name, CheckReport: report})
+		if err != nil {
+			return fmt.Errorf("encode check report: %w", err)
+		}
+		fmt.Println(string(encoded))
+		if report.HasProblems() {
+			failed = append(failed, fmt.Sprintf("%s problems=%d", name, len(report.Problems)))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("check failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func checkDestination(ctx context.Context, cfg *config.Config, name string, opts checkOptions) (backup.CheckReport, error) {
+	store, err := objectStoreForDestination(cfg, name)
+	if err != nil {
+		return backup.CheckReport{}, err
+	}
+	defer storage.Close(store)
+	keys, err := accessEncryptionKeys(cfg, store)
+	if err != nil {
+		return backup.CheckReport{}, err
+	}
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "check")
+	if err != nil {
+		return backup.CheckReport{}, err
+	}
+	defer lock.Release()
+
+	report, err := backup.CheckRepository(ctx, backup.CheckOptions{
+		Store:            store,
+		Keys:             keys.candidates,
+		ReadData:         opts.ReadData,
+		ReadDataFraction: opts.ReadDataFraction,
+	})
+	if errors.Is(err, context.Canceled) {
+		return backup.CheckReport{}, errors.New("check cancelled")
+	}
+	return report, err
+}
@@ -36,13 +36,32 @@ func Run(args []string) error {
 	switch rest[0] {
 	case "backup":
 		if len(rest) < 2 {
-			return errors.New("missing backup subcommand (run|status)")
+			return errors.New("missing backup subcommand (run|stdin|status|pause|resume)")
 		}
 		switch rest[1] {
 		case "run":
-			return runBackup(cfg)
+			opts, err := parseBackupRunArgs(rest[2:])
+			if err != nil {
+				return err
+			}
+			return runBackup(cfg, opts)
+		case "stdin":
+			opts, err := parseBackupStdinArgs(rest[2:])
+			if err != nil {
+				return err
+			}
+			return backupStdin(cfg, opts, os.Stdin)
 		case "status":
 			return backupStatus(cfg)
+		case "pause", "resume":
+			opts, err := parseBackupPauseArgs(rest[2:], rest[1] == "pause")
+			if err != nil {
+				return err
+			}
+			if rest[1] == "pause" {
+				return pauseBackup(opts)
+			}
+			return resumeBackup(opts)
 		default:
 			return errors.New("unknown backup subcommand")
 		}
@@ -55,38 +74,84 @@ func Run(args []string) error {
 			return restoreList(cfg, opts)
 		}
 
+		if len(rest) >= 2 && rest[1] == "export" {
+			opts, err := parseRestoreExportArgs(rest[2:])
+			if err != nil {
+				return err
+			}
+			return restoreExport(cfg, opts)
+		}
+
 		opts, restorePathArg, err := parseRestoreArgs(rest[1:])
 		if err != nil {
 			return err
 		}
 		return restorePath(cfg, restorePathArg, opts)
 	case "snapshot":
-		if len(rest) < 2 || rest[1] != "list" {
+		if len(rest) < 2 {
 			return errors.New("unknown snapshot subcommand")
 		}
-		opts, err := parseSnapshotListArgs(rest[2:])
-		if err != nil {
-			return err
+		switch rest[1] {
+		case "list":
+			opts, err := parseSnapshotListArgs(rest[2:])
+			if err != nil {
+				return err
+			}
+			return snapshotList(opts)
+		case "diff":
+			opts, err := parseSnapshotDiffArgs(rest[2:])
+			if err != nil {
+				return err
+			}
+			return snapshotDiff(cfg, opts)
+		default:
+			return errors.New("unknown snapshot subcommand")
 		}
-		return snapshotList(opts)
 	case "gc":
 		opts, err := parseGCArgs(rest[1:])
 		if err != nil {
 			return err
 		}
 		return runGC(cfg, opts)
+	case "unlock":
+		opts, err := parseUnlockArgs(rest[1:])
+		if err != nil {
+			return err
+		}
+		return runUnlock(cfg, opts)
 	case "verify":
 		opts, err := parseVerifyArgs(rest[1:])
 		if err != nil {
 			return err
 		}
 		return runVerify(cfg, opts)
+	case "check":
+		opts, err := parseCheckArgs(rest[1:])
+		if err != nil {
+			return err
+		}
+		return runCheck(cfg, opts)
+	case "mount":
+		opts, err := parseMountArgs(rest[1:])
+		if err != nil {
+			return err
+		}
+		return runMount(cfg, opts)
 	case "restore-drill":
 		opts, err := parseRestoreDrillArgs(rest[1:])
 		if err != nil {
 			return err
 		}
 		return runRestoreDrill(cfg, opts)
+	case "repo":
+		if len(rest) < 2 || rest[1] != "copy" {
+			return errors.New("unknown repo subcommand (copy)")
+		}
+		opts, err := parseRepoCopyArgs(rest[2:])
+		if err != nil {
+			return err
+		}
+		return runRepoCopy(cfg, opts)
 	case "recovery":
 		if len(rest) < 2 {
 			return errors.New("missing recovery subcommand (bootstrap)")
@@ -103,5 +168,5 @@ func Run(args []string) error {
 }
 
 func usageError() error {
-	return errors.New("usage: baxter [-config path] backup run|status | snapshot list [--limit n] | recovery bootstrap | gc [--dry-run] | verify [--snapshot latest|id|RFC3339] [--prefix path] [--limit n] [--sample n] | restore-drill [--snapshot latest|id|RFC3339] [--prefix path] [--sample n] [--limit n] | restore list [--snapshot latest|id|RFC3339] [--prefix path] [--contains text] | restore [--dry-run] [--verify-only] [--to dir] [--overwrite] [--snapshot latest|id|RFC3339] <path>")
+	return errors.New("usage: baxter [-config path] backup run [--rehash] [--tag name]|stdin --name name [--tag name]|status|pause [--for duration] [--ipc-addr addr]|resume [--ipc-addr addr] | snapshot list [--limit n] | snapshot diff [--prefix path] [--json] [--destination name] <from> <to> | recovery bootstrap | repo copy --from name --to name [--snapshot latest|id|RFC3339] [--reencrypt] [--concurrency n] | gc [--dry-run] [--destination name] | unlock --stale|--all [--destination name] | verify [--snapshot latest|id|RFC3339] [--prefix path] [--limit n] [--sample n] [--destination name] | check [--read-data] [--read-data-subset percent] [--destination name] | mount [--destination name] [--cache-mb n] <mountpoint> | restore-drill [--snapshot latest|id|RFC3339] [--prefix path] [--sample n] [--limit n] [--concurrency n] | restore list [--snapshot latest|id|RFC3339] [--prefix path] [--contains text] [--destination name] | restore export [--snapshot latest|id|RFC3339] [--format tar|tar.zst|zip] [--prefix path] [--destination name] -o file|- | restore [--dry-run] [--verify-only] [--to dir] [--overwrite] [--snapshot latest|id|RFC3339] [--no-mtime] [--no-perms] [--no-owner] [--no-xattrs] [--concurrency n] [--continue-on-error] [--destination name] [--stdout] <path> | restore --resume <journal-id> [--concurrency n] [--continue-on-error] [--destination name]")
 }
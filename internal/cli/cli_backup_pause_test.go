@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPauseAndResumeBackupCallDaemon(t *testing.T) {
+	t.Setenv(ipcTokenEnv, "current-token,next-token")
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(ipcTokenHeader); got != "current-token" {
+			t.Errorf("unexpected token: %q", got)
+		}
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+body["resume_after"])
+		if r.URL.Path == "/v1/backup/resume" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"code":"backup_not_paused","message":"backup not paused"}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"paused","resume_at":"2026-03-02T10:30:00Z"}`))
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	if err := pauseBackup(backupPauseOptions{IPCAddr: addr, ResumeAfter: 30 * time.Minute}); err != nil {
+		t.Fatalf("pause backup: %v", err)
+	}
+	err := resumeBackup(backupPauseOptions{IPCAddr: addr})
+	if err == nil || !strings.Contains(err.Error(), "backup not paused (backup_not_paused)") {
+		t.Fatalf("expected daemon error, got %v", err)
+	}
+	want := []string{"POST /v1/backup/pause 30m0s", "POST /v1/backup/resume "}
+	if strings.Join(requests, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected requests: got %q want %q", requests, want)
+	}
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"baxter/internal/backup"
+	"baxter/internal/config"
+)
+
+func TestRunCheckReportsEachDestination(t *testing.T) {
+	homeDir := t.TempDir()
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcRoot, "doc.txt"), []byte("check payload"), 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "check-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+	cfg.S3.Bucket = ""
+	cfg.Destinations = []config.DestinationConfig{{Name: "drive", LocalPath: filepath.Join(t.TempDir(), "drive")}}
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("run backup: %v", err)
+	}
+
+	out, err := captureStdout(t, func() error {
+		return runCheck(cfg, checkOptions{ReadData: true})
+	})
+	if err != nil {
+		t.Fatalf("check: %v (output %q)", err, out)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a report per destination, got %q", out)
+	}
+	for i, name := range []string{"primary", "drive"} {
+		var summary checkSummary
+		if err := json.Unmarshal([]byte(lines[i]), &summary); err != nil {
+			t.Fatalf("decode report: %v", err)
+		}
+		if summary.Destination != name || summary.Snapshots != 1 || summary.ReadObjects != 1 || summary.HasProblems() {
+			t.Fatalf("unexpected report for %s: %s", name, lines[i])
+		}
+	}
+
+	drive, err := objectStoreForDestination(cfg, "drive")
+	if err != nil {
+		t.Fatalf("drive store: %v", err)
+	}
+	keys, err := drive.ListKeys()
+	if err != nil {
+		t.Fatalf("list drive keys: %v", err)
+	}
+	for _, key := range backup.FilterDataObjectKeys(keys) {
+		if err := drive.DeleteObject(key); err != nil {
+			t.Fatalf("delete %s: %v", key, err)
+		}
+	}
+
+	out, err = captureStdout(t, func() error {
+		return runCheck(cfg, checkOptions{Destination: "drive"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "check failed: drive problems=1") {
+		t.Fatalf("expected check to fail on drive, got %v", err)
+	}
+	if !strings.Contains(out, `"kind":"object_missing"`) {
+		t.Fatalf("expected missing object in report, got %q", out)
+	}
+}
@@ -1,12 +1,17 @@
 package cli
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"baxter/internal/backup"
 	"baxter/internal/config"
+	"baxter/internal/repolock"
 	"baxter/internal/state"
 )
 
@@ -65,6 +70,83 @@ func TestRunGCDryRunAndDelete(t *testing.T) {
 	}
 }
 
+func TestRunGCRefusesWhileRepositoryIsLocked(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+
+	cfg := config.DefaultConfig()
+	cfg.S3.Bucket = ""
+	store, err := objectStoreFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("object store: %v", err)
+	}
+	orphanKey := backup.ObjectKeyForPath("/Users/me/Documents/orphan.txt")
+	if err := store.PutObject(orphanKey, []byte("orphan")); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+	manifestPath, err := state.ManifestPath()
+	if err != nil {
+		t.Fatalf("manifest path: %v", err)
+	}
+	if err := backup.SaveManifest(manifestPath, &backup.Manifest{}); err != nil {
+		t.Fatalf("save manifest: %v", err)
+	}
+
+	// A backup running in this process, such as the daemon's.
+	backupLock, err := repolock.AcquireForOperation(store, repolock.Shared, "backup")
+	if err != nil {
+		t.Fatalf("lock repository: %v", err)
+	}
+	err = runGC(cfg, gcOptions{})
+	if !errors.Is(err, repolock.ErrLocked) || !strings.Contains(err.Error(), "baxter unlock --stale") {
+		t.Fatalf("expected gc to be refused, got %v", err)
+	}
+	if _, err := store.GetObject(orphanKey); err != nil {
+		t.Fatalf("object deleted while locked: %v", err)
+	}
+	if err := backupLock.Release(); err != nil {
+		t.Fatalf("release lock: %v", err)
+	}
+
+	// A lock left behind by a machine that stopped refreshing it.
+	now := time.Now().UTC()
+	payload, err := json.Marshal(repolock.Info{
+		ID:        "abandoned",
+		Mode:      repolock.Shared,
+		Operation: "backup",
+		Host:      "other-mac",
+		PID:       4242,
+		CreatedAt: now.Add(-2 * time.Hour),
+		ExpiresAt: now.Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("encode lock: %v", err)
+	}
+	if err := store.PutObject(repolock.KeyPrefix+"abandoned.json", payload); err != nil {
+		t.Fatalf("put lock: %v", err)
+	}
+	out, err := captureStdout(t, func() error {
+		return runUnlock(cfg, unlockOptions{Stale: true})
+	})
+	if err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if !strings.Contains(out, "removed lock: id=abandoned") || !strings.Contains(out, "unlock complete: removed=1 remaining=0") {
+		t.Fatalf("unexpected unlock output: %q", out)
+	}
+
+	if err := runGC(cfg, gcOptions{}); err != nil {
+		t.Fatalf("run gc: %v", err)
+	}
+	if _, err := store.GetObject(orphanKey); !os.IsNotExist(err) {
+		t.Fatalf("orphan key should be deleted, err=%v", err)
+	}
+	if locks, err := repolock.List(store); err != nil || len(locks) != 0 {
+		t.Fatalf("expected gc to release its lock, got %v %v", locks, err)
+	}
+}
+
 func TestRunVerifyDetectsMissingObjects(t *testing.T) {
 	homeDir := t.TempDir()
 	srcRoot := filepath.Join(t.TempDir(), "src")
@@ -86,7 +168,7 @@ func TestRunVerifyDetectsMissingObjects(t *testing.T) {
 	cfg.Schedule = "manual"
 	cfg.S3.Bucket = ""
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 
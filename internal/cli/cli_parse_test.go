@@ -2,16 +2,57 @@ package cli
 
 import (
 	"testing"
+	"time"
 
 	"baxter/internal/backup"
+	"baxter/internal/daemon"
 )
 
+func TestParseBackupRunArgs(t *testing.T) {
+	opts, err := parseBackupRunArgs([]string{"--rehash"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if !opts.Rehash {
+		t.Fatalf("expected rehash option, got %+v", opts)
+	}
+	if _, err := parseBackupRunArgs([]string{"extra"}); err == nil {
+		t.Fatal("expected positional argument error")
+	}
+
+	opts, err = parseBackupRunArgs([]string{"--tag", "release", "--tag", "weekly"})
+	if err != nil {
+		t.Fatalf("parse tags failed: %v", err)
+	}
+	if len(opts.Tags) != 2 || opts.Tags[0] != "release" || opts.Tags[1] != "weekly" {
+		t.Fatalf("unexpected tags: %+v", opts.Tags)
+	}
+	if _, err := parseBackupRunArgs([]string{"--tag", " "}); err == nil {
+		t.Fatal("expected empty tag error")
+	}
+}
+
+func TestParseBackupStdinArgs(t *testing.T) {
+	opts, err := parseBackupStdinArgs([]string{"--name", "db/prod.sql", "--tag", "nightly"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if opts.Name != "db/prod.sql" || len(opts.Tags) != 1 || opts.Tags[0] != "nightly" {
+		t.Fatalf("unexpected opts: %+v", opts)
+	}
+	for _, args := range [][]string{nil, {"--name", "/db/prod.sql"}, {"--name", "db/prod.sql", "extra"}} {
+		if _, err := parseBackupStdinArgs(args); err == nil {
+			t.Fatalf("expected error for %q", args)
+		}
+	}
+}
+
 func TestParseRestoreArgs(t *testing.T) {
-	opts, path, err := parseRestoreArgs([]string{"--dry-run", "--to", "/tmp/out", "--overwrite", "--snapshot", "latest", "/src/file.txt"})
+	opts, path, err := parseRestoreArgs([]string{"--dry-run", "--to", "/tmp/out", "--overwrite", "--snapshot", "latest", "--destination", "drive", "/src/file.txt"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if !opts.DryRun || !opts.Overwrite || opts.ToDir != "/tmp/out" || opts.Snapshot != "latest" {
+	if !opts.DryRun || !opts.Overwrite || opts.ToDir != "/tmp/out" || opts.Snapshot != "latest" || opts.Destination != "drive" {
 		t.Fatalf("unexpected opts: %+v", opts)
 	}
 	if path != "/src/file.txt" {
@@ -19,6 +60,45 @@ func TestParseRestoreArgs(t *testing.T) {
 	}
 }
 
+func TestParseRestoreArgsMetadataSkipFlags(t *testing.T) {
+	opts, _, err := parseRestoreArgs([]string{"--no-mtime", "--no-perms", "--no-owner", "--no-xattrs", "/src/file.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.NoModTime || !opts.NoPerms || !opts.NoOwner || !opts.NoXattrs {
+		t.Fatalf("unexpected opts: %+v", opts)
+	}
+}
+
+func TestParseRestoreArgsResume(t *testing.T) {
+	opts, path, err := parseRestoreArgs([]string{"--resume", "20260101T000000Z-abcd0123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Resume != "20260101T000000Z-abcd0123" || path != "" {
+		t.Fatalf("unexpected resume parse: opts=%+v path=%q", opts, path)
+	}
+	if _, _, err := parseRestoreArgs([]string{"--resume", "id", "/src/file.txt"}); err == nil {
+		t.Fatal("expected error when combining --resume with a path")
+	}
+	if _, _, err := parseRestoreArgs([]string{"--resume", "id", "--dry-run"}); err == nil {
+		t.Fatal("expected error when combining --resume with --dry-run")
+	}
+}
+
+func TestParseRestoreArgsConcurrency(t *testing.T) {
+	opts, _, err := parseRestoreArgs([]string{"--concurrency", "16", "--continue-on-error", "/src"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Concurrency != 16 || !opts.ContinueOnError {
+		t.Fatalf("unexpected opts: %+v", opts)
+	}
+	if _, _, err := parseRestoreArgs([]string{"--concurrency", "-1", "/src"}); err == nil {
+		t.Fatal("expected negative concurrency to be rejected")
+	}
+}
+
 func TestParseRestoreArgsRequiresPath(t *testing.T) {
 	if _, _, err := parseRestoreArgs([]string{"--dry-run"}); err == nil {
 		t.Fatal("expected usage error for missing path")
@@ -31,6 +111,24 @@ func TestParseRestoreArgsRejectsDryRunAndVerifyOnly(t *testing.T) {
 	}
 }
 
+func TestParseRestoreArgsStdout(t *testing.T) {
+	opts, path, err := parseRestoreArgs([]string{"--stdout", "--snapshot", "latest", "db/prod.sql"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Stdout || path != "db/prod.sql" {
+		t.Fatalf("unexpected parse: opts=%+v path=%q", opts, path)
+	}
+	for _, flagName := range []string{"--dry-run", "--verify-only", "--overwrite"} {
+		if _, _, err := parseRestoreArgs([]string{"--stdout", flagName, "db/prod.sql"}); err == nil {
+			t.Fatalf("expected error when combining --stdout with %s", flagName)
+		}
+	}
+	if _, _, err := parseRestoreArgs([]string{"--stdout", "--to", "/tmp/out", "db/prod.sql"}); err == nil {
+		t.Fatal("expected error when combining --stdout with --to")
+	}
+}
+
 func TestParseRestoreListArgs(t *testing.T) {
 	opts, err := parseRestoreListArgs([]string{"--snapshot", "2026-01-01T00:00:00Z", "--prefix", "/Users/me", "--contains", "report"})
 	if err != nil {
@@ -63,13 +161,64 @@ func TestParseSnapshotListArgsRejectsNegativeLimit(t *testing.T) {
 	}
 }
 
+func TestParseSnapshotDiffArgs(t *testing.T) {
+	opts, err := parseSnapshotDiffArgs([]string{"--prefix", "/Users/me", "--json", "--destination", "offsite", "2026-03-01T00:00:00Z", "latest"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := snapshotDiffOptions{From: "2026-03-01T00:00:00Z", To: "latest", Prefix: "/Users/me", JSON: true, Destination: "offsite"}
+	if opts != want {
+		t.Fatalf("unexpected opts: got %+v want %+v", opts, want)
+	}
+}
+
+func TestParseSnapshotDiffArgsRequiresTwoSnapshots(t *testing.T) {
+	if _, err := parseSnapshotDiffArgs([]string{"latest"}); err == nil {
+		t.Fatal("expected usage error for a single snapshot")
+	}
+	if _, err := parseSnapshotDiffArgs([]string{"a", "b", "c"}); err == nil {
+		t.Fatal("expected usage error for extra args")
+	}
+}
+
+func TestParseRestoreExportArgs(t *testing.T) {
+	opts, err := parseRestoreExportArgs([]string{"--snapshot", "latest", "--format", "tar.zst", "--prefix", "/Users/me/Documents", "-o", "-"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := restoreExportOptions{Snapshot: "latest", Format: backup.ArchiveTarZstd, Prefix: "/Users/me/Documents", Output: "-"}
+	if opts != want {
+		t.Fatalf("unexpected opts: got %+v want %+v", opts, want)
+	}
+	if _, err := parseRestoreExportArgs([]string{"--format", "rar", "-o", "out.rar"}); err == nil {
+		t.Fatal("expected unsupported format to be rejected")
+	}
+	if _, err := parseRestoreExportArgs([]string{"--format", "zip"}); err == nil {
+		t.Fatal("expected usage error without -o")
+	}
+}
+
+func TestParseMountArgs(t *testing.T) {
+	opts, err := parseMountArgs([]string{"--destination", "offsite", "--cache-mb", "128", "/mnt/baxter"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := mountOptions{Mountpoint: "/mnt/baxter", Destination: "offsite", CacheMB: 128}
+	if opts != want {
+		t.Fatalf("unexpected opts: got %+v want %+v", opts, want)
+	}
+	if _, err := parseMountArgs(nil); err == nil {
+		t.Fatal("expected usage error without a mountpoint")
+	}
+}
+
 func TestParseGCArgs(t *testing.T) {
-	opts, err := parseGCArgs([]string{"--dry-run"})
+	opts, err := parseGCArgs([]string{"--dry-run", "--destination", "drive"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if !opts.DryRun {
-		t.Fatal("expected dry-run=true")
+	if !opts.DryRun || opts.Destination != "drive" {
+		t.Fatalf("unexpected opts: %+v", opts)
 	}
 }
 
@@ -80,11 +229,11 @@ func TestParseGCArgsRejectsPositionalArgs(t *testing.T) {
 }
 
 func TestParseVerifyArgs(t *testing.T) {
-	opts, err := parseVerifyArgs([]string{"--snapshot", "latest", "--prefix", "/Users/me", "--limit", "10", "--sample", "5"})
+	opts, err := parseVerifyArgs([]string{"--snapshot", "latest", "--prefix", "/Users/me", "--limit", "10", "--sample", "5", "--destination", "offsite"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if opts.Snapshot != "latest" || opts.Prefix != "/Users/me" || opts.Limit != 10 || opts.Sample != 5 {
+	if opts.Snapshot != "latest" || opts.Prefix != "/Users/me" || opts.Limit != 10 || opts.Sample != 5 || opts.Destination != "offsite" {
 		t.Fatalf("unexpected opts: %+v", opts)
 	}
 }
@@ -98,6 +247,64 @@ func TestParseVerifyArgsRejectsInvalidValues(t *testing.T) {
 	}
 }
 
+func TestParseRepoCopyArgs(t *testing.T) {
+	opts, err := parseRepoCopyArgs([]string{"--from", "primary", "--to", "offsite", "--snapshot", "latest", "--snapshot", "2026-01-01T00:00:00Z", "--reencrypt", "--concurrency", "8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.From != "primary" || opts.To != "offsite" || len(opts.Snapshots) != 2 || !opts.Reencrypt || opts.Concurrency != 8 {
+		t.Fatalf("unexpected opts: %+v", opts)
+	}
+
+	for _, args := range [][]string{
+		{"--from", "primary"},
+		{"--from", "primary", "--to", "primary"},
+		{"--from", "primary", "--to", "offsite", "extra"},
+		{"--from", "primary", "--to", "offsite", "--concurrency", "-1"},
+	} {
+		if _, err := parseRepoCopyArgs(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestParseCheckArgs(t *testing.T) {
+	opts, err := parseCheckArgs([]string{"--read-data-subset", "5%", "--destination", "offsite"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.ReadData || opts.ReadDataFraction != 0.05 || opts.Destination != "offsite" {
+		t.Fatalf("unexpected opts: %+v", opts)
+	}
+
+	for _, args := range [][]string{
+		{"--read-data-subset", "0%"},
+		{"--read-data-subset", "150%"},
+		{"--read-data-subset", "some"},
+		{"extra"},
+	} {
+		if _, err := parseCheckArgs(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestParseUnlockArgs(t *testing.T) {
+	opts, err := parseUnlockArgs([]string{"--stale", "--destination", "offsite"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Stale || opts.All || opts.Destination != "offsite" {
+		t.Fatalf("unexpected opts: %+v", opts)
+	}
+
+	for _, args := range [][]string{nil, {"--stale", "--all"}, {"--all", "extra"}} {
+		if _, err := parseUnlockArgs(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
 func TestParseRestoreDrillArgs(t *testing.T) {
 	opts, err := parseRestoreDrillArgs([]string{"--snapshot", "latest", "--prefix", "/Users/me", "--sample", "12", "--limit", "8"})
 	if err != nil {
@@ -115,6 +322,9 @@ func TestParseRestoreDrillArgsRejectsInvalidValues(t *testing.T) {
 	if _, err := parseRestoreDrillArgs([]string{"--sample", "-1"}); err == nil {
 		t.Fatal("expected negative sample to be rejected")
 	}
+	if _, err := parseRestoreDrillArgs([]string{"--concurrency", "-1"}); err == nil {
+		t.Fatal("expected negative concurrency to be rejected")
+	}
 }
 
 func TestResolvedRestorePath(t *testing.T) {
@@ -137,6 +347,16 @@ func TestResolvedRestorePathNoDestination(t *testing.T) {
 	}
 }
 
+func TestResolvedRestorePathRejectsStreamWithoutDestination(t *testing.T) {
+	if _, err := resolvedRestorePath("db/prod.sql", ""); err == nil {
+		t.Fatal("expected a stream entry without --to to be rejected")
+	}
+	got, err := resolvedRestorePath("db/prod.sql", "/restore")
+	if err != nil || got != "/restore/db/prod.sql" {
+		t.Fatalf("unexpected stream target: %s %v", got, err)
+	}
+}
+
 func TestResolvedRestorePathRejectsTraversal(t *testing.T) {
 	if _, err := resolvedRestorePath("../etc/passwd", "/restore"); err == nil {
 		t.Fatal("expected traversal path to be rejected")
@@ -189,3 +409,26 @@ func TestSampleManifestEntries(t *testing.T) {
 		t.Fatalf("unexpected sample entries: %+v", got)
 	}
 }
+
+func TestParseBackupPauseArgs(t *testing.T) {
+	opts, err := parseBackupPauseArgs([]string{"--for", "45m", "--ipc-addr", "127.0.0.1:5000"}, true)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if opts.ResumeAfter != 45*time.Minute || opts.IPCAddr != "127.0.0.1:5000" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	opts, err = parseBackupPauseArgs(nil, false)
+	if err != nil {
+		t.Fatalf("parse resume failed: %v", err)
+	}
+	if opts.IPCAddr != daemon.DefaultIPCAddress {
+		t.Fatalf("expected default ipc address, got %+v", opts)
+	}
+	if _, err := parseBackupPauseArgs([]string{"--for", "10m"}, false); err == nil {
+		t.Fatal("expected resume to reject --for")
+	}
+	if _, err := parseBackupPauseArgs([]string{"--for", "-1m"}, true); err == nil {
+		t.Fatal("expected negative duration error")
+	}
+}
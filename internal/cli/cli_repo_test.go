@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"baxter/internal/config"
+	"baxter/internal/recovery"
+)
+
+func TestRunRepoCopyCopiesBackupSetToDestination(t *testing.T) {
+	homeDir := t.TempDir()
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcRoot, "doc.txt"), []byte("repo copy payload"), 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "repo-copy-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+	cfg.S3.Bucket = ""
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("run backup: %v", err)
+	}
+
+	// Destinations added after the backup hold nothing yet.
+	cfg.Destinations = []config.DestinationConfig{
+		{Name: "drive", LocalPath: filepath.Join(t.TempDir(), "drive")},
+		{Name: "vault", LocalPath: filepath.Join(t.TempDir(), "vault")},
+	}
+
+	out, err := captureStdout(t, func() error {
+		return runRepoCopy(cfg, repoCopyOptions{From: "primary", To: "drive"})
+	})
+	if err != nil {
+		t.Fatalf("repo copy: %v", err)
+	}
+	if !strings.Contains(out, "repo copy complete: snapshots=1 objects=1 copied=1 skipped=0") || !strings.Contains(out, "reencrypted=false") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if err := runVerify(cfg, verifyOptions{Destination: "drive"}); err != nil {
+		t.Fatalf("verify copy: %v", err)
+	}
+
+	out, err = captureStdout(t, func() error {
+		return runRepoCopy(cfg, repoCopyOptions{From: "drive", To: "vault", Snapshots: []string{"latest"}, Reencrypt: true})
+	})
+	if err != nil {
+		t.Fatalf("reencrypting repo copy: %v", err)
+	}
+	if !strings.Contains(out, "reencrypted=true") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if err := runVerify(cfg, verifyOptions{Destination: "vault"}); err != nil {
+		t.Fatalf("verify reencrypted copy: %v", err)
+	}
+
+	primary, err := objectStoreForDestination(cfg, "primary")
+	if err != nil {
+		t.Fatalf("primary store: %v", err)
+	}
+	vault, err := objectStoreForDestination(cfg, "vault")
+	if err != nil {
+		t.Fatalf("vault store: %v", err)
+	}
+	primaryMetadata, err := recovery.ReadMetadata(primary)
+	if err != nil {
+		t.Fatalf("read primary metadata: %v", err)
+	}
+	vaultMetadata, err := recovery.ReadMetadata(vault)
+	if err != nil {
+		t.Fatalf("read vault metadata: %v", err)
+	}
+	if vaultMetadata.WrappedMasterKey == primaryMetadata.WrappedMasterKey {
+		t.Fatal("reencrypted copy kept the source master key")
+	}
+	if vaultMetadata.BackupSetID != recovery.BackupSetID(cfg) || vaultMetadata.LatestSnapshotID != primaryMetadata.LatestSnapshotID {
+		t.Fatalf("unexpected vault metadata: %+v", vaultMetadata)
+	}
+
+	// The vault now has its own key; copying into it must re-encrypt.
+	err = runRepoCopy(cfg, repoCopyOptions{From: "primary", To: "vault"})
+	if err == nil || !strings.Contains(err.Error(), "pass --reencrypt") {
+		t.Fatalf("expected copy under a different key to be refused, got %v", err)
+	}
+}
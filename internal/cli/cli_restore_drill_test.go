@@ -36,7 +36,7 @@ func TestRunRestoreDrillSuccess(t *testing.T) {
 	cfg.BackupRoots = []string{srcRoot}
 	cfg.Schedule = "manual"
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 
@@ -78,7 +78,7 @@ func TestRunRestoreDrillReturnsErrorWhenSampleFails(t *testing.T) {
 	cfg.BackupRoots = []string{srcRoot}
 	cfg.Schedule = "manual"
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 
@@ -1,9 +1,14 @@
 package cli
 
 import (
+	"archive/zip"
 	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 	"testing"
@@ -11,6 +16,7 @@ import (
 
 	"baxter/internal/backup"
 	"baxter/internal/config"
+	"baxter/internal/repolock"
 	"baxter/internal/state"
 )
 
@@ -37,7 +43,7 @@ func TestRunBackupAndRestorePathWithPassphrase(t *testing.T) {
 	cfg.Schedule = "manual"
 	cfg.S3.Bucket = ""
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 
@@ -60,7 +66,7 @@ func TestRunBackupAndRestorePathWithPassphrase(t *testing.T) {
 	if err := os.WriteFile(sourcePath, updatedContent, 0o600); err != nil {
 		t.Fatalf("update source file: %v", err)
 	}
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("second run backup failed: %v", err)
 	}
 
@@ -128,7 +134,7 @@ func TestRunBackupAndRestoreNestedPaths(t *testing.T) {
 	cfg.BackupRoots = []string{srcRoot}
 	cfg.Schedule = "manual"
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 
@@ -172,7 +178,7 @@ func TestRunBackupAndRestoreNestedPaths(t *testing.T) {
 	if err := os.WriteFile(textPath, updated, 0o600); err != nil {
 		t.Fatalf("update nested text source: %v", err)
 	}
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("second run backup failed: %v", err)
 	}
 
@@ -258,7 +264,7 @@ func TestRestoreDirectoryPathRestoresSubtree(t *testing.T) {
 	cfg.BackupRoots = []string{srcRoot}
 	cfg.Schedule = "manual"
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 
@@ -285,6 +291,298 @@ func TestRestoreDirectoryPathRestoresSubtree(t *testing.T) {
 	}
 }
 
+func TestRestoreDirectoryRecreatesEmptyDirsAndSymlinks(t *testing.T) {
+	homeDir := t.TempDir()
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	restoreRoot := filepath.Join(t.TempDir(), "restore")
+	emptyDir := filepath.Join(srcRoot, "empty")
+	privateDir := filepath.Join(srcRoot, "private")
+	notePath := filepath.Join(privateDir, "note.txt")
+	relLinkPath := filepath.Join(srcRoot, "note-link")
+	absLinkPath := filepath.Join(srcRoot, "private-link")
+
+	if err := os.MkdirAll(emptyDir, 0o755); err != nil {
+		t.Fatalf("mkdir empty dir: %v", err)
+	}
+	if err := os.MkdirAll(privateDir, 0o755); err != nil {
+		t.Fatalf("mkdir private dir: %v", err)
+	}
+	if err := os.WriteFile(notePath, []byte("note"), 0o600); err != nil {
+		t.Fatalf("write note: %v", err)
+	}
+	if err := os.Chmod(privateDir, 0o700); err != nil {
+		t.Fatalf("chmod private dir: %v", err)
+	}
+	noteModTime := time.Date(2024, time.February, 3, 4, 5, 6, 0, time.UTC)
+	if err := os.Chtimes(notePath, noteModTime, noteModTime); err != nil {
+		t.Fatalf("set note mtime: %v", err)
+	}
+	if err := os.Chtimes(privateDir, noteModTime, noteModTime); err != nil {
+		t.Fatalf("set private dir mtime: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("private", "note.txt"), relLinkPath); err != nil {
+		t.Skipf("symlink unsupported in this environment: %v", err)
+	}
+	if err := os.Symlink(privateDir, absLinkPath); err != nil {
+		t.Fatalf("create absolute symlink: %v", err)
+	}
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "entry-kinds-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("run backup failed: %v", err)
+	}
+	if err := restorePath(cfg, srcRoot, restoreOptions{ToDir: restoreRoot}); err != nil {
+		t.Fatalf("restore directory failed: %v", err)
+	}
+
+	restored := func(path string) string {
+		return filepath.Join(restoreRoot, strings.TrimPrefix(filepath.Clean(path), string(filepath.Separator)))
+	}
+
+	if info, err := os.Stat(restored(emptyDir)); err != nil {
+		t.Fatalf("stat restored empty dir: %v", err)
+	} else if !info.IsDir() {
+		t.Fatalf("expected restored empty dir, got mode %v", info.Mode())
+	}
+	if info, err := os.Stat(restored(privateDir)); err != nil {
+		t.Fatalf("stat restored private dir: %v", err)
+	} else if info.Mode().Perm() != 0o700 {
+		t.Fatalf("unexpected restored dir mode: got %v want %v", info.Mode().Perm(), os.FileMode(0o700))
+	} else if !info.ModTime().Equal(noteModTime) {
+		t.Fatalf("unexpected restored dir mtime: got %v want %v", info.ModTime(), noteModTime)
+	}
+	if info, err := os.Stat(restored(notePath)); err != nil {
+		t.Fatalf("stat restored note: %v", err)
+	} else if !info.ModTime().Equal(noteModTime) {
+		t.Fatalf("unexpected restored note mtime: got %v want %v", info.ModTime(), noteModTime)
+	}
+	if target, err := os.Readlink(restored(relLinkPath)); err != nil {
+		t.Fatalf("read restored relative link: %v", err)
+	} else if target != filepath.Join("private", "note.txt") {
+		t.Fatalf("unexpected relative link target: %q", target)
+	}
+	if target, err := os.Readlink(restored(absLinkPath)); err != nil {
+		t.Fatalf("read restored absolute link: %v", err)
+	} else if target != restored(privateDir) {
+		t.Fatalf("expected absolute link target rebased under --to, got %q", target)
+	}
+	if got, err := os.ReadFile(restored(relLinkPath)); err != nil {
+		t.Fatalf("read through restored link: %v", err)
+	} else if string(got) != "note" {
+		t.Fatalf("unexpected content through restored link: %q", string(got))
+	}
+}
+
+func TestRestoreRejectsSymlinkEscapingDestinationRoot(t *testing.T) {
+	homeDir := t.TempDir()
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	restoreRoot := filepath.Join(t.TempDir(), "restore")
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	escapeTarget := strings.Repeat(".."+string(filepath.Separator), strings.Count(srcRoot, string(filepath.Separator))+1) + "etc"
+	if err := os.Symlink(escapeTarget, filepath.Join(srcRoot, "escape")); err != nil {
+		t.Skipf("symlink unsupported in this environment: %v", err)
+	}
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "escape-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("run backup failed: %v", err)
+	}
+	err := restorePath(cfg, srcRoot, restoreOptions{ToDir: restoreRoot})
+	if err == nil || !strings.Contains(err.Error(), "escapes destination root") {
+		t.Fatalf("expected symlink escape error, got %v", err)
+	}
+	if _, err := os.Stat(restoreRoot); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing restored, stat err=%v", err)
+	}
+}
+
+func TestRestoreResumeSkipsCompletedEntries(t *testing.T) {
+	homeDir := t.TempDir()
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	restoreRoot := filepath.Join(t.TempDir(), "restore")
+	firstPath := filepath.Join(srcRoot, "a.txt")
+	secondPath := filepath.Join(srcRoot, "b.txt")
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	if err := os.WriteFile(firstPath, []byte("first"), 0o600); err != nil {
+		t.Fatalf("write first: %v", err)
+	}
+	if err := os.WriteFile(secondPath, []byte("second"), 0o600); err != nil {
+		t.Fatalf("write second: %v", err)
+	}
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "resume-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("run backup failed: %v", err)
+	}
+	m, err := loadRestoreManifest(cfg, "", "")
+	if err != nil {
+		t.Fatalf("load manifest: %v", err)
+	}
+	store, err := objectStoreFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("object store: %v", err)
+	}
+	objectKey := func(path string) string {
+		entry, err := backup.FindEntryByPath(m, path)
+		if err != nil {
+			t.Fatalf("find entry %s: %v", path, err)
+		}
+		return backup.ResolveObjectKey(entry)
+	}
+
+	// Interrupt the restore on the second file.
+	secondPayload, err := store.GetObject(objectKey(secondPath))
+	if err != nil {
+		t.Fatalf("read second object: %v", err)
+	}
+	if err := store.DeleteObject(objectKey(secondPath)); err != nil {
+		t.Fatalf("delete second object: %v", err)
+	}
+	err = restorePath(cfg, srcRoot, restoreOptions{ToDir: restoreRoot})
+	if err == nil || !strings.Contains(err.Error(), "--resume ") {
+		t.Fatalf("expected interrupted restore to name its journal, got %v", err)
+	}
+	journalID := strings.TrimSuffix(err.Error()[strings.LastIndex(err.Error(), "--resume ")+len("--resume "):], ")")
+
+	// Resuming must not fetch the first file again.
+	if err := store.PutObject(objectKey(secondPath), secondPayload); err != nil {
+		t.Fatalf("restore second object: %v", err)
+	}
+	if err := store.DeleteObject(objectKey(firstPath)); err != nil {
+		t.Fatalf("delete first object: %v", err)
+	}
+	opts, _, err := parseRestoreArgs([]string{"--resume", journalID})
+	if err != nil {
+		t.Fatalf("parse resume args: %v", err)
+	}
+	out, err := captureStdout(t, func() error {
+		return restorePath(cfg, "", opts)
+	})
+	if err != nil {
+		t.Fatalf("resume restore failed: %v", err)
+	}
+	if !strings.Contains(out, "resumed_skipped=1") {
+		t.Fatalf("expected one skipped entry, output=%q", out)
+	}
+
+	restored := func(path string) string {
+		return filepath.Join(restoreRoot, strings.TrimPrefix(filepath.Clean(path), string(filepath.Separator)))
+	}
+	for path, want := range map[string]string{firstPath: "first", secondPath: "second"} {
+		if got, err := os.ReadFile(restored(path)); err != nil {
+			t.Fatalf("read restored %s: %v", path, err)
+		} else if string(got) != want {
+			t.Fatalf("unexpected restored content for %s: %q", path, got)
+		}
+	}
+
+	journalDir, err := state.RestoreJournalsDir()
+	if err != nil {
+		t.Fatalf("journal dir: %v", err)
+	}
+	if _, err := backup.OpenRestoreJournal(journalDir, journalID); !errors.Is(err, backup.ErrRestoreJournalNotFound) {
+		t.Fatalf("expected journal to be removed after completion, got %v", err)
+	}
+}
+
+func TestRestoreContinueOnErrorRestoresRemainingEntries(t *testing.T) {
+	homeDir := t.TempDir()
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	restoreRoot := filepath.Join(t.TempDir(), "restore")
+	files := map[string]string{
+		filepath.Join(srcRoot, "a.txt"): "alpha",
+		filepath.Join(srcRoot, "b.txt"): "bravo",
+		filepath.Join(srcRoot, "c.txt"): "charlie",
+	}
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "continue-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("run backup failed: %v", err)
+	}
+	m, err := loadRestoreManifest(cfg, "", "")
+	if err != nil {
+		t.Fatalf("load manifest: %v", err)
+	}
+	store, err := objectStoreFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("object store: %v", err)
+	}
+	brokenPath := filepath.Join(srcRoot, "b.txt")
+	entry, err := backup.FindEntryByPath(m, brokenPath)
+	if err != nil {
+		t.Fatalf("find entry: %v", err)
+	}
+	if err := store.DeleteObject(backup.ResolveObjectKey(entry)); err != nil {
+		t.Fatalf("delete object: %v", err)
+	}
+
+	out, err := captureStdout(t, func() error {
+		return restorePath(cfg, srcRoot, restoreOptions{ToDir: restoreRoot, Concurrency: 2, ContinueOnError: true})
+	})
+	if err == nil || !strings.Contains(err.Error(), "restore incomplete: failures=1") {
+		t.Fatalf("expected incomplete restore error, got %v", err)
+	}
+	if !strings.Contains(out, "restore failed: path="+brokenPath) {
+		t.Fatalf("expected failed entry to be listed, output=%q", out)
+	}
+	for path, want := range files {
+		target := filepath.Join(restoreRoot, strings.TrimPrefix(path, string(filepath.Separator)))
+		got, err := os.ReadFile(target)
+		if path == brokenPath {
+			if !os.IsNotExist(err) {
+				t.Fatalf("expected no file for missing object, got err=%v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("read restored %s: %v", path, err)
+		}
+		if string(got) != want {
+			t.Fatalf("unexpected restored content for %s: %q", path, got)
+		}
+	}
+}
+
 func TestRestorePathFromOlderSnapshotAfterDeletion(t *testing.T) {
 	homeDir := t.TempDir()
 	srcRoot := filepath.Join(t.TempDir(), "src")
@@ -308,7 +606,7 @@ func TestRestorePathFromOlderSnapshotAfterDeletion(t *testing.T) {
 	cfg.Schedule = "manual"
 	cfg.S3.Bucket = ""
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("initial run backup failed: %v", err)
 	}
 
@@ -328,7 +626,7 @@ func TestRestorePathFromOlderSnapshotAfterDeletion(t *testing.T) {
 	if err := os.Remove(sourcePath); err != nil {
 		t.Fatalf("remove source file: %v", err)
 	}
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("second run backup failed: %v", err)
 	}
 
@@ -375,7 +673,7 @@ func TestRestorePathFromOlderSnapshotAfterModification(t *testing.T) {
 	cfg.Schedule = "manual"
 	cfg.S3.Bucket = ""
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("initial run backup failed: %v", err)
 	}
 
@@ -395,7 +693,7 @@ func TestRestorePathFromOlderSnapshotAfterModification(t *testing.T) {
 	if err := os.WriteFile(sourcePath, updatedContent, 0o600); err != nil {
 		t.Fatalf("update source file: %v", err)
 	}
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("second run backup failed: %v", err)
 	}
 
@@ -414,6 +712,98 @@ func TestRestorePathFromOlderSnapshotAfterModification(t *testing.T) {
 	}
 }
 
+func TestSnapshotDiffReportsChangesBetweenBackups(t *testing.T) {
+	homeDir := t.TempDir()
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	editedPath := filepath.Join(srcRoot, "edited.txt")
+	removedPath := filepath.Join(srcRoot, "removed.txt")
+	addedPath := filepath.Join(srcRoot, "added.txt")
+	if err := os.WriteFile(editedPath, []byte("v1"), 0o600); err != nil {
+		t.Fatalf("write edited file: %v", err)
+	}
+	if err := os.WriteFile(removedPath, []byte("bye"), 0o600); err != nil {
+		t.Fatalf("write removed file: %v", err)
+	}
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "test-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+	cfg.S3.Bucket = ""
+
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("initial run backup failed: %v", err)
+	}
+	snapshotDir, err := state.ManifestSnapshotsDir()
+	if err != nil {
+		t.Fatalf("resolve snapshot dir: %v", err)
+	}
+	snapshots, err := backup.ListSnapshotManifests(snapshotDir)
+	if err != nil || len(snapshots) == 0 {
+		t.Fatalf("list snapshots: %v (%d)", err, len(snapshots))
+	}
+	firstID := snapshots[0].ID
+
+	if err := os.WriteFile(editedPath, []byte("version 2"), 0o600); err != nil {
+		t.Fatalf("update edited file: %v", err)
+	}
+	if err := os.Remove(removedPath); err != nil {
+		t.Fatalf("remove file: %v", err)
+	}
+	if err := os.WriteFile(addedPath, []byte("hello"), 0o600); err != nil {
+		t.Fatalf("write added file: %v", err)
+	}
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("second run backup failed: %v", err)
+	}
+
+	out, err := captureStdout(t, func() error {
+		return snapshotDiff(cfg, snapshotDiffOptions{From: firstID, To: "latest", Prefix: srcRoot, JSON: true})
+	})
+	if err != nil {
+		t.Fatalf("snapshot diff failed: %v", err)
+	}
+	var got snapshotDiffOutput
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("decode diff output %q: %v", out, err)
+	}
+	if len(got.Added) != 1 || got.Added[0].Path != addedPath {
+		t.Fatalf("unexpected added: %+v", got.Added)
+	}
+	if len(got.Removed) != 1 || got.Removed[0].Path != removedPath {
+		t.Fatalf("unexpected removed: %+v", got.Removed)
+	}
+	// The source root's mtime changes too, so look the edited file up by path.
+	var edited *backup.DiffChange
+	for i := range got.Modified {
+		if got.Modified[i].Path == editedPath {
+			edited = &got.Modified[i]
+		}
+	}
+	if edited == nil || !edited.ContentChanged || edited.SizeDelta != 7 {
+		t.Fatalf("unexpected modified: %+v", got.Modified)
+	}
+
+	out, err = captureStdout(t, func() error {
+		return snapshotDiff(cfg, snapshotDiffOptions{From: firstID, To: "latest", Prefix: srcRoot})
+	})
+	if err != nil {
+		t.Fatalf("snapshot diff failed: %v", err)
+	}
+	if !strings.Contains(out, "+ "+addedPath+" (5 bytes)") || !strings.Contains(out, "- "+removedPath+" (3 bytes)") {
+		t.Fatalf("unexpected diff output: %q", out)
+	}
+	if !strings.Contains(out, "M "+editedPath+" size 2 -> 9 (+7)") || !strings.Contains(out, "size_delta=+9") {
+		t.Fatalf("unexpected diff summary: %q", out)
+	}
+}
+
 func TestRestorePathReportsMissingObjectClearly(t *testing.T) {
 	homeDir := t.TempDir()
 	srcRoot := filepath.Join(t.TempDir(), "src")
@@ -437,7 +827,7 @@ func TestRestorePathReportsMissingObjectClearly(t *testing.T) {
 	cfg.Schedule = "manual"
 	cfg.S3.Bucket = ""
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 
@@ -492,7 +882,7 @@ func TestRestorePathVerifyOnlyDoesNotWrite(t *testing.T) {
 	cfg.BackupRoots = []string{srcRoot}
 	cfg.Schedule = "manual"
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 	if err := restorePath(cfg, sourcePath, restoreOptions{ToDir: restoreRoot, VerifyOnly: true}); err != nil {
@@ -527,7 +917,7 @@ func TestRestorePathFallsBackToRemoteMetadataWhenLocalCacheMissing(t *testing.T)
 	cfg.BackupRoots = []string{srcRoot}
 	cfg.Schedule = "manual"
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 	clearRestoreCache(t)
@@ -580,7 +970,7 @@ func TestRestorePathChecksumMismatchDoesNotOverwriteTarget(t *testing.T) {
 	cfg.BackupRoots = []string{srcRoot}
 	cfg.Schedule = "manual"
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 
@@ -659,7 +1049,7 @@ func TestRunBackupAndRestoreEdgeFilenames(t *testing.T) {
 	cfg.BackupRoots = []string{srcRoot}
 	cfg.Schedule = "manual"
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("run backup failed: %v", err)
 	}
 
@@ -702,7 +1092,7 @@ func TestRunBackupAndRestoreEdgeFilenames(t *testing.T) {
 	if err := os.WriteFile(spacePath, updated, 0o600); err != nil {
 		t.Fatalf("update spaced source file: %v", err)
 	}
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("second run backup failed: %v", err)
 	}
 
@@ -748,3 +1138,166 @@ func clearRestoreCache(t *testing.T) {
 		t.Fatalf("remove kdf salt: %v", err)
 	}
 }
+
+func TestRestoreExportWritesZipOfDirectory(t *testing.T) {
+	homeDir := t.TempDir()
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	docsDir := filepath.Join(srcRoot, "docs")
+	if err := os.MkdirAll(docsDir, 0o750); err != nil {
+		t.Fatalf("mkdir docs dir: %v", err)
+	}
+	docPath := filepath.Join(docsDir, "plan.txt")
+	if err := os.WriteFile(docPath, []byte("ship it"), 0o640); err != nil {
+		t.Fatalf("write doc: %v", err)
+	}
+	modTime := time.Date(2026, time.January, 6, 8, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(docPath, modTime, modTime); err != nil {
+		t.Fatalf("set doc mtime: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcRoot, "other.txt"), []byte("not exported"), 0o600); err != nil {
+		t.Fatalf("write other file: %v", err)
+	}
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "test-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+	cfg.S3.Bucket = ""
+
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("run backup failed: %v", err)
+	}
+
+	outPath := filepath.Join(t.TempDir(), "docs.zip")
+	out, err := captureStdout(t, func() error {
+		return restoreExport(cfg, restoreExportOptions{Format: backup.ArchiveZip, Prefix: docsDir, Output: outPath})
+	})
+	if err != nil {
+		t.Fatalf("restore export failed: %v", err)
+	}
+	if !strings.Contains(out, "restore export complete:") || !strings.Contains(out, "files=1") {
+		t.Fatalf("unexpected export output: %q", out)
+	}
+
+	archive, err := zip.OpenReader(outPath)
+	if err != nil {
+		t.Fatalf("open exported zip: %v", err)
+	}
+	defer archive.Close()
+	names := make([]string, 0, len(archive.File))
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	docsName := strings.TrimPrefix(filepath.ToSlash(docsDir), "/")
+	if want := []string{docsName + "/", docsName + "/plan.txt"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected archive entries: got %v want %v", names, want)
+	}
+	doc := archive.File[1]
+	if doc.Mode().Perm() != 0o640 || !doc.Modified.Equal(modTime) {
+		t.Fatalf("expected recorded metadata, got mode=%v mtime=%v", doc.Mode(), doc.Modified)
+	}
+	r, err := doc.Open()
+	if err != nil {
+		t.Fatalf("open archived doc: %v", err)
+	}
+	defer r.Close()
+	body, err := io.ReadAll(r)
+	if err != nil || string(body) != "ship it" {
+		t.Fatalf("unexpected archived doc: %q %v", body, err)
+	}
+	leftovers, err := filepath.Glob(filepath.Join(filepath.Dir(outPath), ".docs.zip.baxter-export-*"))
+	if err != nil || len(leftovers) != 0 {
+		t.Fatalf("expected no staging files, got %v %v", leftovers, err)
+	}
+}
+
+func TestBackupStdinRestoresToStdout(t *testing.T) {
+	homeDir := t.TempDir()
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(srcRoot, 0o750); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	docPath := filepath.Join(srcRoot, "notes.txt")
+	if err := os.WriteFile(docPath, []byte("keep me"), 0o600); err != nil {
+		t.Fatalf("write doc: %v", err)
+	}
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "test-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+	cfg.S3.Bucket = ""
+
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("run backup failed: %v", err)
+	}
+	dump := strings.Repeat("INSERT INTO t VALUES (1);\n", 1000)
+	out, err := captureStdout(t, func() error {
+		return backupStdin(cfg, backupStdinOptions{Name: "db/prod.sql"}, strings.NewReader(dump))
+	})
+	if err != nil {
+		t.Fatalf("backup stdin failed: %v", err)
+	}
+	if !strings.Contains(out, "backup stdin complete: name=db/prod.sql") || !strings.Contains(out, "size=26000") {
+		t.Fatalf("unexpected backup stdin output: %q", out)
+	}
+
+	restored, err := captureStdout(t, func() error {
+		return restorePath(cfg, "db/prod.sql", restoreOptions{Stdout: true})
+	})
+	if err != nil {
+		t.Fatalf("restore --stdout failed: %v", err)
+	}
+	if restored != dump {
+		t.Fatalf("restored stream mismatch: got %d bytes want %d", len(restored), len(dump))
+	}
+	if err := restorePath(cfg, "db/prod.sql", restoreOptions{}); err == nil || !strings.Contains(err.Error(), "--stdout or --to") {
+		t.Fatalf("expected restore of a stream without --to to fail, got %v", err)
+	}
+	toDir := t.TempDir()
+	if _, err := captureStdout(t, func() error {
+		return restorePath(cfg, "db/prod.sql", restoreOptions{ToDir: toDir})
+	}); err != nil {
+		t.Fatalf("restore stream --to: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(toDir, "db", "prod.sql")); err != nil || string(got) != dump {
+		t.Fatalf("expected stream restored under --to, got %d bytes (%v)", len(got), err)
+	}
+
+	store, err := objectStoreFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("object store: %v", err)
+	}
+	backupLock, err := repolock.AcquireForOperation(store, repolock.Shared, "backup")
+	if err != nil {
+		t.Fatalf("lock repository: %v", err)
+	}
+	err = backupStdin(cfg, backupStdinOptions{Name: "db/prod.sql"}, strings.NewReader(dump))
+	if !errors.Is(err, repolock.ErrLocked) {
+		t.Fatalf("expected backup stdin to be refused during a backup, got %v", err)
+	}
+	if err := backupLock.Release(); err != nil {
+		t.Fatalf("release lock: %v", err)
+	}
+
+	if err := os.Remove(docPath); err != nil {
+		t.Fatalf("remove doc: %v", err)
+	}
+	restored, err = captureStdout(t, func() error {
+		return restorePath(cfg, docPath, restoreOptions{Stdout: true})
+	})
+	if err != nil || restored != "keep me" {
+		t.Fatalf("expected backed-up file on stdout, got %q %v", restored, err)
+	}
+	if _, err := captureStdout(t, func() error {
+		return restorePath(cfg, srcRoot, restoreOptions{Stdout: true})
+	}); err == nil {
+		t.Fatal("expected restore --stdout of a directory to fail")
+	}
+}
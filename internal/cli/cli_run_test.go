@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"baxter/internal/config"
 	"baxter/internal/crypto"
 	"baxter/internal/recovery"
+	"baxter/internal/repolock"
 	"baxter/internal/state"
 	"baxter/internal/storage"
 )
@@ -142,11 +144,148 @@ func TestRunBackupAllowsFreshLocalStateWithOnlyKDFSalt(t *testing.T) {
 		t.Fatalf("seed local kdf state: %v", err)
 	}
 
-	if err := runBackup(cfg); err != nil {
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
 		t.Fatalf("expected backup to succeed for salt-only local state: %v", err)
 	}
 }
 
+func TestRunBackupTakesTheLockBeforeCreatingKeys(t *testing.T) {
+	setCLIHome(t)
+	t.Setenv(passphraseEnv, "backup-passphrase")
+
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+
+	store, err := objectStoreFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("object store: %v", err)
+	}
+	gcLock, err := repolock.AcquireForOperation(store, repolock.Exclusive, "gc")
+	if err != nil {
+		t.Fatalf("lock repository: %v", err)
+	}
+	defer gcLock.Release()
+
+	if err := runBackup(cfg, backupRunOptions{}); !errors.Is(err, repolock.ErrLocked) {
+		t.Fatalf("expected the backup to be refused, got %v", err)
+	}
+	if err := backupStdin(cfg, backupStdinOptions{Name: "db/prod.sql"}, strings.NewReader("dump")); !errors.Is(err, repolock.ErrLocked) {
+		t.Fatalf("expected the stream backup to be refused, got %v", err)
+	}
+	saltPath, err := state.KDFSaltPath()
+	if err != nil {
+		t.Fatalf("kdf salt path: %v", err)
+	}
+	if _, err := os.Stat(saltPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no KDF salt to be created without the lock, got %v", err)
+	}
+}
+
+func TestRunBackupRunsHooksAroundTheBackup(t *testing.T) {
+	setCLIHome(t)
+	t.Setenv(passphraseEnv, "backup-passphrase")
+
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcRoot, "doc.txt"), []byte("payload"), 0o600); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+	hookLog := filepath.Join(t.TempDir(), "hooks.log")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+	cfg.Hooks.PreBackup = []config.HookConfig{{Command: []string{"/bin/sh", "-c", "exit 1"}}}
+	cfg.Hooks.OnFailure = []config.HookConfig{{Command: []string{"/bin/sh", "-c", `echo "failure $BAXTER_HOOK" >> "$0"`, hookLog}}}
+
+	if err := runBackup(cfg, backupRunOptions{}); err == nil || !strings.Contains(err.Error(), "pre_backup hook") {
+		t.Fatalf("expected pre_backup hook to abort the backup, got %v", err)
+	}
+	snapshotDir, err := state.ManifestSnapshotsDir()
+	if err != nil {
+		t.Fatalf("snapshot dir: %v", err)
+	}
+	if snapshots, err := backup.ListSnapshotManifests(snapshotDir); err != nil || len(snapshots) != 0 {
+		t.Fatalf("expected no snapshot after the aborted backup, got %d (%v)", len(snapshots), err)
+	}
+
+	cfg.Hooks.PreBackup = nil
+	cfg.Hooks.PostBackup = []config.HookConfig{{Command: []string{"/bin/sh", "-c", `echo "post $BAXTER_OPERATION $BAXTER_SNAPSHOT_ID" >> "$0"`, hookLog}}}
+	if err := runBackup(cfg, backupRunOptions{}); err != nil {
+		t.Fatalf("run backup: %v", err)
+	}
+	snapshots, err := backup.ListSnapshotManifests(snapshotDir)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("expected one snapshot, got %d (%v)", len(snapshots), err)
+	}
+
+	logged, err := os.ReadFile(hookLog)
+	if err != nil {
+		t.Fatalf("read hook log: %v", err)
+	}
+	want := "failure on_failure\npost backup " + snapshots[0].ID + "\n"
+	if string(logged) != want {
+		t.Fatalf("unexpected hook log: got %q want %q", logged, want)
+	}
+}
+
+func TestBackupStdinRunsHooksAroundTheBackup(t *testing.T) {
+	setCLIHome(t)
+	t.Setenv(passphraseEnv, "backup-passphrase")
+
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	hookLog := filepath.Join(t.TempDir(), "hooks.log")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+	cfg.Hooks.PreBackup = []config.HookConfig{{Command: []string{"/bin/sh", "-c", "exit 1"}}}
+	cfg.Hooks.OnFailure = []config.HookConfig{{Command: []string{"/bin/sh", "-c", `echo "failure $BAXTER_HOOK" >> "$0"`, hookLog}}}
+
+	dump := "INSERT INTO t VALUES (1);\n"
+	if err := backupStdin(cfg, backupStdinOptions{Name: "db/prod.sql"}, strings.NewReader(dump)); err == nil || !strings.Contains(err.Error(), "pre_backup hook") {
+		t.Fatalf("expected pre_backup hook to abort the stream backup, got %v", err)
+	}
+	snapshotDir, err := state.ManifestSnapshotsDir()
+	if err != nil {
+		t.Fatalf("snapshot dir: %v", err)
+	}
+	if snapshots, err := backup.ListSnapshotManifests(snapshotDir); err != nil || len(snapshots) != 0 {
+		t.Fatalf("expected no snapshot after the aborted stream backup, got %d (%v)", len(snapshots), err)
+	}
+
+	cfg.Hooks.PreBackup = []config.HookConfig{{Command: []string{"/bin/sh", "-c", `echo "pre $BAXTER_OPERATION" >> "$0"`, hookLog}}}
+	cfg.Hooks.PostBackup = []config.HookConfig{{Command: []string{"/bin/sh", "-c", `echo "post $BAXTER_SNAPSHOT_ID $BAXTER_TOTAL" >> "$0"`, hookLog}}}
+	if _, err := captureStdout(t, func() error {
+		return backupStdin(cfg, backupStdinOptions{Name: "db/prod.sql"}, strings.NewReader(dump))
+	}); err != nil {
+		t.Fatalf("backup stdin: %v", err)
+	}
+	snapshots, err := backup.ListSnapshotManifests(snapshotDir)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("expected one snapshot, got %d (%v)", len(snapshots), err)
+	}
+
+	logged, err := os.ReadFile(hookLog)
+	if err != nil {
+		t.Fatalf("read hook log: %v", err)
+	}
+	want := "failure on_failure\npre backup\npost " + snapshots[0].ID + " 1\n"
+	if string(logged) != want {
+		t.Fatalf("unexpected hook log: got %q want %q", logged, want)
+	}
+}
+
 func TestRunBackupMigratesLegacyBackupSetWithoutReupload(t *testing.T) {
 	setCLIHome(t)
 	t.Setenv(passphraseEnv, "legacy-passphrase")
@@ -182,7 +321,7 @@ func TestRunBackupMigratesLegacyBackupSetWithoutReupload(t *testing.T) {
 	salt, initialDataKeys := seedLegacyBackupSet(t, cfg, store, manifestPath, snapshotDir, "legacy-passphrase")
 
 	out, err := captureStdout(t, func() error {
-		return runBackup(cfg)
+		return runBackup(cfg, backupRunOptions{})
 	})
 	if err != nil {
 		t.Fatalf("run backup: %v", err)
@@ -342,6 +481,9 @@ func seedLegacyBackupSet(t *testing.T, cfg *config.Config, store storage.ObjectS
 	key := crypto.KeyFromPassphraseWithSalt(passphrase, salt)
 
 	for _, entry := range manifest.Entries {
+		if !entry.HasStoredContent() {
+			continue
+		}
 		plain, err := os.ReadFile(entry.Path)
 		if err != nil {
 			t.Fatalf("read source file: %v", err)
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	ipcTokenEnv    = "BAXTER_IPC_TOKEN"
+	ipcTokenHeader = "X-Baxter-Token"
+)
+
+var daemonHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+type daemonErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// postDaemon sends request as JSON to a baxterd IPC endpoint and decodes the
+// JSON response into response. The first token in BAXTER_IPC_TOKEN, if any,
+// authenticates the request.
+func postDaemon(addr string, path string, request any, response any) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token, _, _ := strings.Cut(os.Getenv(ipcTokenEnv), ","); strings.TrimSpace(token) != "" {
+		req.Header.Set(ipcTokenHeader, strings.TrimSpace(token))
+	}
+
+	resp, err := daemonHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("contact daemon at %s (is baxterd running?): %w", addr, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("read daemon response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		var errResp daemonErrorResponse
+		if json.Unmarshal(body, &errResp) == nil && errResp.Message != "" {
+			return fmt.Errorf("daemon: %s (%s)", errResp.Message, errResp.Code)
+		}
+		return fmt.Errorf("daemon: unexpected status %s", resp.Status)
+	}
+	if response == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("decode daemon response: %w", err)
+	}
+	return nil
+}
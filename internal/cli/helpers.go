@@ -11,6 +11,7 @@ import (
 	"baxter/internal/backup"
 	"baxter/internal/config"
 	"baxter/internal/crypto"
+	"baxter/internal/hooks"
 	"baxter/internal/recovery"
 	"baxter/internal/recoverycache"
 	"baxter/internal/state"
@@ -83,6 +84,10 @@ func filterRestorePaths(entries []backup.ManifestEntry, opts restoreListOptions)
 
 func resolvedRestorePath(sourcePath string, toDir string) (string, error) {
 	if strings.TrimSpace(toDir) == "" {
+		// Only stream entries, named like db/prod.sql, have relative paths.
+		if !filepath.IsAbs(sourcePath) {
+			return "", fmt.Errorf("%s is a stream with no location on disk; restore it with --stdout or --to dir", sourcePath)
+		}
 		return sourcePath, nil
 	}
 
@@ -282,22 +287,35 @@ func readOrCreateKDFSalt() ([]byte, error) {
 }
 
 func objectStoreFromConfig(cfg *config.Config) (storage.ObjectStore, error) {
+	return objectStoreForDestination(cfg, "")
+}
+
+// objectStoreForDestination opens the named destination alone, or every
+// destination with reads falling back across them when name is empty.
+// Transfers are paced by the configured bandwidth limits.
+func objectStoreForDestination(cfg *config.Config, name string) (storage.ObjectStore, error) {
 	objectsDir, err := state.ObjectStoreDir()
 	if err != nil {
 		return nil, err
 	}
-	store, err := storage.NewFromConfig(cfg.S3, objectsDir)
+	var store storage.ObjectStore
+	if name == "" {
+		store, err = storage.NewFromConfig(cfg, objectsDir)
+	} else {
+		store, err = storage.NewDestinationFromConfig(cfg, objectsDir, name)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("create object store: %w", err)
 	}
-	return store, nil
+	return storage.NewBandwidthLimiter(cfg.Bandwidth).Throttle(store), nil
 }
 
-func loadRestoreManifest(cfg *config.Config, snapshotSelector string) (*backup.Manifest, error) {
-	store, err := objectStoreFromConfig(cfg)
+func loadRestoreManifest(cfg *config.Config, snapshotSelector string, destination string) (*backup.Manifest, error) {
+	store, err := objectStoreForDestination(cfg, destination)
 	if err != nil {
 		return nil, err
 	}
+	defer storage.Close(store)
 	manifest, err := recoverycache.LoadManifest(cfg, store, snapshotSelector, func() (string, error) {
 		return encryptionPassphrase(cfg)
 	})
@@ -306,3 +324,24 @@ func loadRestoreManifest(cfg *config.Config, snapshotSelector string) (*backup.M
 	}
 	return manifest, nil
 }
+
+// hookRunner runs the configured hooks, echoing the output and outcome of
+// each one to stderr.
+func hookRunner(cfg *config.Config) hooks.Runner {
+	return hooks.Runner{
+		Config: cfg.Hooks,
+		Log: func(result hooks.Result) {
+			if result.Output != "" {
+				fmt.Fprint(os.Stderr, result.Output)
+				if !strings.HasSuffix(result.Output, "\n") {
+					fmt.Fprintln(os.Stderr)
+				}
+			}
+			if result.Failed() {
+				fmt.Fprintf(os.Stderr, "hook %s failed: command=%q error=%s\n", result.Stage, result.Command, result.Error)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "hook %s complete: command=%q duration_ms=%d\n", result.Stage, result.Command, result.DurationMS)
+		},
+	}
+}
@@ -1,11 +1,17 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"baxter/internal/backup"
 	"baxter/internal/config"
+	"baxter/internal/hooks"
+	"baxter/internal/repolock"
 	"baxter/internal/state"
+	"baxter/internal/storage"
 )
 
 func runGC(cfg *config.Config, opts gcOptions) error {
@@ -17,22 +23,42 @@ func runGC(cfg *config.Config, opts gcOptions) error {
 	if err != nil {
 		return err
 	}
-	store, err := objectStoreFromConfig(cfg)
+	checkpointPath, err := state.UploadCheckpointPath()
 	if err != nil {
 		return err
 	}
-
-	snapshotPolicy := backup.SnapshotPrunePolicy{
-		Retain:     cfg.Retention.ManifestSnapshots,
-		MaxAgeDays: cfg.Retention.ManifestMaxAgeDays,
+	store, err := objectStoreForDestination(cfg, opts.Destination)
+	if err != nil {
+		return err
+	}
+	defer storage.Close(store)
+	lock, err := repolock.AcquireForOperation(store, repolock.Exclusive, "gc")
+	if err != nil {
+		return err
 	}
+	defer lock.Release()
+
+	snapshotPolicy := backup.SnapshotPrunePolicyFromConfig(cfg.Retention)
 	prunedSnapshots := 0
 	if opts.DryRun {
-		candidates, err := backup.PlanSnapshotPruneManifestsWithPolicy(snapshotDir, snapshotPolicy)
+		decisions, err := backup.PlanSnapshotPruneManifestsWithPolicy(snapshotDir, snapshotPolicy)
 		if err != nil {
 			return err
 		}
-		prunedSnapshots = len(candidates)
+		for _, decision := range decisions {
+			action := "keep"
+			if !decision.Keep {
+				action = "prune"
+				prunedSnapshots++
+			}
+			fmt.Printf(
+				"snapshot %s: id=%s created_at=%s reasons=%s\n",
+				action,
+				decision.Snapshot.ID,
+				decision.Snapshot.CreatedAt.Format(time.RFC3339),
+				strings.Join(decision.Reasons, ","),
+			)
+		}
 	} else {
 		prunedSnapshots, err = backup.PruneSnapshotManifestsWithPolicy(snapshotDir, snapshotPolicy)
 		if err != nil {
@@ -41,14 +67,38 @@ func runGC(cfg *config.Config, opts gcOptions) error {
 	}
 
 	result, err := backup.GarbageCollectObjects(backup.GCOptions{
-		LatestManifestPath: manifestPath,
-		SnapshotDir:        snapshotDir,
-		Store:              store,
-		DryRun:             opts.DryRun,
+		LatestManifestPath:   manifestPath,
+		SnapshotDir:          snapshotDir,
+		Store:                store,
+		DryRun:               opts.DryRun,
+		UploadCheckpointPath: checkpointPath,
+		SnapshotKeys: func() ([][]byte, error) {
+			keys, err := accessEncryptionKeys(cfg, store)
+			if err != nil {
+				return nil, err
+			}
+			return keys.candidates, nil
+		},
+		SnapshotPolicy: snapshotPolicy,
 	})
 	if err != nil {
 		return err
 	}
+	if opts.DryRun {
+		for _, decision := range result.RemoteSnapshotDecisions {
+			action := "keep"
+			if !decision.Keep {
+				action = "prune"
+			}
+			fmt.Printf(
+				"remote snapshot %s: id=%s created_at=%s reasons=%s\n",
+				action,
+				decision.Snapshot.ID,
+				decision.Snapshot.CreatedAt.Format(time.RFC3339),
+				strings.Join(decision.Reasons, ","),
+			)
+		}
+	}
 
 	if result.Skipped {
 		fmt.Printf(
@@ -61,31 +111,70 @@ func runGC(cfg *config.Config, opts gcOptions) error {
 	}
 	if opts.DryRun {
 		fmt.Printf(
-			"gc dry-run: manifests=%d referenced=%d existing=%d would_delete=%d retained=%d would_prune_snapshots=%d\n",
+			"gc dry-run: manifests=%d remote_manifests=%d referenced=%d existing=%d would_delete=%d retained=%d would_prune_snapshots=%d would_prune_remote_snapshots=%d\n",
 			result.SourceManifests,
+			result.RemoteManifests,
 			result.ReferencedObjects,
 			result.ExistingObjects,
 			result.CandidateDeletes,
 			result.RetainedObjects,
 			prunedSnapshots,
+			result.PrunedRemoteManifests,
 		)
 		return nil
 	}
 
 	fmt.Printf(
-		"gc complete: manifests=%d referenced=%d existing=%d deleted=%d retained=%d pruned_snapshots=%d\n",
+		"gc complete: manifests=%d remote_manifests=%d referenced=%d existing=%d deleted=%d retained=%d pruned_snapshots=%d pruned_remote_snapshots=%d\n",
 		result.SourceManifests,
+		result.RemoteManifests,
 		result.ReferencedObjects,
 		result.ExistingObjects,
 		result.DeletedObjects,
 		result.RetainedObjects,
 		prunedSnapshots,
+		result.PrunedRemoteManifests,
 	)
 	return nil
 }
 
+func runUnlock(cfg *config.Config, opts unlockOptions) error {
+	store, err := objectStoreForDestination(cfg, opts.Destination)
+	if err != nil {
+		return err
+	}
+	defer storage.Close(store)
+
+	var removed []repolock.Info
+	if opts.All {
+		removed, err = repolock.RemoveAll(store)
+	} else {
+		removed, err = repolock.RemoveStale(store, time.Now().UTC())
+	}
+	for _, info := range removed {
+		fmt.Printf(
+			"removed lock: id=%s mode=%s operation=%s host=%s pid=%d heartbeat_at=%s\n",
+			info.ID,
+			info.Mode,
+			info.Operation,
+			info.Host,
+			info.PID,
+			info.HeartbeatAt.Format(time.RFC3339),
+		)
+	}
+	if err != nil {
+		return err
+	}
+	remaining, err := repolock.List(store)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("unlock complete: removed=%d remaining=%d\n", len(removed), len(remaining))
+	return nil
+}
+
 func runVerify(cfg *config.Config, opts verifyOptions) error {
-	manifest, err := loadRestoreManifest(cfg, opts.Snapshot)
+	manifest, err := loadRestoreManifest(cfg, opts.Snapshot, opts.Destination)
 	if err != nil {
 		return err
 	}
@@ -99,39 +188,45 @@ func runVerify(cfg *config.Config, opts verifyOptions) error {
 		entries = entries[:opts.Limit]
 	}
 
-	store, err := objectStoreFromConfig(cfg)
+	store, err := objectStoreForDestination(cfg, opts.Destination)
 	if err != nil {
 		return err
 	}
+	defer storage.Close(store)
 	keys, err := accessEncryptionKeys(cfg, store)
 	if err != nil {
 		return err
 	}
-
-	result, err := backup.VerifyManifestEntriesWithKeys(entries, keys.candidates, store)
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "verify")
 	if err != nil {
 		return err
 	}
+	defer lock.Release()
 
-	fmt.Printf(
-		"verify complete: total=%d checked=%d ok=%d missing=%d read_errors=%d decrypt_errors=%d checksum_errors=%d\n",
-		totalCandidates,
-		result.Checked,
-		result.OK,
-		result.Missing,
-		result.ReadErrors,
-		result.DecryptErrors,
-		result.ChecksumErrors,
-	)
-
-	if result.HasFailures() {
-		return fmt.Errorf(
-			"verify failed: missing=%d read_errors=%d decrypt_errors=%d checksum_errors=%d",
+	result, err := backup.VerifyManifestEntriesWithKeys(context.Background(), entries, keys.candidates, store)
+	if err == nil {
+		fmt.Printf(
+			"verify complete: total=%d checked=%d ok=%d missing=%d read_errors=%d decrypt_errors=%d checksum_errors=%d\n",
+			totalCandidates,
+			result.Checked,
+			result.OK,
 			result.Missing,
 			result.ReadErrors,
 			result.DecryptErrors,
 			result.ChecksumErrors,
 		)
+		if result.HasFailures() {
+			err = fmt.Errorf(
+				"verify failed: missing=%d read_errors=%d decrypt_errors=%d checksum_errors=%d",
+				result.Missing,
+				result.ReadErrors,
+				result.DecryptErrors,
+				result.ChecksumErrors,
+			)
+		}
 	}
-	return nil
+
+	// post_verify hooks see the outcome; their failures are only recorded.
+	_, _ = hookRunner(cfg).Run(context.Background(), hooks.PostVerify, hooks.VerifyEnv(result, err))
+	return err
 }
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"baxter/internal/backup"
+	"baxter/internal/config"
+	"baxter/internal/mount"
+	"baxter/internal/recoverycache"
+	"baxter/internal/repolock"
+	"baxter/internal/state"
+	"baxter/internal/storage"
+)
+
+// runMount serves the snapshots of one destination read-only at the
+// mountpoint until interrupted. It holds a shared repository lock so gc
+// cannot delete objects that are being read. On platforms without FUSE
+// support it fails before touching the repository.
+func runMount(cfg *config.Config, opts mountOptions) error {
+	if !mount.Supported {
+		return mount.ErrUnsupported
+	}
+	snapshotDir, err := state.ManifestSnapshotsDir()
+	if err != nil {
+		return err
+	}
+	snapshots, err := backup.ListSnapshotManifests(snapshotDir)
+	if err != nil {
+		return fmt.Errorf("list snapshots: %w", err)
+	}
+
+	store, err := objectStoreForDestination(cfg, opts.Destination)
+	if err != nil {
+		return err
+	}
+	defer storage.Close(store)
+	keys, err := accessEncryptionKeys(cfg, store)
+	if err != nil {
+		return err
+	}
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "mount")
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	fsys := mount.New(mount.Source{
+		Snapshots: snapshots,
+		Load: func(selector string) (*backup.Manifest, error) {
+			return recoverycache.LoadManifest(cfg, store, selector, func() (string, error) {
+				return encryptionPassphrase(cfg)
+			})
+		},
+		Store: store,
+		Keys:  keys.candidates,
+	}, int64(opts.CacheMB)<<20)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("mounting %d snapshots read-only at %s; press Ctrl-C to unmount\n", len(snapshots), opts.Mountpoint)
+	if err := mount.Mount(ctx, fsys, opts.Mountpoint); err != nil {
+		return err
+	}
+	fmt.Printf("unmounted %s\n", opts.Mountpoint)
+	return nil
+}
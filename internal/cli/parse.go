@@ -4,8 +4,95 @@ import (
 	"errors"
 	"flag"
 	"os"
+	"strconv"
+	"strings"
+
+	"baxter/internal/backup"
+	"baxter/internal/daemon"
 )
 
+func parseBackupRunArgs(args []string) (backupRunOptions, error) {
+	runFS := flag.NewFlagSet("backup run", flag.ContinueOnError)
+	runFS.SetOutput(os.Stderr)
+
+	var opts backupRunOptions
+	runFS.BoolVar(&opts.Rehash, "rehash", false, "hash every file instead of reusing hashes for unchanged files")
+	runFS.Func("tag", "tag the snapshot (repeatable); keep_tags retention never prunes tagged snapshots", func(value string) error {
+		tag := strings.TrimSpace(value)
+		if tag == "" {
+			return errors.New("tag must not be empty")
+		}
+		opts.Tags = append(opts.Tags, tag)
+		return nil
+	})
+
+	if err := runFS.Parse(args); err != nil {
+		return backupRunOptions{}, err
+	}
+	if len(runFS.Args()) != 0 {
+		return backupRunOptions{}, errors.New("usage: baxter backup run [--rehash] [--tag name]")
+	}
+	return opts, nil
+}
+
+func parseBackupStdinArgs(args []string) (backupStdinOptions, error) {
+	stdinFS := flag.NewFlagSet("backup stdin", flag.ContinueOnError)
+	stdinFS.SetOutput(os.Stderr)
+
+	var opts backupStdinOptions
+	stdinFS.StringVar(&opts.Name, "name", "", "name of the stream entry, a relative path such as db/prod.sql")
+	stdinFS.Func("tag", "tag the snapshot (repeatable); keep_tags retention never prunes tagged snapshots", func(value string) error {
+		tag := strings.TrimSpace(value)
+		if tag == "" {
+			return errors.New("tag must not be empty")
+		}
+		opts.Tags = append(opts.Tags, tag)
+		return nil
+	})
+
+	if err := stdinFS.Parse(args); err != nil {
+		return backupStdinOptions{}, err
+	}
+	if len(stdinFS.Args()) != 0 || opts.Name == "" {
+		return backupStdinOptions{}, errors.New("usage: baxter backup stdin --name name [--tag name]")
+	}
+	if err := backup.ValidateStreamName(opts.Name); err != nil {
+		return backupStdinOptions{}, err
+	}
+	return opts, nil
+}
+
+// parseBackupPauseArgs parses the flags of backup pause, or of backup resume
+// when pause is false.
+func parseBackupPauseArgs(args []string, pause bool) (backupPauseOptions, error) {
+	name, usage := "backup resume", "usage: baxter backup resume [--ipc-addr addr]"
+	if pause {
+		name, usage = "backup pause", "usage: baxter backup pause [--for duration] [--ipc-addr addr]"
+	}
+	pauseFS := flag.NewFlagSet(name, flag.ContinueOnError)
+	pauseFS.SetOutput(os.Stderr)
+
+	var opts backupPauseOptions
+	pauseFS.StringVar(&opts.IPCAddr, "ipc-addr", daemon.DefaultIPCAddress, "daemon IPC address")
+	if pause {
+		pauseFS.DurationVar(&opts.ResumeAfter, "for", 0, "resume automatically after this long (e.g. 30m); 0 waits for backup resume")
+	}
+
+	if err := pauseFS.Parse(args); err != nil {
+		return backupPauseOptions{}, err
+	}
+	if len(pauseFS.Args()) != 0 {
+		return backupPauseOptions{}, errors.New(usage)
+	}
+	if opts.ResumeAfter < 0 {
+		return backupPauseOptions{}, errors.New("--for must be >= 0")
+	}
+	if strings.TrimSpace(opts.IPCAddr) == "" {
+		return backupPauseOptions{}, errors.New("--ipc-addr must not be empty")
+	}
+	return opts, nil
+}
+
 func parseRestoreArgs(args []string) (restoreOptions, string, error) {
 	restoreFS := flag.NewFlagSet("restore", flag.ContinueOnError)
 	restoreFS.SetOutput(os.Stderr)
@@ -16,18 +103,42 @@ func parseRestoreArgs(args []string) (restoreOptions, string, error) {
 	restoreFS.BoolVar(&opts.Overwrite, "overwrite", false, "overwrite existing target files")
 	restoreFS.BoolVar(&opts.VerifyOnly, "verify-only", false, "verify restore content checksum without writing files")
 	restoreFS.StringVar(&opts.Snapshot, "snapshot", "", "restore from snapshot selector (latest, snapshot id, or RFC3339 timestamp)")
+	restoreFS.BoolVar(&opts.NoModTime, "no-mtime", false, "do not restore recorded modification times")
+	restoreFS.BoolVar(&opts.NoPerms, "no-perms", false, "do not restore recorded permissions")
+	restoreFS.BoolVar(&opts.NoOwner, "no-owner", false, "do not restore recorded ownership (only applied when running as root)")
+	restoreFS.BoolVar(&opts.NoXattrs, "no-xattrs", false, "do not restore recorded extended attributes")
+	restoreFS.StringVar(&opts.Resume, "resume", "", "resume an interrupted restore from its journal id")
+	restoreFS.IntVar(&opts.Concurrency, "concurrency", 0, "number of entries restored in parallel (0 for the default)")
+	restoreFS.BoolVar(&opts.ContinueOnError, "continue-on-error", false, "keep restoring other entries after a failure and report all failures at the end")
+	restoreFS.StringVar(&opts.Destination, "destination", "", "read from this destination only (primary or a [[destinations]] name); by default each object is read from the first destination that has it")
+	restoreFS.BoolVar(&opts.Stdout, "stdout", false, "write the content of a single file to stdout instead of restoring it")
 
 	if err := restoreFS.Parse(args); err != nil {
 		return restoreOptions{}, "", err
 	}
+	if opts.Concurrency < 0 {
+		return restoreOptions{}, "", errors.New("concurrency must be >= 0")
+	}
 
 	rest := restoreFS.Args()
+	if opts.Resume != "" {
+		if len(rest) != 0 {
+			return restoreOptions{}, "", errors.New("usage: baxter restore --resume <journal-id>")
+		}
+		if opts.DryRun || opts.VerifyOnly || opts.Stdout {
+			return restoreOptions{}, "", errors.New("restore --resume cannot be combined with --dry-run, --verify-only or --stdout")
+		}
+		return opts, "", nil
+	}
 	if len(rest) != 1 {
-		return restoreOptions{}, "", errors.New("usage: baxter restore [--dry-run] [--verify-only] [--to dir] [--overwrite] [--snapshot latest|id|RFC3339] <path>")
+		return restoreOptions{}, "", errors.New("usage: baxter restore [--dry-run] [--verify-only] [--to dir] [--overwrite] [--snapshot latest|id|RFC3339] [--no-mtime] [--no-perms] [--no-owner] [--no-xattrs] [--concurrency n] [--continue-on-error] [--destination name] [--stdout] <path> | restore --resume <journal-id> [--concurrency n] [--continue-on-error] [--destination name]")
 	}
 	if opts.DryRun && opts.VerifyOnly {
 		return restoreOptions{}, "", errors.New("restore --dry-run and --verify-only cannot be used together")
 	}
+	if opts.Stdout && (opts.DryRun || opts.VerifyOnly || opts.ToDir != "" || opts.Overwrite) {
+		return restoreOptions{}, "", errors.New("restore --stdout cannot be combined with --dry-run, --verify-only, --to or --overwrite")
+	}
 	return opts, rest[0], nil
 }
 
@@ -39,16 +150,43 @@ func parseRestoreListArgs(args []string) (restoreListOptions, error) {
 	listFS.StringVar(&opts.Prefix, "prefix", "", "filter restore paths by prefix")
 	listFS.StringVar(&opts.Contains, "contains", "", "filter restore paths containing text")
 	listFS.StringVar(&opts.Snapshot, "snapshot", "", "list paths from snapshot selector (latest, snapshot id, or RFC3339 timestamp)")
+	listFS.StringVar(&opts.Destination, "destination", "", "read from this destination only (primary or a [[destinations]] name); by default each object is read from the first destination that has it")
 
 	if err := listFS.Parse(args); err != nil {
 		return restoreListOptions{}, err
 	}
 	if len(listFS.Args()) != 0 {
-		return restoreListOptions{}, errors.New("usage: baxter restore list [--snapshot latest|id|RFC3339] [--prefix path] [--contains text]")
+		return restoreListOptions{}, errors.New("usage: baxter restore list [--snapshot latest|id|RFC3339] [--prefix path] [--contains text] [--destination name]")
 	}
 	return opts, nil
 }
 
+func parseRestoreExportArgs(args []string) (restoreExportOptions, error) {
+	exportFS := flag.NewFlagSet("restore export", flag.ContinueOnError)
+	exportFS.SetOutput(os.Stderr)
+
+	var opts restoreExportOptions
+	var format string
+	exportFS.StringVar(&opts.Snapshot, "snapshot", "", "export from snapshot selector (latest, snapshot id, or RFC3339 timestamp)")
+	exportFS.StringVar(&format, "format", string(backup.ArchiveTar), "archive format: tar, tar.zst or zip")
+	exportFS.StringVar(&opts.Prefix, "prefix", "", "export only this file or directory")
+	exportFS.StringVar(&opts.Output, "o", "", "write the archive to this file, or - for stdout")
+	exportFS.StringVar(&opts.Destination, "destination", "", "read from this destination only (primary or a [[destinations]] name); by default each object is read from the first destination that has it")
+
+	if err := exportFS.Parse(args); err != nil {
+		return restoreExportOptions{}, err
+	}
+	if len(exportFS.Args()) != 0 || strings.TrimSpace(opts.Output) == "" {
+		return restoreExportOptions{}, errors.New("usage: baxter restore export [--snapshot latest|id|RFC3339] [--format tar|tar.zst|zip] [--prefix path] [--destination name] -o file|-")
+	}
+	parsed, err := backup.ParseArchiveFormat(format)
+	if err != nil {
+		return restoreExportOptions{}, err
+	}
+	opts.Format = parsed
+	return opts, nil
+}
+
 func parseSnapshotListArgs(args []string) (snapshotListOptions, error) {
 	listFS := flag.NewFlagSet("snapshot list", flag.ContinueOnError)
 	listFS.SetOutput(os.Stderr)
@@ -68,18 +206,58 @@ func parseSnapshotListArgs(args []string) (snapshotListOptions, error) {
 	return opts, nil
 }
 
+func parseSnapshotDiffArgs(args []string) (snapshotDiffOptions, error) {
+	diffFS := flag.NewFlagSet("snapshot diff", flag.ContinueOnError)
+	diffFS.SetOutput(os.Stderr)
+
+	var opts snapshotDiffOptions
+	diffFS.StringVar(&opts.Prefix, "prefix", "", "only compare paths under this prefix")
+	diffFS.BoolVar(&opts.JSON, "json", false, "print the diff as JSON")
+	diffFS.StringVar(&opts.Destination, "destination", "", "read snapshots from this destination only (primary or a [[destinations]] name)")
+
+	if err := diffFS.Parse(args); err != nil {
+		return snapshotDiffOptions{}, err
+	}
+	positional := diffFS.Args()
+	if len(positional) != 2 || strings.TrimSpace(positional[0]) == "" || strings.TrimSpace(positional[1]) == "" {
+		return snapshotDiffOptions{}, errors.New("usage: baxter snapshot diff [--prefix path] [--json] [--destination name] <from> <to>")
+	}
+	opts.From = strings.TrimSpace(positional[0])
+	opts.To = strings.TrimSpace(positional[1])
+	return opts, nil
+}
+
 func parseGCArgs(args []string) (gcOptions, error) {
 	gcFS := flag.NewFlagSet("gc", flag.ContinueOnError)
 	gcFS.SetOutput(os.Stderr)
 
 	var opts gcOptions
 	gcFS.BoolVar(&opts.DryRun, "dry-run", false, "show object deletions without deleting")
+	gcFS.StringVar(&opts.Destination, "destination", "", "collect garbage on this destination only (primary or a [[destinations]] name); by default on every destination")
 
 	if err := gcFS.Parse(args); err != nil {
 		return gcOptions{}, err
 	}
 	if len(gcFS.Args()) != 0 {
-		return gcOptions{}, errors.New("usage: baxter gc [--dry-run]")
+		return gcOptions{}, errors.New("usage: baxter gc [--dry-run] [--destination name]")
+	}
+	return opts, nil
+}
+
+func parseUnlockArgs(args []string) (unlockOptions, error) {
+	unlockFS := flag.NewFlagSet("unlock", flag.ContinueOnError)
+	unlockFS.SetOutput(os.Stderr)
+
+	var opts unlockOptions
+	unlockFS.BoolVar(&opts.Stale, "stale", false, "remove locks whose process is gone or that have not been refreshed in time")
+	unlockFS.BoolVar(&opts.All, "all", false, "remove every lock, including those of running processes")
+	unlockFS.StringVar(&opts.Destination, "destination", "", "remove locks on this destination only (primary or a [[destinations]] name); by default on every destination")
+
+	if err := unlockFS.Parse(args); err != nil {
+		return unlockOptions{}, err
+	}
+	if len(unlockFS.Args()) != 0 || opts.Stale == opts.All {
+		return unlockOptions{}, errors.New("usage: baxter unlock --stale|--all [--destination name]")
 	}
 	return opts, nil
 }
@@ -93,12 +271,13 @@ func parseVerifyArgs(args []string) (verifyOptions, error) {
 	verifyFS.StringVar(&opts.Prefix, "prefix", "", "verify only paths with this prefix")
 	verifyFS.IntVar(&opts.Limit, "limit", 0, "maximum entries to verify after filtering (0 for all)")
 	verifyFS.IntVar(&opts.Sample, "sample", 0, "sample size before limit is applied (0 for all)")
+	verifyFS.StringVar(&opts.Destination, "destination", "", "read from this destination only (primary or a [[destinations]] name); by default each object is read from the first destination that has it")
 
 	if err := verifyFS.Parse(args); err != nil {
 		return verifyOptions{}, err
 	}
 	if len(verifyFS.Args()) != 0 {
-		return verifyOptions{}, errors.New("usage: baxter verify [--snapshot latest|id|RFC3339] [--prefix path] [--limit n] [--sample n]")
+		return verifyOptions{}, errors.New("usage: baxter verify [--snapshot latest|id|RFC3339] [--prefix path] [--limit n] [--sample n] [--destination name]")
 	}
 	if opts.Limit < 0 {
 		return verifyOptions{}, errors.New("limit must be >= 0")
@@ -109,6 +288,54 @@ func parseVerifyArgs(args []string) (verifyOptions, error) {
 	return opts, nil
 }
 
+func parseCheckArgs(args []string) (checkOptions, error) {
+	checkFS := flag.NewFlagSet("check", flag.ContinueOnError)
+	checkFS.SetOutput(os.Stderr)
+
+	var opts checkOptions
+	checkFS.BoolVar(&opts.ReadData, "read-data", false, "download and decrypt every referenced object and compare its checksum")
+	checkFS.Func("read-data-subset", "like --read-data, for a random percentage of the referenced objects (e.g. 5%)", func(value string) error {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return errors.New("read-data-subset must be a percentage in (0%, 100%]")
+		}
+		opts.ReadData = true
+		opts.ReadDataFraction = percent / 100
+		return nil
+	})
+	checkFS.StringVar(&opts.Destination, "destination", "", "check this destination only (primary or a [[destinations]] name); by default every destination is checked")
+
+	if err := checkFS.Parse(args); err != nil {
+		return checkOptions{}, err
+	}
+	if len(checkFS.Args()) != 0 {
+		return checkOptions{}, errors.New("usage: baxter check [--read-data] [--read-data-subset percent] [--destination name]")
+	}
+	return opts, nil
+}
+
+func parseMountArgs(args []string) (mountOptions, error) {
+	mountFS := flag.NewFlagSet("mount", flag.ContinueOnError)
+	mountFS.SetOutput(os.Stderr)
+
+	var opts mountOptions
+	mountFS.StringVar(&opts.Destination, "destination", "", "read snapshots from this destination only (primary or a [[destinations]] name)")
+	mountFS.IntVar(&opts.CacheMB, "cache-mb", 0, "memory for decrypted file content, in MiB (default 64)")
+
+	if err := mountFS.Parse(args); err != nil {
+		return mountOptions{}, err
+	}
+	if opts.CacheMB < 0 {
+		return mountOptions{}, errors.New("cache-mb must be >= 0")
+	}
+	positional := mountFS.Args()
+	if len(positional) != 1 || strings.TrimSpace(positional[0]) == "" {
+		return mountOptions{}, errors.New("usage: baxter mount [--destination name] [--cache-mb n] <mountpoint>")
+	}
+	opts.Mountpoint = positional[0]
+	return opts, nil
+}
+
 func parseRestoreDrillArgs(args []string) (restoreDrillOptions, error) {
 	drillFS := flag.NewFlagSet("restore-drill", flag.ContinueOnError)
 	drillFS.SetOutput(os.Stderr)
@@ -118,12 +345,13 @@ func parseRestoreDrillArgs(args []string) (restoreDrillOptions, error) {
 	drillFS.StringVar(&opts.Prefix, "prefix", "", "drill only paths with this prefix")
 	drillFS.IntVar(&opts.Sample, "sample", 10, "sample size before limit is applied (0 for all)")
 	drillFS.IntVar(&opts.Limit, "limit", 0, "maximum entries to drill after filtering (0 for all)")
+	drillFS.IntVar(&opts.Concurrency, "concurrency", 0, "number of entries drilled in parallel (0 for the default)")
 
 	if err := drillFS.Parse(args); err != nil {
 		return restoreDrillOptions{}, err
 	}
 	if len(drillFS.Args()) != 0 {
-		return restoreDrillOptions{}, errors.New("usage: baxter restore-drill [--snapshot latest|id|RFC3339] [--prefix path] [--sample n] [--limit n]")
+		return restoreDrillOptions{}, errors.New("usage: baxter restore-drill [--snapshot latest|id|RFC3339] [--prefix path] [--sample n] [--limit n] [--concurrency n]")
 	}
 	if opts.Limit < 0 {
 		return restoreDrillOptions{}, errors.New("limit must be >= 0")
@@ -131,5 +359,47 @@ func parseRestoreDrillArgs(args []string) (restoreDrillOptions, error) {
 	if opts.Sample < 0 {
 		return restoreDrillOptions{}, errors.New("sample must be >= 0")
 	}
+	if opts.Concurrency < 0 {
+		return restoreDrillOptions{}, errors.New("concurrency must be >= 0")
+	}
+	return opts, nil
+}
+
+func parseRepoCopyArgs(args []string) (repoCopyOptions, error) {
+	copyFS := flag.NewFlagSet("repo copy", flag.ContinueOnError)
+	copyFS.SetOutput(os.Stderr)
+
+	var opts repoCopyOptions
+	copyFS.StringVar(&opts.From, "from", "", "destination to copy from (primary or a [[destinations]] name)")
+	copyFS.StringVar(&opts.To, "to", "", "destination to copy to (primary or a [[destinations]] name)")
+	copyFS.Func("snapshot", "copy this snapshot (latest, snapshot id, or RFC3339 timestamp; repeatable); by default every snapshot is copied", func(value string) error {
+		selector := strings.TrimSpace(value)
+		if selector == "" {
+			return errors.New("snapshot must not be empty")
+		}
+		opts.Snapshots = append(opts.Snapshots, selector)
+		return nil
+	})
+	copyFS.BoolVar(&opts.Reencrypt, "reencrypt", false, "encrypt the copy under the target's own master key (a new one when the target is empty)")
+	copyFS.IntVar(&opts.Concurrency, "concurrency", 0, "number of objects copied in parallel (0 for the default)")
+
+	usage := errors.New("usage: baxter repo copy --from name --to name [--snapshot latest|id|RFC3339] [--reencrypt] [--concurrency n]")
+	if err := copyFS.Parse(args); err != nil {
+		return repoCopyOptions{}, err
+	}
+	if len(copyFS.Args()) != 0 {
+		return repoCopyOptions{}, usage
+	}
+	opts.From = strings.TrimSpace(opts.From)
+	opts.To = strings.TrimSpace(opts.To)
+	if opts.From == "" || opts.To == "" {
+		return repoCopyOptions{}, usage
+	}
+	if opts.From == opts.To {
+		return repoCopyOptions{}, errors.New("--from and --to must name different destinations")
+	}
+	if opts.Concurrency < 0 {
+		return repoCopyOptions{}, errors.New("concurrency must be >= 0")
+	}
 	return opts, nil
 }
@@ -18,6 +18,7 @@ func runRecoveryBootstrap(cfg *config.Config) error {
 	if err != nil {
 		return err
 	}
+	defer storage.Close(store)
 
 	result, err := bootstrapRecoveryCache(cfg, store)
 	if err != nil {
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -232,7 +233,7 @@ func seedRemoteRecoveryState(t *testing.T, cfg *config.Config, store storage.Obj
 		t.Fatalf("derive keys: %v", err)
 	}
 
-	if _, err := backup.Run(cfg, backup.RunOptions{
+	if _, err := backup.Run(context.Background(), cfg, backup.RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -280,7 +281,7 @@ func seedRemoteRecoveryHistory(
 		if err := os.WriteFile(sourcePath, version, 0o600); err != nil {
 			t.Fatalf("write source version: %v", err)
 		}
-		if _, err := backup.Run(cfg, backup.RunOptions{
+		if _, err := backup.Run(context.Background(), cfg, backup.RunOptions{
 			ManifestPath:      manifestPath,
 			SnapshotDir:       snapshotDir,
 			SnapshotRetention: 30,
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"baxter/internal/backup"
+	"baxter/internal/config"
+	"baxter/internal/recovery"
+	"baxter/internal/repolock"
+	"baxter/internal/storage"
+)
+
+type repoCopyPlan struct {
+	sourceKeys  [][]byte
+	targetKey   []byte
+	snapshotIDs []string
+	// metadata is written to the target once every snapshot is copied.
+	metadata recovery.Metadata
+}
+
+func runRepoCopy(cfg *config.Config, opts repoCopyOptions) error {
+	source, err := objectStoreForDestination(cfg, opts.From)
+	if err != nil {
+		return err
+	}
+	defer storage.Close(source)
+	target, err := objectStoreForDestination(cfg, opts.To)
+	if err != nil {
+		return err
+	}
+	defer storage.Close(target)
+	passphrase, err := encryptionPassphrase(cfg)
+	if err != nil {
+		return err
+	}
+
+	sourceLock, err := repolock.AcquireForOperation(source, repolock.Shared, "repo copy")
+	if err != nil {
+		return fmt.Errorf("lock %s: %w", opts.From, err)
+	}
+	defer sourceLock.Release()
+	targetLock, err := repolock.AcquireForOperation(target, repolock.Shared, "repo copy")
+	if err != nil {
+		return fmt.Errorf("lock %s: %w", opts.To, err)
+	}
+	defer targetLock.Release()
+
+	plan, err := planRepoCopy(source, target, passphrase, recovery.BackupSetID(cfg), opts, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := backup.CopySnapshots(ctx, backup.CopyOptions{
+		Source:      source,
+		Target:      target,
+		SourceKeys:  plan.sourceKeys,
+		TargetKey:   plan.targetKey,
+		SnapshotIDs: plan.snapshotIDs,
+		Concurrency: opts.Concurrency,
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return errors.New("repo copy cancelled; objects copied so far are skipped by the next run")
+		}
+		return err
+	}
+	if err := recovery.WriteMetadata(target, plan.metadata); err != nil {
+		return fmt.Errorf("write recovery metadata to %s: %w", opts.To, err)
+	}
+
+	fmt.Printf(
+		"repo copy complete: snapshots=%d objects=%d copied=%d skipped=%d latest=%s reencrypted=%t\n",
+		result.Snapshots,
+		result.Objects,
+		result.Copied,
+		result.Skipped,
+		plan.metadata.LatestSnapshotID,
+		len(plan.targetKey) > 0,
+	)
+	return nil
+}
+
+// planRepoCopy resolves the snapshots to copy and the keys on both sides. A
+// target that already holds a backup set keeps its master key: copying into
+// it without --reencrypt is refused when that key differs from the source's,
+// since objects it already has could not be read with the copied manifests.
+// The target's recovery metadata is labelled with backupSetID, so the copy
+// belongs to the configured backup set whichever destination it came from.
+func planRepoCopy(source, target storage.ObjectStore, passphrase, backupSetID string, opts repoCopyOptions, now time.Time) (repoCopyPlan, error) {
+	sourceMetadata, err := recovery.ReadMetadata(source)
+	if err != nil {
+		if errors.Is(err, recovery.ErrMetadataNotFound) {
+			return repoCopyPlan{}, fmt.Errorf("destination %s holds no backup set: %w", opts.From, err)
+		}
+		return repoCopyPlan{}, fmt.Errorf("read recovery metadata from %s: %w", opts.From, err)
+	}
+	sourceKeys, err := recovery.KeySetFromMetadata(sourceMetadata, passphrase)
+	if err != nil {
+		return repoCopyPlan{}, fmt.Errorf("unlock destination %s: %w", opts.From, err)
+	}
+	available, err := backup.ListRemoteSnapshotIDs(source)
+	if err != nil {
+		return repoCopyPlan{}, err
+	}
+	snapshotIDs, err := backup.SelectRemoteSnapshotIDs(available, sourceMetadata.LatestSnapshotID, opts.Snapshots)
+	if err != nil {
+		return repoCopyPlan{}, err
+	}
+	if len(snapshotIDs) == 0 {
+		return repoCopyPlan{}, fmt.Errorf("destination %s holds no snapshots", opts.From)
+	}
+
+	plan := repoCopyPlan{sourceKeys: sourceKeys.Candidates, snapshotIDs: snapshotIDs}
+	targetMetadata, err := recovery.ReadMetadata(target)
+	switch {
+	case err == nil:
+		targetKeys, err := recovery.KeySetFromMetadata(targetMetadata, passphrase)
+		if err != nil {
+			return repoCopyPlan{}, fmt.Errorf("unlock destination %s: %w", opts.To, err)
+		}
+		if !bytes.Equal(targetKeys.Primary, sourceKeys.Primary) {
+			if !opts.Reencrypt {
+				return repoCopyPlan{}, fmt.Errorf("destination %s holds a backup set under a different key; pass --reencrypt to copy into it", opts.To)
+			}
+			plan.targetKey = targetKeys.Primary
+		}
+		plan.metadata = targetMetadata
+	case errors.Is(err, recovery.ErrMetadataNotFound):
+		salt, err := sourceMetadata.KDFSalt()
+		if err != nil {
+			return repoCopyPlan{}, err
+		}
+		wrapped := sourceKeys.WrappedMasterKey
+		if opts.Reencrypt {
+			keySet, err := recovery.NewWrappedKeySet(passphrase, salt)
+			if err != nil {
+				return repoCopyPlan{}, err
+			}
+			plan.targetKey = keySet.Primary
+			wrapped = keySet.WrappedMasterKey
+		}
+		plan.metadata, err = recovery.NewMetadata(backupSetID, salt, "", wrapped, now)
+		if err != nil {
+			return repoCopyPlan{}, err
+		}
+		plan.metadata.UpdatedAt = time.Time{}
+	default:
+		return repoCopyPlan{}, fmt.Errorf("read recovery metadata from %s: %w", opts.To, err)
+	}
+	plan.metadata.BackupSetID = backupSetID
+
+	// Replicas compare updated_at to find the ones that missed a backup, so
+	// the copy only claims the source's time when it includes its latest
+	// snapshot.
+	newest := snapshotIDs[len(snapshotIDs)-1]
+	updatedAt, _ := backup.SnapshotIDTime(newest)
+	for _, id := range snapshotIDs {
+		if id == sourceMetadata.LatestSnapshotID {
+			updatedAt = sourceMetadata.UpdatedAt
+		}
+	}
+	if newest > plan.metadata.LatestSnapshotID {
+		plan.metadata.LatestSnapshotID = newest
+	}
+	if updatedAt.After(plan.metadata.UpdatedAt) {
+		plan.metadata.UpdatedAt = updatedAt.UTC()
+	}
+	if plan.metadata.UpdatedAt.IsZero() {
+		plan.metadata.UpdatedAt = now
+	}
+	return plan, nil
+}
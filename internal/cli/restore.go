@@ -1,122 +1,230 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"baxter/internal/backup"
 	"baxter/internal/config"
-	"baxter/internal/crypto"
+	"baxter/internal/hooks"
+	"baxter/internal/repolock"
 	"baxter/internal/state"
 	"baxter/internal/storage"
 )
 
 func restorePath(cfg *config.Config, requestedPath string, opts restoreOptions) error {
-	m, err := loadRestoreManifest(cfg, opts.Snapshot)
+	metadataOpts := backup.RestoreMetadataOptions{
+		SkipModTime: opts.NoModTime,
+		SkipMode:    opts.NoPerms,
+		SkipOwner:   opts.NoOwner,
+		SkipXattrs:  opts.NoXattrs,
+	}
+	journalDir, err := state.RestoreJournalsDir()
 	if err != nil {
 		return err
 	}
+	var journal *backup.RestoreJournal
+	if opts.Resume != "" {
+		journal, err = backup.OpenRestoreJournal(journalDir, opts.Resume)
+		if err != nil {
+			return err
+		}
+		requestedPath = journal.SourcePath
+		opts.ToDir = journal.ToDir
+		opts.Overwrite = journal.Overwrite
+		opts.Snapshot = journal.ManifestSelector()
+		metadataOpts = journal.Metadata
+	}
+	// closeJournal keeps the journal for a later --resume and points the
+	// user at it.
+	closeJournal := func(err error) error {
+		if journal == nil {
+			return err
+		}
+		_ = journal.Close()
+		return fmt.Errorf("%w (resume with: baxter restore --resume %s)", err, journal.ID)
+	}
+
+	m, err := loadRestoreManifest(cfg, opts.Snapshot, opts.Destination)
+	if err != nil {
+		return closeJournal(err)
+	}
+	if journal != nil && !m.CreatedAt.Equal(journal.ManifestCreatedAt) {
+		_ = journal.Close()
+		return fmt.Errorf("snapshot for restore journal %s is no longer available", journal.ID)
+	}
 
 	selection, err := backup.ResolveRestoreSelection(m, requestedPath)
 	if err != nil {
-		return err
+		return closeJournal(err)
 	}
 
-	store, err := objectStoreFromConfig(cfg)
+	store, err := objectStoreForDestination(cfg, opts.Destination)
 	if err != nil {
-		return err
+		return closeJournal(err)
 	}
+	defer storage.Close(store)
 	keys, err := accessEncryptionKeys(cfg, store)
 	if err != nil {
-		return err
+		return closeJournal(err)
+	}
+	if opts.Stdout {
+		return restoreToStdout(store, keys, selection)
 	}
 
 	targetPath, err := resolvedRestorePath(selection.SourcePath, opts.ToDir)
 	if err != nil {
-		return err
+		return closeJournal(err)
 	}
 
 	if opts.DryRun {
 		fmt.Printf("restore dry-run: source=%s target=%s overwrite=%t\n", selection.SourcePath, targetPath, opts.Overwrite)
 		return nil
 	}
-
-	type restoreTarget struct {
-		entry      backup.ManifestEntry
-		targetPath string
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "restore")
+	if err != nil {
+		return closeJournal(err)
 	}
+	defer lock.Release()
 
-	targets := make([]restoreTarget, 0, len(selection.Entries))
+	jobs := make([]backup.RestoreJob, 0, len(selection.Entries))
 	for _, entry := range selection.Entries {
 		entryTargetPath, err := resolvedRestorePath(entry.Path, opts.ToDir)
 		if err != nil {
-			return err
+			return closeJournal(err)
+		}
+		if entry.IsSymlink() {
+			entry.LinkTarget, err = backup.RestoreLinkTarget(entry, entryTargetPath, opts.ToDir)
+			if err != nil {
+				return closeJournal(err)
+			}
 		}
-		targets = append(targets, restoreTarget{
-			entry:      entry,
-			targetPath: entryTargetPath,
+		jobs = append(jobs, backup.RestoreJob{
+			Entry:      entry,
+			TargetPath: entryTargetPath,
 		})
 	}
 
 	if !opts.Overwrite && !opts.VerifyOnly {
-		for _, target := range targets {
-			if _, err := os.Stat(target.targetPath); err == nil {
-				return fmt.Errorf("target exists: %s (use --overwrite to replace)", target.targetPath)
+		for _, job := range jobs {
+			if job.Entry.IsDir() || (journal != nil && journal.Completed(job.Entry.Path)) {
+				continue
+			}
+			if _, err := os.Lstat(job.TargetPath); err == nil {
+				return closeJournal(fmt.Errorf("target exists: %s (use --overwrite to replace)", job.TargetPath))
 			} else if !os.IsNotExist(err) {
-				return err
+				return closeJournal(err)
 			}
 		}
 	}
 
-	for _, target := range targets {
-		if err := backup.CloudPlaceholderRestoreErrorForEntry(target.entry); err != nil {
-			return err
-		}
-		payload, err := store.GetObject(backup.ResolveObjectKey(target.entry))
-		if err != nil {
-			switch {
-			case storage.IsNotFound(err):
-				return fmt.Errorf("restore object missing for path %s", target.entry.Path)
-			case storage.IsTransient(err):
-				return fmt.Errorf("restore storage transient failure for %s: %w", target.entry.Path, err)
-			default:
-				return fmt.Errorf("read object: %w", err)
-			}
+	if !opts.VerifyOnly {
+		if _, err := hookRunner(cfg).Run(context.Background(), hooks.PreRestore, hooks.RestoreEnv(selection.SourcePath, targetPath, opts.Snapshot)); err != nil {
+			return closeJournal(err)
 		}
+	}
 
-		plain, err := crypto.DecryptBytesWithAnyKey(keys.candidates, payload)
+	if !opts.VerifyOnly && journal == nil {
+		journal, err = backup.CreateRestoreJournal(journalDir, backup.RestoreJournal{
+			SourcePath:        selection.SourcePath,
+			ToDir:             opts.ToDir,
+			Overwrite:         opts.Overwrite,
+			Metadata:          metadataOpts,
+			ManifestCreatedAt: m.CreatedAt,
+		})
 		if err != nil {
-			return fmt.Errorf("decrypt object: %w", err)
-		}
-		if err := backup.VerifyEntryContent(target.entry, plain); err != nil {
-			return fmt.Errorf("verify restored content: %w", err)
-		}
-
-		if opts.VerifyOnly {
-			continue
+			return fmt.Errorf("create restore journal: %w", err)
 		}
+	}
 
-		if err := os.MkdirAll(filepath.Dir(target.targetPath), 0o755); err != nil {
-			return err
+	result, err := backup.RestoreJobs(jobs, backup.RestoreJobsOptions{
+		Store:           store,
+		Keys:            keys.candidates,
+		Metadata:        metadataOpts,
+		VerifyOnly:      opts.VerifyOnly,
+		Concurrency:     opts.Concurrency,
+		ContinueOnError: opts.ContinueOnError,
+		Journal:         journal,
+	})
+	if err != nil {
+		if opts.ContinueOnError && len(result.Failures) > 0 {
+			for _, failure := range result.Failures {
+				fmt.Printf("restore failed: path=%s error=%s\n", failure.Path, restoreFailureError(failure.Path, failure.Err))
+			}
+			return closeJournal(fmt.Errorf("restore incomplete: failures=%d files=%d", len(result.Failures), len(jobs)))
 		}
-		if err := os.WriteFile(target.targetPath, plain, target.entry.Mode.Perm()); err != nil {
-			return err
+		var entryErr *backup.RestoreEntryError
+		if errors.As(err, &entryErr) {
+			return closeJournal(restoreFailureError(entryErr.Path, entryErr.Err))
 		}
+		return closeJournal(err)
 	}
 
 	if opts.VerifyOnly {
-		fmt.Printf("restore verify-only complete: source=%s files=%d\n", selection.SourcePath, len(targets))
+		fmt.Printf("restore verify-only complete: source=%s files=%d\n", selection.SourcePath, len(jobs))
+		return nil
+	}
+
+	if err := journal.Remove(); err != nil {
+		return fmt.Errorf("remove restore journal: %w", err)
+	}
+
+	if result.Skipped > 0 {
+		fmt.Printf("restore complete: source=%s target=%s files=%d resumed_skipped=%d\n", selection.SourcePath, targetPath, len(jobs), result.Skipped)
 		return nil
 	}
+	fmt.Printf("restore complete: source=%s target=%s files=%d\n", selection.SourcePath, targetPath, len(jobs))
+	return nil
+}
+
+// restoreToStdout writes the verified content of a single file entry, such
+// as a stream stored by backup stdin, to stdout.
+func restoreToStdout(store storage.ObjectStore, keys encryptionKeySet, selection backup.RestoreSelection) error {
+	if selection.IsDirectory || len(selection.Entries) != 1 || !selection.Entries[0].Mode.IsRegular() {
+		return fmt.Errorf("restore --stdout needs a single file, got %s", selection.SourcePath)
+	}
+	entry := selection.Entries[0]
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "restore")
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
 
-	fmt.Printf("restore complete: source=%s target=%s files=%d\n", selection.SourcePath, targetPath, len(targets))
+	if entry.Size == 0 && !entry.IsChunked() {
+		// Empty files are checked without a fetch.
+		err = backup.VerifyEntryContent(entry, nil)
+	} else {
+		err = backup.CopyStoredEntryContent(os.Stdout, store, keys.candidates, entry)
+	}
+	if err != nil {
+		return restoreFailureError(entry.Path, err)
+	}
 	return nil
 }
 
+func restoreFailureError(path string, err error) error {
+	switch {
+	case errors.Is(err, backup.ErrRestoreWrite):
+		return err
+	case storage.IsNotFound(err):
+		return fmt.Errorf("restore object missing for path %s", path)
+	case storage.IsTransient(err):
+		return fmt.Errorf("restore storage transient failure for %s: %w", path, err)
+	case errors.Is(err, backup.ErrChecksumMismatch):
+		return fmt.Errorf("verify restored content: %w", err)
+	default:
+		return err
+	}
+}
+
 func restoreList(cfg *config.Config, opts restoreListOptions) error {
-	m, err := loadRestoreManifest(cfg, opts.Snapshot)
+	m, err := loadRestoreManifest(cfg, opts.Snapshot, opts.Destination)
 	if err != nil {
 		return err
 	}
@@ -127,6 +235,71 @@ func restoreList(cfg *config.Config, opts restoreListOptions) error {
 	return nil
 }
 
+// restoreExport writes a snapshot, or the part of it under --prefix, as an
+// archive. A file output is staged next to its target and only renamed into
+// place once every entry has been written and verified.
+func restoreExport(cfg *config.Config, opts restoreExportOptions) error {
+	m, err := loadRestoreManifest(cfg, opts.Snapshot, opts.Destination)
+	if err != nil {
+		return err
+	}
+	prefix := opts.Prefix
+	if strings.TrimSpace(prefix) == "" {
+		prefix = string(filepath.Separator)
+	}
+	selection, err := backup.ResolveRestoreSelection(m, prefix)
+	if err != nil {
+		return err
+	}
+
+	store, err := objectStoreForDestination(cfg, opts.Destination)
+	if err != nil {
+		return err
+	}
+	defer storage.Close(store)
+	keys, err := accessEncryptionKeys(cfg, store)
+	if err != nil {
+		return err
+	}
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "export")
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	exportOpts := backup.ExportArchiveOptions{Format: opts.Format, Store: store, Keys: keys.candidates}
+	if opts.Output == "-" {
+		result, err := backup.ExportArchive(os.Stdout, selection.Entries, exportOpts)
+		if err != nil {
+			return restoreFailureError(selection.SourcePath, err)
+		}
+		fmt.Fprintf(os.Stderr, "restore export complete: source=%s format=%s files=%d bytes=%d\n", selection.SourcePath, opts.Format, result.Files, result.Bytes)
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(opts.Output), "."+filepath.Base(opts.Output)+".baxter-export-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	result, err := backup.ExportArchive(tmp, selection.Entries, exportOpts)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, opts.Output)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return restoreFailureError(selection.SourcePath, err)
+	}
+	fmt.Printf("restore export complete: source=%s format=%s files=%d bytes=%d output=%s\n", selection.SourcePath, opts.Format, result.Files, result.Bytes, opts.Output)
+	return nil
+}
+
 func snapshotList(opts snapshotListOptions) error {
 	snapshotDir, err := state.ManifestSnapshotsDir()
 	if err != nil {
@@ -143,7 +316,60 @@ func snapshotList(opts snapshotListOptions) error {
 	}
 	for i := 0; i < limit; i++ {
 		s := snapshots[i]
-		fmt.Printf("%s %s entries=%d\n", s.ID, s.CreatedAt.Format(time.RFC3339), s.Entries)
+		line := fmt.Sprintf("%s %s entries=%d", s.ID, s.CreatedAt.Format(time.RFC3339), s.Entries)
+		if len(s.Tags) > 0 {
+			line += " tags=" + strings.Join(s.Tags, ",")
+		}
+		if s.Incomplete {
+			line += " incomplete"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+type snapshotDiffOutput struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	backup.SnapshotDiff
+}
+
+func snapshotDiff(cfg *config.Config, opts snapshotDiffOptions) error {
+	from, err := loadRestoreManifest(cfg, opts.From, opts.Destination)
+	if err != nil {
+		return fmt.Errorf("%s: %w", opts.From, err)
+	}
+	to, err := loadRestoreManifest(cfg, opts.To, opts.Destination)
+	if err != nil {
+		return fmt.Errorf("%s: %w", opts.To, err)
+	}
+	diff := backup.DiffManifests(from, to, opts.Prefix)
+
+	if opts.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(snapshotDiffOutput{From: opts.From, To: opts.To, SnapshotDiff: diff})
+	}
+
+	for _, entry := range diff.Added {
+		fmt.Printf("+ %s (%d bytes)\n", entry.Path, entry.Size)
+	}
+	for _, entry := range diff.Removed {
+		fmt.Printf("- %s (%d bytes)\n", entry.Path, entry.Size)
+	}
+	for _, change := range diff.Modified {
+		line := "M " + change.Path
+		if change.ContentChanged || change.SizeDelta != 0 {
+			line += fmt.Sprintf(" size %d -> %d (%+d)", change.OldSize, change.NewSize, change.SizeDelta)
+		}
+		if change.NewMode != "" {
+			line += fmt.Sprintf(" mode %s -> %s", change.OldMode, change.NewMode)
+		}
+		if change.NewModTime != nil {
+			line += fmt.Sprintf(" mtime %s -> %s", change.OldModTime.Format(time.RFC3339), change.NewModTime.Format(time.RFC3339))
+		}
+		fmt.Println(line)
 	}
+	fmt.Printf("diff: added=%d removed=%d modified=%d size_delta=%+d\n", len(diff.Added), len(diff.Removed), len(diff.Modified), diff.SizeDelta)
 	return nil
 }
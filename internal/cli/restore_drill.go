@@ -2,14 +2,15 @@ package cli
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"slices"
 	"time"
 
 	"baxter/internal/backup"
 	"baxter/internal/config"
-	"baxter/internal/crypto"
+	"baxter/internal/repolock"
 	"baxter/internal/storage"
 )
 
@@ -33,12 +34,15 @@ type restoreDrillSummary struct {
 
 func runRestoreDrill(cfg *config.Config, opts restoreDrillOptions) error {
 	startedAt := time.Now().UTC()
-	manifest, err := loadRestoreManifest(cfg, opts.Snapshot)
+	manifest, err := loadRestoreManifest(cfg, opts.Snapshot, "")
 	if err != nil {
 		return err
 	}
 
 	entries := filterManifestEntriesByPrefix(manifest.Entries, opts.Prefix)
+	entries = slices.DeleteFunc(entries, func(entry backup.ManifestEntry) bool {
+		return entry.IsDir() || entry.IsSymlink()
+	})
 	candidateCount := len(entries)
 	if opts.Sample > 0 {
 		entries = sampleManifestEntries(entries, opts.Sample)
@@ -51,10 +55,16 @@ func runRestoreDrill(cfg *config.Config, opts restoreDrillOptions) error {
 	if err != nil {
 		return err
 	}
+	defer storage.Close(store)
 	keySet, err := accessEncryptionKeys(cfg, store)
 	if err != nil {
 		return err
 	}
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "restore-drill")
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
 
 	tempDir, err := os.MkdirTemp("", "baxter-restore-drill-*")
 	if err != nil {
@@ -62,16 +72,25 @@ func runRestoreDrill(cfg *config.Config, opts restoreDrillOptions) error {
 	}
 	defer os.RemoveAll(tempDir)
 
-	failures := make([]restoreDrillFailure, 0)
+	jobs := make([]backup.RestoreJob, 0, len(entries))
 	sampledPaths := make([]string, 0, len(entries))
 	for _, entry := range entries {
 		sampledPaths = append(sampledPaths, entry.Path)
-		if err := runRestoreDrillEntry(tempDir, entry, keySet.candidates, store); err != nil {
-			failures = append(failures, restoreDrillFailure{
-				Path:  entry.Path,
-				Error: err.Error(),
-			})
-		}
+		jobs = append(jobs, backup.RestoreJob{Entry: entry})
+	}
+	result, _ := backup.RestoreJobs(jobs, backup.RestoreJobsOptions{
+		Concurrency:     opts.Concurrency,
+		ContinueOnError: true,
+		Restore: func(job backup.RestoreJob) error {
+			return runRestoreDrillEntry(tempDir, job.Entry, keySet.candidates, store)
+		},
+	})
+	failures := make([]restoreDrillFailure, 0, len(result.Failures))
+	for _, failure := range result.Failures {
+		failures = append(failures, restoreDrillFailure{
+			Path:  failure.Path,
+			Error: failure.Err.Error(),
+		})
 	}
 
 	summary := restoreDrillSummary{
@@ -107,31 +126,18 @@ func runRestoreDrillEntry(tempDir string, entry backup.ManifestEntry, decryption
 		return fmt.Errorf("resolve target: %w", err)
 	}
 
-	payload, err := store.GetObject(backup.ResolveObjectKey(entry))
-	if err != nil {
-		return fmt.Errorf("read object: %w", err)
-	}
-
-	plain, err := crypto.DecryptBytesWithAnyKey(decryptionKeys, payload)
-	if err != nil {
-		return fmt.Errorf("decrypt object: %w", err)
-	}
-	if err := backup.VerifyEntryContent(entry, plain); err != nil {
-		return fmt.Errorf("verify content: %w", err)
-	}
-
-	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
-		return fmt.Errorf("create target dir: %w", err)
-	}
-	if err := os.WriteFile(targetPath, plain, entry.Mode.Perm()); err != nil {
-		return fmt.Errorf("write target: %w", err)
+	if err := backup.RestoreEntryFile(store, decryptionKeys, entry, targetPath, backup.RestoreMetadataOptions{SkipOwner: true, SkipXattrs: true}); err != nil {
+		switch {
+		case errors.Is(err, backup.ErrRestoreWrite):
+			return fmt.Errorf("write target: %w", err)
+		case errors.Is(err, backup.ErrChecksumMismatch):
+			return fmt.Errorf("verify content: %w", err)
+		default:
+			return err
+		}
 	}
 
-	restored, err := os.ReadFile(targetPath)
-	if err != nil {
-		return fmt.Errorf("read restored target: %w", err)
-	}
-	if err := backup.VerifyEntryContent(entry, restored); err != nil {
+	if err := backup.VerifyEntryFile(entry, targetPath); err != nil {
 		return fmt.Errorf("verify restored target: %w", err)
 	}
 	return nil
@@ -1,39 +1,122 @@
 package cli
 
+import (
+	"time"
+
+	"baxter/internal/backup"
+)
+
 const passphraseEnv = "BAXTER_PASSPHRASE"
 
+type backupRunOptions struct {
+	Rehash bool
+	Tags   []string
+}
+
+// backupStdinOptions name the stream entry backup stdin stores.
+type backupStdinOptions struct {
+	Name string
+	Tags []string
+}
+
+// backupPauseOptions control a backup running in baxterd, reached at
+// IPCAddr.
+type backupPauseOptions struct {
+	IPCAddr     string
+	ResumeAfter time.Duration
+}
+
 type restoreOptions struct {
-	DryRun     bool
-	ToDir      string
-	Overwrite  bool
-	VerifyOnly bool
-	Snapshot   string
+	DryRun          bool
+	ToDir           string
+	Overwrite       bool
+	VerifyOnly      bool
+	Snapshot        string
+	NoModTime       bool
+	NoPerms         bool
+	NoOwner         bool
+	NoXattrs        bool
+	Resume          string
+	Concurrency     int
+	ContinueOnError bool
+	Destination     string
+	Stdout          bool
 }
 
 type restoreListOptions struct {
-	Prefix   string
-	Contains string
-	Snapshot string
+	Prefix      string
+	Contains    string
+	Snapshot    string
+	Destination string
+}
+
+type restoreExportOptions struct {
+	Snapshot    string
+	Format      backup.ArchiveFormat
+	Prefix      string
+	Output      string
+	Destination string
 }
 
 type snapshotListOptions struct {
 	Limit int
 }
 
+type snapshotDiffOptions struct {
+	From        string
+	To          string
+	Prefix      string
+	JSON        bool
+	Destination string
+}
+
 type gcOptions struct {
-	DryRun bool
+	DryRun      bool
+	Destination string
+}
+
+type unlockOptions struct {
+	Stale       bool
+	All         bool
+	Destination string
 }
 
 type verifyOptions struct {
-	Snapshot string
-	Prefix   string
-	Limit    int
-	Sample   int
+	Snapshot    string
+	Prefix      string
+	Limit       int
+	Sample      int
+	Destination string
 }
 
 type restoreDrillOptions struct {
-	Snapshot string
-	Prefix   string
-	Sample   int
-	Limit    int
+	Snapshot    string
+	Prefix      string
+	Sample      int
+	Limit       int
+	Concurrency int
+}
+
+type mountOptions struct {
+	Mountpoint  string
+	Destination string
+	// CacheMB bounds the decrypted content kept in memory (0 for the
+	// default).
+	CacheMB int
+}
+
+type checkOptions struct {
+	ReadData bool
+	// ReadDataFraction is the share of objects read with --read-data-subset
+	// (0 for all of them).
+	ReadDataFraction float64
+	Destination      string
+}
+
+type repoCopyOptions struct {
+	From        string
+	To          string
+	Snapshots   []string
+	Reencrypt   bool
+	Concurrency int
 }
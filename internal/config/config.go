@@ -3,25 +3,98 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
-	BackupRoots  []string         `toml:"backup_roots"`
-	ExcludePaths []string         `toml:"exclude_paths"`
-	ExcludeGlobs []string         `toml:"exclude_globs"`
-	Schedule     string           `toml:"schedule"`
-	DailyTime    string           `toml:"daily_time"`
-	WeeklyDay    string           `toml:"weekly_day"`
-	WeeklyTime   string           `toml:"weekly_time"`
-	S3           S3Config         `toml:"s3"`
-	Encryption   EncryptionConfig `toml:"encryption"`
-	Retention    RetentionConfig  `toml:"retention"`
-	Verify       VerifyConfig     `toml:"verify"`
+	BackupRoots  []string `toml:"backup_roots"`
+	ExcludePaths []string `toml:"exclude_paths"`
+	ExcludeGlobs []string `toml:"exclude_globs"`
+	Schedule     string   `toml:"schedule"`
+	DailyTime    string   `toml:"daily_time"`
+	WeeklyDay    string   `toml:"weekly_day"`
+	WeeklyTime   string   `toml:"weekly_time"`
+	// RehashIntervalDays forces a full content hash of every file once the
+	// last full hash is this many days old (0 = rely on the stat cache).
+	RehashIntervalDays int              `toml:"rehash_interval_days"`
+	S3                 S3Config         `toml:"s3"`
+	SFTP               SFTPConfig       `toml:"sftp"`
+	WebDAV             WebDAVConfig     `toml:"webdav"`
+	Encryption         EncryptionConfig `toml:"encryption"`
+	Retention          RetentionConfig  `toml:"retention"`
+	Verify             VerifyConfig     `toml:"verify"`
+	Bandwidth          BandwidthConfig  `toml:"bandwidth"`
+	// Destinations are additional object stores every backup is replicated
+	// to. The store selected above is the destination named "primary".
+	Destinations []DestinationConfig `toml:"destinations"`
+	Hooks        HooksConfig         `toml:"hooks"`
+}
+
+// HooksConfig lists commands run around operations, in order, for each
+// stage: before and after a backup, after a failed backup, before a restore
+// writes files and after a verify.
+type HooksConfig struct {
+	PreBackup  []HookConfig `toml:"pre_backup"`
+	PostBackup []HookConfig `toml:"post_backup"`
+	OnFailure  []HookConfig `toml:"on_failure"`
+	PreRestore []HookConfig `toml:"pre_restore"`
+	PostVerify []HookConfig `toml:"post_verify"`
+}
+
+// Hook failure policies.
+const (
+	HookOnErrorAbort    = "abort"
+	HookOnErrorContinue = "continue"
+)
+
+// DefaultHookTimeout bounds a hook without a timeout of its own.
+const DefaultHookTimeout = 10 * time.Minute
+
+type HookConfig struct {
+	// Command is the program and its arguments. It is not run through a
+	// shell; use ["/bin/sh", "-c", "..."] for one.
+	Command []string `toml:"command"`
+	// Timeout is a duration such as "30s" or "5m"; the hook is killed and
+	// counts as failed once it runs this long.
+	Timeout string `toml:"timeout"`
+	// OnError is "abort" to fail the operation when the hook exits non-zero
+	// or times out, or "continue" to only record the failure. Only hooks
+	// before an operation may abort it, and do by default; the others run
+	// once it is done and always continue.
+	OnError string `toml:"on_error"`
+}
+
+// TimeoutDuration returns Timeout, or DefaultHookTimeout when it is unset or
+// invalid; Validate reports invalid values.
+func (h HookConfig) TimeoutDuration() time.Duration {
+	d, err := time.ParseDuration(h.Timeout)
+	if err != nil || d <= 0 {
+		return DefaultHookTimeout
+	}
+	return d
+}
+
+// PrimaryDestinationName names the object store selected by the top-level
+// s3, sftp or webdav section, or local storage when none is set.
+const PrimaryDestinationName = "primary"
+
+// DestinationConfig is a named replica of the backup set. Exactly one of its
+// backends must be set.
+type DestinationConfig struct {
+	Name   string       `toml:"name"`
+	S3     S3Config     `toml:"s3"`
+	SFTP   SFTPConfig   `toml:"sftp"`
+	WebDAV WebDAVConfig `toml:"webdav"`
+	// LocalPath stores objects in a directory, such as an external drive.
+	LocalPath string `toml:"local_path"`
 }
 
 type S3Config struct {
@@ -32,6 +105,32 @@ type S3Config struct {
 	AWSProfile string `toml:"aws_profile"`
 }
 
+// SFTPConfig selects an SSH server as the object store. Only public key
+// authentication is supported and the server's host key must be pinned.
+type SFTPConfig struct {
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
+	User string `toml:"user"`
+	// IdentityFile is an unencrypted private key in OpenSSH or PEM format.
+	IdentityFile string `toml:"identity_file"`
+	// HostKey pins the server's public key, either in authorized_keys form
+	// ("ssh-ed25519 AAAA...") or as its SHA256 fingerprint ("SHA256:...").
+	HostKey string `toml:"host_key"`
+	// Path is the directory objects are stored under; relative paths are
+	// resolved from the login directory.
+	Path string `toml:"path"`
+}
+
+// WebDAVConfig selects a WebDAV collection, such as a Nextcloud folder, as
+// the object store. The password is read from BAXTER_WEBDAV_PASSWORD or, when
+// that is unset, from the keychain item named here.
+type WebDAVConfig struct {
+	URL             string `toml:"url"`
+	Username        string `toml:"username"`
+	KeychainService string `toml:"keychain_service"`
+	KeychainAccount string `toml:"keychain_account"`
+}
+
 type EncryptionConfig struct {
 	KeychainService string `toml:"keychain_service"`
 	KeychainAccount string `toml:"keychain_account"`
@@ -40,6 +139,70 @@ type EncryptionConfig struct {
 type RetentionConfig struct {
 	ManifestSnapshots  int `toml:"manifest_snapshots"`
 	ManifestMaxAgeDays int `toml:"manifest_max_age_days"`
+	// Generational rules keep the newest snapshot of each of the last N
+	// hours, days, weeks, months and years. A snapshot is kept when any rule
+	// (manifest_snapshots included) keeps it.
+	KeepHourly  int `toml:"keep_hourly"`
+	KeepDaily   int `toml:"keep_daily"`
+	KeepWeekly  int `toml:"keep_weekly"`
+	KeepMonthly int `toml:"keep_monthly"`
+	KeepYearly  int `toml:"keep_yearly"`
+	// KeepWithin keeps every snapshot taken within this long of the newest
+	// one, written like "36h", "14d" or "1y6m".
+	KeepWithin string   `toml:"keep_within"`
+	KeepTags   []string `toml:"keep_tags"`
+}
+
+// KeepWithinDuration returns KeepWithin as a duration, or 0 when it is unset
+// or invalid; Validate reports invalid values.
+func (r RetentionConfig) KeepWithinDuration() time.Duration {
+	d, err := ParseRetentionDuration(r.KeepWithin)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// ParseRetentionDuration parses a sequence of counts with units h (hours),
+// d (days), w (weeks), m (30-day months) and y (365-day years). An empty
+// string is a zero duration.
+func ParseRetentionDuration(value string) (time.Duration, error) {
+	trimmed := strings.ToLower(strings.TrimSpace(value))
+	if trimmed == "" {
+		return 0, nil
+	}
+	units := map[byte]time.Duration{
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'm': 30 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}
+	var total time.Duration
+	count := -1
+	for i := 0; i < len(trimmed); i++ {
+		c := trimmed[i]
+		if c >= '0' && c <= '9' {
+			if count < 0 {
+				count = 0
+			}
+			count = count*10 + int(c-'0')
+			if count > 1_000_000 {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			continue
+		}
+		unit, ok := units[c]
+		if !ok || count < 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		total += time.Duration(count) * unit
+		count = -1
+	}
+	if count >= 0 {
+		return 0, fmt.Errorf("invalid duration %q: missing unit", value)
+	}
+	return total, nil
 }
 
 type VerifyConfig struct {
@@ -50,6 +213,150 @@ type VerifyConfig struct {
 	Prefix     string `toml:"prefix"`
 	Limit      int    `toml:"limit"`
 	Sample     int    `toml:"sample"`
+	// Destination verifies one named destination instead of reading from
+	// whichever destination has each object.
+	Destination string `toml:"destination"`
+}
+
+// BandwidthConfig limits transfer rates to and from remote object stores.
+// Limits are written like "2MB/s", "512KiB/s" or "unlimited"; an empty limit
+// is unlimited.
+type BandwidthConfig struct {
+	UploadLimit   string `toml:"upload_limit"`
+	DownloadLimit string `toml:"download_limit"`
+	// Windows replace the limits above during parts of the week; the first
+	// window containing the current time applies.
+	Windows []BandwidthWindow `toml:"windows"`
+}
+
+// BandwidthWindow applies from Start until End (HH:MM, local time); an End
+// before Start runs past midnight. Days restricts the window to the weekdays
+// it starts on, every day when empty. A limit left empty keeps the limit of
+// the bandwidth section; set "unlimited" to lift it.
+type BandwidthWindow struct {
+	Start         string   `toml:"start"`
+	End           string   `toml:"end"`
+	Days          []string `toml:"days"`
+	UploadLimit   string   `toml:"upload_limit"`
+	DownloadLimit string   `toml:"download_limit"`
+}
+
+// BandwidthLimits are transfer rates in bytes per second; 0 is unlimited.
+type BandwidthLimits struct {
+	Upload   int64
+	Download int64
+}
+
+// LimitsAt returns the limits that apply at t and the window they come from,
+// written "start-end", or "" outside every window. Invalid limits count as
+// unlimited; Validate reports them.
+func (b BandwidthConfig) LimitsAt(t time.Time) (BandwidthLimits, string) {
+	limits := BandwidthLimits{
+		Upload:   parseBandwidthLimitOrZero(b.UploadLimit),
+		Download: parseBandwidthLimitOrZero(b.DownloadLimit),
+	}
+	for _, window := range b.Windows {
+		if !window.contains(t) {
+			continue
+		}
+		if window.UploadLimit != "" {
+			limits.Upload = parseBandwidthLimitOrZero(window.UploadLimit)
+		}
+		if window.DownloadLimit != "" {
+			limits.Download = parseBandwidthLimitOrZero(window.DownloadLimit)
+		}
+		return limits, window.Start + "-" + window.End
+	}
+	return limits, ""
+}
+
+func (w BandwidthWindow) contains(t time.Time) bool {
+	start, okStart := minutesOfDay(w.Start)
+	end, okEnd := minutesOfDay(w.End)
+	if !okStart || !okEnd || start == end {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start < end {
+		return now >= start && now < end && w.onDay(t.Weekday())
+	}
+	if now >= start {
+		return w.onDay(t.Weekday())
+	}
+	// Past midnight the window belongs to the day it started on.
+	return now < end && w.onDay((t.Weekday()+6)%7)
+}
+
+func (w BandwidthWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	name := strings.ToLower(day.String())
+	for _, d := range w.Days {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+func minutesOfDay(value string) (int, bool) {
+	if !isValidHHMM(value) {
+		return 0, false
+	}
+	hour := int(value[0]-'0')*10 + int(value[1]-'0')
+	minute := int(value[3]-'0')*10 + int(value[4]-'0')
+	return hour*60 + minute, true
+}
+
+// ParseBandwidthLimit parses a rate such as "2MB/s", "1.5MiB/s" or "800KB"
+// into bytes per second. Units are B, KB, MB and GB (powers of 1000) or KiB,
+// MiB and GiB (powers of 1024); the "/s" suffix is optional. "", "0" and
+// "unlimited" are 0, meaning no limit.
+func ParseBandwidthLimit(value string) (int64, error) {
+	trimmed := strings.ToLower(strings.TrimSpace(value))
+	trimmed = strings.TrimSuffix(trimmed, "/s")
+	if trimmed == "" || trimmed == "unlimited" {
+		return 0, nil
+	}
+	end := 0
+	for end < len(trimmed) && (trimmed[end] >= '0' && trimmed[end] <= '9' || trimmed[end] == '.') {
+		end++
+	}
+	amount, err := strconv.ParseFloat(trimmed[:end], 64)
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("invalid bandwidth limit %q", value)
+	}
+	units := map[string]float64{
+		"":    1,
+		"b":   1,
+		"kb":  1e3,
+		"mb":  1e6,
+		"gb":  1e9,
+		"kib": 1 << 10,
+		"mib": 1 << 20,
+		"gib": 1 << 30,
+	}
+	unit, ok := units[strings.TrimSpace(trimmed[end:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid bandwidth limit %q: unit must be B, KB, MB, GB, KiB, MiB or GiB", value)
+	}
+	rate := amount * unit
+	if rate > 1e15 {
+		return 0, fmt.Errorf("invalid bandwidth limit %q", value)
+	}
+	if rate > 0 && rate < 1 {
+		return 0, fmt.Errorf("invalid bandwidth limit %q: below 1 byte per second", value)
+	}
+	return int64(rate), nil
+}
+
+func parseBandwidthLimitOrZero(value string) int64 {
+	rate, err := ParseBandwidthLimit(value)
+	if err != nil {
+		return 0
+	}
+	return rate
 }
 
 func DefaultConfig() *Config {
@@ -67,6 +374,9 @@ func DefaultConfig() *Config {
 			Bucket:   "",
 			Prefix:   "baxter/",
 		},
+		SFTP: SFTPConfig{
+			Port: 22,
+		},
 		Encryption: EncryptionConfig{
 			KeychainService: "baxter",
 			KeychainAccount: "default",
@@ -135,6 +445,17 @@ func (c *Config) ApplyDefaults() {
 	if c.S3.Prefix == "" {
 		c.S3.Prefix = "baxter/"
 	}
+	if c.SFTP.Port == 0 {
+		c.SFTP.Port = 22
+	}
+	for i := range c.Destinations {
+		if c.Destinations[i].S3.Prefix == "" {
+			c.Destinations[i].S3.Prefix = "baxter/"
+		}
+		if c.Destinations[i].SFTP.Port == 0 {
+			c.Destinations[i].SFTP.Port = 22
+		}
+	}
 	if c.Encryption.KeychainService == "" {
 		c.Encryption.KeychainService = "baxter"
 	}
@@ -183,8 +504,8 @@ func (c *Config) Normalize() {
 		c.ExcludeGlobs[i] = strings.TrimSpace(pattern)
 	}
 
-	if c.S3.Prefix != "" && !strings.HasSuffix(c.S3.Prefix, "/") {
-		c.S3.Prefix += "/"
+	for i, tag := range c.Retention.KeepTags {
+		c.Retention.KeepTags[i] = strings.TrimSpace(tag)
 	}
 
 	c.Verify.Schedule = strings.ToLower(strings.TrimSpace(c.Verify.Schedule))
@@ -192,7 +513,58 @@ func (c *Config) Normalize() {
 	c.Verify.WeeklyDay = strings.ToLower(strings.TrimSpace(c.Verify.WeeklyDay))
 	c.Verify.WeeklyTime = strings.TrimSpace(c.Verify.WeeklyTime)
 	c.Verify.Prefix = strings.TrimSpace(c.Verify.Prefix)
-	c.S3.AWSProfile = strings.TrimSpace(c.S3.AWSProfile)
+	c.Verify.Destination = strings.TrimSpace(c.Verify.Destination)
+	c.Bandwidth.UploadLimit = strings.TrimSpace(c.Bandwidth.UploadLimit)
+	c.Bandwidth.DownloadLimit = strings.TrimSpace(c.Bandwidth.DownloadLimit)
+	for i := range c.Bandwidth.Windows {
+		window := &c.Bandwidth.Windows[i]
+		window.Start = strings.TrimSpace(window.Start)
+		window.End = strings.TrimSpace(window.End)
+		window.UploadLimit = strings.TrimSpace(window.UploadLimit)
+		window.DownloadLimit = strings.TrimSpace(window.DownloadLimit)
+		for j, day := range window.Days {
+			window.Days[j] = strings.ToLower(strings.TrimSpace(day))
+		}
+	}
+	for _, stage := range c.Hooks.stages() {
+		for i := range stage.hooks {
+			hook := &stage.hooks[i]
+			hook.Timeout = strings.TrimSpace(hook.Timeout)
+			hook.OnError = strings.ToLower(strings.TrimSpace(hook.OnError))
+		}
+	}
+	normalizeStorageBackend(&c.S3, &c.SFTP, &c.WebDAV)
+	for i := range c.Destinations {
+		dest := &c.Destinations[i]
+		dest.Name = strings.TrimSpace(dest.Name)
+		normalizeStorageBackend(&dest.S3, &dest.SFTP, &dest.WebDAV)
+		dest.LocalPath = strings.TrimSpace(dest.LocalPath)
+		if dest.LocalPath != "" {
+			dest.LocalPath = filepath.Clean(dest.LocalPath)
+		}
+	}
+}
+
+func normalizeStorageBackend(s3 *S3Config, sftp *SFTPConfig, webdav *WebDAVConfig) {
+	if s3.Prefix != "" && !strings.HasSuffix(s3.Prefix, "/") {
+		s3.Prefix += "/"
+	}
+	s3.AWSProfile = strings.TrimSpace(s3.AWSProfile)
+	sftp.Host = strings.TrimSpace(sftp.Host)
+	sftp.User = strings.TrimSpace(sftp.User)
+	sftp.IdentityFile = strings.TrimSpace(sftp.IdentityFile)
+	if sftp.IdentityFile != "" {
+		sftp.IdentityFile = filepath.Clean(sftp.IdentityFile)
+	}
+	sftp.HostKey = strings.TrimSpace(sftp.HostKey)
+	sftp.Path = strings.TrimSpace(sftp.Path)
+	webdav.URL = strings.TrimSpace(webdav.URL)
+	if webdav.URL != "" && !strings.HasSuffix(webdav.URL, "/") {
+		webdav.URL += "/"
+	}
+	webdav.Username = strings.TrimSpace(webdav.Username)
+	webdav.KeychainService = strings.TrimSpace(webdav.KeychainService)
+	webdav.KeychainAccount = strings.TrimSpace(webdav.KeychainAccount)
 }
 
 func (c *Config) Validate() error {
@@ -250,20 +622,11 @@ func (c *Config) Validate() error {
 		}
 	}
 
-	if c.S3.Bucket == "" {
-		if c.S3.Region != "" || c.S3.Endpoint != "" {
-			return errors.New("s3.bucket is required when s3.region or s3.endpoint is set")
-		}
-	} else {
-		if c.S3.Region == "" {
-			return errors.New("s3.region is required when s3.bucket is set")
-		}
-		if strings.Contains(c.S3.Bucket, "/") {
-			return errors.New("s3.bucket must not contain '/'")
-		}
-		if c.S3.Prefix == "" {
-			return errors.New("s3.prefix must not be empty")
-		}
+	if err := validateStorageBackend("", c.S3, c.SFTP, c.WebDAV); err != nil {
+		return err
+	}
+	if err := c.validateDestinations(); err != nil {
+		return err
 	}
 
 	if strings.TrimSpace(c.Encryption.KeychainService) == "" {
@@ -272,12 +635,32 @@ func (c *Config) Validate() error {
 	if strings.TrimSpace(c.Encryption.KeychainAccount) == "" {
 		return errors.New("encryption.keychain_account must not be empty")
 	}
+	if c.RehashIntervalDays < 0 {
+		return errors.New("rehash_interval_days must be >= 0")
+	}
 	if c.Retention.ManifestSnapshots < 0 {
 		return errors.New("retention.manifest_snapshots must be >= 0")
 	}
 	if c.Retention.ManifestMaxAgeDays < 0 {
 		return errors.New("retention.manifest_max_age_days must be >= 0")
 	}
+	for _, rule := range []struct {
+		name string
+		keep int
+	}{
+		{"keep_hourly", c.Retention.KeepHourly},
+		{"keep_daily", c.Retention.KeepDaily},
+		{"keep_weekly", c.Retention.KeepWeekly},
+		{"keep_monthly", c.Retention.KeepMonthly},
+		{"keep_yearly", c.Retention.KeepYearly},
+	} {
+		if rule.keep < 0 {
+			return fmt.Errorf("retention.%s must be >= 0", rule.name)
+		}
+	}
+	if _, err := ParseRetentionDuration(c.Retention.KeepWithin); err != nil {
+		return fmt.Errorf("retention.keep_within: %w", err)
+	}
 	switch c.Verify.Schedule {
 	case "", "daily", "weekly", "manual":
 		// valid
@@ -312,6 +695,87 @@ func (c *Config) Validate() error {
 	if c.Verify.Sample < 0 {
 		return errors.New("verify.sample must be >= 0")
 	}
+	if err := c.Hooks.validate(); err != nil {
+		return err
+	}
+	return c.Bandwidth.validate()
+}
+
+type hookStage struct {
+	name  string
+	hooks []HookConfig
+	// before is set for stages that run before their operation, the only
+	// ones that can abort it.
+	before bool
+}
+
+func (h HooksConfig) stages() []hookStage {
+	return []hookStage{
+		{"pre_backup", h.PreBackup, true},
+		{"post_backup", h.PostBackup, false},
+		{"on_failure", h.OnFailure, false},
+		{"pre_restore", h.PreRestore, true},
+		{"post_verify", h.PostVerify, false},
+	}
+}
+
+func (h HooksConfig) validate() error {
+	for _, stage := range h.stages() {
+		for i, hook := range stage.hooks {
+			section := fmt.Sprintf("hooks.%s[%d].", stage.name, i)
+			if len(hook.Command) == 0 || strings.TrimSpace(hook.Command[0]) == "" {
+				return fmt.Errorf("%scommand must name a program", section)
+			}
+			if hook.Timeout != "" {
+				if d, err := time.ParseDuration(hook.Timeout); err != nil || d <= 0 {
+					return fmt.Errorf("%stimeout must be a positive duration such as 30s or 5m", section)
+				}
+			}
+			switch hook.OnError {
+			case "", HookOnErrorContinue:
+				// valid
+			case HookOnErrorAbort:
+				if !stage.before {
+					return fmt.Errorf("%son_error must be continue: %s hooks run after the operation is done", section, stage.name)
+				}
+			default:
+				return fmt.Errorf("%son_error must be abort or continue", section)
+			}
+		}
+	}
+	return nil
+}
+
+func (b BandwidthConfig) validate() error {
+	if _, err := ParseBandwidthLimit(b.UploadLimit); err != nil {
+		return fmt.Errorf("bandwidth.upload_limit: %w", err)
+	}
+	if _, err := ParseBandwidthLimit(b.DownloadLimit); err != nil {
+		return fmt.Errorf("bandwidth.download_limit: %w", err)
+	}
+	for i, window := range b.Windows {
+		section := fmt.Sprintf("bandwidth.windows[%d].", i)
+		if !isValidHHMM(window.Start) {
+			return fmt.Errorf("%sstart must be in HH:MM (24-hour) format", section)
+		}
+		if !isValidHHMM(window.End) {
+			return fmt.Errorf("%send must be in HH:MM (24-hour) format", section)
+		}
+		if window.Start == window.End {
+			return fmt.Errorf("%sstart and end must differ", section)
+		}
+		for _, day := range window.Days {
+			if !isValidWeekday(day) {
+				return fmt.Errorf("%sdays must be among: sunday,monday,tuesday,wednesday,thursday,friday,saturday", section)
+			}
+		}
+		if _, err := ParseBandwidthLimit(window.UploadLimit); err != nil {
+			return fmt.Errorf("%supload_limit: %w", section, err)
+		}
+		if _, err := ParseBandwidthLimit(window.DownloadLimit); err != nil {
+			return fmt.Errorf("%sdownload_limit: %w", section, err)
+		}
+	}
 	return nil
 }
 
@@ -339,3 +803,155 @@ func isValidWeekday(value string) bool {
 		return false
 	}
 }
+
+// validateWebDAVURL requires an https URL, since credentials are sent with
+// every request; plain http is accepted only for a server on this machine.
+func validateWebDAVURL(section string, raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("%swebdav.url must be a valid http(s) URL", section)
+	}
+	if parsed.User != nil {
+		return fmt.Errorf("%swebdav.url must not contain credentials; set webdav.username instead", section)
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "https":
+		return nil
+	case "http":
+		host := parsed.Hostname()
+		if host == "localhost" || net.ParseIP(host).IsLoopback() {
+			return nil
+		}
+		return fmt.Errorf("%swebdav.url must use https unless the server is on localhost", section)
+	default:
+		return fmt.Errorf("%swebdav.url must use http or https", section)
+	}
+}
+
+// validateStorageBackend checks one set of backend sections. section prefixes
+// setting names in errors, e.g. "destinations[0]." for a replica.
+func validateStorageBackend(section string, s3 S3Config, sftp SFTPConfig, webdav WebDAVConfig) error {
+	if s3.Bucket == "" {
+		if s3.Region != "" || s3.Endpoint != "" {
+			return fmt.Errorf("%ss3.bucket is required when s3.region or s3.endpoint is set", section)
+		}
+	} else {
+		if s3.Region == "" {
+			return fmt.Errorf("%ss3.region is required when s3.bucket is set", section)
+		}
+		if strings.Contains(s3.Bucket, "/") {
+			return fmt.Errorf("%ss3.bucket must not contain '/'", section)
+		}
+		if s3.Prefix == "" {
+			return fmt.Errorf("%ss3.prefix must not be empty", section)
+		}
+	}
+
+	if sftp.Host == "" {
+		if sftp.User != "" || sftp.IdentityFile != "" || sftp.HostKey != "" || sftp.Path != "" {
+			return fmt.Errorf("%ssftp.host is required when other sftp settings are set", section)
+		}
+	} else {
+		if s3.Bucket != "" {
+			return fmt.Errorf("%ss3.bucket and sftp.host cannot both be set", section)
+		}
+		if strings.ContainsAny(sftp.Host, "/@ ") {
+			return fmt.Errorf("%ssftp.host must be a host name or address", section)
+		}
+		if sftp.Port < 1 || sftp.Port > 65535 {
+			return fmt.Errorf("%ssftp.port must be between 1 and 65535", section)
+		}
+		if sftp.User == "" {
+			return fmt.Errorf("%ssftp.user is required when sftp.host is set", section)
+		}
+		if sftp.IdentityFile == "" {
+			return fmt.Errorf("%ssftp.identity_file is required when sftp.host is set", section)
+		}
+		if !filepath.IsAbs(sftp.IdentityFile) {
+			return fmt.Errorf("%ssftp.identity_file must be an absolute path", section)
+		}
+		if sftp.HostKey == "" {
+			return fmt.Errorf("%ssftp.host_key is required when sftp.host is set", section)
+		}
+		if sftp.Path == "" {
+			return fmt.Errorf("%ssftp.path must not be empty", section)
+		}
+	}
+
+	if webdav.URL == "" {
+		if webdav.Username != "" || webdav.KeychainService != "" || webdav.KeychainAccount != "" {
+			return fmt.Errorf("%swebdav.url is required when other webdav settings are set", section)
+		}
+	} else {
+		if s3.Bucket != "" || sftp.Host != "" {
+			return fmt.Errorf("%swebdav.url cannot be combined with s3.bucket or sftp.host", section)
+		}
+		if err := validateWebDAVURL(section, webdav.URL); err != nil {
+			return err
+		}
+		if (webdav.KeychainService == "") != (webdav.KeychainAccount == "") {
+			return fmt.Errorf("%swebdav.keychain_service and webdav.keychain_account must be set together", section)
+		}
+		if webdav.KeychainService != "" && webdav.Username == "" {
+			return fmt.Errorf("%swebdav.username is required when a webdav keychain item is set", section)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateDestinations() error {
+	names := map[string]bool{PrimaryDestinationName: true}
+	for i, dest := range c.Destinations {
+		section := fmt.Sprintf("destinations[%d].", i)
+		if dest.Name == "" {
+			return fmt.Errorf("%sname must not be empty", section)
+		}
+		if !isValidDestinationName(dest.Name) {
+			return fmt.Errorf("%sname must contain only letters, digits, '-' and '_'", section)
+		}
+		if names[dest.Name] {
+			return fmt.Errorf("%sname %q is already used", section, dest.Name)
+		}
+		names[dest.Name] = true
+
+		backends := 0
+		for _, set := range []bool{dest.S3.Bucket != "", dest.SFTP.Host != "", dest.WebDAV.URL != "", dest.LocalPath != ""} {
+			if set {
+				backends++
+			}
+		}
+		if backends != 1 {
+			return fmt.Errorf("%s must set exactly one of s3.bucket, sftp.host, webdav.url or local_path", strings.TrimSuffix(section, "."))
+		}
+		if dest.LocalPath != "" && !filepath.IsAbs(dest.LocalPath) {
+			return fmt.Errorf("%slocal_path must be an absolute path", section)
+		}
+		if err := validateStorageBackend(section, dest.S3, dest.SFTP, dest.WebDAV); err != nil {
+			return err
+		}
+	}
+	if c.Verify.Destination != "" && !names[c.Verify.Destination] {
+		return fmt.Errorf("verify.destination %q is not a configured destination", c.Verify.Destination)
+	}
+	return nil
+}
+
+// DestinationNames lists "primary" followed by the configured replicas.
+func (c *Config) DestinationNames() []string {
+	names := []string{PrimaryDestinationName}
+	for _, dest := range c.Destinations {
+		names = append(names, dest.Name)
+	}
+	return names
+}
+
+func isValidDestinationName(name string) bool {
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
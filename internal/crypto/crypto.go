@@ -154,6 +154,8 @@ func DecryptBytes(key []byte, payload []byte) ([]byte, error) {
 			return nil, err
 		}
 		return decompressAfterDecryption(payload[1], plain)
+	case payloadVersionV5:
+		return decryptStreamBytes(key, payload)
 	default:
 		return nil, errors.New("unsupported payload version")
 	}
@@ -0,0 +1,326 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// Stream payloads are split into fixed-size segments that are sealed
+// independently, so neither side has to hold the whole object in memory.
+// Segment nonces are the random header prefix followed by a big-endian
+// segment counter and a final-segment flag, which rejects reordered, dropped
+// and truncated segments. The header is bound to every segment as AAD.
+//
+// The header also carries a random salt, and segments are sealed under a key
+// derived from the repository key and that salt with HKDF-SHA256, so every
+// object has a key of its own and random nonce prefixes cannot collide across
+// objects.
+const (
+	payloadVersionV5     byte = 5
+	streamSegmentSize         = 64 * 1024
+	streamSaltLen             = 32
+	streamNoncePrefixLen      = 7
+	streamHeaderLen           = 2 + 4 + streamSaltLen + streamNoncePrefixLen
+	streamTagSize             = 16
+	streamSubkeyInfo          = "baxter stream segment key v5"
+)
+
+// EncryptStream reads plaintext from src and writes a version 5 payload to dst.
+// Compression is decided from the leading bytes of src, using the same size
+// threshold and benefit check as EncryptBytes.
+func EncryptStream(key []byte, dst io.Writer, src io.Reader) error {
+	if _, err := aes.NewCipher(key); err != nil {
+		return err
+	}
+
+	buffered := bufio.NewReaderSize(src, compressionMinBytes)
+	sample, err := buffered.Peek(compressionMinBytes)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return err
+	}
+	compression := compressionNone
+	if len(sample) >= compressionMinBytes {
+		compressed, err := gzipCompress(sample)
+		if err != nil {
+			return err
+		}
+		if len(compressed) < len(sample) {
+			compression = compressionGzip
+		}
+	}
+
+	header := make([]byte, streamHeaderLen)
+	header[0] = payloadVersionV5
+	header[1] = compression
+	binary.BigEndian.PutUint32(header[2:6], streamSegmentSize)
+	if _, err := io.ReadFull(rand.Reader, header[6:]); err != nil {
+		return err
+	}
+	gcm, err := streamAEAD(key, header)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	sw := &streamWriter{gcm: gcm, dst: dst, header: header, buf: make([]byte, 0, streamSegmentSize)}
+	if compression == compressionGzip {
+		gz := gzip.NewWriter(sw)
+		if _, err := io.Copy(gz, buffered); err != nil {
+			_ = gz.Close()
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	} else if _, err := io.Copy(sw, buffered); err != nil {
+		return err
+	}
+	return sw.close()
+}
+
+// NewDecryptReader returns a reader over the plaintext of payload, trying
+// each key in order. Stream payloads are decrypted segment by segment;
+// older single-shot payloads are read fully and decrypted in memory.
+func NewDecryptReader(keys [][]byte, payload io.Reader) (io.Reader, error) {
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(payload, version); err != nil {
+		return nil, errors.New("payload too short")
+	}
+	if version[0] != payloadVersionV5 {
+		rest, err := io.ReadAll(payload)
+		if err != nil {
+			return nil, err
+		}
+		plain, err := DecryptBytesWithAnyKey(keys, append(version, rest...))
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(plain), nil
+	}
+
+	header := make([]byte, streamHeaderLen)
+	header[0] = version[0]
+	if _, err := io.ReadFull(payload, header[1:]); err != nil {
+		return nil, errors.New("payload too short")
+	}
+	segmentSize := binary.BigEndian.Uint32(header[2:6])
+	if segmentSize == 0 || segmentSize > math.MaxInt32-streamTagSize-1 {
+		return nil, errors.New("invalid payload segment size")
+	}
+
+	sr := &streamReader{
+		src:    payload,
+		header: header,
+		sealed: make([]byte, int(segmentSize)+streamTagSize+1),
+	}
+	if err := sr.readSegment(); err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, key := range keys {
+		if len(key) == 0 {
+			continue
+		}
+		gcm, err := streamAEAD(key, header)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		sr.gcm = gcm
+		if err := sr.openSegment(); err != nil {
+			lastErr = err
+			continue
+		}
+		return decompressStream(header[1], sr)
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, errors.New("no encryption keys provided")
+}
+
+func decryptStreamBytes(key []byte, payload []byte) ([]byte, error) {
+	plain, err := NewDecryptReader([][]byte{key}, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(plain)
+}
+
+func decompressStream(compression byte, plain io.Reader) (io.Reader, error) {
+	switch compression {
+	case compressionNone:
+		return plain, nil
+	case compressionGzip:
+		return gzip.NewReader(plain)
+	default:
+		return nil, errors.New("unsupported compression algorithm")
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// streamAEAD returns the cipher that seals the segments of the payload with
+// header, keyed by the subkey of key and the header's salt.
+func streamAEAD(key []byte, header []byte) (cipher.AEAD, error) {
+	subkey, err := streamSubkey(key, header[6:6+streamSaltLen])
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(subkey)
+}
+
+// streamSubkey derives an object's segment key, as long as key so the AES
+// variant is kept.
+func streamSubkey(key []byte, salt []byte) ([]byte, error) {
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, err
+	}
+	return hkdf.Key(sha256.New, key, salt, streamSubkeyInfo, len(key))
+}
+
+// streamNonce builds a segment nonce from the prefix that ends the header.
+func streamNonce(header []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, nonceSize)
+	copy(nonce, header[streamHeaderLen-streamNoncePrefixLen:])
+	binary.BigEndian.PutUint32(nonce[streamNoncePrefixLen:], counter)
+	if final {
+		nonce[nonceSize-1] = 1
+	}
+	return nonce
+}
+
+type streamWriter struct {
+	gcm     cipher.AEAD
+	dst     io.Writer
+	header  []byte
+	buf     []byte
+	counter uint32
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only sealed once more data arrives, so the last
+		// segment is always the one carrying the final flag.
+		if len(w.buf) == cap(w.buf) {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *streamWriter) seal(final bool) error {
+	if w.counter == math.MaxUint32 {
+		return errors.New("payload too large")
+	}
+	sealed := w.gcm.Seal(nil, streamNonce(w.header, w.counter, final), w.buf, w.header)
+	if _, err := w.dst.Write(sealed); err != nil {
+		return err
+	}
+	w.counter++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *streamWriter) close() error {
+	return w.seal(true)
+}
+
+type streamReader struct {
+	gcm      cipher.AEAD
+	src      io.Reader
+	header   []byte
+	sealed   []byte
+	pending  int
+	plainBuf []byte
+	plain    []byte
+	counter  uint32
+	final    bool
+}
+
+// readSegment loads the next sealed segment into the front of the buffer.
+// One extra byte is read ahead so a short read identifies the final segment.
+func (r *streamReader) readSegment() error {
+	carried := 0
+	if r.pending > len(r.sealed)-1 {
+		r.sealed[0] = r.sealed[len(r.sealed)-1]
+		carried = 1
+	}
+	n, err := io.ReadFull(r.src, r.sealed[carried:])
+	n += carried
+	switch {
+	case err == nil:
+		r.pending = n
+		r.final = false
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		r.pending = n
+		r.final = true
+	default:
+		return err
+	}
+	if r.pending < streamTagSize {
+		return errors.New("payload truncated")
+	}
+	return nil
+}
+
+func (r *streamReader) openSegment() error {
+	size := r.pending
+	if !r.final {
+		size = len(r.sealed) - 1
+	}
+	plain, err := r.gcm.Open(r.plainBuf[:0], streamNonce(r.header, r.counter, r.final), r.sealed[:size], r.header)
+	if err != nil {
+		return err
+	}
+	r.plainBuf = plain
+	r.plain = plain
+	return nil
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.final {
+			return 0, io.EOF
+		}
+		if r.counter == math.MaxUint32 {
+			return 0, errors.New("payload too large")
+		}
+		r.counter++
+		if err := r.readSegment(); err != nil {
+			return 0, err
+		}
+		if err := r.openSegment(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
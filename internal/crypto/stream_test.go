@@ -0,0 +1,185 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func encryptStreamForTest(t *testing.T, key []byte, plain []byte) []byte {
+	t.Helper()
+	var payload bytes.Buffer
+	if err := EncryptStream(key, &payload, bytes.NewReader(plain)); err != nil {
+		t.Fatalf("encrypt stream failed: %v", err)
+	}
+	return payload.Bytes()
+}
+
+func TestEncryptStreamRoundTrip(t *testing.T) {
+	key := KeyFromPassphrase("secret-passphrase")
+	random := make([]byte, 3*streamSegmentSize+17)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatalf("random payload: %v", err)
+	}
+
+	cases := map[string][]byte{
+		"empty":         {},
+		"small":         []byte("backup payload"),
+		"exact segment": bytes.Repeat([]byte{0x5a}, streamSegmentSize),
+		"multi segment": random,
+		"compressible":  bytes.Repeat([]byte("baxter-stream-"), compressionMinBytes),
+	}
+	for name, plain := range cases {
+		payload := encryptStreamForTest(t, key, plain)
+		if payload[0] != payloadVersionV5 {
+			t.Fatalf("%s: payload version mismatch: got %d want %d", name, payload[0], payloadVersionV5)
+		}
+
+		reader, err := NewDecryptReader([][]byte{key}, bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("%s: open decrypt reader: %v", name, err)
+		}
+		got, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("%s: read plaintext: %v", name, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("%s: roundtrip mismatch: got %d bytes want %d", name, len(got), len(plain))
+		}
+
+		viaBytes, err := DecryptBytes(key, payload)
+		if err != nil {
+			t.Fatalf("%s: decrypt bytes: %v", name, err)
+		}
+		if !bytes.Equal(viaBytes, plain) {
+			t.Fatalf("%s: DecryptBytes mismatch", name)
+		}
+	}
+}
+
+func TestEncryptStreamCompressesWhenBeneficial(t *testing.T) {
+	key := KeyFromPassphrase("secret-passphrase")
+	plain := bytes.Repeat([]byte("a"), compressionMinBytes*4)
+
+	payload := encryptStreamForTest(t, key, plain)
+	if payload[1] != compressionGzip {
+		t.Fatalf("compression mismatch: got %d want %d", payload[1], compressionGzip)
+	}
+	if len(payload) >= len(plain) {
+		t.Fatalf("expected compressed payload, got %d bytes for %d plaintext bytes", len(payload), len(plain))
+	}
+}
+
+func TestDecryptReaderTriesEachKey(t *testing.T) {
+	keyA := KeyFromPassphrase("a")
+	keyB := KeyFromPassphrase("b")
+	payload := encryptStreamForTest(t, keyB, []byte("rotated"))
+
+	reader, err := NewDecryptReader([][]byte{keyA, keyB}, bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("open decrypt reader: %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read plaintext: %v", err)
+	}
+	if string(got) != "rotated" {
+		t.Fatalf("unexpected plaintext: %q", got)
+	}
+
+	if _, err := NewDecryptReader([][]byte{keyA}, bytes.NewReader(payload)); err == nil {
+		t.Fatal("expected decrypt failure with wrong key")
+	}
+}
+
+func TestDecryptReaderRejectsTruncatedAndTamperedStreams(t *testing.T) {
+	key := KeyFromPassphrase("secret-passphrase")
+	plain := make([]byte, 3*streamSegmentSize)
+	if _, err := rand.Read(plain); err != nil {
+		t.Fatalf("random payload: %v", err)
+	}
+	payload := encryptStreamForTest(t, key, plain)
+
+	segment := streamSegmentSize + streamTagSize
+	truncated := payload[:streamHeaderLen+2*segment]
+	if _, err := DecryptBytes(key, truncated); err == nil {
+		t.Fatal("expected failure for stream truncated at a segment boundary")
+	}
+
+	tampered := append([]byte(nil), payload...)
+	tampered[streamHeaderLen+segment+10] ^= 0xff
+	if _, err := DecryptBytes(key, tampered); err == nil {
+		t.Fatal("expected failure for tampered segment")
+	}
+
+	trailing := append(append([]byte(nil), payload...), 0x00)
+	if _, err := DecryptBytes(key, trailing); err == nil {
+		t.Fatal("expected failure for trailing data after final segment")
+	}
+
+	// Stream payloads only exist as version 5; no other stream layout is read.
+	relabelled := append([]byte(nil), payload...)
+	relabelled[0] = 4
+	if _, err := NewDecryptReader([][]byte{key}, bytes.NewReader(relabelled)); err == nil {
+		t.Fatal("expected failure for a stream payload of another version")
+	}
+}
+
+func TestDecryptReaderReadsLegacyPayloads(t *testing.T) {
+	key := KeyFromPassphrase("secret-passphrase")
+	payload, err := EncryptBytes(key, []byte("v3 payload"))
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	reader, err := NewDecryptReader([][]byte{key}, bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("open decrypt reader: %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read plaintext: %v", err)
+	}
+	if string(got) != "v3 payload" {
+		t.Fatalf("unexpected plaintext: %q", got)
+	}
+}
+
+func TestEncryptStreamUsesAKeyPerObject(t *testing.T) {
+	key := KeyFromPassphrase("secret-passphrase")
+	plain := []byte("same plaintext")
+	first := encryptStreamForTest(t, key, plain)
+	second := encryptStreamForTest(t, key, plain)
+
+	if bytes.Equal(first[:streamHeaderLen], second[:streamHeaderLen]) {
+		t.Fatal("expected each payload to get its own header")
+	}
+	if bytes.Equal(first[streamHeaderLen:], second[streamHeaderLen:]) {
+		t.Fatal("expected different ciphertexts for the same plaintext")
+	}
+	firstKey, err := streamSubkey(key, first[6:6+streamSaltLen])
+	if err != nil {
+		t.Fatalf("derive subkey: %v", err)
+	}
+	secondKey, err := streamSubkey(key, second[6:6+streamSaltLen])
+	if err != nil {
+		t.Fatalf("derive subkey: %v", err)
+	}
+	if bytes.Equal(firstKey, secondKey) || bytes.Equal(firstKey, key) || len(firstKey) != len(key) {
+		t.Fatal("expected distinct per-object subkeys of the key's length")
+	}
+
+	// The segments are sealed under the subkey, not the repository key.
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatalf("new gcm: %v", err)
+	}
+	if _, err := gcm.Open(nil, streamNonce(first[:streamHeaderLen], 0, true), first[streamHeaderLen:], first[:streamHeaderLen]); err == nil {
+		t.Fatal("expected the repository key not to open the segment")
+	}
+
+	if err := EncryptStream([]byte("short"), io.Discard, bytes.NewReader(plain)); err == nil {
+		t.Fatal("expected an invalid key to be rejected")
+	}
+}
@@ -13,21 +13,46 @@ import (
 	"baxter/internal/config"
 	"baxter/internal/crypto"
 	"baxter/internal/recovery"
+	"baxter/internal/repolock"
 	"baxter/internal/state"
 	"baxter/internal/storage"
 )
 
-var objectStoreFromConfig = storage.NewFromConfig
+var (
+	objectStoreFromConfig      = storage.NewFromConfig
+	destinationStoreFromConfig = storage.NewDestinationFromConfig
+)
 
 func (d *Daemon) objectStore(cfg *config.Config) (storage.ObjectStore, error) {
+	return d.destinationStore(cfg, "")
+}
+
+// destinationStore opens the named destination alone, or every destination
+// with reads falling back across them when name is empty. Transfers share the
+// daemon's bandwidth limits.
+func (d *Daemon) destinationStore(cfg *config.Config, name string) (storage.ObjectStore, error) {
 	objectsDir, err := state.ObjectStoreDir()
 	if err != nil {
 		return nil, err
 	}
-	return objectStoreFromConfig(cfg.S3, objectsDir)
+	var store storage.ObjectStore
+	if name == "" {
+		store, err = objectStoreFromConfig(cfg, objectsDir)
+	} else {
+		store, err = destinationStoreFromConfig(cfg, objectsDir, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.bandwidth.Throttle(store), nil
 }
 
-var errBackupAlreadyRunning = errors.New("backup already running")
+var (
+	errBackupAlreadyRunning = errors.New("backup already running")
+	errBackupNotRunning     = errors.New("backup not running")
+	errBackupAlreadyPaused  = errors.New("backup already paused")
+	errBackupNotPaused      = errors.New("backup not paused")
+)
 
 func (d *Daemon) triggerBackup() error {
 	cfg := d.currentConfig()
@@ -37,53 +62,153 @@ func (d *Daemon) triggerBackup() error {
 		d.mu.Unlock()
 		return errBackupAlreadyRunning
 	}
+	ctx, cancel := context.WithCancel(context.Background())
 	d.running = true
+	d.cancelBackup = cancel
+	d.backupPause = &backup.PauseGate{}
 	d.status.State = "running"
 	d.status.LastError = ""
 	d.status.BackupProgress = backupProgressSummary{}
 	d.mu.Unlock()
 
 	go func() {
-		err := d.backupRunner(context.Background(), cfg)
-		if err != nil {
+		defer cancel()
+		err := d.runBackup(ctx, cfg)
+		switch {
+		case err != nil && errors.Is(err, context.Canceled):
+			d.setBackupCancelled()
+		case err != nil:
 			d.setFailed(err)
-			return
+		default:
+			d.setIdleSuccess()
 		}
-		d.setIdleSuccess()
 	}()
 	return nil
 }
 
-func (d *Daemon) performBackup(ctx context.Context, cfg *config.Config) error {
-	_ = ctx
+// requestBackupCancel stops the running backup. The run winds down in the
+// background; the previous snapshot stays current.
+func (d *Daemon) requestBackupCancel() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.running || d.cancelBackup == nil {
+		return errBackupNotRunning
+	}
+	d.cancelBackup()
+	return nil
+}
+
+// pauseBackup parks the upload workers of the running backup once their
+// current objects are stored. With resumeAfter set, the backup resumes on its
+// own after that long, so a forgotten pause does not stop backups for good.
+func (d *Daemon) pauseBackup(resumeAfter time.Duration) (time.Time, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.running || d.backupPause == nil {
+		return time.Time{}, errBackupNotRunning
+	}
+	if !d.backupPause.Pause() {
+		return time.Time{}, errBackupAlreadyPaused
+	}
+	now := d.now().UTC()
+	d.pauseGeneration++
+	d.status.State = "paused"
+	d.status.PausedAt = now
+	d.status.ResumeAt = time.Time{}
+	if resumeAfter > 0 {
+		d.status.ResumeAt = now.Add(resumeAfter)
+		generation := d.pauseGeneration
+		timer := d.timerAfter(resumeAfter)
+		go func() {
+			<-timer
+			d.autoResumeBackup(generation)
+		}()
+	}
+	return d.status.ResumeAt, nil
+}
+
+func (d *Daemon) resumeBackup() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.running || d.backupPause == nil {
+		return errBackupNotRunning
+	}
+	if !d.backupPause.Resume() {
+		return errBackupNotPaused
+	}
+	d.clearBackupPausedLocked()
+	return nil
+}
+
+// autoResumeBackup resumes the backup when it is still in the pause that
+// scheduled it, and not resumed and paused again since.
+func (d *Daemon) autoResumeBackup(generation int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pauseGeneration != generation || d.backupPause == nil || !d.backupPause.Resume() {
+		return
+	}
+	d.clearBackupPausedLocked()
+	fmt.Println("backup resumed: pause timed out")
+}
+
+func (d *Daemon) clearBackupPausedLocked() {
+	d.status.State = "running"
+	d.status.PausedAt = time.Time{}
+	d.status.ResumeAt = time.Time{}
+}
+
+func (d *Daemon) currentBackupPause() *backup.PauseGate {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.backupPause
+}
+
+func (d *Daemon) performBackup(ctx context.Context, cfg *config.Config) (backup.RunResult, error) {
 	manifestPath, err := state.ManifestPath()
 	if err != nil {
-		return err
+		return backup.RunResult{}, err
 	}
 	snapshotDir, err := state.ManifestSnapshotsDir()
 	if err != nil {
-		return err
+		return backup.RunResult{}, err
+	}
+	statCachePath, err := state.StatCachePath()
+	if err != nil {
+		return backup.RunResult{}, err
+	}
+	checkpointPath, err := state.UploadCheckpointPath()
+	if err != nil {
+		return backup.RunResult{}, err
 	}
 
 	store, err := d.objectStore(cfg)
 	if err != nil {
-		return fmt.Errorf("create object store: %w", err)
+		return backup.RunResult{}, fmt.Errorf("create object store: %w", err)
 	}
+	defer storage.Close(store)
+	// The lock is taken before the keys are resolved, which may create the
+	// wrapped key in the recovery metadata.
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "backup")
+	if err != nil {
+		return backup.RunResult{}, err
+	}
+	defer lock.Release()
 	saltPath, err := state.KDFSaltPath()
 	if err != nil {
-		return err
+		return backup.RunResult{}, err
 	}
 	allowCreateWrappedIfMissing, err := backup.AllowCreateWrappedKeyWithoutMetadata(manifestPath, snapshotDir, saltPath, store)
 	if err != nil {
-		return err
+		return backup.RunResult{}, err
 	}
 	keys, err := backupEncryptionKeys(cfg, store, allowCreateWrappedIfMissing)
 	if err != nil {
-		return err
+		return backup.RunResult{}, err
 	}
 
 	var lastProgressLog time.Time
-	result, err := backup.Run(cfg, backup.RunOptions{
+	result, err := backup.Run(ctx, cfg, backup.RunOptions{
 		ManifestPath:       manifestPath,
 		SnapshotDir:        snapshotDir,
 		SnapshotRetention:  cfg.Retention.ManifestSnapshots,
@@ -93,6 +218,12 @@ func (d *Daemon) performBackup(ctx context.Context, cfg *config.Config) error {
 		WrappedMasterKey:   keys.wrapped,
 		BackupSetID:        recovery.BackupSetID(cfg),
 		Store:              store,
+		StatCachePath:      statCachePath,
+		RehashInterval:     time.Duration(cfg.RehashIntervalDays) * 24 * time.Hour,
+		CheckpointPath:     checkpointPath,
+		SnapshotKeep:       backup.SnapshotKeepRulesFromConfig(cfg.Retention),
+		Pause:              d.currentBackupPause(),
+		Lock:               lock,
 		Progress: func(update backup.ProgressUpdate) {
 			now := time.Now()
 			d.setBackupProgress(backupProgressSummary{
@@ -116,11 +247,12 @@ func (d *Daemon) performBackup(ctx context.Context, cfg *config.Config) error {
 			}
 		},
 	})
+	d.setDestinationStatuses(storage.DestinationStatuses(store), err == nil)
 	if err != nil {
-		return err
+		return backup.RunResult{}, err
 	}
-	fmt.Printf("backup complete: uploaded=%d removed=%d total=%d\n", result.Uploaded, result.Removed, result.Total)
-	return nil
+	fmt.Printf("backup complete: uploaded=%d reused=%d removed=%d total=%d\n", result.Uploaded, result.Reused, result.Removed, result.Total)
+	return result, nil
 }
 
 func encryptionKey(cfg *config.Config) ([]byte, error) {
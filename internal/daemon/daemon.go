@@ -8,7 +8,9 @@ import (
 	"sync"
 	"time"
 
+	"baxter/internal/backup"
 	"baxter/internal/config"
+	"baxter/internal/storage"
 )
 
 const (
@@ -26,7 +28,7 @@ type Daemon struct {
 	configLoader          func(string) (*config.Config, error)
 	clockNow              func() time.Time
 	timerAfter            func(time.Duration) <-chan time.Time
-	backupRunner          func(context.Context, *config.Config) error
+	backupRunner          func(context.Context, *config.Config) (backup.RunResult, error)
 	scheduleChanged       chan struct{}
 	verifyScheduleChanged chan struct{}
 	ipcAddr               string
@@ -34,11 +36,19 @@ type Daemon struct {
 	restoreIndexMu        sync.Mutex
 	running               bool
 	verifyRunning         bool
+	cancelBackup          context.CancelFunc
+	backupPause           *backup.PauseGate
+	pauseGeneration       int
+	cancelVerify          context.CancelFunc
 	status                daemonStatus
 	handler               http.Handler
 	restoreListSource     restoreManifestSourceState
 	restoreListIndexedAt  time.Time
 	restoreListIndex      *restoreManifestIndex
+	bandwidth             *storage.BandwidthLimiter
+	// historyMu serializes writes of the run history file.
+	historyMu sync.Mutex
+	history   []runRecord
 }
 
 func New(cfg *config.Config) *Daemon {
@@ -55,9 +65,11 @@ func New(cfg *config.Config) *Daemon {
 			VerifyState: "idle",
 		},
 	}
+	d.bandwidth = storage.NewBandwidthLimiter(cfg.Bandwidth)
 	d.backupRunner = d.performBackup
 	d.handler = d.newHandler()
 	d.loadPersistedStatus()
+	d.loadRunHistory()
 	return d
 }
 
@@ -120,7 +132,7 @@ func (d *Daemon) newHTTPServer() *http.Server {
 }
 
 func (d *Daemon) RunOnce(ctx context.Context) error {
-	if err := d.backupRunner(ctx, d.currentConfig()); err != nil {
+	if err := d.runBackup(ctx, d.currentConfig()); err != nil {
 		d.setFailed(err)
 		return err
 	}
@@ -24,8 +24,10 @@ func TestReadEndpointsRejectMissingTokenWhenConfigured(t *testing.T) {
 		body   []byte
 	}{
 		{name: "status", method: http.MethodGet, path: "/v1/status"},
+		{name: "run history", method: http.MethodGet, path: "/v1/history"},
 		{name: "snapshots", method: http.MethodGet, path: "/v1/snapshots"},
 		{name: "restore list", method: http.MethodGet, path: "/v1/restore/list"},
+		{name: "restore export", method: http.MethodGet, path: "/v1/restore/export"},
 		{name: "restore dry-run", method: http.MethodPost, path: "/v1/restore/dry-run", body: []byte(`{"path":"/Users/me/Documents/report.txt"}`)},
 	}
 
@@ -2,7 +2,9 @@ package daemon
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -11,6 +13,9 @@ import (
 	"time"
 
 	"baxter/internal/config"
+	"baxter/internal/repolock"
+	"baxter/internal/state"
+	"baxter/internal/storage"
 )
 
 func TestRunBackupEndpointStateTransition(t *testing.T) {
@@ -85,3 +90,80 @@ func TestRunBackupEndpointStateTransition(t *testing.T) {
 		t.Fatal("expected last_error after failed run")
 	}
 }
+
+func TestPerformBackupTakesTheLockBeforeCreatingKeys(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "daemon-test-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.Schedule = "manual"
+	cfg.BackupRoots = []string{t.TempDir()}
+
+	d := New(cfg)
+	store, err := d.objectStore(cfg)
+	if err != nil {
+		t.Fatalf("object store: %v", err)
+	}
+	gcLock, err := repolock.AcquireForOperation(store, repolock.Exclusive, "gc")
+	if err != nil {
+		t.Fatalf("lock repository: %v", err)
+	}
+	defer gcLock.Release()
+
+	if _, err := d.performBackup(context.Background(), cfg); !errors.Is(err, repolock.ErrLocked) {
+		t.Fatalf("expected the backup to be refused, got %v", err)
+	}
+	saltPath, err := state.KDFSaltPath()
+	if err != nil {
+		t.Fatalf("kdf salt path: %v", err)
+	}
+	if _, err := os.Stat(saltPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no KDF salt to be created without the lock, got %v", err)
+	}
+}
+
+func TestStatusReportsDestinationOutcomes(t *testing.T) {
+	d := New(config.DefaultConfig())
+	firstRun := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	d.clockNow = func() time.Time { return firstRun }
+	d.setDestinationStatuses([]storage.DestinationStatus{
+		{Name: "primary"},
+		{Name: "nas"},
+	}, true)
+
+	d.clockNow = func() time.Time { return firstRun.Add(24 * time.Hour) }
+	d.setDestinationStatuses([]storage.DestinationStatus{
+		{Name: "primary"},
+		{Name: "nas", Failed: true, Err: errors.New("dial tcp: connection refused")},
+		{Name: "drive"},
+	}, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+	rr := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status endpoint code: got %d want %d", rr.Code, http.StatusOK)
+	}
+	var resp statusResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode status response: %v", err)
+	}
+
+	// An uncommitted run leaves earlier successes in place and does not
+	// count as one.
+	want := []destinationStatusResponse{
+		{Name: "primary", State: "ok", LastSuccessAt: "2026-03-01T02:00:00Z"},
+		{Name: "nas", State: "failed", LastError: "dial tcp: connection refused", LastSuccessAt: "2026-03-01T02:00:00Z"},
+		{Name: "drive", State: "pending"},
+	}
+	if len(resp.Destinations) != len(want) {
+		t.Fatalf("unexpected destinations: %+v", resp.Destinations)
+	}
+	for i := range want {
+		if resp.Destinations[i] != want[i] {
+			t.Fatalf("destination %d: got %+v want %+v", i, resp.Destinations[i], want[i])
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -77,6 +78,185 @@ func TestRunBackupEndpointReturnsConflictWhenAlreadyRunning(t *testing.T) {
 	}
 }
 
+func TestCancelBackupEndpointStopsRunningBackup(t *testing.T) {
+	d := New(config.DefaultConfig())
+	started := make(chan struct{})
+	d.backupRunner = func(ctx context.Context, _ *config.Config) (backup.RunResult, error) {
+		close(started)
+		<-ctx.Done()
+		return backup.RunResult{}, ctx.Err()
+	}
+
+	idleRR := httptest.NewRecorder()
+	d.Handler().ServeHTTP(idleRR, httptest.NewRequest(http.MethodPost, "/v1/backup/cancel", nil))
+	if idleRR.Code != http.StatusConflict {
+		t.Fatalf("idle cancel status code: got %d want %d", idleRR.Code, http.StatusConflict)
+	}
+	if errResp := decodeErrorResponse(t, idleRR); errResp.Code != "backup_not_running" {
+		t.Fatalf("unexpected error code: got %q", errResp.Code)
+	}
+
+	lastBackupAt := d.snapshot().LastBackupAt
+	if err := d.triggerBackup(); err != nil {
+		t.Fatalf("trigger backup: %v", err)
+	}
+	<-started
+
+	rr := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/backup/cancel", nil))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("cancel status code: got %d want %d", rr.Code, http.StatusAccepted)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for d.snapshot().State == "running" {
+		if time.Now().After(deadline) {
+			t.Fatal("backup did not stop after cancel")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	status := d.snapshot()
+	if status.State != "idle" || status.LastError != "backup cancelled" {
+		t.Fatalf("unexpected status after cancel: state=%q last_error=%q", status.State, status.LastError)
+	}
+	if status.LastBackupAt != lastBackupAt {
+		t.Fatalf("cancelled backup changed last backup time: got %q want %q", status.LastBackupAt, lastBackupAt)
+	}
+}
+
+func TestPauseAndResumeBackupEndpoints(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+
+	d := New(config.DefaultConfig())
+	var timers []chan time.Time
+	d.timerAfter = func(time.Duration) <-chan time.Time {
+		timer := make(chan time.Time, 1)
+		timers = append(timers, timer)
+		return timer
+	}
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	d.backupRunner = func(ctx context.Context, _ *config.Config) (backup.RunResult, error) {
+		close(started)
+		<-finish
+		return backup.RunResult{}, d.currentBackupPause().Wait(ctx)
+	}
+	post := func(path string, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		d.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rr
+	}
+	expectError := func(rr *httptest.ResponseRecorder, code string) {
+		t.Helper()
+		if rr.Code != http.StatusConflict {
+			t.Fatalf("status code: got %d want %d", rr.Code, http.StatusConflict)
+		}
+		if errResp := decodeErrorResponse(t, rr); errResp.Code != code {
+			t.Fatalf("unexpected error code: got %q want %q", errResp.Code, code)
+		}
+	}
+	waitForState := func(want string) statusResponse {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			status := d.snapshot()
+			if status.State == want {
+				return status
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("state: got %q want %q", status.State, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	expectError(post("/v1/backup/pause", ""), "backup_not_running")
+	if err := d.triggerBackup(); err != nil {
+		t.Fatalf("trigger backup: %v", err)
+	}
+	<-started
+
+	rr := post("/v1/backup/pause", "")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("pause status code: got %d want %d", rr.Code, http.StatusAccepted)
+	}
+	status := d.snapshot()
+	if status.State != "paused" || status.BackupPausedAt == "" || status.BackupResumeAt != "" {
+		t.Fatalf("unexpected status while paused: %+v", status)
+	}
+	expectError(post("/v1/backup/pause", ""), "backup_paused")
+
+	if rr := post("/v1/backup/resume", ""); rr.Code != http.StatusAccepted {
+		t.Fatalf("resume status code: got %d want %d", rr.Code, http.StatusAccepted)
+	}
+	if status := d.snapshot(); status.State != "running" || status.BackupPausedAt != "" {
+		t.Fatalf("unexpected status after resume: %+v", status)
+	}
+	expectError(post("/v1/backup/resume", ""), "backup_not_paused")
+
+	// A timed pause resumes on its own.
+	rr = post("/v1/backup/pause", `{"resume_after":"30m"}`)
+	var resp backupPauseResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode pause response: %v", err)
+	}
+	if rr.Code != http.StatusAccepted || resp.ResumeAt == "" || d.snapshot().BackupResumeAt != resp.ResumeAt {
+		t.Fatalf("unexpected timed pause response: %d %+v", rr.Code, resp)
+	}
+	if len(timers) != 1 {
+		t.Fatalf("expected one auto-resume timer, got %d", len(timers))
+	}
+	timers[0] <- time.Now()
+	waitForState("running")
+
+	close(finish)
+	status = waitForState("idle")
+	if status.LastError != "" || status.BackupPausedAt != "" {
+		t.Fatalf("unexpected status after backup: %+v", status)
+	}
+}
+
+func TestPauseBackupTimerIgnoresLaterPause(t *testing.T) {
+	d := New(config.DefaultConfig())
+	timer := make(chan time.Time, 1)
+	d.timerAfter = func(time.Duration) <-chan time.Time { return timer }
+	d.mu.Lock()
+	d.running = true
+	d.backupPause = &backup.PauseGate{}
+	d.mu.Unlock()
+
+	if _, err := d.pauseBackup(time.Minute); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	generation := d.pauseGeneration
+	if err := d.resumeBackup(); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if _, err := d.pauseBackup(0); err != nil {
+		t.Fatalf("pause again: %v", err)
+	}
+	d.autoResumeBackup(generation)
+	if status := d.snapshot(); status.State != "paused" {
+		t.Fatalf("expected the stale timer to leave the new pause alone, got %q", status.State)
+	}
+}
+
+func TestCancelVerifyEndpointReturnsConflictWhenIdle(t *testing.T) {
+	d := New(config.DefaultConfig())
+	rr := httptest.NewRecorder()
+
+	d.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/verify/cancel", nil))
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("status code: got %d want %d", rr.Code, http.StatusConflict)
+	}
+	if errResp := decodeErrorResponse(t, rr); errResp.Code != "verify_not_running" {
+		t.Fatalf("unexpected error code: got %q", errResp.Code)
+	}
+}
+
 func TestRunBackupEndpointRejectsNonPost(t *testing.T) {
 	d := New(config.DefaultConfig())
 	req := httptest.NewRequest(http.MethodGet, "/v1/backup/run", nil)
@@ -186,7 +366,7 @@ func TestRunBackupEndpointRejectsMissingTokenWhenConfigured(t *testing.T) {
 func TestRunBackupEndpointAcceptsValidTokenWhenConfigured(t *testing.T) {
 	d := New(config.DefaultConfig())
 	d.SetIPCAuthToken("secret-token")
-	d.backupRunner = func(context.Context, *config.Config) error { return nil }
+	d.backupRunner = func(context.Context, *config.Config) (backup.RunResult, error) { return backup.RunResult{}, nil }
 	req := httptest.NewRequest(http.MethodPost, "/v1/backup/run", nil)
 	req.Header.Set(ipcTokenHeader, "secret-token")
 	rr := httptest.NewRecorder()
@@ -322,6 +502,59 @@ func TestReloadConfigEndpointUpdatesSchedule(t *testing.T) {
 	}
 }
 
+func TestBandwidthEndpointsOverrideScheduleInStatus(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Bandwidth = config.BandwidthConfig{UploadLimit: "2MB/s", DownloadLimit: "unlimited"}
+	d := New(cfg)
+	now := time.Now()
+	d.clockNow = func() time.Time { return now }
+
+	status := func() bandwidthStatusResponse {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		d.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/status", nil))
+		var resp statusResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+		return resp.Bandwidth
+	}
+	if got, want := status(), (bandwidthStatusResponse{UploadLimit: 2_000_000}); got != want {
+		t.Fatalf("unexpected scheduled bandwidth: got %+v want %+v", got, want)
+	}
+
+	body := strings.NewReader(`{"upload_limit":"500KB/s","download_limit":"1MiB/s","duration":"2h"}`)
+	rr := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/bandwidth/set", body))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("set status code: got %d want %d: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	want := bandwidthStatusResponse{
+		UploadLimit:   500_000,
+		DownloadLimit: 1 << 20,
+		Override:      true,
+		OverrideUntil: now.Add(2 * time.Hour).Format(time.RFC3339),
+	}
+	if got := status(); got != want {
+		t.Fatalf("unexpected overridden bandwidth: got %+v want %+v", got, want)
+	}
+
+	rr = httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/bandwidth/set", strings.NewReader(`{"upload_limit":"fast"}`)))
+	if rr.Code != http.StatusBadRequest || decodeErrorResponse(t, rr).Code != "invalid_bandwidth" {
+		t.Fatalf("expected invalid_bandwidth, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/bandwidth/clear", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("clear status code: got %d want %d", rr.Code, http.StatusOK)
+	}
+	if got, want := status(), (bandwidthStatusResponse{UploadLimit: 2_000_000}); got != want {
+		t.Fatalf("unexpected bandwidth after clear: got %+v want %+v", got, want)
+	}
+}
+
 func TestRestoreListEndpoint(t *testing.T) {
 	homeDir := t.TempDir()
 	t.Setenv("HOME", homeDir)
@@ -562,6 +795,121 @@ func TestSnapshotsEndpoint(t *testing.T) {
 	}
 }
 
+func TestSnapshotRetentionEndpointExplainsPlan(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+
+	snapshotDir := testManifestSnapshotsDir(t)
+	newest := time.Now().UTC().Add(-time.Hour)
+	for i, tags := range [][]string{nil, nil, {"release"}} {
+		if _, err := backup.SaveSnapshotManifest(snapshotDir, &backup.Manifest{
+			CreatedAt: newest.Add(-time.Duration(i) * time.Minute),
+			Tags:      tags,
+			Entries:   []backup.ManifestEntry{{Path: "/a.txt"}},
+		}); err != nil {
+			t.Fatalf("save snapshot %d: %v", i, err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Retention.ManifestSnapshots = 1
+	cfg.Retention.KeepTags = []string{"release"}
+	d := New(cfg)
+	req := httptest.NewRequest(http.MethodGet, "/v1/snapshots/retention", nil)
+	rr := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status code: got %d want %d body=%s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var resp snapshotRetentionResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Snapshots) != 3 || resp.Prune != 1 {
+		t.Fatalf("unexpected retention plan: %+v", resp)
+	}
+	got := make([]string, 0, len(resp.Snapshots))
+	for _, snapshot := range resp.Snapshots {
+		got = append(got, fmt.Sprintf("%t:%s", snapshot.Keep, strings.Join(snapshot.Reasons, ",")))
+	}
+	if want := "true:last false:no-rule true:tag:release"; strings.Join(got, " ") != want {
+		t.Fatalf("unexpected decisions: got %q want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestSnapshotDiffEndpoint(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+
+	snapshotDir := testManifestSnapshotsDir(t)
+	older, err := backup.SaveSnapshotManifest(snapshotDir, &backup.Manifest{
+		CreatedAt: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Entries: []backup.ManifestEntry{
+			{Path: "/docs/a.txt", Size: 10, SHA256: "a1"},
+			{Path: "/docs/b.txt", Size: 5, SHA256: "b1"},
+			{Path: "/music/c.mp3", Size: 100, SHA256: "c1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("save older snapshot: %v", err)
+	}
+	newer, err := backup.SaveSnapshotManifest(snapshotDir, &backup.Manifest{
+		CreatedAt: time.Date(2026, time.January, 2, 0, 0, 0, 0, time.UTC),
+		Entries: []backup.ManifestEntry{
+			{Path: "/docs/a.txt", Size: 14, SHA256: "a2"},
+			{Path: "/docs/d.txt", Size: 3, SHA256: "d1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("save newer snapshot: %v", err)
+	}
+
+	d := New(config.DefaultConfig())
+	req := httptest.NewRequest(http.MethodGet, "/v1/snapshots/diff?from="+older.ID+"&to="+newer.ID+"&prefix=/docs", nil)
+	rr := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status code: got %d want %d body=%s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var resp snapshotDiffResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.From != older.ID || resp.To != newer.ID {
+		t.Fatalf("unexpected selectors: %+v", resp)
+	}
+	if len(resp.Added) != 1 || resp.Added[0].Path != "/docs/d.txt" {
+		t.Fatalf("unexpected added: %+v", resp.Added)
+	}
+	if len(resp.Removed) != 1 || resp.Removed[0].Path != "/docs/b.txt" {
+		t.Fatalf("unexpected removed (prefix should hide /music): %+v", resp.Removed)
+	}
+	if len(resp.Modified) != 1 || resp.Modified[0].Path != "/docs/a.txt" || resp.Modified[0].SizeDelta != 4 {
+		t.Fatalf("unexpected modified: %+v", resp.Modified)
+	}
+	if resp.SizeDelta != 2 {
+		t.Fatalf("size delta: got %d want 2", resp.SizeDelta)
+	}
+}
+
+func TestSnapshotDiffEndpointRequiresBothSnapshots(t *testing.T) {
+	d := New(config.DefaultConfig())
+	req := httptest.NewRequest(http.MethodGet, "/v1/snapshots/diff?from=latest", nil)
+	rr := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status code: got %d want %d", rr.Code, http.StatusBadRequest)
+	}
+	errResp := decodeErrorResponse(t, rr)
+	if errResp.Code != "invalid_request" {
+		t.Fatalf("unexpected error code: got %q", errResp.Code)
+	}
+}
+
 func TestDaemonErrorContractMethodNotAllowedAcrossEndpoints(t *testing.T) {
 	d := New(config.DefaultConfig())
 
@@ -572,11 +920,19 @@ func TestDaemonErrorContractMethodNotAllowedAcrossEndpoints(t *testing.T) {
 	}{
 		{name: "status", method: http.MethodPost, path: "/v1/status"},
 		{name: "backup run", method: http.MethodGet, path: "/v1/backup/run"},
+		{name: "backup pause", method: http.MethodGet, path: "/v1/backup/pause"},
+		{name: "backup resume", method: http.MethodGet, path: "/v1/backup/resume"},
 		{name: "config reload", method: http.MethodGet, path: "/v1/config/reload"},
+		{name: "bandwidth set", method: http.MethodGet, path: "/v1/bandwidth/set"},
+		{name: "bandwidth clear", method: http.MethodGet, path: "/v1/bandwidth/clear"},
+		{name: "run history", method: http.MethodPost, path: "/v1/history"},
 		{name: "snapshots", method: http.MethodPost, path: "/v1/snapshots"},
+		{name: "snapshot retention", method: http.MethodPost, path: "/v1/snapshots/retention"},
+		{name: "snapshot diff", method: http.MethodPost, path: "/v1/snapshots/diff"},
 		{name: "restore list", method: http.MethodPost, path: "/v1/restore/list"},
 		{name: "restore dry-run", method: http.MethodGet, path: "/v1/restore/dry-run"},
 		{name: "restore run", method: http.MethodGet, path: "/v1/restore/run"},
+		{name: "restore export", method: http.MethodPost, path: "/v1/restore/export"},
 	}
 
 	for _, tc := range tests {
@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"baxter/internal/backup"
+	"baxter/internal/config"
+)
+
+func TestBackupHooksAreRecordedInRunHistory(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+
+	cfg := config.DefaultConfig()
+	cfg.Hooks.PreBackup = []config.HookConfig{{Command: []string{"/bin/sh", "-c", `echo "freeze $BAXTER_OPERATION"`}}}
+	cfg.Hooks.PostBackup = []config.HookConfig{{Command: []string{"/bin/sh", "-c", `echo "thaw $BAXTER_SNAPSHOT_ID $BAXTER_TOTAL"`}}}
+	d := New(cfg)
+	runs := 0
+	d.backupRunner = func(context.Context, *config.Config) (backup.RunResult, error) {
+		runs++
+		return backup.RunResult{SnapshotID: "20260301T000000Z", Total: 7}, nil
+	}
+
+	if err := d.RunOnce(context.Background()); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+
+	cfg.Hooks.PreBackup = []config.HookConfig{{Command: []string{"/bin/sh", "-c", "echo database busy; exit 2"}}}
+	if err := d.RunOnce(context.Background()); err == nil || !strings.Contains(err.Error(), "pre_backup hook") {
+		t.Fatalf("expected pre_backup hook to abort the backup, got %v", err)
+	}
+	if runs != 1 {
+		t.Fatalf("expected the aborted backup not to run, ran %d times", runs)
+	}
+	if status := d.snapshot(); status.State != "failed" || !strings.Contains(status.LastError, "exit status 2") {
+		t.Fatalf("unexpected status: state=%q last_error=%q", status.State, status.LastError)
+	}
+
+	// A restarted daemon reads the history back.
+	d = New(cfg)
+	rr := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/history?limit=5", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status code: got %d want %d", rr.Code, http.StatusOK)
+	}
+	var resp runHistoryResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	if len(resp.Runs) != 2 {
+		t.Fatalf("expected 2 runs, got %+v", resp.Runs)
+	}
+	failed, succeeded := resp.Runs[0], resp.Runs[1]
+	if failed.Result != "failed" || len(failed.Hooks) != 1 || failed.Hooks[0].ExitCode != 2 || failed.Hooks[0].Output != "database busy\n" {
+		t.Fatalf("unexpected failed run: %+v", failed)
+	}
+	if succeeded.Operation != "backup" || succeeded.Result != "success" || succeeded.SnapshotID != "20260301T000000Z" {
+		t.Fatalf("unexpected successful run: %+v", succeeded)
+	}
+	if len(succeeded.Hooks) != 2 || succeeded.Hooks[0].Output != "freeze backup\n" || succeeded.Hooks[1].Output != "thaw 20260301T000000Z 7\n" {
+		t.Fatalf("unexpected hook results: %+v", succeeded.Hooks)
+	}
+
+	rr = httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/history?limit=-1", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status code: got %d want %d", rr.Code, http.StatusBadRequest)
+	}
+}
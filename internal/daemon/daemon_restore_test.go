@@ -1,9 +1,14 @@
 package daemon
 
 import (
+	"archive/tar"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -18,6 +23,8 @@ import (
 	"baxter/internal/recovery"
 	"baxter/internal/state"
 	"baxter/internal/storage"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 var daemonTestKDFSalt = []byte("0123456789abcdef")
@@ -55,11 +62,11 @@ func TestRestoreRunEndpoint(t *testing.T) {
 	if err != nil {
 		t.Fatalf("object store dir: %v", err)
 	}
-	store, err := storage.NewFromConfig(cfg.S3, objectsDir)
+	store, err := storage.NewFromConfig(cfg, objectsDir)
 	if err != nil {
 		t.Fatalf("new object store: %v", err)
 	}
-	_, err = backup.Run(cfg, backup.RunOptions{
+	_, err = backup.Run(context.Background(), cfg, backup.RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: cfg.Retention.ManifestSnapshots,
@@ -117,6 +124,147 @@ func TestRestoreRunEndpoint(t *testing.T) {
 	}
 }
 
+func TestRestoreExportEndpointStreamsArchive(t *testing.T) {
+	homeDir := t.TempDir()
+	srcRoot := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatalf("mkdir src root: %v", err)
+	}
+	sourcePath := filepath.Join(srcRoot, "doc.txt")
+	sourceBody := []byte("daemon export body")
+	if err := os.WriteFile(sourcePath, sourceBody, 0o640); err != nil {
+		t.Fatalf("write source file: %v", err)
+	}
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+	t.Setenv(passphraseEnv, "daemon-export-passphrase")
+
+	cfg := config.DefaultConfig()
+	cfg.BackupRoots = []string{srcRoot}
+	cfg.Schedule = "manual"
+
+	manifestPath, err := state.ManifestPath()
+	if err != nil {
+		t.Fatalf("manifest path: %v", err)
+	}
+	snapshotDir, err := state.ManifestSnapshotsDir()
+	if err != nil {
+		t.Fatalf("manifest snapshots dir: %v", err)
+	}
+	objectsDir, err := state.ObjectStoreDir()
+	if err != nil {
+		t.Fatalf("object store dir: %v", err)
+	}
+	store, err := storage.NewFromConfig(cfg, objectsDir)
+	if err != nil {
+		t.Fatalf("new object store: %v", err)
+	}
+	_, err = backup.Run(context.Background(), cfg, backup.RunOptions{
+		ManifestPath:      manifestPath,
+		SnapshotDir:       snapshotDir,
+		SnapshotRetention: cfg.Retention.ManifestSnapshots,
+		EncryptionKey:     crypto.KeyFromPassphrase("daemon-export-passphrase"),
+		KDFSalt:           daemonTestKDFSalt,
+		BackupSetID:       recovery.BackupSetID(cfg),
+		Store:             store,
+	})
+	if err != nil {
+		t.Fatalf("run backup: %v", err)
+	}
+
+	d := New(cfg)
+	req := httptest.NewRequest(http.MethodGet, "/v1/restore/export?format=tar&prefix="+srcRoot, nil)
+	rr := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status code: got %d want %d body=%s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/x-tar" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+
+	wantName := strings.TrimPrefix(filepath.ToSlash(sourcePath), "/")
+	archive := tar.NewReader(rr.Body)
+	found := false
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read archive: %v", err)
+		}
+		if header.Name != wantName {
+			continue
+		}
+		found = true
+		body, err := io.ReadAll(archive)
+		if err != nil {
+			t.Fatalf("read archived file: %v", err)
+		}
+		if !bytes.Equal(body, sourceBody) {
+			t.Fatalf("archived body mismatch: got %q want %q", body, sourceBody)
+		}
+		if fs.FileMode(header.Mode).Perm() != 0o640 {
+			t.Fatalf("unexpected archived mode: %o", header.Mode)
+		}
+	}
+	if !found {
+		t.Fatalf("archive has no entry %q", wantName)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/restore/export?format=tar.zst&prefix="+srcRoot, nil)
+	rr = httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("tar.zst status code: got %d want %d body=%s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/zstd" {
+		t.Fatalf("unexpected tar.zst content type: %q", got)
+	}
+	decoder, err := zstd.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("open zstd stream: %v", err)
+	}
+	defer decoder.Close()
+	archive = tar.NewReader(decoder)
+	found = false
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar.zst archive: %v", err)
+		}
+		if header.Name != wantName {
+			continue
+		}
+		found = true
+		body, err := io.ReadAll(archive)
+		if err != nil {
+			t.Fatalf("read archived file: %v", err)
+		}
+		if !bytes.Equal(body, sourceBody) {
+			t.Fatalf("tar.zst archived body mismatch: got %q want %q", body, sourceBody)
+		}
+	}
+	if !found {
+		t.Fatalf("tar.zst archive has no entry %q", wantName)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/restore/export?format=rar", nil)
+	rr = httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status code: got %d want %d", rr.Code, http.StatusBadRequest)
+	}
+	if errResp := decodeErrorResponse(t, rr); errResp.Code != "invalid_request" {
+		t.Fatalf("unexpected error code: got %q", errResp.Code)
+	}
+}
+
 func TestRestoreRunEndpointVerifyOnlyDoesNotWrite(t *testing.T) {
 	homeDir := t.TempDir()
 	srcRoot := filepath.Join(t.TempDir(), "src")
@@ -150,11 +298,11 @@ func TestRestoreRunEndpointVerifyOnlyDoesNotWrite(t *testing.T) {
 	if err != nil {
 		t.Fatalf("object store dir: %v", err)
 	}
-	store, err := storage.NewFromConfig(cfg.S3, objectsDir)
+	store, err := storage.NewFromConfig(cfg, objectsDir)
 	if err != nil {
 		t.Fatalf("new object store: %v", err)
 	}
-	_, err = backup.Run(cfg, backup.RunOptions{
+	_, err = backup.Run(context.Background(), cfg, backup.RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: cfg.Retention.ManifestSnapshots,
@@ -227,7 +375,7 @@ func TestRestoreRunEndpointFallsBackToRemoteMetadataWhenLocalCacheMissing(t *tes
 	if err != nil {
 		t.Fatalf("object store dir: %v", err)
 	}
-	store, err := storage.NewFromConfig(cfg.S3, objectsDir)
+	store, err := storage.NewFromConfig(cfg, objectsDir)
 	if err != nil {
 		t.Fatalf("new object store: %v", err)
 	}
@@ -235,7 +383,7 @@ func TestRestoreRunEndpointFallsBackToRemoteMetadataWhenLocalCacheMissing(t *tes
 	if err != nil {
 		t.Fatalf("create wrapped key set: %v", err)
 	}
-	_, err = backup.Run(cfg, backup.RunOptions{
+	_, err = backup.Run(context.Background(), cfg, backup.RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: cfg.Retention.ManifestSnapshots,
@@ -319,11 +467,11 @@ func TestRestoreRunEndpointRestoresDirectorySubtree(t *testing.T) {
 	if err != nil {
 		t.Fatalf("object store dir: %v", err)
 	}
-	store, err := storage.NewFromConfig(cfg.S3, objectsDir)
+	store, err := storage.NewFromConfig(cfg, objectsDir)
 	if err != nil {
 		t.Fatalf("new object store: %v", err)
 	}
-	_, err = backup.Run(cfg, backup.RunOptions{
+	_, err = backup.Run(context.Background(), cfg, backup.RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: cfg.Retention.ManifestSnapshots,
@@ -421,11 +569,11 @@ func TestRestoreRunEndpointRejectsExistingTargetWithoutOverwrite(t *testing.T) {
 	if err != nil {
 		t.Fatalf("object store dir: %v", err)
 	}
-	store, err := storage.NewFromConfig(cfg.S3, objectsDir)
+	store, err := storage.NewFromConfig(cfg, objectsDir)
 	if err != nil {
 		t.Fatalf("new object store: %v", err)
 	}
-	_, err = backup.Run(cfg, backup.RunOptions{
+	_, err = backup.Run(context.Background(), cfg, backup.RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: cfg.Retention.ManifestSnapshots,
@@ -464,6 +612,25 @@ func TestRestoreRunEndpointRejectsExistingTargetWithoutOverwrite(t *testing.T) {
 	}
 }
 
+func TestRestoreRunEndpointReturnsJournalNotFoundCode(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+
+	d := New(config.DefaultConfig())
+	body := bytes.NewBufferString(`{"resume":"20260101T000000Z-00000000"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/restore/run", body)
+	rr := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status code: got %d want %d", rr.Code, http.StatusNotFound)
+	}
+	errResp := decodeErrorResponse(t, rr)
+	if errResp.Code != "restore_journal_not_found" {
+		t.Fatalf("unexpected error code: got %q", errResp.Code)
+	}
+}
+
 func TestRestoreRunEndpointChecksumMismatchDoesNotOverwrite(t *testing.T) {
 	homeDir := t.TempDir()
 	srcRoot := filepath.Join(t.TempDir(), "src")
@@ -496,11 +663,11 @@ func TestRestoreRunEndpointChecksumMismatchDoesNotOverwrite(t *testing.T) {
 	if err != nil {
 		t.Fatalf("object store dir: %v", err)
 	}
-	store, err := storage.NewFromConfig(cfg.S3, objectsDir)
+	store, err := storage.NewFromConfig(cfg, objectsDir)
 	if err != nil {
 		t.Fatalf("new object store: %v", err)
 	}
-	_, err = backup.Run(cfg, backup.RunOptions{
+	_, err = backup.Run(context.Background(), cfg, backup.RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: cfg.Retention.ManifestSnapshots,
@@ -590,11 +757,11 @@ func TestRestoreRunEndpointReturnsObjectMissingCode(t *testing.T) {
 	if err != nil {
 		t.Fatalf("object store dir: %v", err)
 	}
-	store, err := storage.NewFromConfig(cfg.S3, objectsDir)
+	store, err := storage.NewFromConfig(cfg, objectsDir)
 	if err != nil {
 		t.Fatalf("new object store: %v", err)
 	}
-	_, err = backup.Run(cfg, backup.RunOptions{
+	_, err = backup.Run(context.Background(), cfg, backup.RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: cfg.Retention.ManifestSnapshots,
@@ -667,7 +834,7 @@ func TestRestoreRunEndpointReturnsStorageTransientCode(t *testing.T) {
 	t.Cleanup(func() {
 		objectStoreFromConfig = originalFactory
 	})
-	objectStoreFromConfig = func(_ config.S3Config, _ string) (storage.ObjectStore, error) {
+	objectStoreFromConfig = func(_ *config.Config, _ string) (storage.ObjectStore, error) {
 		return transientReadStore{}, nil
 	}
 
@@ -718,21 +885,31 @@ func TestRestoreDryRunEndpointReturnsExternalPlaceholderCode(t *testing.T) {
 	}
 }
 
-func TestClassifyRestoreReadObjectError(t *testing.T) {
-	statusCode, code, _ := classifyRestoreReadObjectError("/Users/me/doc.txt", os.ErrNotExist)
+func TestClassifyRestoreContentError(t *testing.T) {
+	statusCode, code, _ := classifyRestoreContentError("/Users/me/doc.txt", os.ErrNotExist)
 	if statusCode != http.StatusNotFound || code != "restore_object_missing" {
 		t.Fatalf("unexpected not found classification: status=%d code=%s", statusCode, code)
 	}
 
-	statusCode, code, _ = classifyRestoreReadObjectError("/Users/me/doc.txt", storage.ErrTransient)
+	statusCode, code, _ = classifyRestoreContentError("/Users/me/doc.txt", storage.ErrTransient)
 	if statusCode != http.StatusServiceUnavailable || code != "restore_storage_transient" {
 		t.Fatalf("unexpected transient classification: status=%d code=%s", statusCode, code)
 	}
 
-	statusCode, code, _ = classifyRestoreReadObjectError("/Users/me/doc.txt", errors.New("boom"))
+	statusCode, code, _ = classifyRestoreContentError("/Users/me/doc.txt", errors.New("boom"))
 	if statusCode != http.StatusBadRequest || code != "read_object_failed" {
 		t.Fatalf("unexpected fallback classification: status=%d code=%s", statusCode, code)
 	}
+
+	statusCode, code, _ = classifyRestoreContentError("/Users/me/doc.txt", fmt.Errorf("%w chunks/sha256/x.enc: boom", backup.ErrObjectDecrypt))
+	if statusCode != http.StatusBadRequest || code != "decrypt_failed" {
+		t.Fatalf("unexpected decrypt classification: status=%d code=%s", statusCode, code)
+	}
+
+	statusCode, code, _ = classifyRestoreContentError("/Users/me/doc.txt", fmt.Errorf("%w for /Users/me/doc.txt", backup.ErrChecksumMismatch))
+	if statusCode != http.StatusBadRequest || code != "integrity_check_failed" {
+		t.Fatalf("unexpected checksum classification: status=%d code=%s", statusCode, code)
+	}
 }
 
 func TestDaemonErrorContractRestoreDryRunDecodeFailure(t *testing.T) {
@@ -836,6 +1013,67 @@ func TestDaemonErrorContractRestoreDryRunInvalidRestoreTarget(t *testing.T) {
 	}
 }
 
+func TestDaemonErrorContractRestoreDryRunRejectsStreamWithoutToDir(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+
+	manifestPath := testManifestPath(t)
+	m := &backup.Manifest{
+		CreatedAt: time.Now().UTC(),
+		Entries: []backup.ManifestEntry{
+			{Path: "db/prod.sql", Mode: 0o600, SourceKind: "stream"},
+		},
+	}
+	if err := backup.SaveManifest(manifestPath, m); err != nil {
+		t.Fatalf("save manifest: %v", err)
+	}
+
+	body := bytes.NewBufferString(`{"path":"db/prod.sql"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/restore/dry-run", body)
+	rr := httptest.NewRecorder()
+	d := New(config.DefaultConfig())
+	d.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status code: got %d want %d", rr.Code, http.StatusBadRequest)
+	}
+	errResp := decodeErrorResponse(t, rr)
+	if errResp.Code != "invalid_restore_target" || !strings.Contains(errResp.Message, "to_dir") {
+		t.Fatalf("unexpected error: %+v", errResp)
+	}
+}
+
+func TestDaemonErrorContractRestoreDryRunRejectsEscapingSymlink(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", homeDir)
+
+	manifestPath := testManifestPath(t)
+	m := &backup.Manifest{
+		CreatedAt: time.Now().UTC(),
+		Entries: []backup.ManifestEntry{
+			{Path: "/Users/me", Mode: fs.ModeDir | 0o755},
+			{Path: "/Users/me/escape", Mode: fs.ModeSymlink | 0o777, LinkTarget: "../../../../etc"},
+		},
+	}
+	if err := backup.SaveManifest(manifestPath, m); err != nil {
+		t.Fatalf("save manifest: %v", err)
+	}
+
+	body := bytes.NewBufferString(`{"path":"/Users/me","to_dir":"/tmp/out"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/restore/dry-run", body)
+	rr := httptest.NewRecorder()
+	d := New(config.DefaultConfig())
+	d.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status code: got %d want %d", rr.Code, http.StatusBadRequest)
+	}
+	errResp := decodeErrorResponse(t, rr)
+	if errResp.Code != "invalid_restore_target" {
+		t.Fatalf("unexpected error code: got %q", errResp.Code)
+	}
+}
+
 func clearDaemonRestoreCache(t *testing.T) {
 	t.Helper()
 
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"baxter/internal/backup"
 	"baxter/internal/config"
 )
 
@@ -332,12 +333,12 @@ func TestDaemonEndToEndReloadScheduledTriggerAndStatus(t *testing.T) {
 	}
 
 	backupDone := make(chan struct{}, 1)
-	d.backupRunner = func(ctx context.Context, cfg *config.Config) error {
+	d.backupRunner = func(ctx context.Context, cfg *config.Config) (backup.RunResult, error) {
 		nowMu.Lock()
 		currentNow = time.Date(2026, time.February, 8, 9, 30, 0, 0, time.UTC)
 		nowMu.Unlock()
 		backupDone <- struct{}{}
-		return nil
+		return backup.RunResult{}, nil
 	}
 
 	d.SetConfigPath("/tmp/config.toml")
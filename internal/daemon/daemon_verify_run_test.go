@@ -60,7 +60,7 @@ func TestRunVerifyEndpointSuccessUpdatesStatus(t *testing.T) {
 	if err != nil {
 		t.Fatalf("object store dir: %v", err)
 	}
-	store, err := storage.NewFromConfig(cfg.S3, objectsDir)
+	store, err := storage.NewFromConfig(cfg, objectsDir)
 	if err != nil {
 		t.Fatalf("create object store: %v", err)
 	}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -12,8 +14,11 @@ import (
 	"time"
 
 	"baxter/internal/backup"
-	"baxter/internal/crypto"
+	"baxter/internal/config"
+	"baxter/internal/hooks"
+	"baxter/internal/repolock"
 	"baxter/internal/state"
+	"baxter/internal/storage"
 )
 
 const maxJSONRequestBodyBytes = 1 << 20
@@ -22,12 +27,22 @@ func (d *Daemon) newHandler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v1/status", d.requireIPCAuth(d.handleStatus))
 	mux.HandleFunc("/v1/backup/run", d.requireIPCWriteAuth(d.handleRunBackup))
+	mux.HandleFunc("/v1/backup/cancel", d.requireIPCWriteAuth(d.handleCancelBackup))
+	mux.HandleFunc("/v1/backup/pause", d.requireIPCWriteAuth(d.handlePauseBackup))
+	mux.HandleFunc("/v1/backup/resume", d.requireIPCWriteAuth(d.handleResumeBackup))
 	mux.HandleFunc("/v1/verify/run", d.requireIPCWriteAuth(d.handleRunVerify))
+	mux.HandleFunc("/v1/verify/cancel", d.requireIPCWriteAuth(d.handleCancelVerify))
 	mux.HandleFunc("/v1/config/reload", d.requireIPCWriteAuth(d.handleReloadConfig))
+	mux.HandleFunc("/v1/bandwidth/set", d.requireIPCWriteAuth(d.handleSetBandwidth))
+	mux.HandleFunc("/v1/bandwidth/clear", d.requireIPCWriteAuth(d.handleClearBandwidth))
+	mux.HandleFunc("/v1/history", d.requireIPCAuth(d.handleRunHistory))
 	mux.HandleFunc("/v1/snapshots", d.requireIPCAuth(d.handleSnapshots))
+	mux.HandleFunc("/v1/snapshots/retention", d.requireIPCAuth(d.handleSnapshotRetention))
+	mux.HandleFunc("/v1/snapshots/diff", d.requireIPCAuth(d.handleSnapshotDiff))
 	mux.HandleFunc("/v1/restore/list", d.requireIPCAuth(d.handleRestoreList))
 	mux.HandleFunc("/v1/restore/dry-run", d.requireIPCAuth(d.handleRestoreDryRun))
 	mux.HandleFunc("/v1/restore/run", d.requireIPCWriteAuth(d.handleRestoreRun))
+	mux.HandleFunc("/v1/restore/export", d.requireIPCAuth(d.handleRestoreExport))
 	return mux
 }
 
@@ -57,6 +72,77 @@ func (d *Daemon) handleRunBackup(w http.ResponseWriter, r *http.Request) {
 	d.writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
 }
 
+func (d *Daemon) handleCancelBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	if err := d.requestBackupCancel(); err != nil {
+		d.writeError(w, http.StatusConflict, "backup_not_running", err.Error())
+		return
+	}
+
+	d.writeJSON(w, http.StatusAccepted, map[string]string{"status": "cancelling"})
+}
+
+func (d *Daemon) handlePauseBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	// The body is optional: an empty one pauses until resumed.
+	var req backupPauseRequest
+	if err := decodeJSONRequest(w, r, &req); err != nil && !errors.Is(err, io.EOF) {
+		d.writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("decode request: %v", err))
+		return
+	}
+	var resumeAfter time.Duration
+	if value := strings.TrimSpace(req.ResumeAfter); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			d.writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("resume_after must be a positive duration such as 30m or 2h, got %q", req.ResumeAfter))
+			return
+		}
+		resumeAfter = parsed
+	}
+
+	resumeAt, err := d.pauseBackup(resumeAfter)
+	if err != nil {
+		if errors.Is(err, errBackupAlreadyPaused) {
+			d.writeError(w, http.StatusConflict, "backup_paused", err.Error())
+			return
+		}
+		d.writeError(w, http.StatusConflict, "backup_not_running", err.Error())
+		return
+	}
+
+	resp := backupPauseResponse{Status: "paused"}
+	if !resumeAt.IsZero() {
+		resp.ResumeAt = resumeAt.Format(time.RFC3339)
+	}
+	d.writeJSON(w, http.StatusAccepted, resp)
+}
+
+func (d *Daemon) handleResumeBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	if err := d.resumeBackup(); err != nil {
+		if errors.Is(err, errBackupNotPaused) {
+			d.writeError(w, http.StatusConflict, "backup_not_paused", err.Error())
+			return
+		}
+		d.writeError(w, http.StatusConflict, "backup_not_running", err.Error())
+		return
+	}
+
+	d.writeJSON(w, http.StatusAccepted, backupPauseResponse{Status: "running"})
+}
+
 func (d *Daemon) handleRunVerify(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
@@ -75,6 +161,20 @@ func (d *Daemon) handleRunVerify(w http.ResponseWriter, r *http.Request) {
 	d.writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
 }
 
+func (d *Daemon) handleCancelVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	if err := d.requestVerifyCancel(); err != nil {
+		d.writeError(w, http.StatusConflict, "verify_not_running", err.Error())
+		return
+	}
+
+	d.writeJSON(w, http.StatusAccepted, map[string]string{"status": "cancelling"})
+}
+
 func (d *Daemon) handleReloadConfig(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
@@ -93,6 +193,53 @@ func (d *Daemon) handleReloadConfig(w http.ResponseWriter, r *http.Request) {
 	d.writeJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
 }
 
+// handleSetBandwidth overrides the scheduled bandwidth limits; transfers
+// already running pick up the new limits on their next read.
+func (d *Daemon) handleSetBandwidth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	var req bandwidthSetRequest
+	if err := decodeJSONRequest(w, r, &req); err != nil {
+		d.writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("decode request: %v", err))
+		return
+	}
+	upload, err := config.ParseBandwidthLimit(req.UploadLimit)
+	if err != nil {
+		d.writeError(w, http.StatusBadRequest, "invalid_bandwidth", fmt.Sprintf("upload_limit: %v", err))
+		return
+	}
+	download, err := config.ParseBandwidthLimit(req.DownloadLimit)
+	if err != nil {
+		d.writeError(w, http.StatusBadRequest, "invalid_bandwidth", fmt.Sprintf("download_limit: %v", err))
+		return
+	}
+	var until time.Time
+	if duration := strings.TrimSpace(req.Duration); duration != "" {
+		parsed, err := time.ParseDuration(duration)
+		if err != nil || parsed <= 0 {
+			d.writeError(w, http.StatusBadRequest, "invalid_bandwidth", fmt.Sprintf("duration must be a positive duration such as 30m or 2h, got %q", req.Duration))
+			return
+		}
+		until = d.clockNow().Add(parsed)
+	}
+
+	d.bandwidth.SetOverride(config.BandwidthLimits{Upload: upload, Download: download}, until)
+	d.writeJSON(w, http.StatusOK, bandwidthStatusFrom(d.bandwidth.Status()))
+}
+
+func (d *Daemon) handleClearBandwidth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	d.bandwidth.ClearOverride()
+	d.writeJSON(w, http.StatusOK, bandwidthStatusFrom(d.bandwidth.Status()))
+}
+
 func (d *Daemon) handleRestoreList(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
@@ -118,6 +265,23 @@ func (d *Daemon) handleRestoreList(w http.ResponseWriter, r *http.Request) {
 	d.writeJSON(w, http.StatusOK, restoreListResponse{Paths: paths})
 }
 
+func (d *Daemon) handleRunHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	limit := 0
+	if rawLimit := strings.TrimSpace(r.URL.Query().Get("limit")); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 0 {
+			d.writeError(w, http.StatusBadRequest, "invalid_request", "limit must be >= 0")
+			return
+		}
+		limit = parsed
+	}
+	d.writeJSON(w, http.StatusOK, runHistoryResponse{Runs: d.runHistory(limit)})
+}
+
 func (d *Daemon) handleSnapshots(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
@@ -154,14 +318,88 @@ func (d *Daemon) handleSnapshots(w http.ResponseWriter, r *http.Request) {
 	}
 	for _, snapshot := range snapshots {
 		resp.Snapshots = append(resp.Snapshots, snapshotSummary{
-			ID:        snapshot.ID,
-			CreatedAt: snapshot.CreatedAt.Format(time.RFC3339),
-			Entries:   snapshot.Entries,
+			ID:         snapshot.ID,
+			CreatedAt:  snapshot.CreatedAt.Format(time.RFC3339),
+			Entries:    snapshot.Entries,
+			Incomplete: snapshot.Incomplete,
+			Tags:       snapshot.Tags,
 		})
 	}
 	d.writeJSON(w, http.StatusOK, resp)
 }
 
+// handleSnapshotRetention previews the configured retention policy: which
+// snapshots the next prune keeps or removes, and the rules behind each.
+func (d *Daemon) handleSnapshotRetention(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	snapshotDir, err := state.ManifestSnapshotsDir()
+	if err != nil {
+		d.writeError(w, http.StatusInternalServerError, "state_path_failed", err.Error())
+		return
+	}
+	cfg := d.currentConfig()
+	decisions, err := backup.PlanSnapshotPruneManifestsWithPolicy(snapshotDir, backup.SnapshotPrunePolicyFromConfig(cfg.Retention))
+	if err != nil {
+		d.writeError(w, http.StatusBadRequest, "snapshot_list_failed", fmt.Sprintf("list snapshots: %v", err))
+		return
+	}
+
+	resp := snapshotRetentionResponse{
+		Snapshots: make([]snapshotRetentionSummary, 0, len(decisions)),
+	}
+	for _, decision := range decisions {
+		if !decision.Keep {
+			resp.Prune++
+		}
+		resp.Snapshots = append(resp.Snapshots, snapshotRetentionSummary{
+			ID:        decision.Snapshot.ID,
+			CreatedAt: decision.Snapshot.CreatedAt.Format(time.RFC3339),
+			Keep:      decision.Keep,
+			Reasons:   decision.Reasons,
+			Tags:      decision.Snapshot.Tags,
+		})
+	}
+	d.writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSnapshotDiff compares two snapshots, optionally limited to a path
+// prefix, for the from and to snapshot selectors.
+func (d *Daemon) handleSnapshotDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	from := strings.TrimSpace(query.Get("from"))
+	to := strings.TrimSpace(query.Get("to"))
+	if from == "" || to == "" {
+		d.writeError(w, http.StatusBadRequest, "invalid_request", "from and to are required")
+		return
+	}
+
+	fromManifest, err := d.loadManifestForRestore(from)
+	if err != nil {
+		d.writeError(w, http.StatusBadRequest, "manifest_load_failed", fmt.Sprintf("load manifest %s: %v", from, err))
+		return
+	}
+	toManifest, err := d.loadManifestForRestore(to)
+	if err != nil {
+		d.writeError(w, http.StatusBadRequest, "manifest_load_failed", fmt.Sprintf("load manifest %s: %v", to, err))
+		return
+	}
+
+	d.writeJSON(w, http.StatusOK, snapshotDiffResponse{
+		From:         from,
+		To:           to,
+		SnapshotDiff: backup.DiffManifests(fromManifest, toManifest, strings.TrimSpace(query.Get("prefix"))),
+	})
+}
+
 func (d *Daemon) handleRestoreDryRun(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
@@ -203,6 +441,61 @@ func (d *Daemon) handleRestoreRun(w http.ResponseWriter, r *http.Request) {
 		d.writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("decode request: %v", err))
 		return
 	}
+
+	if req.Concurrency < 0 {
+		d.writeError(w, http.StatusBadRequest, "invalid_request", "concurrency must be >= 0")
+		return
+	}
+
+	journalDir, err := state.RestoreJournalsDir()
+	if err != nil {
+		d.writeError(w, http.StatusInternalServerError, "state_path_failed", err.Error())
+		return
+	}
+	metadataOpts := backup.RestoreMetadataOptions{
+		SkipModTime: req.NoModTime,
+		SkipMode:    req.NoPerms,
+		SkipOwner:   req.NoOwner,
+		SkipXattrs:  req.NoXattrs,
+	}
+	var journal *backup.RestoreJournal
+	if resumeID := strings.TrimSpace(req.Resume); resumeID != "" {
+		if req.VerifyOnly {
+			d.writeError(w, http.StatusBadRequest, "invalid_request", "resume cannot be combined with verify_only")
+			return
+		}
+		journal, err = backup.OpenRestoreJournal(journalDir, resumeID)
+		if err != nil {
+			d.setLastRestoreError(err.Error())
+			if errors.Is(err, backup.ErrRestoreJournalNotFound) {
+				d.writeError(w, http.StatusNotFound, "restore_journal_not_found", err.Error())
+				return
+			}
+			d.writeError(w, http.StatusBadRequest, "restore_journal_invalid", err.Error())
+			return
+		}
+		req.Path = journal.SourcePath
+		req.ToDir = journal.ToDir
+		req.Overwrite = journal.Overwrite
+		req.Snapshot = journal.ManifestSelector()
+		metadataOpts = journal.Metadata
+	}
+	// record is set once the restore gets past its checks and is kept in
+	// the run history.
+	var record *runRecord
+	// failRestore keeps any journal for a later resume and reports it.
+	failRestore := func(statusCode int, code string, message string) {
+		if journal != nil {
+			_ = journal.Close()
+			message = fmt.Sprintf("%s (resume journal %s)", message, journal.ID)
+		}
+		if record != nil {
+			d.recordRun(*record, errors.New(message))
+		}
+		d.setLastRestoreError(message)
+		d.writeError(w, statusCode, code, message)
+	}
+
 	requestedPath := strings.TrimSpace(req.Path)
 	if requestedPath == "" {
 		d.writeError(w, http.StatusBadRequest, "invalid_request", "path is required")
@@ -211,78 +504,125 @@ func (d *Daemon) handleRestoreRun(w http.ResponseWriter, r *http.Request) {
 
 	plan, err := d.resolveRestoreTarget(requestedPath, req.ToDir, req.Snapshot)
 	if err != nil {
+		if journal != nil {
+			_ = journal.Close()
+		}
 		d.setLastRestoreError(err.Error())
 		d.writeRestoreError(w, err)
 		return
 	}
+	if journal != nil && !plan.ManifestCreatedAt.Equal(journal.ManifestCreatedAt) {
+		failRestore(http.StatusConflict, "restore_journal_stale", fmt.Sprintf("snapshot for restore journal %s is no longer available", journal.ID))
+		return
+	}
 
 	cfg := d.currentConfig()
-	store, err := d.objectStore(cfg)
+	store, err := d.destinationStore(cfg, strings.TrimSpace(req.Destination))
 	if err != nil {
-		d.setLastRestoreError(err.Error())
-		d.writeError(w, http.StatusInternalServerError, "object_store_failed", err.Error())
+		failRestore(http.StatusInternalServerError, "object_store_failed", err.Error())
 		return
 	}
+	defer storage.Close(store)
 
 	keys, err := accessEncryptionKeys(cfg, store)
 	if err != nil {
-		d.setLastRestoreError(err.Error())
-		d.writeError(w, http.StatusBadRequest, "restore_key_unavailable", err.Error())
+		failRestore(http.StatusBadRequest, "restore_key_unavailable", err.Error())
+		return
+	}
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "restore")
+	if err != nil {
+		failRestore(http.StatusConflict, "repository_locked", err.Error())
 		return
 	}
+	defer lock.Release()
 
 	if !req.Overwrite && !req.VerifyOnly {
 		for _, target := range plan.Targets {
-			if _, err := os.Stat(target.TargetPath); err == nil {
-				msg := fmt.Sprintf("target exists: %s (use overwrite=true to replace)", target.TargetPath)
-				d.setLastRestoreError(msg)
-				d.writeError(w, http.StatusBadRequest, "target_exists", msg)
+			if target.Entry.IsDir() || (journal != nil && journal.Completed(target.Entry.Path)) {
+				continue
+			}
+			if _, err := os.Lstat(target.TargetPath); err == nil {
+				failRestore(http.StatusBadRequest, "target_exists", fmt.Sprintf("target exists: %s (use overwrite=true to replace)", target.TargetPath))
 				return
 			} else if !os.IsNotExist(err) {
-				d.setLastRestoreError(err.Error())
-				d.writeError(w, http.StatusBadRequest, "write_failed", err.Error())
+				failRestore(http.StatusBadRequest, "write_failed", err.Error())
 				return
 			}
 		}
 	}
 
-	for _, target := range plan.Targets {
-		payload, err := store.GetObject(backup.ResolveObjectKey(target.Entry))
+	record = &runRecord{Operation: "restore", StartedAt: d.now().UTC(), Path: plan.SourcePath}
+	if !req.VerifyOnly {
+		hookResults, err := d.hookRunner(cfg).Run(r.Context(), hooks.PreRestore, hooks.RestoreEnv(plan.SourcePath, plan.TargetPath, req.Snapshot))
+		record.Hooks = hookResults
 		if err != nil {
-			d.setLastRestoreError(err.Error())
-			statusCode, code, message := classifyRestoreReadObjectError(target.Entry.Path, err)
-			d.writeError(w, statusCode, code, message)
+			failRestore(http.StatusConflict, "restore_hook_failed", err.Error())
 			return
 		}
+	}
 
-		plain, err := crypto.DecryptBytesWithAnyKey(keys.candidates, payload)
+	if !req.VerifyOnly && journal == nil {
+		journal, err = backup.CreateRestoreJournal(journalDir, backup.RestoreJournal{
+			SourcePath:        plan.SourcePath,
+			ToDir:             req.ToDir,
+			Overwrite:         req.Overwrite,
+			Metadata:          metadataOpts,
+			ManifestCreatedAt: plan.ManifestCreatedAt,
+		})
 		if err != nil {
-			d.setLastRestoreError(err.Error())
-			d.writeError(w, http.StatusBadRequest, "decrypt_failed", fmt.Sprintf("decrypt object: %v", err))
+			failRestore(http.StatusInternalServerError, "write_failed", fmt.Sprintf("create restore journal: %v", err))
 			return
 		}
-		if err := backup.VerifyEntryContent(target.Entry, plain); err != nil {
-			d.setLastRestoreError(err.Error())
-			d.writeError(w, http.StatusBadRequest, "integrity_check_failed", err.Error())
+	}
+
+	jobs := make([]backup.RestoreJob, 0, len(plan.Targets))
+	for _, target := range plan.Targets {
+		jobs = append(jobs, backup.RestoreJob{Entry: target.Entry, TargetPath: target.TargetPath})
+	}
+	result, err := backup.RestoreJobs(jobs, backup.RestoreJobsOptions{
+		Store:           store,
+		Keys:            keys.candidates,
+		Metadata:        metadataOpts,
+		VerifyOnly:      req.VerifyOnly,
+		Concurrency:     req.Concurrency,
+		ContinueOnError: req.ContinueOnError,
+		Journal:         journal,
+		Progress: func(update backup.RestoreProgress) {
+			d.setRestoreProgress(restoreProgressSummary{
+				Restored:    update.Done,
+				Total:       update.Total,
+				CurrentPath: update.Path,
+			})
+		},
+	})
+	d.setRestoreProgress(restoreProgressSummary{})
+	if err != nil {
+		var entryErr *backup.RestoreEntryError
+		if !errors.As(err, &entryErr) {
+			failRestore(http.StatusInternalServerError, "write_failed", err.Error())
 			return
 		}
-
-		if req.VerifyOnly {
-			continue
+		statusCode, code, message := http.StatusBadRequest, "write_failed", err.Error()
+		if !errors.Is(err, backup.ErrRestoreWrite) {
+			statusCode, code, message = classifyRestoreContentError(entryErr.Path, entryErr.Err)
 		}
-
-		if err := os.MkdirAll(filepath.Dir(target.TargetPath), 0o755); err != nil {
-			d.setLastRestoreError(err.Error())
-			d.writeError(w, http.StatusBadRequest, "write_failed", err.Error())
-			return
+		if len(result.Failures) > 1 {
+			message = fmt.Sprintf("restore incomplete: failures=%d files=%d; first failure: %s", len(result.Failures), len(jobs), message)
 		}
-		if err := os.WriteFile(target.TargetPath, plain, target.Entry.Mode.Perm()); err != nil {
+		failRestore(statusCode, code, message)
+		return
+	}
+
+	if !req.VerifyOnly {
+		if err := journal.Remove(); err != nil {
+			d.recordRun(*record, err)
 			d.setLastRestoreError(err.Error())
-			d.writeError(w, http.StatusBadRequest, "write_failed", err.Error())
+			d.writeError(w, http.StatusInternalServerError, "write_failed", fmt.Sprintf("remove restore journal: %v", err))
 			return
 		}
 	}
 
+	d.recordRun(*record, nil)
 	d.setRestoreSuccess(plan.SourcePath)
 	d.writeJSON(w, http.StatusOK, restoreRunResponse{
 		SourcePath: plan.SourcePath,
@@ -292,6 +632,68 @@ func (d *Daemon) handleRestoreRun(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleRestoreExport streams a snapshot, or the part of it under prefix, as
+// an archive. Errors found before the first byte is sent are reported as
+// JSON; a failure mid-stream aborts the connection so the client never sees
+// a truncated archive as complete.
+func (d *Daemon) handleRestoreExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		d.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	format := backup.ArchiveTar
+	if rawFormat := strings.TrimSpace(query.Get("format")); rawFormat != "" {
+		parsed, err := backup.ParseArchiveFormat(rawFormat)
+		if err != nil {
+			d.writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		format = parsed
+	}
+	prefix := strings.TrimSpace(query.Get("prefix"))
+	if prefix == "" {
+		prefix = string(filepath.Separator)
+	}
+
+	plan, err := d.resolveRestoreTarget(prefix, "", strings.TrimSpace(query.Get("snapshot")))
+	if err != nil {
+		d.writeRestoreError(w, err)
+		return
+	}
+	entries := make([]backup.ManifestEntry, 0, len(plan.Targets))
+	for _, target := range plan.Targets {
+		entries = append(entries, target.Entry)
+	}
+
+	cfg := d.currentConfig()
+	store, err := d.destinationStore(cfg, strings.TrimSpace(query.Get("destination")))
+	if err != nil {
+		d.writeError(w, http.StatusInternalServerError, "object_store_failed", err.Error())
+		return
+	}
+	defer storage.Close(store)
+	keys, err := accessEncryptionKeys(cfg, store)
+	if err != nil {
+		d.writeError(w, http.StatusBadRequest, "restore_key_unavailable", err.Error())
+		return
+	}
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "export")
+	if err != nil {
+		d.writeError(w, http.StatusConflict, "repository_locked", err.Error())
+		return
+	}
+	defer lock.Release()
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "baxter-export." + string(format)}))
+	w.WriteHeader(http.StatusOK)
+	if _, err := backup.ExportArchive(w, entries, backup.ExportArchiveOptions{Format: format, Store: store, Keys: keys.candidates}); err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
+
 func decodeJSONRequest(w http.ResponseWriter, r *http.Request, dst any) error {
 	r.Body = http.MaxBytesReader(w, r.Body, maxJSONRequestBodyBytes)
 	return json.NewDecoder(r.Body).Decode(dst)
@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"baxter/internal/backup"
+	"baxter/internal/config"
+	"baxter/internal/hooks"
+	"baxter/internal/state"
+)
+
+// maxRunHistory bounds the runs kept in the run history.
+const maxRunHistory = 50
+
+// runRecord is one backup, restore or verify run in the run history, with
+// the output of the hooks that ran around it.
+type runRecord struct {
+	Operation  string         `json:"operation"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Result     string         `json:"result"`
+	Error      string         `json:"error,omitempty"`
+	SnapshotID string         `json:"snapshot_id,omitempty"`
+	Path       string         `json:"path,omitempty"`
+	Hooks      []hooks.Result `json:"hooks,omitempty"`
+}
+
+type runHistoryResponse struct {
+	Runs []runRecord `json:"runs"`
+}
+
+func (d *Daemon) hookRunner(cfg *config.Config) hooks.Runner {
+	return hooks.Runner{
+		Config: cfg.Hooks,
+		Log: func(result hooks.Result) {
+			if result.Failed() {
+				fmt.Printf("hook %s failed: command=%q error=%s\n", result.Stage, result.Command, result.Error)
+				return
+			}
+			fmt.Printf("hook %s complete: command=%q duration_ms=%d\n", result.Stage, result.Command, result.DurationMS)
+		},
+	}
+}
+
+// runBackup runs a backup between its hooks and records it in the run
+// history.
+func (d *Daemon) runBackup(ctx context.Context, cfg *config.Config) error {
+	record := runRecord{Operation: "backup", StartedAt: d.now().UTC()}
+	result, hookResults, err := d.hookRunner(cfg).Backup(ctx, func(ctx context.Context) (backup.RunResult, error) {
+		return d.backupRunner(ctx, cfg)
+	})
+	record.SnapshotID = result.SnapshotID
+	record.Hooks = hookResults
+	d.recordRun(record, err)
+	return err
+}
+
+// recordRun finishes record with the outcome err and adds it to the run
+// history.
+func (d *Daemon) recordRun(record runRecord, err error) {
+	record.FinishedAt = d.now().UTC()
+	switch {
+	case err == nil:
+		record.Result = "success"
+	case errors.Is(err, context.Canceled):
+		record.Result = "cancelled"
+	default:
+		record.Result = "failed"
+		record.Error = err.Error()
+	}
+
+	d.historyMu.Lock()
+	defer d.historyMu.Unlock()
+	d.mu.Lock()
+	d.history = append(d.history, record)
+	if over := len(d.history) - maxRunHistory; over > 0 {
+		d.history = append([]runRecord(nil), d.history[over:]...)
+	}
+	history := append([]runRecord(nil), d.history...)
+	d.mu.Unlock()
+
+	if err := writeRunHistory(history); err != nil {
+		fmt.Fprintf(os.Stderr, "persist run history: %v\n", err)
+	}
+}
+
+// runHistory returns up to limit runs, newest first; limit 0 returns all.
+func (d *Daemon) runHistory(limit int) []runRecord {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	runs := make([]runRecord, 0, len(d.history))
+	for i := len(d.history) - 1; i >= 0; i-- {
+		if limit > 0 && len(runs) == limit {
+			break
+		}
+		runs = append(runs, d.history[i])
+	}
+	return runs
+}
+
+func (d *Daemon) loadRunHistory() {
+	history, err := readRunHistory()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "load run history: %v\n", err)
+		}
+		return
+	}
+	d.mu.Lock()
+	d.history = history
+	d.mu.Unlock()
+}
+
+func readRunHistory() ([]runRecord, error) {
+	path, err := state.RunHistoryPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var history []runRecord
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func writeRunHistory(history []runRecord) error {
+	path, err := state.RunHistoryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
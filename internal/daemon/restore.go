@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"baxter/internal/backup"
 	"baxter/internal/recoverycache"
@@ -25,9 +26,10 @@ type restoreTarget struct {
 }
 
 type restoreTargetPlan struct {
-	SourcePath string
-	TargetPath string
-	Targets    []restoreTarget
+	SourcePath        string
+	TargetPath        string
+	Targets           []restoreTarget
+	ManifestCreatedAt time.Time
 }
 
 func (d *Daemon) resolveRestoreTarget(requestedPath string, toDir string, snapshotSelector string) (restoreTargetPlan, error) {
@@ -55,6 +57,12 @@ func (d *Daemon) resolveRestoreTarget(requestedPath string, toDir string, snapsh
 		if err != nil {
 			return restoreTargetPlan{}, fmt.Errorf("%w: %v", errRestoreTargetInvalid, err)
 		}
+		if entry.IsSymlink() {
+			entry.LinkTarget, err = backup.RestoreLinkTarget(entry, entryTargetPath, toDir)
+			if err != nil {
+				return restoreTargetPlan{}, fmt.Errorf("%w: %v", errRestoreTargetInvalid, err)
+			}
+		}
 		targets = append(targets, restoreTarget{
 			Entry:      entry,
 			TargetPath: entryTargetPath,
@@ -62,9 +70,10 @@ func (d *Daemon) resolveRestoreTarget(requestedPath string, toDir string, snapsh
 	}
 
 	return restoreTargetPlan{
-		SourcePath: selection.SourcePath,
-		TargetPath: targetPath,
-		Targets:    targets,
+		SourcePath:        selection.SourcePath,
+		TargetPath:        targetPath,
+		Targets:           targets,
+		ManifestCreatedAt: m.CreatedAt,
 	}, nil
 }
 
@@ -120,6 +129,10 @@ func immediateRestoreChild(path string, prefix string) (string, bool, bool) {
 
 func resolvedRestorePath(sourcePath string, toDir string) (string, error) {
 	if strings.TrimSpace(toDir) == "" {
+		// Only stream entries, named like db/prod.sql, have relative paths.
+		if !filepath.IsAbs(sourcePath) {
+			return "", fmt.Errorf("%s is a stream with no location on disk; restore it with to_dir", sourcePath)
+		}
 		return sourcePath, nil
 	}
 
@@ -160,6 +173,7 @@ func (d *Daemon) loadManifestForRestore(snapshotSelector string) (*backup.Manife
 	if err != nil {
 		return nil, err
 	}
+	defer storage.Close(store)
 	manifest, err := recoverycache.LoadManifest(cfg, store, snapshotSelector, func() (string, error) {
 		return encryptionPassphrase(cfg)
 	})
@@ -169,13 +183,17 @@ func (d *Daemon) loadManifestForRestore(snapshotSelector string) (*backup.Manife
 	return manifest, nil
 }
 
-func classifyRestoreReadObjectError(entryPath string, err error) (int, string, string) {
+func classifyRestoreContentError(entryPath string, err error) (int, string, string) {
 	switch {
 	case storage.IsNotFound(err):
 		return http.StatusNotFound, "restore_object_missing", fmt.Sprintf("restore object missing for path %s", entryPath)
 	case storage.IsTransient(err):
 		return http.StatusServiceUnavailable, "restore_storage_transient", fmt.Sprintf("transient storage read failure for %s: %v", entryPath, err)
+	case errors.Is(err, backup.ErrObjectDecrypt):
+		return http.StatusBadRequest, "decrypt_failed", err.Error()
+	case errors.Is(err, backup.ErrChecksumMismatch):
+		return http.StatusBadRequest, "integrity_check_failed", err.Error()
 	default:
-		return http.StatusBadRequest, "read_object_failed", fmt.Sprintf("read object: %v", err)
+		return http.StatusBadRequest, "read_object_failed", err.Error()
 	}
 }
@@ -23,15 +23,20 @@ type restoreManifestSourceState struct {
 
 type restoreManifestIndex struct {
 	paths []string
+	dirs  map[string]bool
 }
 
 func newRestoreManifestIndex(entries []backup.ManifestEntry) *restoreManifestIndex {
 	paths := make([]string, 0, len(entries))
+	dirs := make(map[string]bool)
 	sorted := true
 	previous := ""
 	for i, entry := range entries {
 		path := filepath.Clean(entry.Path)
 		paths = append(paths, path)
+		if entry.IsDir() {
+			dirs[path] = true
+		}
 		if i > 0 && previous > path {
 			sorted = false
 		}
@@ -40,7 +45,7 @@ func newRestoreManifestIndex(entries []backup.ManifestEntry) *restoreManifestInd
 	if !sorted {
 		sort.Strings(paths)
 	}
-	return &restoreManifestIndex{paths: paths}
+	return &restoreManifestIndex{paths: paths, dirs: dirs}
 }
 
 func (idx *restoreManifestIndex) filterPaths(prefix string, contains string) []string {
@@ -75,7 +80,7 @@ func (idx *restoreManifestIndex) filterChildrenPaths(prefix string, contains str
 		if !ok {
 			continue
 		}
-		children[childPath] = children[childPath] || isDirectory
+		children[childPath] = children[childPath] || isDirectory || idx.dirs[childPath]
 	}
 
 	paths := make([]string, 0, len(children))
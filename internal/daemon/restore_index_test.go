@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"encoding/json"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -32,6 +33,25 @@ func TestRestoreManifestIndexChildrenFiltersMatchingBranches(t *testing.T) {
 	}
 }
 
+func TestRestoreManifestIndexChildrenMarksEmptyDirectories(t *testing.T) {
+	index := newRestoreManifestIndex([]backup.ManifestEntry{
+		{Path: "/Users/me", Mode: fs.ModeDir | 0o755},
+		{Path: "/Users/me/Empty", Mode: fs.ModeDir | 0o755},
+		{Path: "/Users/me/link", Mode: fs.ModeSymlink | 0o777, LinkTarget: "Empty"},
+		{Path: "/Users/me/notes.txt"},
+	})
+
+	got := index.filterChildrenPaths("/Users/me", "")
+	want := []string{
+		"/Users/me/Empty/",
+		"/Users/me/link",
+		"/Users/me/notes.txt",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected children: got %#v want %#v", got, want)
+	}
+}
+
 func TestRestoreManifestIndexPathsWithPrefixSkipsPrefixCollisions(t *testing.T) {
 	index := newRestoreManifestIndex([]backup.ManifestEntry{
 		{Path: "/Users/me/actions-runner/config.sh"},
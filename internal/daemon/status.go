@@ -7,11 +7,12 @@ import (
 
 	"baxter/internal/backup"
 	"baxter/internal/config"
+	"baxter/internal/storage"
 )
 
 func (d *Daemon) setFailed(err error) {
 	d.mu.Lock()
-	d.running = false
+	d.finishBackupRunLocked()
 	d.status.State = "failed"
 	d.status.LastError = err.Error()
 	d.status.BackupProgress = backupProgressSummary{}
@@ -21,7 +22,7 @@ func (d *Daemon) setFailed(err error) {
 
 func (d *Daemon) setIdleSuccess() {
 	d.mu.Lock()
-	d.running = false
+	d.finishBackupRunLocked()
 	d.status.State = "idle"
 	d.status.LastBackupAt = d.now().UTC()
 	d.status.LastError = ""
@@ -30,12 +31,72 @@ func (d *Daemon) setIdleSuccess() {
 	d.persistStatus()
 }
 
+func (d *Daemon) setBackupCancelled() {
+	d.mu.Lock()
+	d.finishBackupRunLocked()
+	d.status.State = "idle"
+	d.status.LastError = "backup cancelled"
+	d.status.BackupProgress = backupProgressSummary{}
+	d.mu.Unlock()
+	d.persistStatus()
+}
+
+// finishBackupRunLocked clears the state of a backup run that ended, paused
+// or not. d.mu must be held.
+func (d *Daemon) finishBackupRunLocked() {
+	d.running = false
+	d.cancelBackup = nil
+	d.backupPause = nil
+	d.status.PausedAt = time.Time{}
+	d.status.ResumeAt = time.Time{}
+}
+
+// setDestinationStatuses records how each destination fared in a backup
+// run. Destinations that took every write only count as successful when the
+// run committed; those marked failed keep the error that dropped them.
+func (d *Daemon) setDestinationStatuses(statuses []storage.DestinationStatus, committed bool) {
+	now := d.now().UTC()
+	d.mu.Lock()
+	previous := make(map[string]destinationState, len(d.status.Destinations))
+	for _, dest := range d.status.Destinations {
+		previous[dest.Name] = dest
+	}
+	var destinations []destinationState
+	for _, status := range statuses {
+		dest := previous[status.Name]
+		dest.Name = status.Name
+		switch {
+		case status.Failed:
+			dest.State = "failed"
+			dest.LastError = "write failed"
+			if status.Err != nil {
+				dest.LastError = status.Err.Error()
+			}
+		case committed:
+			dest.State = "ok"
+			dest.LastError = ""
+			dest.LastSuccessAt = now
+		case dest.State == "":
+			dest.State = "pending"
+		}
+		destinations = append(destinations, dest)
+	}
+	d.status.Destinations = destinations
+	d.mu.Unlock()
+}
+
 func (d *Daemon) setBackupProgress(progress backupProgressSummary) {
 	d.mu.Lock()
 	d.status.BackupProgress = progress
 	d.mu.Unlock()
 }
 
+func (d *Daemon) setRestoreProgress(progress restoreProgressSummary) {
+	d.mu.Lock()
+	d.status.RestoreProgress = progress
+	d.mu.Unlock()
+}
+
 func (d *Daemon) setNextScheduledAt(next time.Time) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -74,6 +135,7 @@ func (d *Daemon) setRestoreSuccess(restoredPath string) {
 func (d *Daemon) setVerifyResult(result backup.VerifyResult) {
 	d.mu.Lock()
 	d.verifyRunning = false
+	d.cancelVerify = nil
 	d.status.VerifyState = "idle"
 	d.status.LastVerifyAt = d.now().UTC()
 	d.status.LastVerifyError = ""
@@ -92,6 +154,7 @@ func (d *Daemon) setVerifyResult(result backup.VerifyResult) {
 func (d *Daemon) setVerifyFailed(err error, result backup.VerifyResult) {
 	d.mu.Lock()
 	d.verifyRunning = false
+	d.cancelVerify = nil
 	d.status.VerifyState = "failed"
 	d.status.LastVerifyAt = d.now().UTC()
 	d.status.LastVerifyError = err.Error()
@@ -107,6 +170,16 @@ func (d *Daemon) setVerifyFailed(err error, result backup.VerifyResult) {
 	d.persistStatus()
 }
 
+func (d *Daemon) setVerifyCancelled() {
+	d.mu.Lock()
+	d.verifyRunning = false
+	d.cancelVerify = nil
+	d.status.VerifyState = "idle"
+	d.status.LastVerifyError = "verify cancelled"
+	d.mu.Unlock()
+	d.persistStatus()
+}
+
 func verifyFailureError(result backup.VerifyResult) error {
 	return fmt.Errorf(
 		"verify failed: missing=%d read_errors=%d decrypt_errors=%d checksum_errors=%d",
@@ -154,6 +227,7 @@ func (d *Daemon) reloadConfig() (*config.Config, error) {
 	d.mu.Lock()
 	d.cfg = cfg
 	d.mu.Unlock()
+	d.bandwidth.SetSchedule(cfg.Bandwidth)
 	return cfg, nil
 }
 
@@ -169,6 +243,15 @@ func (d *Daemon) snapshot() statusResponse {
 	resp.BackupUploaded = d.status.BackupProgress.Uploaded
 	resp.BackupTotal = d.status.BackupProgress.Total
 	resp.BackupCurrentPath = d.status.BackupProgress.CurrentPath
+	if !d.status.PausedAt.IsZero() {
+		resp.BackupPausedAt = d.status.PausedAt.Format(time.RFC3339)
+	}
+	if !d.status.ResumeAt.IsZero() {
+		resp.BackupResumeAt = d.status.ResumeAt.Format(time.RFC3339)
+	}
+	resp.RestoreRestored = d.status.RestoreProgress.Restored
+	resp.RestoreTotal = d.status.RestoreProgress.Total
+	resp.RestoreCurrentPath = d.status.RestoreProgress.CurrentPath
 	if !d.status.LastBackupAt.IsZero() {
 		resp.LastBackupAt = d.status.LastBackupAt.Format(time.RFC3339)
 	}
@@ -199,5 +282,30 @@ func (d *Daemon) snapshot() statusResponse {
 	resp.LastVerifyReadErrors = d.status.LastVerifyResult.ReadErrors
 	resp.LastVerifyDecryptErrors = d.status.LastVerifyResult.DecryptErrors
 	resp.LastVerifyChecksumErrors = d.status.LastVerifyResult.ChecksumErrors
+	for _, dest := range d.status.Destinations {
+		summary := destinationStatusResponse{
+			Name:      dest.Name,
+			State:     dest.State,
+			LastError: dest.LastError,
+		}
+		if !dest.LastSuccessAt.IsZero() {
+			summary.LastSuccessAt = dest.LastSuccessAt.Format(time.RFC3339)
+		}
+		resp.Destinations = append(resp.Destinations, summary)
+	}
+	resp.Bandwidth = bandwidthStatusFrom(d.bandwidth.Status())
+	return resp
+}
+
+func bandwidthStatusFrom(status storage.BandwidthStatus) bandwidthStatusResponse {
+	resp := bandwidthStatusResponse{
+		UploadLimit:   status.Limits.Upload,
+		DownloadLimit: status.Limits.Download,
+		Window:        status.Window,
+		Override:      status.Override,
+	}
+	if !status.OverrideUntil.IsZero() {
+		resp.OverrideUntil = status.OverrideUntil.Format(time.RFC3339)
+	}
 	return resp
 }
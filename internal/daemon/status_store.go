@@ -43,14 +43,23 @@ func (d *Daemon) loadPersistedStatus() {
 		status.VerifyState = "idle"
 		changed = true
 	}
-	if status.State == "running" {
+	if status.State == "running" || status.State == "paused" {
 		status.State = "idle"
 		changed = true
 	}
+	if !status.PausedAt.IsZero() || !status.ResumeAt.IsZero() {
+		status.PausedAt = time.Time{}
+		status.ResumeAt = time.Time{}
+		changed = true
+	}
 	if status.BackupProgress != (backupProgressSummary{}) {
 		status.BackupProgress = backupProgressSummary{}
 		changed = true
 	}
+	if status.RestoreProgress != (restoreProgressSummary{}) {
+		status.RestoreProgress = restoreProgressSummary{}
+		changed = true
+	}
 	if recovered, ok := recoverLastBackupAt(status.LastBackupAt); ok && !recovered.Equal(status.LastBackupAt) {
 		status.LastBackupAt = recovered
 		changed = true
@@ -125,8 +134,12 @@ func latestLocalBackupTime() (time.Time, bool) {
 		return time.Time{}, false
 	}
 	snapshots, err := backup.ListSnapshotManifests(snapshotDir)
-	if err != nil || len(snapshots) == 0 {
+	if err != nil {
+		return time.Time{}, false
+	}
+	latest, ok := backup.LatestCompleteSnapshot(snapshots)
+	if !ok {
 		return time.Time{}, false
 	}
-	return snapshots[0].CreatedAt.UTC(), true
+	return latest.CreatedAt.UTC(), true
 }
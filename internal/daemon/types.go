@@ -1,6 +1,10 @@
 package daemon
 
-import "time"
+import (
+	"time"
+
+	"baxter/internal/backup"
+)
 
 const DefaultIPCAddress = "127.0.0.1:41820"
 const passphraseEnv = "BAXTER_PASSPHRASE"
@@ -11,6 +15,7 @@ type daemonStatus struct {
 	NextScheduledAt  time.Time
 	LastError        string
 	BackupProgress   backupProgressSummary
+	RestoreProgress  restoreProgressSummary
 	LastRestoreAt    time.Time
 	LastRestorePath  string
 	LastRestoreError string
@@ -19,6 +24,20 @@ type daemonStatus struct {
 	NextVerifyAt     time.Time
 	LastVerifyError  string
 	LastVerifyResult verifyResultSummary
+	// Destinations holds the outcome of the last backup for each destination
+	// when replicas are configured.
+	Destinations []destinationState
+	// PausedAt and ResumeAt are set while State is "paused"; ResumeAt is
+	// zero when the backup waits for an explicit resume.
+	PausedAt time.Time
+	ResumeAt time.Time
+}
+
+type destinationState struct {
+	Name          string
+	State         string
+	LastError     string
+	LastSuccessAt time.Time
 }
 
 type verifyResultSummary struct {
@@ -36,6 +55,12 @@ type backupProgressSummary struct {
 	CurrentPath string
 }
 
+type restoreProgressSummary struct {
+	Restored    int
+	Total       int
+	CurrentPath string
+}
+
 type statusResponse struct {
 	State                    string `json:"state"`
 	LastBackupAt             string `json:"last_backup_at,omitempty"`
@@ -44,9 +69,14 @@ type statusResponse struct {
 	BackupUploaded           int    `json:"backup_uploaded,omitempty"`
 	BackupTotal              int    `json:"backup_total,omitempty"`
 	BackupCurrentPath        string `json:"backup_current_path,omitempty"`
+	BackupPausedAt           string `json:"backup_paused_at,omitempty"`
+	BackupResumeAt           string `json:"backup_resume_at,omitempty"`
 	LastRestoreAt            string `json:"last_restore_at,omitempty"`
 	LastRestorePath          string `json:"last_restore_path,omitempty"`
 	LastRestoreError         string `json:"last_restore_error,omitempty"`
+	RestoreRestored          int    `json:"restore_restored,omitempty"`
+	RestoreTotal             int    `json:"restore_total,omitempty"`
+	RestoreCurrentPath       string `json:"restore_current_path,omitempty"`
 	VerifyState              string `json:"verify_state"`
 	LastVerifyAt             string `json:"last_verify_at,omitempty"`
 	NextVerifyAt             string `json:"next_verify_at,omitempty"`
@@ -57,6 +87,46 @@ type statusResponse struct {
 	LastVerifyReadErrors     int    `json:"last_verify_read_errors,omitempty"`
 	LastVerifyDecryptErrors  int    `json:"last_verify_decrypt_errors,omitempty"`
 	LastVerifyChecksumErrors int    `json:"last_verify_checksum_errors,omitempty"`
+
+	Destinations []destinationStatusResponse `json:"destinations,omitempty"`
+	Bandwidth    bandwidthStatusResponse     `json:"bandwidth"`
+}
+
+// backupPauseRequest pauses the running backup. ResumeAfter, a duration such
+// as "30m", resumes it automatically.
+type backupPauseRequest struct {
+	ResumeAfter string `json:"resume_after,omitempty"`
+}
+
+type backupPauseResponse struct {
+	Status   string `json:"status"`
+	ResumeAt string `json:"resume_at,omitempty"`
+}
+
+// bandwidthStatusResponse reports limits in bytes per second; 0 is
+// unlimited.
+type bandwidthStatusResponse struct {
+	UploadLimit   int64  `json:"upload_limit"`
+	DownloadLimit int64  `json:"download_limit"`
+	Window        string `json:"window,omitempty"`
+	Override      bool   `json:"override,omitempty"`
+	OverrideUntil string `json:"override_until,omitempty"`
+}
+
+// bandwidthSetRequest overrides the scheduled limits. An empty limit is
+// unlimited; without a duration the override lasts until cleared or the
+// daemon restarts.
+type bandwidthSetRequest struct {
+	UploadLimit   string `json:"upload_limit"`
+	DownloadLimit string `json:"download_limit"`
+	Duration      string `json:"duration,omitempty"`
+}
+
+type destinationStatusResponse struct {
+	Name          string `json:"name"`
+	State         string `json:"state"`
+	LastError     string `json:"last_error,omitempty"`
+	LastSuccessAt string `json:"last_success_at,omitempty"`
 }
 
 type restoreListResponse struct {
@@ -77,11 +147,19 @@ type restoreDryRunResponse struct {
 }
 
 type restoreRunRequest struct {
-	Path       string `json:"path"`
-	ToDir      string `json:"to_dir,omitempty"`
-	Overwrite  bool   `json:"overwrite,omitempty"`
-	VerifyOnly bool   `json:"verify_only,omitempty"`
-	Snapshot   string `json:"snapshot,omitempty"`
+	Path            string `json:"path"`
+	ToDir           string `json:"to_dir,omitempty"`
+	Overwrite       bool   `json:"overwrite,omitempty"`
+	VerifyOnly      bool   `json:"verify_only,omitempty"`
+	Snapshot        string `json:"snapshot,omitempty"`
+	NoModTime       bool   `json:"no_mtime,omitempty"`
+	NoPerms         bool   `json:"no_perms,omitempty"`
+	NoOwner         bool   `json:"no_owner,omitempty"`
+	NoXattrs        bool   `json:"no_xattrs,omitempty"`
+	Resume          string `json:"resume,omitempty"`
+	Concurrency     int    `json:"concurrency,omitempty"`
+	ContinueOnError bool   `json:"continue_on_error,omitempty"`
+	Destination     string `json:"destination,omitempty"`
 }
 
 type restoreRunResponse struct {
@@ -104,11 +182,32 @@ type scheduleConfig struct {
 }
 
 type snapshotSummary struct {
-	ID        string `json:"id"`
-	CreatedAt string `json:"created_at"`
-	Entries   int    `json:"entries"`
+	ID         string   `json:"id"`
+	CreatedAt  string   `json:"created_at"`
+	Entries    int      `json:"entries"`
+	Incomplete bool     `json:"incomplete,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
 }
 
 type snapshotsResponse struct {
 	Snapshots []snapshotSummary `json:"snapshots"`
 }
+
+type snapshotRetentionSummary struct {
+	ID        string   `json:"id"`
+	CreatedAt string   `json:"created_at"`
+	Keep      bool     `json:"keep"`
+	Reasons   []string `json:"reasons"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+type snapshotRetentionResponse struct {
+	Snapshots []snapshotRetentionSummary `json:"snapshots"`
+	Prune     int                        `json:"prune"`
+}
+
+type snapshotDiffResponse struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	backup.SnapshotDiff
+}
@@ -9,9 +9,15 @@ import (
 
 	"baxter/internal/backup"
 	"baxter/internal/config"
+	"baxter/internal/hooks"
+	"baxter/internal/repolock"
+	"baxter/internal/storage"
 )
 
-var errVerifyAlreadyRunning = errors.New("verify already running")
+var (
+	errVerifyAlreadyRunning = errors.New("verify already running")
+	errVerifyNotRunning     = errors.New("verify not running")
+)
 
 func (d *Daemon) triggerVerify() error {
 	cfg := d.currentConfig()
@@ -21,29 +27,52 @@ func (d *Daemon) triggerVerify() error {
 		d.mu.Unlock()
 		return errVerifyAlreadyRunning
 	}
+	ctx, cancel := context.WithCancel(context.Background())
 	d.verifyRunning = true
+	d.cancelVerify = cancel
 	d.status.VerifyState = "running"
 	d.status.LastVerifyError = ""
 	d.mu.Unlock()
 
 	go func() {
-		result, err := d.performVerify(context.Background(), cfg)
-		if err != nil {
-			d.setVerifyFailed(err, result)
-			return
+		defer cancel()
+		record := runRecord{Operation: "verify", StartedAt: d.now().UTC()}
+		result, err := d.performVerify(ctx, cfg)
+		if err == nil && result.HasFailures() {
+			err = verifyFailureError(result)
+		}
+		if !errors.Is(err, context.Canceled) {
+			// post_verify hooks see the outcome; their failures are only
+			// recorded.
+			record.Hooks, _ = d.hookRunner(cfg).Run(ctx, hooks.PostVerify, hooks.VerifyEnv(result, err))
 		}
-		if result.HasFailures() {
-			d.setVerifyFailed(verifyFailureError(result), result)
-			return
+		d.recordRun(record, err)
+		switch {
+		case err != nil && errors.Is(err, context.Canceled):
+			d.setVerifyCancelled()
+		case err != nil:
+			d.setVerifyFailed(err, result)
+		default:
+			d.setVerifyResult(result)
 		}
-		d.setVerifyResult(result)
 	}()
 
 	return nil
 }
 
+// requestVerifyCancel stops the running verify; the previous verify result
+// is kept.
+func (d *Daemon) requestVerifyCancel() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.verifyRunning || d.cancelVerify == nil {
+		return errVerifyNotRunning
+	}
+	d.cancelVerify()
+	return nil
+}
+
 func (d *Daemon) performVerify(ctx context.Context, cfg *config.Config) (backup.VerifyResult, error) {
-	_ = ctx
 	manifest, err := d.loadManifestForRestore("")
 	if err != nil {
 		return backup.VerifyResult{}, fmt.Errorf("load manifest: %w", err)
@@ -57,16 +86,22 @@ func (d *Daemon) performVerify(ctx context.Context, cfg *config.Config) (backup.
 		entries = entries[:cfg.Verify.Limit]
 	}
 
-	store, err := d.objectStore(cfg)
+	store, err := d.destinationStore(cfg, cfg.Verify.Destination)
 	if err != nil {
 		return backup.VerifyResult{}, fmt.Errorf("create object store: %w", err)
 	}
+	defer storage.Close(store)
 	keys, err := accessEncryptionKeys(cfg, store)
 	if err != nil {
 		return backup.VerifyResult{}, err
 	}
+	lock, err := repolock.AcquireForOperation(store, repolock.Shared, "verify")
+	if err != nil {
+		return backup.VerifyResult{}, err
+	}
+	defer lock.Release()
 
-	result, err := backup.VerifyManifestEntriesWithKeys(entries, keys.candidates, store)
+	result, err := backup.VerifyManifestEntriesWithKeys(ctx, entries, keys.candidates, store)
 	if err != nil {
 		return backup.VerifyResult{}, err
 	}
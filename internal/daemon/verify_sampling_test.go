@@ -139,7 +139,7 @@ func TestPerformVerifyAppliesPrefixSampleAndLimit(t *testing.T) {
 			if err != nil {
 				t.Fatalf("object store dir: %v", err)
 			}
-			store, err := storage.NewFromConfig(cfg.S3, objectsDir)
+			store, err := storage.NewFromConfig(cfg, objectsDir)
 			if err != nil {
 				t.Fatalf("create object store: %v", err)
 			}
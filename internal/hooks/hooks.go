@@ -0,0 +1,269 @@
+// Package hooks runs the commands configured under [hooks] around backups,
+// restores and verifies.
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"baxter/internal/backup"
+	"baxter/internal/config"
+)
+
+// Stage names the point of an operation a hook runs at.
+type Stage string
+
+const (
+	PreBackup  Stage = "pre_backup"
+	PostBackup Stage = "post_backup"
+	OnFailure  Stage = "on_failure"
+	PreRestore Stage = "pre_restore"
+	PostVerify Stage = "post_verify"
+)
+
+// maxOutputBytes bounds the output kept for each hook; the tail is kept.
+const maxOutputBytes = 16 << 10
+
+// waitDelay is how long a killed hook may keep its output open, through
+// children it started, before Run stops waiting for it.
+const waitDelay = 5 * time.Second
+
+// Result describes one hook that ran.
+type Result struct {
+	Stage      Stage     `json:"stage"`
+	Command    string    `json:"command"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	// ExitCode is -1 when the hook did not exit on its own.
+	ExitCode int    `json:"exit_code"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Output holds the combined stdout and stderr of the hook.
+	Output string `json:"output,omitempty"`
+}
+
+func (r Result) Failed() bool {
+	return r.Error != ""
+}
+
+// Error reports a hook whose failure aborts the operation.
+type Error struct {
+	Result Result
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s hook %q failed: %s", e.Result.Stage, e.Result.Command, e.Result.Error)
+}
+
+// Runner runs the hooks of a configuration.
+type Runner struct {
+	Config config.HooksConfig
+	// Log, when set, is called with each result as its hook finishes.
+	Log func(Result)
+}
+
+// Run runs the hooks of stage in order. Each gets the environment of this
+// process plus BAXTER_HOOK set to the stage and the variables in env. A
+// pre_backup or pre_restore hook that fails under the abort policy stops the
+// stage and is returned as an *Error; other failures are only recorded in the
+// results. Cancelling ctx kills the running hook and returns ctx.Err().
+func (r Runner) Run(ctx context.Context, stage Stage, env map[string]string) ([]Result, error) {
+	var results []Result
+	for _, hook := range r.hooks(stage) {
+		result := run(ctx, stage, hook, env)
+		results = append(results, result)
+		if r.Log != nil {
+			r.Log(result)
+		}
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		if result.Failed() && abortsOnError(stage, hook) {
+			return results, &Error{Result: result}
+		}
+	}
+	return results, nil
+}
+
+// Backup runs a backup between the pre_backup and post_backup hooks. When a
+// pre_backup hook aborts, the backup does not run. When the backup or an
+// aborting hook fails, the on_failure hooks run with BAXTER_ERROR set. The
+// failures of post_backup and on_failure hooks are only recorded: the
+// snapshot is committed, or the backup failed, by the time they run. A
+// cancelled backup runs no further hooks.
+func (r Runner) Backup(ctx context.Context, backupFn func(context.Context) (backup.RunResult, error)) (backup.RunResult, []Result, error) {
+	results, err := r.Run(ctx, PreBackup, BackupEnv(backup.RunResult{}, nil))
+	var result backup.RunResult
+	if err == nil {
+		result, err = backupFn(ctx)
+	}
+	if err == nil {
+		post, _ := r.Run(ctx, PostBackup, BackupEnv(result, nil))
+		results = append(results, post...)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		failure, _ := r.Run(ctx, OnFailure, BackupEnv(result, err))
+		results = append(results, failure...)
+	}
+	return result, results, err
+}
+
+func (r Runner) hooks(stage Stage) []config.HookConfig {
+	switch stage {
+	case PreBackup:
+		return r.Config.PreBackup
+	case PostBackup:
+		return r.Config.PostBackup
+	case OnFailure:
+		return r.Config.OnFailure
+	case PreRestore:
+		return r.Config.PreRestore
+	case PostVerify:
+		return r.Config.PostVerify
+	default:
+		return nil
+	}
+}
+
+// abortsOnError applies the hook's policy, which defaults to abort. Only
+// hooks that run before an operation can abort it; config validation rejects
+// the abort policy for the others.
+func abortsOnError(stage Stage, hook config.HookConfig) bool {
+	if stage != PreBackup && stage != PreRestore {
+		return false
+	}
+	return hook.OnError != config.HookOnErrorContinue
+}
+
+func run(ctx context.Context, stage Stage, hook config.HookConfig, env map[string]string) Result {
+	timeout := hook.TimeoutDuration()
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := Result{
+		Stage:     stage,
+		Command:   strings.Join(hook.Command, " "),
+		StartedAt: time.Now().UTC(),
+		ExitCode:  -1,
+	}
+	output := &tailBuffer{}
+	cmd := exec.CommandContext(hookCtx, hook.Command[0], hook.Command[1:]...)
+	cmd.Env = append(os.Environ(), "BAXTER_HOOK="+string(stage))
+	for _, key := range sortedKeys(env) {
+		cmd.Env = append(cmd.Env, key+"="+env[key])
+	}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = waitDelay
+
+	err := cmd.Run()
+	result.DurationMS = time.Since(result.StartedAt).Milliseconds()
+	result.Output = output.String()
+	if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		result.Error = ctx.Err().Error()
+	case errors.Is(hookCtx.Err(), context.DeadlineExceeded):
+		result.TimedOut = true
+		result.Error = fmt.Sprintf("timed out after %s", timeout)
+	default:
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func sortedKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tailBuffer keeps the last maxOutputBytes written to it.
+type tailBuffer struct {
+	mu        sync.Mutex
+	data      []byte
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if over := len(b.data) - maxOutputBytes; over > 0 {
+		b.data = append(b.data[:0], b.data[over:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return "[output truncated]\n" + string(b.data)
+	}
+	return string(b.data)
+}
+
+// BackupEnv describes a backup to its hooks: the snapshot it committed and
+// its counts once it succeeded, and BAXTER_ERROR once it failed.
+func BackupEnv(result backup.RunResult, err error) map[string]string {
+	env := map[string]string{"BAXTER_OPERATION": "backup"}
+	if result.SnapshotID != "" {
+		env["BAXTER_SNAPSHOT_ID"] = result.SnapshotID
+		env["BAXTER_UPLOADED"] = strconv.Itoa(result.Uploaded)
+		env["BAXTER_REUSED"] = strconv.Itoa(result.Reused)
+		env["BAXTER_REMOVED"] = strconv.Itoa(result.Removed)
+		env["BAXTER_TOTAL"] = strconv.Itoa(result.Total)
+	}
+	if err != nil {
+		env["BAXTER_ERROR"] = err.Error()
+	}
+	return env
+}
+
+// RestoreEnv describes a restore of source to target from the snapshot
+// selector (empty for the latest snapshot).
+func RestoreEnv(source, target, snapshot string) map[string]string {
+	env := map[string]string{
+		"BAXTER_OPERATION":      "restore",
+		"BAXTER_RESTORE_SOURCE": source,
+		"BAXTER_RESTORE_TARGET": target,
+	}
+	if snapshot != "" {
+		env["BAXTER_SNAPSHOT"] = snapshot
+	}
+	return env
+}
+
+// VerifyEnv describes a finished verify; err is set when it found problems
+// or could not complete.
+func VerifyEnv(result backup.VerifyResult, err error) map[string]string {
+	env := map[string]string{
+		"BAXTER_OPERATION":              "verify",
+		"BAXTER_VERIFY_CHECKED":         strconv.Itoa(result.Checked),
+		"BAXTER_VERIFY_OK":              strconv.Itoa(result.OK),
+		"BAXTER_VERIFY_MISSING":         strconv.Itoa(result.Missing),
+		"BAXTER_VERIFY_READ_ERRORS":     strconv.Itoa(result.ReadErrors),
+		"BAXTER_VERIFY_DECRYPT_ERRORS":  strconv.Itoa(result.DecryptErrors),
+		"BAXTER_VERIFY_CHECKSUM_ERRORS": strconv.Itoa(result.ChecksumErrors),
+	}
+	if err != nil {
+		env["BAXTER_ERROR"] = err.Error()
+	}
+	return env
+}
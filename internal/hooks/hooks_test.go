@@ -0,0 +1,152 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"baxter/internal/backup"
+	"baxter/internal/config"
+)
+
+func shellHook(script string) config.HookConfig {
+	return config.HookConfig{Command: []string{"/bin/sh", "-c", script}}
+}
+
+func TestRunPassesEnvironmentAndCapturesOutput(t *testing.T) {
+	runner := Runner{Config: config.HooksConfig{
+		PostBackup: []config.HookConfig{shellHook(`echo "$BAXTER_HOOK $BAXTER_SNAPSHOT_ID $BAXTER_UPLOADED"; echo oops >&2`)},
+	}}
+	results, err := runner.Run(context.Background(), PostBackup, BackupEnv(backup.RunResult{SnapshotID: "20260301T000000Z", Uploaded: 3}, nil))
+	if err != nil {
+		t.Fatalf("run hooks: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	result := results[0]
+	if result.Failed() || result.ExitCode != 0 || result.Stage != PostBackup {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Output != "post_backup 20260301T000000Z 3\noops\n" {
+		t.Fatalf("unexpected output: %q", result.Output)
+	}
+}
+
+func TestRunAppliesFailurePolicies(t *testing.T) {
+	var logged []Result
+	runner := Runner{
+		Config: config.HooksConfig{
+			PreBackup: []config.HookConfig{
+				{Command: []string{"/bin/sh", "-c", "exit 3"}, OnError: config.HookOnErrorContinue},
+				shellHook("exit 4"),
+				shellHook("echo never"),
+			},
+			PostVerify: []config.HookConfig{shellHook("exit 5"), shellHook("echo still runs")},
+		},
+		Log: func(result Result) { logged = append(logged, result) },
+	}
+
+	results, err := runner.Run(context.Background(), PreBackup, nil)
+	var hookErr *Error
+	if !errors.As(err, &hookErr) || hookErr.Result.ExitCode != 4 {
+		t.Fatalf("expected the second pre_backup hook to abort, got %v", err)
+	}
+	if len(results) != 2 || results[0].ExitCode != 3 || !results[0].Failed() || len(logged) != 2 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	results, err = runner.Run(context.Background(), PostVerify, nil)
+	if err != nil {
+		t.Fatalf("post_verify hooks continue by default, got %v", err)
+	}
+	if len(results) != 2 || !results[0].Failed() || results[1].Output != "still runs\n" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestRunKillsHookAfterTimeout(t *testing.T) {
+	runner := Runner{Config: config.HooksConfig{
+		PreRestore: []config.HookConfig{{Command: []string{"/bin/sh", "-c", "echo started; exec sleep 10"}, Timeout: "100ms"}},
+	}}
+	results, err := runner.Run(context.Background(), PreRestore, RestoreEnv("/src", "/dst", ""))
+	if err == nil || !results[0].TimedOut || results[0].ExitCode != -1 {
+		t.Fatalf("expected timeout, got %v %+v", err, results)
+	}
+	if results[0].Output != "started\n" {
+		t.Fatalf("expected output before the timeout, got %q", results[0].Output)
+	}
+}
+
+func TestBackupRunsFailureHooksWhenPreBackupAborts(t *testing.T) {
+	runner := Runner{Config: config.HooksConfig{
+		PreBackup:  []config.HookConfig{shellHook("echo locked; exit 1")},
+		PostBackup: []config.HookConfig{shellHook("echo post")},
+		OnFailure:  []config.HookConfig{shellHook(`echo "$BAXTER_ERROR"`)},
+	}}
+	ran := false
+	_, results, err := runner.Backup(context.Background(), func(context.Context) (backup.RunResult, error) {
+		ran = true
+		return backup.RunResult{}, nil
+	})
+	if err == nil || ran {
+		t.Fatalf("expected the backup not to run, got ran=%t err=%v", ran, err)
+	}
+	if len(results) != 2 || results[1].Stage != OnFailure {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if !strings.Contains(results[1].Output, "pre_backup hook") {
+		t.Fatalf("expected on_failure hook to see the error, got %q", results[1].Output)
+	}
+}
+
+func TestBackupIgnoresFailingPostBackupHooks(t *testing.T) {
+	// Validation rejects abort after an operation; Run ignores it anyway.
+	abort := shellHook("echo notify failed; exit 1")
+	abort.OnError = config.HookOnErrorAbort
+	runner := Runner{Config: config.HooksConfig{
+		PostBackup: []config.HookConfig{abort, shellHook("echo still runs")},
+		OnFailure:  []config.HookConfig{shellHook("echo failure")},
+		PostVerify: []config.HookConfig{abort},
+	}}
+	result, results, err := runner.Backup(context.Background(), func(context.Context) (backup.RunResult, error) {
+		return backup.RunResult{SnapshotID: "20260301T000000Z"}, nil
+	})
+	if err != nil || result.SnapshotID != "20260301T000000Z" {
+		t.Fatalf("expected the committed backup to succeed, got %v", err)
+	}
+	if len(results) != 2 || !results[0].Failed() || results[1].Output != "still runs\n" {
+		t.Fatalf("expected both post_backup hooks and no on_failure hook, got %+v", results)
+	}
+
+	if _, err := runner.Run(context.Background(), PostVerify, nil); err != nil {
+		t.Fatalf("expected post_verify failures to be recorded only, got %v", err)
+	}
+}
+
+func TestBackupSkipsHooksAfterCancel(t *testing.T) {
+	runner := Runner{Config: config.HooksConfig{
+		PostBackup: []config.HookConfig{shellHook("echo post")},
+		OnFailure:  []config.HookConfig{shellHook("echo failure")},
+	}}
+	_, results, err := runner.Backup(context.Background(), func(context.Context) (backup.RunResult, error) {
+		return backup.RunResult{}, context.Canceled
+	})
+	if !errors.Is(err, context.Canceled) || len(results) != 0 {
+		t.Fatalf("expected no hooks after cancel, got %v %+v", err, results)
+	}
+}
+
+func TestTailBufferKeepsTheEnd(t *testing.T) {
+	var buf tailBuffer
+	buf.Write([]byte(strings.Repeat("a", maxOutputBytes)))
+	buf.Write([]byte("end"))
+	got := buf.String()
+	if !strings.HasPrefix(got, "[output truncated]\n") || !strings.HasSuffix(got, "aend") {
+		t.Fatalf("unexpected tail: %q...", got[:40])
+	}
+	if len(got) != len("[output truncated]\n")+maxOutputBytes {
+		t.Fatalf("unexpected tail length %d", len(got))
+	}
+}
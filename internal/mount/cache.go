@@ -0,0 +1,64 @@
+package mount
+
+import (
+	"container/list"
+	"sync"
+)
+
+// blockCache keeps recently read plaintext blocks, evicting the least
+// recently used once the total size passes max. Blocks are verified before
+// they are cached and must not be modified by readers.
+type blockCache struct {
+	mu    sync.Mutex
+	max   int64
+	size  int64
+	order *list.List
+	items map[string]*list.Element
+}
+
+type cachedBlock struct {
+	key  string
+	data []byte
+}
+
+func newBlockCache(max int64) *blockCache {
+	return &blockCache{
+		max:   max,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *blockCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cachedBlock).data, true
+}
+
+// put adds a block. Blocks larger than the whole cache are not kept.
+func (c *blockCache) put(key string, data []byte) {
+	size := int64(len(data))
+	if size > c.max {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cachedBlock{key: key, data: data})
+	c.size += size
+	for c.size > c.max {
+		oldest := c.order.Back()
+		block := oldest.Value.(*cachedBlock)
+		c.order.Remove(oldest)
+		delete(c.items, block.key)
+		c.size -= int64(len(block.data))
+	}
+}
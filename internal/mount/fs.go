@@ -0,0 +1,541 @@
+// Package mount presents backup snapshots as a read-only file tree. FS lays
+// out every snapshot under snapshots/<id>/ and the latest one under latest/,
+// building directories from manifest entry paths and fetching object content
+// lazily as files are read. Mount serves an FS through FUSE where the
+// platform supports it.
+package mount
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"baxter/internal/backup"
+	"baxter/internal/storage"
+)
+
+const (
+	LatestDir    = "latest"
+	SnapshotsDir = "snapshots"
+
+	// DefaultCacheSize bounds the decrypted objects and chunks kept in memory.
+	DefaultCacheSize int64 = 64 << 20
+
+	// contentBlockSize splits unchunked entries larger than it into blocks
+	// that are decrypted from one stream and cached separately; smaller
+	// ones are read and cached whole.
+	contentBlockSize int64 = 1 << 20
+
+	virtualDirMode = fs.ModeDir | 0o555
+)
+
+// Source is what an FS exposes.
+type Source struct {
+	// Snapshots are listed under snapshots/, each by its ID.
+	Snapshots []backup.ManifestSnapshot
+	// Load returns the manifest for a snapshot selector: a snapshot ID or
+	// "latest" (LatestDir). It is called at most once per selector.
+	Load  func(selector string) (*backup.Manifest, error)
+	Store storage.ObjectStore
+	Keys  [][]byte
+}
+
+// FS is a read-only fs.FS over the snapshots of a Source. Manifests are
+// loaded the first time their tree is visited; file content is fetched,
+// decrypted and verified an object or chunk at a time and kept in a bounded
+// cache. Open returns files that also implement io.ReaderAt and io.Seeker.
+type FS struct {
+	src   Source
+	cache *blockCache
+	// created is reported as the modification time of the synthesized
+	// top-level directories.
+	created time.Time
+
+	mu    sync.Mutex
+	trees map[string]*snapshotTree
+}
+
+// ErrUnsupported is returned by Mount on platforms other than Linux, where
+// Supported is false.
+var ErrUnsupported = errors.New("mount is only supported on Linux, not " + runtime.GOOS)
+
+var (
+	_ fs.FS        = (*FS)(nil)
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// New returns an FS over src whose block cache holds up to cacheSize bytes
+// (DefaultCacheSize when cacheSize <= 0).
+func New(src Source, cacheSize int64) *FS {
+	if cacheSize <= 0 {
+		cacheSize = DefaultCacheSize
+	}
+	snapshots := append([]backup.ManifestSnapshot(nil), src.Snapshots...)
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].ID < snapshots[j].ID })
+	src.Snapshots = snapshots
+	return &FS{
+		src:     src,
+		cache:   newBlockCache(cacheSize),
+		created: time.Now().UTC(),
+		trees:   make(map[string]*snapshotTree),
+	}
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dirFile{fsys: f, name: name, info: info}, nil
+	}
+	entry, _ := info.Sys().(backup.ManifestEntry)
+	return &file{fsys: f, name: name, info: info, entry: entry}, nil
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := f.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return f.readDir(name)
+}
+
+// ReadLink returns the recorded target of a symlink entry.
+func (f *FS) ReadLink(name string) (string, error) {
+	snapshot, rel, err := f.resolve("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if snapshot == nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	target, err := snapshot.ReadLink(rel)
+	return target, rebase(err, name)
+}
+
+// dirInfo describes the synthesized top-level directories and snapshot
+// roots.
+type dirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (i dirInfo) Name() string       { return i.name }
+func (i dirInfo) Size() int64        { return 0 }
+func (i dirInfo) Mode() fs.FileMode  { return virtualDirMode }
+func (i dirInfo) ModTime() time.Time { return i.modTime }
+func (i dirInfo) IsDir() bool        { return true }
+
+// Sys returns a manifest entry carrying only the mode and modification
+// time, like those of directories synthesized inside a snapshot.
+func (i dirInfo) Sys() any { return backup.ManifestEntry{Mode: virtualDirMode, ModTime: i.modTime} }
+
+type snapshotTree struct {
+	fsys *backup.SnapshotFS
+	// created is the manifest's creation time, which dates latest/.
+	created time.Time
+	err     error
+}
+
+// tree returns the snapshot tree for selector, loading its manifest the
+// first time. Load failures are remembered so a bad snapshot is not fetched
+// on every lookup.
+func (f *FS) tree(selector string) (*snapshotTree, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t, ok := f.trees[selector]; ok {
+		return t, t.err
+	}
+	t := &snapshotTree{}
+	m, err := f.src.Load(selector)
+	switch {
+	case err != nil:
+		t.err = err
+	case m == nil:
+		t.err = errors.New("snapshot has no manifest")
+	default:
+		t.fsys = backup.NewSnapshotFS(m, f.src.Store, f.src.Keys)
+		t.created = m.CreatedAt
+	}
+	f.trees[selector] = t
+	return t, t.err
+}
+
+func (f *FS) snapshot(id string) (backup.ManifestSnapshot, bool) {
+	i := sort.Search(len(f.src.Snapshots), func(i int) bool { return f.src.Snapshots[i].ID >= id })
+	if i < len(f.src.Snapshots) && f.src.Snapshots[i].ID == id {
+		return f.src.Snapshots[i], true
+	}
+	return backup.ManifestSnapshot{}, false
+}
+
+// resolve splits name into the snapshot it lies in and its path there. The
+// snapshot is nil for the top-level directories.
+func (f *FS) resolve(op, name string) (*backup.SnapshotFS, string, error) {
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." || name == SnapshotsDir {
+		return nil, "", nil
+	}
+	selector, rest, _ := strings.Cut(name, "/")
+	switch selector {
+	case LatestDir:
+	case SnapshotsDir:
+		id, idRest, _ := strings.Cut(rest, "/")
+		if _, ok := f.snapshot(id); !ok {
+			return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		selector, rest = id, idRest
+	default:
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	t, err := f.tree(selector)
+	if err != nil {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if rest == "" {
+		rest = "."
+	}
+	return t.fsys, rest, nil
+}
+
+func (f *FS) stat(op, name string) (fs.FileInfo, error) {
+	snapshot, rel, err := f.resolve(op, name)
+	switch {
+	case err != nil:
+		return nil, err
+	case snapshot == nil:
+		return dirInfo{name: path.Base(name), modTime: f.created}, nil
+	case rel == "." && name == LatestDir:
+		return f.latestInfo(), nil
+	case rel == ".":
+		snapshot, _ := f.snapshot(path.Base(name))
+		return dirInfo{name: snapshot.ID, modTime: snapshot.CreatedAt}, nil
+	}
+	info, err := snapshot.Stat(rel)
+	return info, rebase(err, name)
+}
+
+// latestInfo dates latest/ at the creation of the snapshot it shows.
+func (f *FS) latestInfo() fs.FileInfo {
+	t, err := f.tree(LatestDir)
+	if err != nil {
+		// Listed anyway; opening it reports the load error.
+		return dirInfo{name: LatestDir, modTime: f.created}
+	}
+	return dirInfo{name: LatestDir, modTime: t.created}
+}
+
+// readDir lists directory name.
+func (f *FS) readDir(name string) ([]fs.DirEntry, error) {
+	switch name {
+	case ".":
+		return []fs.DirEntry{
+			fs.FileInfoToDirEntry(f.latestInfo()),
+			fs.FileInfoToDirEntry(dirInfo{name: SnapshotsDir, modTime: f.created}),
+		}, nil
+	case SnapshotsDir:
+		entries := make([]fs.DirEntry, 0, len(f.src.Snapshots))
+		for _, snapshot := range f.src.Snapshots {
+			entries = append(entries, fs.FileInfoToDirEntry(dirInfo{name: snapshot.ID, modTime: snapshot.CreatedAt}))
+		}
+		return entries, nil
+	}
+	snapshot, rel, err := f.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := snapshot.ReadDir(rel)
+	return entries, rebase(err, name)
+}
+
+// rebase reports a path error from inside a snapshot under its full name.
+func rebase(err error, name string) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+	return err
+}
+
+type dirFile struct {
+	fsys    *FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+	offset  int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dirFile) Close() error { return nil }
+
+func (d *dirFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.readDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.read = true
+	}
+	remaining := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	d.offset += count
+	return remaining[:count], nil
+}
+
+// file reads the stored content of an entry. Reads at any offset fetch only
+// the objects or chunks they cover; a large unchunked entry is fetched once
+// for reads that move forward through it.
+type file struct {
+	fsys   *FS
+	name   string
+	info   fs.FileInfo
+	entry  backup.ManifestEntry
+	offset int64
+	closed bool
+	stream contentStream
+}
+
+var (
+	_ io.ReaderAt = (*file)(nil)
+	_ io.Seeker   = (*file)(nil)
+)
+
+func (fl *file) Stat() (fs.FileInfo, error) { return fl.info, nil }
+
+func (fl *file) Close() error {
+	if fl.closed {
+		return fs.ErrClosed
+	}
+	fl.closed = true
+	fl.stream.close()
+	return nil
+}
+
+func (fl *file) Read(p []byte) (int, error) {
+	n, err := fl.ReadAt(p, fl.offset)
+	fl.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (fl *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += fl.offset
+	case io.SeekEnd:
+		offset += fl.entry.Size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: fl.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: fl.name, Err: fs.ErrInvalid}
+	}
+	fl.offset = offset
+	return offset, nil
+}
+
+func (fl *file) ReadAt(p []byte, off int64) (int, error) {
+	if fl.closed {
+		return 0, fs.ErrClosed
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: fl.name, Err: fs.ErrInvalid}
+	}
+	n, err := fl.fsys.readAt(fl.entry, p, off, &fl.stream)
+	if err != nil && err != io.EOF {
+		err = &fs.PathError{Op: "read", Path: fl.name, Err: err}
+	}
+	return n, err
+}
+
+// readAt copies the content of entry at off into p, one cached block at a
+// time: a chunk of a chunked entry, a contentBlockSize block of a large
+// unchunked one and otherwise the whole entry. stream serves the blocks of
+// large unchunked entries that are not cached.
+func (f *FS) readAt(entry backup.ManifestEntry, p []byte, off int64, stream *contentStream) (int, error) {
+	if entry.Mode&fs.ModeSymlink != 0 {
+		return 0, fs.ErrInvalid
+	}
+	if off >= entry.Size {
+		return 0, io.EOF
+	}
+
+	read := 0
+	index, blockStart := 0, int64(0)
+	if streamedEntry(entry) {
+		index = int(off / contentBlockSize)
+		blockStart = int64(index) * contentBlockSize
+	}
+	for ; read < len(p) && off < entry.Size; index++ {
+		blockSize := entry.Size
+		switch {
+		case entry.IsChunked():
+			if index >= len(entry.Chunks) {
+				return read, io.ErrUnexpectedEOF
+			}
+			blockSize = entry.Chunks[index].Size
+		case streamedEntry(entry):
+			blockSize = min(contentBlockSize, entry.Size-blockStart)
+		case index > 0:
+			return read, io.ErrUnexpectedEOF
+		}
+		if off >= blockStart+blockSize {
+			blockStart += blockSize
+			continue
+		}
+		block, err := f.block(entry, index, stream)
+		if err != nil {
+			return read, err
+		}
+		if off-blockStart >= int64(len(block)) {
+			return read, io.ErrUnexpectedEOF
+		}
+		n := copy(p[read:], block[off-blockStart:])
+		read += n
+		off += int64(n)
+		blockStart += blockSize
+	}
+	if read < len(p) {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+func (f *FS) block(entry backup.ManifestEntry, index int, stream *contentStream) ([]byte, error) {
+	cacheKey := blockCacheKey(entry, index)
+	if block, ok := f.cache.get(cacheKey); ok {
+		return block, nil
+	}
+
+	var block []byte
+	var err error
+	switch {
+	case entry.IsChunked():
+		block, err = backup.ReadStoredChunk(f.src.Store, f.src.Keys, entry, index)
+	case streamedEntry(entry):
+		return stream.block(f, entry, index)
+	default:
+		block, err = backup.ReadStoredEntryContent(f.src.Store, f.src.Keys, entry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.cache.put(cacheKey, block)
+	return block, nil
+}
+
+// streamedEntry reports whether entry is read in contentBlockSize blocks.
+func streamedEntry(entry backup.ManifestEntry) bool {
+	return !entry.IsChunked() && entry.Size > contentBlockSize
+}
+
+func blockCacheKey(entry backup.ManifestEntry, index int) string {
+	switch {
+	case entry.IsChunked():
+		return "chunk/" + entry.Chunks[index].SHA256
+	case streamedEntry(entry):
+		return "content/" + entry.SHA256 + "/" + strconv.Itoa(index)
+	default:
+		return "content/" + entry.SHA256
+	}
+}
+
+// contentStream decrypts a large unchunked entry front to back for one open
+// file, caching every block it passes, so reads that move forward fetch the
+// object once. A block behind the stream, evicted from the cache, restarts
+// it from the beginning of the object. The last block is only served once
+// the whole-entry checksum has matched.
+type contentStream struct {
+	mu   sync.Mutex
+	body io.ReadCloser
+	next int
+}
+
+func (s *contentStream) block(f *FS, entry backup.ManifestEntry, index int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Another read of this file may have passed the block meanwhile.
+	if block, ok := f.cache.get(blockCacheKey(entry, index)); ok {
+		return block, nil
+	}
+	if s.body == nil || s.next > index {
+		s.closeLocked()
+		body, err := backup.OpenStoredEntryContent(f.src.Store, f.src.Keys, entry)
+		if err != nil {
+			return nil, err
+		}
+		s.body, s.next = body, 0
+	}
+
+	for {
+		start := int64(s.next) * contentBlockSize
+		block := make([]byte, min(contentBlockSize, entry.Size-start))
+		if _, err := io.ReadFull(s.body, block); err != nil {
+			s.closeLocked()
+			return nil, err
+		}
+		if start+int64(len(block)) == entry.Size {
+			// Draining the stream checks the whole-entry checksum.
+			_, err := io.Copy(io.Discard, s.body)
+			s.closeLocked()
+			if err != nil {
+				return nil, err
+			}
+		}
+		f.cache.put(blockCacheKey(entry, s.next), block)
+		s.next++
+		if s.next > index {
+			return block, nil
+		}
+	}
+}
+
+func (s *contentStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *contentStream) closeLocked() {
+	if s.body != nil {
+		_ = s.body.Close()
+		s.body = nil
+	}
+}
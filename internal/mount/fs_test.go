@@ -0,0 +1,340 @@
+package mount
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"baxter/internal/backup"
+	"baxter/internal/crypto"
+	"baxter/internal/storage"
+)
+
+var testKey = []byte("01234567890123456789012345678901")
+
+// countingStore counts object reads so tests can tell cached reads apart.
+type countingStore struct {
+	storage.ObjectStore
+	gets int
+}
+
+func (s *countingStore) GetObject(key string) ([]byte, error) {
+	s.gets++
+	return s.ObjectStore.GetObject(key)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func putEncrypted(t *testing.T, store storage.ObjectStore, objectKey string, plain []byte) {
+	t.Helper()
+	payload, err := crypto.EncryptBytes(testKey, plain)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if err := store.PutObject(objectKey, payload); err != nil {
+		t.Fatalf("put %s: %v", objectKey, err)
+	}
+}
+
+// storedFile stores content as a single object, or as chunks of chunkSize
+// when chunkSize > 0, and returns its manifest entry.
+func storedFile(t *testing.T, store storage.ObjectStore, path string, content []byte, chunkSize int) backup.ManifestEntry {
+	t.Helper()
+	entry := backup.ManifestEntry{
+		Path:    path,
+		Size:    int64(len(content)),
+		Mode:    0o644,
+		ModTime: time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC),
+		SHA256:  sha256Hex(content),
+	}
+	if chunkSize <= 0 {
+		entry.ObjectKey = backup.ObjectKeyForContentSHA256(entry.SHA256)
+		putEncrypted(t, store, entry.ObjectKey, content)
+		return entry
+	}
+	for start := 0; start < len(content); start += chunkSize {
+		end := min(start+chunkSize, len(content))
+		chunk := content[start:end]
+		ref := backup.ChunkRef{SHA256: sha256Hex(chunk), Size: int64(len(chunk))}
+		putEncrypted(t, store, backup.ObjectKeyForChunkSHA256(ref.SHA256), chunk)
+		entry.Chunks = append(entry.Chunks, ref)
+	}
+	return entry
+}
+
+type testRepo struct {
+	store     *countingStore
+	snapshots []backup.ManifestSnapshot
+	manifests map[string]*backup.Manifest
+	loads     map[string]int
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	return &testRepo{
+		store:     &countingStore{ObjectStore: storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))},
+		manifests: make(map[string]*backup.Manifest),
+		loads:     make(map[string]int),
+	}
+}
+
+func (r *testRepo) add(id string, m *backup.Manifest) {
+	r.snapshots = append(r.snapshots, backup.ManifestSnapshot{ID: id, CreatedAt: m.CreatedAt, Entries: len(m.Entries)})
+	r.manifests[id] = m
+	r.manifests[LatestDir] = m
+}
+
+func (r *testRepo) fs(cacheSize int64) *FS {
+	return New(Source{
+		Snapshots: r.snapshots,
+		Load: func(selector string) (*backup.Manifest, error) {
+			r.loads[selector]++
+			m, ok := r.manifests[selector]
+			if !ok {
+				return nil, errors.New("snapshot not found")
+			}
+			return m, nil
+		},
+		Store: r.store,
+		Keys:  [][]byte{testKey},
+	}, cacheSize)
+}
+
+func TestFSLaysOutSnapshotsAndLatest(t *testing.T) {
+	repo := newTestRepo(t)
+	created := time.Date(2026, time.March, 1, 10, 0, 0, 0, time.UTC)
+	repo.add("20260301T090000Z", &backup.Manifest{CreatedAt: created.Add(-time.Hour), Entries: []backup.ManifestEntry{
+		storedFile(t, repo.store, "/Users/me/notes.txt", []byte("first draft"), 0),
+	}})
+	repo.add("20260301T100000Z", &backup.Manifest{CreatedAt: created, Entries: []backup.ManifestEntry{
+		{Path: "/Users/me", Mode: fs.ModeDir | 0o750, ModTime: created},
+		storedFile(t, repo.store, "/Users/me/notes.txt", []byte("second draft"), 0),
+		storedFile(t, repo.store, "/Users/me/big.bin", bytes.Repeat([]byte("0123456789"), 1000), 4096),
+		{Path: "/Users/me/link", Mode: fs.ModeSymlink | 0o777, LinkTarget: "notes.txt"},
+		{Path: "/Users/me/empty.txt", Mode: 0o600, SHA256: sha256Hex(nil)},
+	}})
+	fsys := repo.fs(0)
+
+	if err := fstest.TestFS(fsys,
+		"latest/Users/me/notes.txt",
+		"latest/Users/me/big.bin",
+		"snapshots/20260301T090000Z/Users/me/notes.txt",
+		"snapshots/20260301T100000Z/Users/me/empty.txt",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := fs.ReadFile(fsys, "snapshots/20260301T090000Z/Users/me/notes.txt")
+	if err != nil || string(old) != "first draft" {
+		t.Fatalf("read old snapshot file: %q %v", old, err)
+	}
+	info, err := fs.Stat(fsys, "latest/Users/me")
+	if err != nil {
+		t.Fatalf("stat recorded dir: %v", err)
+	}
+	if info.Mode() != fs.ModeDir|0o750 {
+		t.Fatalf("expected recorded dir mode, got %v", info.Mode())
+	}
+	info, err = fs.Stat(fsys, "latest/Users")
+	if err != nil || !info.IsDir() || !info.ModTime().Equal(created) {
+		t.Fatalf("expected synthesized dir at snapshot time, got %v %v", info, err)
+	}
+	target, err := fsys.ReadLink("latest/Users/me/link")
+	if err != nil || target != "notes.txt" {
+		t.Fatalf("readlink: %q %v", target, err)
+	}
+	if _, err := fsys.Stat("snapshots/20990101T000000Z"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected unknown snapshot to be missing, got %v", err)
+	}
+	if repo.loads["20260301T090000Z"] != 1 || repo.loads[LatestDir] != 1 {
+		t.Fatalf("expected each manifest to load once, got %v", repo.loads)
+	}
+}
+
+func TestFSReadsChunkedFilesAtAnyOffsetThroughCache(t *testing.T) {
+	repo := newTestRepo(t)
+	content := make([]byte, 10000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	repo.add("20260301T100000Z", &backup.Manifest{Entries: []backup.ManifestEntry{
+		storedFile(t, repo.store, "/data.bin", content, 1000),
+	}})
+	fsys := repo.fs(0)
+
+	f, err := fsys.Open("latest/data.bin")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	readerAt := f.(io.ReaderAt)
+
+	buf := make([]byte, 2500)
+	n, err := readerAt.ReadAt(buf, 1500)
+	if err != nil || n != len(buf) || !bytes.Equal(buf, content[1500:4000]) {
+		t.Fatalf("read across chunks: n=%d err=%v", n, err)
+	}
+	if repo.store.gets != 3 {
+		t.Fatalf("expected only the three covering chunks to be fetched, got %d", repo.store.gets)
+	}
+	if _, err := readerAt.ReadAt(buf[:100], 2000); err != nil {
+		t.Fatalf("reread: %v", err)
+	}
+	if repo.store.gets != 3 {
+		t.Fatalf("expected reread to hit the cache, got %d fetches", repo.store.gets)
+	}
+
+	n, err = readerAt.ReadAt(buf, 9000)
+	if n != 1000 || err != io.EOF || !bytes.Equal(buf[:n], content[9000:]) {
+		t.Fatalf("read at end: n=%d err=%v", n, err)
+	}
+}
+
+func TestFSRejectsTamperedContent(t *testing.T) {
+	repo := newTestRepo(t)
+	entry := storedFile(t, repo.store, "/doc.txt", []byte("original"), 0)
+	putEncrypted(t, repo.store, entry.ObjectKey, []byte("tampered"))
+	repo.add("20260301T100000Z", &backup.Manifest{Entries: []backup.ManifestEntry{entry}})
+
+	_, err := fs.ReadFile(repo.fs(0), "latest/doc.txt")
+	if !errors.Is(err, backup.ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+// storedLargeFile stores content as a single stream payload, as backups
+// store large unchunked files, and returns its manifest entry.
+func storedLargeFile(t *testing.T, store storage.ObjectStore, path string, content []byte) backup.ManifestEntry {
+	t.Helper()
+	entry := backup.ManifestEntry{
+		Path:   path,
+		Size:   int64(len(content)),
+		Mode:   0o644,
+		SHA256: sha256Hex(content),
+	}
+	entry.ObjectKey = backup.ObjectKeyForContentSHA256(entry.SHA256)
+	var payload bytes.Buffer
+	if err := crypto.EncryptStream(testKey, &payload, bytes.NewReader(content)); err != nil {
+		t.Fatalf("encrypt stream: %v", err)
+	}
+	if err := store.PutObject(entry.ObjectKey, payload.Bytes()); err != nil {
+		t.Fatalf("put %s: %v", entry.ObjectKey, err)
+	}
+	return entry
+}
+
+func TestFSReadsLargeUnchunkedFilesWithOneFetch(t *testing.T) {
+	repo := newTestRepo(t)
+	content := make([]byte, 4*contentBlockSize+12345)
+	for i := range content {
+		content[i] = byte(i*31 + i/7)
+	}
+	repo.add("20260301T100000Z", &backup.Manifest{Entries: []backup.ManifestEntry{
+		storedLargeFile(t, repo.store, "/big.bin", content),
+	}})
+	fsys := repo.fs(2 * contentBlockSize)
+
+	f, err := fsys.Open("latest/big.bin")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	var got bytes.Buffer
+	buf := make([]byte, 128<<10)
+	for reads := 0; ; reads++ {
+		n, err := f.Read(buf)
+		got.Write(buf[:n])
+		if err == io.EOF {
+			if reads < 30 {
+				t.Fatalf("expected many small reads, got %d", reads)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+	}
+	if !bytes.Equal(got.Bytes(), content) {
+		t.Fatal("sequential read mismatch")
+	}
+	if repo.store.gets != 1 {
+		t.Fatalf("expected sequential reads to fetch the object once, got %d fetches", repo.store.gets)
+	}
+
+	readerAt := f.(io.ReaderAt)
+	tail := make([]byte, 1000)
+	if n, err := readerAt.ReadAt(tail, int64(len(content))-2000); err != nil || n != len(tail) || !bytes.Equal(tail, content[len(content)-2000:len(content)-1000]) {
+		t.Fatalf("reread cached tail: n=%d err=%v", n, err)
+	}
+	if repo.store.gets != 1 {
+		t.Fatalf("expected the cached tail to be served without a fetch, got %d fetches", repo.store.gets)
+	}
+
+	// The first blocks were evicted, so reading them starts over.
+	head := make([]byte, 1000)
+	if n, err := readerAt.ReadAt(head, 10); err != nil || n != len(head) || !bytes.Equal(head, content[10:1010]) {
+		t.Fatalf("reread evicted head: n=%d err=%v", n, err)
+	}
+	if repo.store.gets != 2 {
+		t.Fatalf("expected an evicted block to refetch once, got %d fetches", repo.store.gets)
+	}
+}
+
+func TestFSRejectsTamperedLargeContent(t *testing.T) {
+	repo := newTestRepo(t)
+	content := bytes.Repeat([]byte("original"), int(contentBlockSize/4))
+	entry := storedLargeFile(t, repo.store, "/big.bin", content)
+	tampered := storedLargeFile(t, repo.store, "/other.bin", bytes.Repeat([]byte("tampered"), int(contentBlockSize/4)))
+	entry.ObjectKey = tampered.ObjectKey
+	repo.add("20260301T100000Z", &backup.Manifest{Entries: []backup.ManifestEntry{entry}})
+
+	_, err := fs.ReadFile(repo.fs(0), "latest/big.bin")
+	if !errors.Is(err, backup.ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestBlockCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newBlockCache(10)
+	cache.put("a", []byte("aaaa"))
+	cache.put("b", []byte("bbbb"))
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	cache.put("c", []byte("cccc"))
+	if _, ok := cache.get("b"); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("expected recently used a to stay")
+	}
+	cache.put("huge", make([]byte, 11))
+	if _, ok := cache.get("huge"); ok {
+		t.Fatal("expected a block larger than the cache to be skipped")
+	}
+}
+
+func TestMountIsGatedToLinux(t *testing.T) {
+	if Supported != (runtime.GOOS == "linux") {
+		t.Fatalf("unexpected Supported on %s: %v", runtime.GOOS, Supported)
+	}
+	if Supported {
+		t.Skip("mounting is exercised by the Linux FUSE tests")
+	}
+	err := Mount(context.Background(), New(Source{}, 0), t.TempDir())
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
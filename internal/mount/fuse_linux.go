@@ -0,0 +1,641 @@
+//go:build linux
+
+package mount
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Supported reports whether Mount can serve an FS on this platform.
+const Supported = true
+
+// The server speaks the FUSE kernel protocol directly on /dev/fuse rather
+// than through a FUSE library, so baxter keeps x/sys as its only system
+// dependency. That ties it to Linux: macOS (macFUSE) and the BSDs use
+// different device and mount conventions, and Mount returns ErrUnsupported
+// there. Only the requests a read-only filesystem needs are handled; writes
+// are refused with EROFS and everything else with ENOSYS, which the kernel
+// treats as "not implemented" and stops sending.
+const (
+	fuseKernelVersion      = 7
+	fuseKernelMinorVersion = 31
+	fuseMinInitOutMinor    = 23
+	fuseRootID             = 1
+	fuseMaxWrite           = 128 << 10
+	fuseReadBufferSize     = fuseMaxWrite + 4096
+	// Snapshots never change while mounted, so the kernel may cache names
+	// and attributes for long.
+	fuseCacheValid = time.Hour
+
+	fuseAsyncRead  = 1 << 0
+	fuseKeepCache  = 1 << 1
+	fuseInHeaderSz = 40
+)
+
+const (
+	opLookup      = 1
+	opForget      = 2
+	opGetattr     = 3
+	opSetattr     = 4
+	opReadlink    = 5
+	opSymlink     = 6
+	opMknod       = 8
+	opMkdir       = 9
+	opUnlink      = 10
+	opRmdir       = 11
+	opRename      = 12
+	opLink        = 13
+	opOpen        = 14
+	opRead        = 15
+	opWrite       = 16
+	opStatfs      = 17
+	opRelease     = 18
+	opFsync       = 20
+	opSetxattr    = 21
+	opRemovexattr = 24
+	opFlush       = 25
+	opInit        = 26
+	opOpendir     = 27
+	opReaddir     = 28
+	opReleasedir  = 29
+	opFsyncdir    = 30
+	opCreate      = 35
+	opInterrupt   = 36
+	opDestroy     = 38
+	opBatchForget = 42
+	opFallocate   = 43
+	opRename2     = 45
+)
+
+// Mount serves fsys read-only at mountpoint until ctx is done or the
+// filesystem is unmounted from outside, and unmounts it before returning.
+// Mounting needs root or a fusermount helper on PATH.
+func Mount(ctx context.Context, fsys *FS, mountpoint string) error {
+	mountpoint, err := filepath.Abs(mountpoint)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(mountpoint)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("mountpoint %s is not a directory", mountpoint)
+	}
+
+	dev, unmount, err := mountFUSE(mountpoint)
+	if err != nil {
+		return fmt.Errorf("mount %s: %w", mountpoint, err)
+	}
+	srv := &fuseServer{
+		fsys:     fsys,
+		dev:      dev,
+		uid:      uint32(os.Getuid()),
+		gid:      uint32(os.Getgid()),
+		paths:    map[uint64]string{fuseRootID: "."},
+		nodes:    map[string]uint64{".": fuseRootID},
+		nextNode: fuseRootID + 1,
+		handles:  make(map[uint64]*fuseHandle),
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- srv.serve() }()
+	select {
+	case err := <-served:
+		_ = unmount()
+		_ = unix.Close(dev)
+		return err
+	case <-ctx.Done():
+		unmountErr := unmount()
+		serveErr := <-served
+		_ = unix.Close(dev)
+		if unmountErr != nil {
+			return fmt.Errorf("unmount %s: %w", mountpoint, unmountErr)
+		}
+		return serveErr
+	}
+}
+
+// mountFUSE mounts a FUSE filesystem at mountpoint and returns the
+// /dev/fuse descriptor that serves it. Root mounts directly; other users go
+// through fusermount, which hands the descriptor back over a socket.
+func mountFUSE(mountpoint string) (int, func() error, error) {
+	if os.Geteuid() == 0 {
+		fd, unmount, err := mountDirect(mountpoint)
+		if err == nil || !errors.Is(err, unix.EPERM) {
+			return fd, unmount, err
+		}
+	}
+	return mountWithFusermount(mountpoint)
+}
+
+func mountDirect(mountpoint string) (int, func() error, error) {
+	fd, err := unix.Open("/dev/fuse", unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, nil, fmt.Errorf("open /dev/fuse: %w", err)
+	}
+	options := fmt.Sprintf("fd=%d,rootmode=%o,user_id=%d,group_id=%d", fd, unix.S_IFDIR, os.Getuid(), os.Getgid())
+	if err := unix.Mount("baxter", mountpoint, "fuse.baxter", unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, options); err != nil {
+		_ = unix.Close(fd)
+		return -1, nil, err
+	}
+	unmount := func() error {
+		err := unix.Unmount(mountpoint, 0)
+		if errors.Is(err, unix.EBUSY) {
+			// Something is still using the mount; detach it so the
+			// server can exit and the kernel cleans up later.
+			err = unix.Unmount(mountpoint, unix.MNT_DETACH)
+		}
+		return err
+	}
+	return fd, unmount, nil
+}
+
+func mountWithFusermount(mountpoint string) (int, func() error, error) {
+	helper := ""
+	for _, name := range []string{"fusermount3", "fusermount"} {
+		if path, err := exec.LookPath(name); err == nil {
+			helper = path
+			break
+		}
+	}
+	if helper == "" {
+		return -1, nil, errors.New("fusermount not found; install FUSE or mount as root")
+	}
+
+	pair, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return -1, nil, err
+	}
+	remote := os.NewFile(uintptr(pair[1]), "fusermount-socket")
+	defer unix.Close(pair[0])
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(helper, "-o", "ro,nosuid,nodev,fsname=baxter,subtype=baxter", "--", mountpoint)
+	cmd.ExtraFiles = []*os.File{remote}
+	cmd.Env = append(os.Environ(), "_FUSE_COMMFD=3")
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	_ = remote.Close()
+	if runErr != nil {
+		return -1, nil, fmt.Errorf("%s: %w: %s", filepath.Base(helper), runErr, strings.TrimSpace(stderr.String()))
+	}
+
+	buf := make([]byte, 1)
+	oob := make([]byte, unix.CmsgSpace(4))
+	_, oobn, _, _, err := unix.Recvmsg(pair[0], buf, oob, 0)
+	if err != nil {
+		return -1, nil, fmt.Errorf("receive /dev/fuse descriptor: %w", err)
+	}
+	messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(messages) == 0 {
+		return -1, nil, errors.New("fusermount did not pass a /dev/fuse descriptor")
+	}
+	fds, err := unix.ParseUnixRights(&messages[0])
+	if err != nil || len(fds) == 0 {
+		return -1, nil, errors.New("fusermount did not pass a /dev/fuse descriptor")
+	}
+	unix.CloseOnExec(fds[0])
+
+	unmount := func() error {
+		out, err := exec.Command(helper, "-u", "-z", mountpoint).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	return fds[0], unmount, nil
+}
+
+type fuseServer struct {
+	fsys     *FS
+	dev      int
+	uid, gid uint32
+
+	mu sync.Mutex
+	// Node IDs are handed out per path and kept for the life of the mount,
+	// so FORGET needs no bookkeeping.
+	paths      map[uint64]string
+	nodes      map[string]uint64
+	nextNode   uint64
+	handles    map[uint64]*fuseHandle
+	nextHandle uint64
+}
+
+type fuseHandle struct {
+	file    fs.File
+	entries []fs.DirEntry
+}
+
+type fuseRequest struct {
+	opcode uint32
+	unique uint64
+	nodeID uint64
+	body   []byte
+}
+
+// serve answers requests until the filesystem is unmounted. INIT is handled
+// inline; every other request gets its own goroutine so a slow object fetch
+// does not hold up directory listings.
+func (s *fuseServer) serve() error {
+	buf := make([]byte, fuseReadBufferSize)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		n, err := unix.Read(s.dev, buf)
+		switch {
+		case errors.Is(err, unix.EINTR), errors.Is(err, unix.EAGAIN), errors.Is(err, unix.ENOENT):
+			continue
+		case errors.Is(err, unix.ENODEV):
+			// Unmounted.
+			return nil
+		case err != nil:
+			return fmt.Errorf("read /dev/fuse: %w", err)
+		case n < fuseInHeaderSz:
+			return fmt.Errorf("short FUSE request: %d bytes", n)
+		}
+
+		req := fuseRequest{
+			opcode: binary.NativeEndian.Uint32(buf[4:8]),
+			unique: binary.NativeEndian.Uint64(buf[8:16]),
+			nodeID: binary.NativeEndian.Uint64(buf[16:24]),
+		}
+		req.body = append([]byte(nil), buf[fuseInHeaderSz:n]...)
+		switch req.opcode {
+		case opInit:
+			if err := s.handleInit(req); err != nil {
+				return err
+			}
+		case opForget, opBatchForget, opInterrupt:
+			// No reply expected.
+		case opDestroy:
+			s.reply(req, 0, nil)
+			return nil
+		default:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.handle(req)
+			}()
+		}
+	}
+}
+
+func (s *fuseServer) handleInit(req fuseRequest) error {
+	if len(req.body) < 16 {
+		s.reply(req, unix.EIO, nil)
+		return errors.New("short FUSE INIT request")
+	}
+	major := binary.NativeEndian.Uint32(req.body[0:4])
+	minor := binary.NativeEndian.Uint32(req.body[4:8])
+	maxReadahead := binary.NativeEndian.Uint32(req.body[8:12])
+	flags := binary.NativeEndian.Uint32(req.body[12:16])
+	if major < fuseKernelVersion {
+		s.reply(req, unix.EPROTO, nil)
+		return fmt.Errorf("unsupported FUSE protocol %d.%d", major, minor)
+	}
+	if major > fuseKernelVersion {
+		// The kernel retries INIT with the version we answer with.
+		out := make([]byte, 8)
+		binary.NativeEndian.PutUint32(out[0:4], fuseKernelVersion)
+		binary.NativeEndian.PutUint32(out[4:8], fuseKernelMinorVersion)
+		s.reply(req, 0, out)
+		return nil
+	}
+	if minor > fuseKernelMinorVersion {
+		minor = fuseKernelMinorVersion
+	}
+
+	out := make([]byte, 64)
+	binary.NativeEndian.PutUint32(out[0:4], fuseKernelVersion)
+	binary.NativeEndian.PutUint32(out[4:8], minor)
+	binary.NativeEndian.PutUint32(out[8:12], maxReadahead)
+	binary.NativeEndian.PutUint32(out[12:16], flags&fuseAsyncRead)
+	binary.NativeEndian.PutUint16(out[16:18], 16) // max_background
+	binary.NativeEndian.PutUint16(out[18:20], 12) // congestion_threshold
+	binary.NativeEndian.PutUint32(out[20:24], fuseMaxWrite)
+	binary.NativeEndian.PutUint32(out[24:28], 1) // time_gran
+	if minor < fuseMinInitOutMinor {
+		out = out[:24]
+	}
+	s.reply(req, 0, out)
+	return nil
+}
+
+func (s *fuseServer) handle(req fuseRequest) {
+	switch req.opcode {
+	case opLookup:
+		s.handleLookup(req)
+	case opGetattr:
+		s.handleGetattr(req)
+	case opReadlink:
+		s.handleReadlink(req)
+	case opOpen, opOpendir:
+		s.handleOpen(req)
+	case opRead:
+		s.handleRead(req)
+	case opReaddir:
+		s.handleReaddir(req)
+	case opRelease, opReleasedir:
+		s.handleRelease(req)
+	case opStatfs:
+		s.handleStatfs(req)
+	case opFlush, opFsync, opFsyncdir:
+		s.reply(req, 0, nil)
+	case opSetattr, opSymlink, opMknod, opMkdir, opUnlink, opRmdir, opRename, opLink,
+		opWrite, opSetxattr, opRemovexattr, opCreate, opFallocate, opRename2:
+		s.reply(req, unix.EROFS, nil)
+	default:
+		s.reply(req, unix.ENOSYS, nil)
+	}
+}
+
+func (s *fuseServer) handleLookup(req fuseRequest) {
+	parent, ok := s.path(req.nodeID)
+	name := string(bytes.TrimRight(req.body, "\x00"))
+	if !ok || name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		s.reply(req, unix.ENOENT, nil)
+		return
+	}
+	childPath := name
+	if parent != "." {
+		childPath = parent + "/" + name
+	}
+	info, err := s.fsys.Stat(childPath)
+	if err != nil {
+		s.reply(req, fuseErrno(err), nil)
+		return
+	}
+	nodeID := s.node(childPath)
+	out := make([]byte, 40, 40+fuseAttrSize)
+	binary.NativeEndian.PutUint64(out[0:8], nodeID)
+	putValidity(out[16:24], out[32:36])
+	putValidity(out[24:32], out[36:40])
+	out = s.appendAttr(out, nodeID, info)
+	s.reply(req, 0, out)
+}
+
+func (s *fuseServer) handleGetattr(req fuseRequest) {
+	name, ok := s.path(req.nodeID)
+	if !ok {
+		s.reply(req, unix.ENOENT, nil)
+		return
+	}
+	info, err := s.fsys.Stat(name)
+	if err != nil {
+		s.reply(req, fuseErrno(err), nil)
+		return
+	}
+	out := make([]byte, 16, 16+fuseAttrSize)
+	putValidity(out[0:8], out[8:12])
+	s.reply(req, 0, s.appendAttr(out, req.nodeID, info))
+}
+
+func (s *fuseServer) handleReadlink(req fuseRequest) {
+	name, ok := s.path(req.nodeID)
+	if !ok {
+		s.reply(req, unix.ENOENT, nil)
+		return
+	}
+	target, err := s.fsys.ReadLink(name)
+	if err != nil {
+		s.reply(req, fuseErrno(err), nil)
+		return
+	}
+	s.reply(req, 0, []byte(target))
+}
+
+func (s *fuseServer) handleOpen(req fuseRequest) {
+	name, ok := s.path(req.nodeID)
+	if !ok || len(req.body) < 4 {
+		s.reply(req, unix.ENOENT, nil)
+		return
+	}
+	if flags := binary.NativeEndian.Uint32(req.body[0:4]); flags&unix.O_ACCMODE != unix.O_RDONLY {
+		s.reply(req, unix.EROFS, nil)
+		return
+	}
+	handle := &fuseHandle{}
+	if req.opcode == opOpendir {
+		entries, err := s.fsys.ReadDir(name)
+		if err != nil {
+			s.reply(req, fuseErrno(err), nil)
+			return
+		}
+		handle.entries = entries
+	} else {
+		f, err := s.fsys.Open(name)
+		if err != nil {
+			s.reply(req, fuseErrno(err), nil)
+			return
+		}
+		if _, ok := f.(io.ReaderAt); !ok {
+			_ = f.Close()
+			s.reply(req, unix.EISDIR, nil)
+			return
+		}
+		handle.file = f
+	}
+
+	s.mu.Lock()
+	s.nextHandle++
+	fh := s.nextHandle
+	s.handles[fh] = handle
+	s.mu.Unlock()
+
+	out := make([]byte, 16)
+	binary.NativeEndian.PutUint64(out[0:8], fh)
+	binary.NativeEndian.PutUint32(out[8:12], fuseKeepCache)
+	s.reply(req, 0, out)
+}
+
+// fuse_read_in: fh, offset, size, ...
+func readIn(body []byte) (fh uint64, offset int64, size uint32, ok bool) {
+	if len(body) < 20 {
+		return 0, 0, 0, false
+	}
+	return binary.NativeEndian.Uint64(body[0:8]), int64(binary.NativeEndian.Uint64(body[8:16])), binary.NativeEndian.Uint32(body[16:20]), true
+}
+
+func (s *fuseServer) handleRead(req fuseRequest) {
+	fh, offset, size, ok := readIn(req.body)
+	handle := s.openHandle(fh)
+	if !ok || handle == nil || handle.file == nil {
+		s.reply(req, unix.EBADF, nil)
+		return
+	}
+	buf := make([]byte, size)
+	n, err := handle.file.(io.ReaderAt).ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.reply(req, unix.EIO, nil)
+		return
+	}
+	s.reply(req, 0, buf[:n])
+}
+
+func (s *fuseServer) handleReaddir(req fuseRequest) {
+	fh, offset, size, ok := readIn(req.body)
+	handle := s.openHandle(fh)
+	if !ok || handle == nil || handle.file != nil {
+		s.reply(req, unix.EBADF, nil)
+		return
+	}
+	dirPath, _ := s.path(req.nodeID)
+
+	out := make([]byte, 0, size)
+	for i := offset; i >= 0 && i < int64(len(handle.entries)); i++ {
+		entry := handle.entries[i]
+		name := entry.Name()
+		recordSize := (24 + len(name) + 7) &^ 7
+		if len(out)+recordSize > int(size) {
+			break
+		}
+		childPath := name
+		if dirPath != "." {
+			childPath = dirPath + "/" + name
+		}
+		record := make([]byte, recordSize)
+		binary.NativeEndian.PutUint64(record[0:8], s.node(childPath))
+		binary.NativeEndian.PutUint64(record[8:16], uint64(i+1))
+		binary.NativeEndian.PutUint32(record[16:20], uint32(len(name)))
+		binary.NativeEndian.PutUint32(record[20:24], fuseModeBits(entry.Type())>>12)
+		copy(record[24:], name)
+		out = append(out, record...)
+	}
+	s.reply(req, 0, out)
+}
+
+func (s *fuseServer) handleRelease(req fuseRequest) {
+	if len(req.body) >= 8 {
+		fh := binary.NativeEndian.Uint64(req.body[0:8])
+		s.mu.Lock()
+		handle := s.handles[fh]
+		delete(s.handles, fh)
+		s.mu.Unlock()
+		if handle != nil && handle.file != nil {
+			_ = handle.file.Close()
+		}
+	}
+	s.reply(req, 0, nil)
+}
+
+func (s *fuseServer) handleStatfs(req fuseRequest) {
+	out := make([]byte, 80)
+	binary.NativeEndian.PutUint32(out[40:44], 4096) // bsize
+	binary.NativeEndian.PutUint32(out[44:48], 255)  // namelen
+	binary.NativeEndian.PutUint32(out[48:52], 4096) // frsize
+	s.reply(req, 0, out)
+}
+
+func (s *fuseServer) reply(req fuseRequest, errno unix.Errno, payload []byte) {
+	if errno != 0 {
+		payload = nil
+	}
+	out := make([]byte, 16+len(payload))
+	binary.NativeEndian.PutUint32(out[0:4], uint32(len(out)))
+	binary.NativeEndian.PutUint32(out[4:8], uint32(-int32(errno)))
+	binary.NativeEndian.PutUint64(out[8:16], req.unique)
+	copy(out[16:], payload)
+	// ENOENT means the request was interrupted and nobody is waiting for
+	// the answer; nothing else can be done about other failures either.
+	_, _ = unix.Write(s.dev, out)
+}
+
+func (s *fuseServer) path(nodeID uint64) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name, ok := s.paths[nodeID]
+	return name, ok
+}
+
+func (s *fuseServer) node(name string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if nodeID, ok := s.nodes[name]; ok {
+		return nodeID
+	}
+	nodeID := s.nextNode
+	s.nextNode++
+	s.nodes[name] = nodeID
+	s.paths[nodeID] = name
+	return nodeID
+}
+
+func (s *fuseServer) openHandle(fh uint64) *fuseHandle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handles[fh]
+}
+
+const fuseAttrSize = 88
+
+// appendAttr appends a fuse_attr for info. Files are reported as owned by
+// the user serving the mount, since recorded owners rarely exist on the
+// machine browsing the backup.
+func (s *fuseServer) appendAttr(out []byte, nodeID uint64, info fs.FileInfo) []byte {
+	attr := make([]byte, fuseAttrSize)
+	size := uint64(info.Size())
+	mtime := info.ModTime()
+	secs, nsecs := uint64(0), uint32(0)
+	if !mtime.IsZero() && mtime.Unix() > 0 {
+		secs, nsecs = uint64(mtime.Unix()), uint32(mtime.Nanosecond())
+	}
+	nlink := uint32(1)
+	if info.IsDir() {
+		nlink = 2
+	}
+	binary.NativeEndian.PutUint64(attr[0:8], nodeID)
+	binary.NativeEndian.PutUint64(attr[8:16], size)
+	binary.NativeEndian.PutUint64(attr[16:24], (size+511)/512)
+	for i := 0; i < 3; i++ { // atime, mtime, ctime
+		binary.NativeEndian.PutUint64(attr[24+8*i:32+8*i], secs)
+		binary.NativeEndian.PutUint32(attr[48+4*i:52+4*i], nsecs)
+	}
+	binary.NativeEndian.PutUint32(attr[60:64], fuseModeBits(info.Mode())|uint32(info.Mode().Perm()))
+	binary.NativeEndian.PutUint32(attr[64:68], nlink)
+	binary.NativeEndian.PutUint32(attr[68:72], s.uid)
+	binary.NativeEndian.PutUint32(attr[72:76], s.gid)
+	binary.NativeEndian.PutUint32(attr[80:84], 4096) // blksize
+	return append(out, attr...)
+}
+
+func putValidity(secs []byte, nsecs []byte) {
+	binary.NativeEndian.PutUint64(secs, uint64(fuseCacheValid/time.Second))
+	binary.NativeEndian.PutUint32(nsecs, 0)
+}
+
+func fuseModeBits(mode fs.FileMode) uint32 {
+	switch {
+	case mode.IsDir():
+		return unix.S_IFDIR
+	case mode&fs.ModeSymlink != 0:
+		return unix.S_IFLNK
+	default:
+		return unix.S_IFREG
+	}
+}
+
+func fuseErrno(err error) unix.Errno {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return unix.ENOENT
+	case errors.Is(err, fs.ErrInvalid):
+		return unix.EINVAL
+	default:
+		return unix.EIO
+	}
+}
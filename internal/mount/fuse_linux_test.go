@@ -0,0 +1,84 @@
+//go:build linux
+
+package mount
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"baxter/internal/backup"
+)
+
+func TestMountServesSnapshotsThroughFUSE(t *testing.T) {
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		t.Skip("FUSE is not available")
+	}
+	repo := newTestRepo(t)
+	content := bytes.Repeat([]byte("baxter "), 3000)
+	repo.add("20260301T100000Z", &backup.Manifest{CreatedAt: time.Date(2026, time.March, 1, 10, 0, 0, 0, time.UTC), Entries: []backup.ManifestEntry{
+		storedFile(t, repo.store, "/Users/me/report.txt", content, 4096),
+		{Path: "/Users/me/link", Mode: fs.ModeSymlink | 0o777, LinkTarget: "report.txt"},
+	}})
+
+	// The test reads through the mount from the process serving it. Some
+	// syscalls, fstat among them, hold their P while the kernel waits for the
+	// server, so leave the server Ps of its own.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	mountpoint := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mounted := make(chan error, 1)
+	go func() { mounted <- Mount(ctx, repo.fs(0), mountpoint) }()
+
+	// The mount is ready once the synthesized top level shows up.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if entries, _ := os.ReadDir(mountpoint); len(entries) == 2 {
+			break
+		}
+		select {
+		case err := <-mounted:
+			t.Skipf("cannot mount FUSE here: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("mount did not come up")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	defer func() {
+		cancel()
+		if err := <-mounted; err != nil {
+			t.Errorf("mount: %v", err)
+		}
+	}()
+
+	got, err := os.ReadFile(filepath.Join(mountpoint, "snapshots", "20260301T100000Z", "Users", "me", "report.txt"))
+	if err != nil {
+		t.Fatalf("read through mount: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("content read through mount differs")
+	}
+	target, err := os.Readlink(filepath.Join(mountpoint, "latest", "Users", "me", "link"))
+	if err != nil || target != "report.txt" {
+		t.Fatalf("readlink through mount: %q %v", target, err)
+	}
+	info, err := os.Stat(filepath.Join(mountpoint, "latest", "Users", "me", "report.txt"))
+	if err != nil || info.Size() != int64(len(content)) || !info.ModTime().Equal(time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("stat through mount: %v %v", info, err)
+	}
+	err = os.WriteFile(filepath.Join(mountpoint, "latest", "new.txt"), []byte("x"), 0o600)
+	if !errors.Is(err, syscall.EROFS) {
+		t.Fatalf("expected writes to fail with EROFS, got %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package mount
+
+import "context"
+
+// Supported reports whether Mount can serve an FS on this platform.
+const Supported = false
+
+// Mount is only implemented on Linux. Elsewhere it returns ErrUnsupported and
+// FS can still be used in-process.
+func Mount(ctx context.Context, fsys *FS, mountpoint string) error {
+	return ErrUnsupported
+}
@@ -2,6 +2,7 @@ package recovery
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"baxter/internal/config"
@@ -14,7 +15,19 @@ func BackupSetID(cfg *config.Config) string {
 
 	bucket := strings.TrimSpace(cfg.S3.Bucket)
 	if bucket == "" {
-		return "local"
+		if webdavURL := strings.TrimSpace(cfg.WebDAV.URL); webdavURL != "" {
+			return "webdav+" + webdavURL
+		}
+		host := strings.TrimSpace(cfg.SFTP.Host)
+		if host == "" {
+			return "local"
+		}
+		// Relative paths start at the login directory, written as ~ like scp.
+		remotePath := path.Clean(strings.TrimSpace(cfg.SFTP.Path))
+		if !path.IsAbs(remotePath) {
+			remotePath = "/~/" + remotePath
+		}
+		return fmt.Sprintf("sftp://%s@%s:%d%s", strings.TrimSpace(cfg.SFTP.User), host, cfg.SFTP.Port, remotePath)
 	}
 
 	prefix := strings.Trim(strings.TrimSpace(cfg.S3.Prefix), "/")
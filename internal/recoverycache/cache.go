@@ -1,7 +1,6 @@
 package recoverycache
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -239,29 +238,7 @@ func remoteManifestKey(resolvePassphrase PassphraseResolver, metadata recovery.M
 }
 
 func readRemoteSnapshotManifest(store storage.ObjectStore, snapshotID string, key []byte) (*backup.Manifest, error) {
-	objectKey, err := backup.RemoteSnapshotManifestObjectKey(snapshotID)
-	if err != nil {
-		return nil, err
-	}
-
-	payload, err := store.GetObject(objectKey)
-	if err != nil {
-		return nil, fmt.Errorf("get remote snapshot manifest: %w", err)
-	}
-
-	plain, err := crypto.DecryptBytes(key, payload)
-	if err != nil {
-		return nil, fmt.Errorf("decrypt remote snapshot manifest: %w", err)
-	}
-
-	var manifest backup.Manifest
-	if err := json.Unmarshal(plain, &manifest); err != nil {
-		return nil, fmt.Errorf("decode remote snapshot manifest: %w", err)
-	}
-	if manifest.Entries == nil {
-		manifest.Entries = []backup.ManifestEntry{}
-	}
-	return &manifest, nil
+	return backup.ReadEncryptedSnapshotManifest(store, snapshotID, [][]byte{key})
 }
 
 func remoteSnapshotManifestSet(
@@ -477,10 +454,11 @@ func latestLocalSnapshotID(snapshotDir string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if len(snapshots) == 0 {
+	latest, ok := backup.LatestCompleteSnapshot(snapshots)
+	if !ok {
 		return "", backup.ErrSnapshotNotFound
 	}
-	return snapshots[0].ID, nil
+	return latest.ID, nil
 }
 
 func isLatestSelector(selector string) bool {
@@ -1,6 +1,7 @@
 package recoverycache
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"slices"
@@ -285,7 +286,7 @@ func (s *trackingStore) remoteManifestGetCount() int {
 func seedStateBackup(t *testing.T, cfg *config.Config, store storage.ObjectStore, passphrase string, salt []byte, manifestPath string, snapshotDir string) {
 	t.Helper()
 
-	if _, err := backup.Run(cfg, backup.RunOptions{
+	if _, err := backup.Run(context.Background(), cfg, backup.RunOptions{
 		ManifestPath:      manifestPath,
 		SnapshotDir:       snapshotDir,
 		SnapshotRetention: 30,
@@ -0,0 +1,19 @@
+//go:build !darwin && !linux
+
+package repolock
+
+// Without flock, processes on this machine are excluded by the lock objects
+// alone.
+type localLock struct{}
+
+func lockLocalFile(path string, exclusive bool) (*localLock, error) {
+	return &localLock{}, nil
+}
+
+func (l *localLock) unlock() error {
+	return nil
+}
+
+func processAlive(pid int) bool {
+	return true
+}
@@ -0,0 +1,400 @@
+// Package repolock keeps processes that share a repository from running
+// conflicting operations. A holder writes a lock object under system/locks/
+// that it refreshes while it runs, and holds a file lock on its machine.
+// Shared locks (backup, restore, verify) coexist; an exclusive lock (gc)
+// excludes every other lock.
+package repolock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"baxter/internal/state"
+	"baxter/internal/storage"
+)
+
+type Mode string
+
+const (
+	Shared    Mode = "shared"
+	Exclusive Mode = "exclusive"
+)
+
+const (
+	KeyPrefix = "system/locks/"
+	keySuffix = ".json"
+
+	// DefaultTTL is how long a lock outlives its last heartbeat before
+	// others treat it as stale.
+	DefaultTTL             = 30 * time.Minute
+	DefaultRefreshInterval = 5 * time.Minute
+)
+
+var ErrLocked = errors.New("repository is locked")
+
+// ErrLockLost reports a lock that went unrefreshed past its expiry, so other
+// processes may have taken conflicting locks since.
+var ErrLockLost = errors.New("repository lock lost")
+
+// Info is the content of a lock object.
+type Info struct {
+	ID          string    `json:"id"`
+	Mode        Mode      `json:"mode"`
+	Operation   string    `json:"operation"`
+	Host        string    `json:"host"`
+	PID         int       `json:"pid"`
+	CreatedAt   time.Time `json:"created_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (i Info) Key() string {
+	return KeyPrefix + i.ID + keySuffix
+}
+
+// Stale reports whether the holder of the lock is gone: it stopped
+// refreshing the lock before ExpiresAt, or it ran on this machine and its
+// process has exited.
+func (i Info) Stale(now time.Time) bool {
+	if !now.Before(i.ExpiresAt) {
+		return true
+	}
+	host, err := os.Hostname()
+	return err == nil && i.Host == host && i.PID > 0 && !processAlive(i.PID)
+}
+
+func (i Info) conflicts(mode Mode) bool {
+	return mode == Exclusive || i.Mode == Exclusive
+}
+
+func (i Info) String() string {
+	return fmt.Sprintf("%s %s lock held by pid %d on %s since %s", i.Mode, i.Operation, i.PID, i.Host, i.CreatedAt.Format(time.RFC3339))
+}
+
+// IsLockKey reports whether key names a lock object.
+func IsLockKey(key string) bool {
+	return strings.HasPrefix(key, KeyPrefix) && strings.HasSuffix(key, keySuffix)
+}
+
+type Options struct {
+	Store     storage.ObjectStore
+	Mode      Mode
+	Operation string
+	// LocalPath is a file locked alongside the lock object, so processes on
+	// this machine exclude each other even where the store lists new objects
+	// late. Empty skips it.
+	LocalPath       string
+	TTL             time.Duration
+	RefreshInterval time.Duration
+	Now             func() time.Time
+}
+
+type Lock struct {
+	store           storage.ObjectStore
+	local           *localLock
+	ttl             time.Duration
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	mu   sync.Mutex
+	info Info
+	// refreshErr is the error of the last refresh when it failed.
+	refreshErr error
+	lost       error
+
+	stop    chan struct{}
+	done    chan struct{}
+	release sync.Once
+	err     error
+}
+
+// Acquire takes a lock on opts.Store, failing with ErrLocked when a lock
+// that is not stale conflicts with it. The store has no compare-and-swap, so
+// the lock object is written first and the locks are listed again: of two
+// processes racing for conflicting locks both back off, never both proceed.
+func Acquire(opts Options) (*Lock, error) {
+	if opts.Store == nil {
+		return nil, errors.New("object store is required")
+	}
+	if opts.Mode != Shared && opts.Mode != Exclusive {
+		return nil, fmt.Errorf("invalid lock mode %q", opts.Mode)
+	}
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	refreshInterval := opts.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+	now := opts.Now
+	if now == nil {
+		now = func() time.Time { return time.Now().UTC() }
+	}
+
+	local := &localLock{}
+	if opts.LocalPath != "" {
+		var err error
+		local, err = lockLocalFile(opts.LocalPath, opts.Mode == Exclusive)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := newLockID()
+	if err != nil {
+		_ = local.unlock()
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	createdAt := now()
+	info := Info{
+		ID:          id,
+		Mode:        opts.Mode,
+		Operation:   opts.Operation,
+		Host:        host,
+		PID:         os.Getpid(),
+		CreatedAt:   createdAt,
+		HeartbeatAt: createdAt,
+		ExpiresAt:   createdAt.Add(ttl),
+	}
+
+	if err := checkConflicts(opts.Store, info, now()); err != nil {
+		_ = local.unlock()
+		return nil, err
+	}
+	if err := writeInfo(opts.Store, info); err != nil {
+		_ = local.unlock()
+		return nil, err
+	}
+	if err := checkConflicts(opts.Store, info, now()); err != nil {
+		_ = opts.Store.DeleteObject(info.Key())
+		_ = local.unlock()
+		return nil, err
+	}
+
+	l := &Lock{
+		store:           opts.Store,
+		local:           local,
+		ttl:             ttl,
+		refreshInterval: refreshInterval,
+		now:             now,
+		info:            info,
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	go l.refresh()
+	return l, nil
+}
+
+// AcquireForOperation takes a lock on store for operation, together with the
+// file lock under the app directory; release it when the operation is done.
+func AcquireForOperation(store storage.ObjectStore, mode Mode, operation string) (*Lock, error) {
+	localPath, err := state.RepositoryLockPath()
+	if err != nil {
+		return nil, err
+	}
+	lock, err := Acquire(Options{
+		Store:     store,
+		Mode:      mode,
+		Operation: operation,
+		LocalPath: localPath,
+	})
+	if errors.Is(err, ErrLocked) {
+		return nil, fmt.Errorf("%w; if that process is no longer running, remove its lock with: baxter unlock --stale", err)
+	}
+	return lock, err
+}
+
+func (l *Lock) Info() Info {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.info
+}
+
+// Release stops refreshing the lock and removes it. Calling it again is a
+// no-op.
+func (l *Lock) Release() error {
+	l.release.Do(func() {
+		close(l.stop)
+		<-l.done
+		err := l.store.DeleteObject(l.Info().Key())
+		if err != nil && !storage.IsNotFound(err) {
+			l.err = fmt.Errorf("remove lock: %w", err)
+		}
+		if err := l.local.unlock(); err != nil && l.err == nil {
+			l.err = fmt.Errorf("release local lock: %w", err)
+		}
+	})
+	return l.err
+}
+
+// Err returns ErrLockLost once the lock has gone unrefreshed past its
+// expiry, e.g. after the machine slept or the store was unreachable for the
+// whole TTL. Work that relies on the lock, such as objects a backup stored
+// but has not committed, must not be committed after that. A lost lock
+// stays lost. Err of a nil Lock is nil.
+func (l *Lock) Err() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.checkLostLocked(l.now())
+}
+
+func (l *Lock) checkLostLocked(now time.Time) error {
+	if l.lost == nil && !now.Before(l.info.ExpiresAt) {
+		l.lost = fmt.Errorf("%w: not refreshed since %s", ErrLockLost, l.info.HeartbeatAt.Format(time.RFC3339))
+		if l.refreshErr != nil {
+			l.lost = fmt.Errorf("%w: %w", l.lost, l.refreshErr)
+		}
+	}
+	return l.lost
+}
+
+// refresh moves the lock's expiry forward until Release. A failed refresh is
+// retried at the next tick; the lock is lost once it has not been refreshed
+// for the whole TTL, and is no longer refreshed after that.
+func (l *Lock) refresh() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			now := l.now()
+			l.mu.Lock()
+			if l.checkLostLocked(now) != nil {
+				l.mu.Unlock()
+				return
+			}
+			info := l.info
+			l.mu.Unlock()
+			info.HeartbeatAt = now
+			info.ExpiresAt = now.Add(l.ttl)
+			err := writeInfo(l.store, info)
+			l.mu.Lock()
+			l.refreshErr = err
+			if err == nil {
+				l.info = info
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+func checkConflicts(store storage.ObjectStore, self Info, now time.Time) error {
+	locks, err := List(store)
+	if err != nil {
+		return err
+	}
+	for _, other := range locks {
+		if other.ID == self.ID || !other.conflicts(self.Mode) || other.Stale(now) {
+			continue
+		}
+		return fmt.Errorf("%w: %s", ErrLocked, other)
+	}
+	return nil
+}
+
+// List returns the locks in store, oldest first. A lock object that cannot
+// be decoded is returned with only its ID set, which makes it stale.
+func List(store storage.ObjectStore) ([]Info, error) {
+	var keys []string
+	var err error
+	if lister, ok := store.(storage.PrefixKeyLister); ok {
+		keys, err = lister.ListKeysWithPrefix(KeyPrefix)
+	} else {
+		keys, err = store.ListKeys()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list locks: %w", err)
+	}
+
+	var locks []Info
+	for _, key := range keys {
+		if !IsLockKey(key) {
+			continue
+		}
+		payload, err := store.GetObject(key)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("read lock %s: %w", key, err)
+		}
+		var info Info
+		if err := json.Unmarshal(payload, &info); err != nil {
+			info = Info{}
+		}
+		info.ID = strings.TrimSuffix(strings.TrimPrefix(key, KeyPrefix), keySuffix)
+		locks = append(locks, info)
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		if locks[i].CreatedAt.Equal(locks[j].CreatedAt) {
+			return locks[i].ID < locks[j].ID
+		}
+		return locks[i].CreatedAt.Before(locks[j].CreatedAt)
+	})
+	return locks, nil
+}
+
+// RemoveStale deletes the locks in store that are stale at now and returns
+// them.
+func RemoveStale(store storage.ObjectStore, now time.Time) ([]Info, error) {
+	return removeLocks(store, func(info Info) bool { return info.Stale(now) })
+}
+
+// RemoveAll deletes every lock in store, including those of running
+// processes, and returns them.
+func RemoveAll(store storage.ObjectStore) ([]Info, error) {
+	return removeLocks(store, func(Info) bool { return true })
+}
+
+func removeLocks(store storage.ObjectStore, remove func(Info) bool) ([]Info, error) {
+	locks, err := List(store)
+	if err != nil {
+		return nil, err
+	}
+	var removed []Info
+	for _, info := range locks {
+		if !remove(info) {
+			continue
+		}
+		if err := store.DeleteObject(info.Key()); err != nil && !storage.IsNotFound(err) {
+			return removed, fmt.Errorf("remove lock %s: %w", info.ID, err)
+		}
+		removed = append(removed, info)
+	}
+	return removed, nil
+}
+
+func writeInfo(store storage.ObjectStore, info Info) error {
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("encode lock: %w", err)
+	}
+	if err := store.PutObject(info.Key(), payload); err != nil {
+		return fmt.Errorf("write lock: %w", err)
+	}
+	return nil
+}
+
+func newLockID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate lock id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,259 @@
+package repolock
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"baxter/internal/storage"
+)
+
+func newTestStore(t *testing.T) *storage.LocalClient {
+	t.Helper()
+	return storage.NewLocalClient(filepath.Join(t.TempDir(), "objects"))
+}
+
+func mustAcquire(t *testing.T, opts Options) *Lock {
+	t.Helper()
+	lock, err := Acquire(opts)
+	if err != nil {
+		t.Fatalf("acquire %s lock: %v", opts.Mode, err)
+	}
+	t.Cleanup(func() { _ = lock.Release() })
+	return lock
+}
+
+func TestAcquireSharedAndExclusiveLocks(t *testing.T) {
+	store := newTestStore(t)
+
+	first := mustAcquire(t, Options{Store: store, Mode: Shared, Operation: "backup"})
+	mustAcquire(t, Options{Store: store, Mode: Shared, Operation: "restore"})
+	if _, err := Acquire(Options{Store: store, Mode: Exclusive, Operation: "gc"}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected exclusive lock to be refused, got %v", err)
+	}
+
+	locks, err := List(store)
+	if err != nil {
+		t.Fatalf("list locks: %v", err)
+	}
+	if len(locks) != 2 || locks[0].ID != first.Info().ID || locks[0].Operation != "backup" {
+		t.Fatalf("unexpected locks: %+v", locks)
+	}
+
+	if _, err := RemoveAll(store); err != nil {
+		t.Fatalf("remove locks: %v", err)
+	}
+	exclusive := mustAcquire(t, Options{Store: store, Mode: Exclusive, Operation: "gc"})
+	if _, err := Acquire(Options{Store: store, Mode: Shared, Operation: "backup"}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected shared lock to be refused, got %v", err)
+	}
+	if err := exclusive.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := exclusive.Release(); err != nil {
+		t.Fatalf("second release: %v", err)
+	}
+	mustAcquire(t, Options{Store: store, Mode: Shared, Operation: "backup"})
+}
+
+func TestAcquireIgnoresAndRemovesStaleLocks(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	stale := Info{ID: "stale", Mode: Exclusive, Operation: "gc", Host: "elsewhere", PID: 1, CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)}
+	if err := writeInfo(store, stale); err != nil {
+		t.Fatalf("write stale lock: %v", err)
+	}
+	if err := store.PutObject(KeyPrefix+"garbled.json", []byte("{")); err != nil {
+		t.Fatalf("write garbled lock: %v", err)
+	}
+
+	live := mustAcquire(t, Options{Store: store, Mode: Shared, Operation: "backup", Now: func() time.Time { return now }})
+
+	removed, err := RemoveStale(store, now)
+	if err != nil {
+		t.Fatalf("remove stale locks: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Fatalf("expected the stale and garbled locks to be removed, got %+v", removed)
+	}
+	locks, err := List(store)
+	if err != nil {
+		t.Fatalf("list locks: %v", err)
+	}
+	if len(locks) != 1 || locks[0].ID != live.Info().ID {
+		t.Fatalf("unexpected remaining locks: %+v", locks)
+	}
+}
+
+// racingStore writes a conflicting lock right after the first lock object,
+// as another process could between listing and writing.
+type racingStore struct {
+	*storage.LocalClient
+	rival Info
+	raced bool
+}
+
+func (s *racingStore) PutObject(key string, data []byte) error {
+	if err := s.LocalClient.PutObject(key, data); err != nil {
+		return err
+	}
+	if !s.raced {
+		s.raced = true
+		return writeInfo(s.LocalClient, s.rival)
+	}
+	return nil
+}
+
+func TestAcquireBacksOffWhenRacedByConflictingLock(t *testing.T) {
+	now := time.Now().UTC()
+	store := &racingStore{
+		LocalClient: newTestStore(t),
+		rival:       Info{ID: "rival", Mode: Exclusive, Operation: "gc", Host: "elsewhere", PID: 1, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+	}
+
+	if _, err := Acquire(Options{Store: store, Mode: Shared, Operation: "backup"}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected lock to be refused, got %v", err)
+	}
+	locks, err := List(store)
+	if err != nil {
+		t.Fatalf("list locks: %v", err)
+	}
+	if len(locks) != 1 || locks[0].ID != "rival" {
+		t.Fatalf("expected the backed-off lock to be removed, got %+v", locks)
+	}
+}
+
+func TestAcquireHoldsLocalFileLock(t *testing.T) {
+	localPath := filepath.Join(t.TempDir(), "repository.lock")
+
+	lock := mustAcquire(t, Options{Store: newTestStore(t), Mode: Exclusive, Operation: "gc", LocalPath: localPath})
+	// A different store: only the local lock can refuse it.
+	if _, err := Acquire(Options{Store: newTestStore(t), Mode: Shared, Operation: "backup", LocalPath: localPath}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected local lock to refuse, got %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	mustAcquire(t, Options{Store: newTestStore(t), Mode: Shared, Operation: "backup", LocalPath: localPath})
+	mustAcquire(t, Options{Store: newTestStore(t), Mode: Shared, Operation: "restore", LocalPath: localPath})
+}
+
+func TestLockRefreshesHeartbeat(t *testing.T) {
+	store := newTestStore(t)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := make(chan time.Time, 1)
+	clock <- start
+	now := func() time.Time {
+		current := <-clock
+		clock <- current.Add(time.Minute)
+		return current
+	}
+
+	lock := mustAcquire(t, Options{Store: store, Mode: Shared, Operation: "backup", TTL: time.Hour, RefreshInterval: 5 * time.Millisecond, Now: now})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		payload, err := store.GetObject(lock.Info().Key())
+		if err != nil {
+			t.Fatalf("read lock: %v", err)
+		}
+		var info Info
+		if err := json.Unmarshal(payload, &info); err != nil {
+			t.Fatalf("decode lock: %v", err)
+		}
+		if info.HeartbeatAt.After(info.CreatedAt) && info.ExpiresAt.Equal(info.HeartbeatAt.Add(time.Hour)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("lock was not refreshed: %+v", info)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if locks, _ := List(store); len(locks) != 0 {
+		t.Fatalf("expected lock to be removed, got %+v", locks)
+	}
+}
+
+func TestLockErrReportsLapsedLock(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	current := start
+	now := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return current
+	}
+	setNow := func(t time.Time) {
+		mu.Lock()
+		current = t
+		mu.Unlock()
+	}
+
+	lock := mustAcquire(t, Options{Store: newTestStore(t), Mode: Shared, Operation: "backup", TTL: 30 * time.Minute, RefreshInterval: time.Hour, Now: now})
+	if err := lock.Err(); err != nil {
+		t.Fatalf("fresh lock: %v", err)
+	}
+	// The machine slept past the TTL before the next refresh.
+	setNow(start.Add(31 * time.Minute))
+	if err := lock.Err(); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected lapsed lock to be lost, got %v", err)
+	}
+	setNow(start)
+	if err := lock.Err(); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected lost lock to stay lost, got %v", err)
+	}
+	if err := (*Lock)(nil).Err(); err != nil {
+		t.Fatalf("nil lock: %v", err)
+	}
+}
+
+// failingPutStore fails puts once failing is set.
+type failingPutStore struct {
+	*storage.LocalClient
+	failing atomic.Bool
+}
+
+func (s *failingPutStore) PutObject(key string, data []byte) error {
+	if s.failing.Load() {
+		return errors.New("store unreachable")
+	}
+	return s.LocalClient.PutObject(key, data)
+}
+
+func TestLockIsLostWhenRefreshesFailForTheTTL(t *testing.T) {
+	store := &failingPutStore{LocalClient: newTestStore(t)}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := make(chan time.Time, 1)
+	clock <- start
+	now := func() time.Time {
+		current := <-clock
+		clock <- current.Add(time.Minute)
+		return current
+	}
+
+	lock := mustAcquire(t, Options{Store: store, Mode: Shared, Operation: "backup", TTL: 10 * time.Minute, RefreshInterval: 5 * time.Millisecond, Now: now})
+	store.failing.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := lock.Err()
+		if err != nil {
+			if !errors.Is(err, ErrLockLost) || !strings.Contains(err.Error(), "store unreachable") {
+				t.Fatalf("unexpected lock error: %v", err)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("lock was not lost after failed refreshes")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	store.failing.Store(false)
+}
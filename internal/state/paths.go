@@ -55,6 +55,22 @@ func ManifestSnapshotsDir() (string, error) {
 	return filepath.Join(dir, "manifests"), nil
 }
 
+func UploadCheckpointPath() (string, error) {
+	dir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "upload_checkpoint.journal"), nil
+}
+
+func RestoreJournalsDir() (string, error) {
+	dir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "restore_journals"), nil
+}
+
 func ObjectStoreDir() (string, error) {
 	dir, err := AppDir()
 	if err != nil {
@@ -78,3 +94,31 @@ func DaemonStatusPath() (string, error) {
 	}
 	return filepath.Join(dir, "daemon_status.json"), nil
 }
+
+// RunHistoryPath holds the recent backup, restore and verify runs of
+// baxterd with the output of their hooks.
+func RunHistoryPath() (string, error) {
+	dir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "run_history.json"), nil
+}
+
+// RepositoryLockPath is the file processes on this machine lock while they
+// hold a repository lock.
+func RepositoryLockPath() (string, error) {
+	dir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "repository.lock"), nil
+}
+
+func StatCachePath() (string, error) {
+	dir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "stat_cache.json"), nil
+}
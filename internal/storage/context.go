@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// ContextObjectStore is implemented by stores that can bind their requests to
+// a context, so cancelling it aborts requests already in flight.
+type ContextObjectStore interface {
+	WithContext(ctx context.Context) ObjectStore
+}
+
+// WithContext returns a view of store whose operations fail once ctx is done.
+// Stores that cannot bind ctx to their requests are checked before each
+// operation and while streaming object bodies.
+func WithContext(ctx context.Context, store ObjectStore) ObjectStore {
+	if ctx == nil || store == nil {
+		return store
+	}
+	if binder, ok := store.(ContextObjectStore); ok {
+		return binder.WithContext(ctx)
+	}
+	return &contextStore{ctx: ctx, inner: store}
+}
+
+type contextStore struct {
+	ctx   context.Context
+	inner ObjectStore
+}
+
+func (s *contextStore) PutObject(key string, data []byte) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.inner.PutObject(key, data)
+}
+
+func (s *contextStore) GetObject(key string) ([]byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.inner.GetObject(key)
+}
+
+func (s *contextStore) DeleteObject(key string) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.inner.DeleteObject(key)
+}
+
+func (s *contextStore) ListKeys() ([]string, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.inner.ListKeys()
+}
+
+func (s *contextStore) ObjectExists(key string) (bool, error) {
+	if err := s.ctx.Err(); err != nil {
+		return false, err
+	}
+	return ObjectExists(s.inner, key)
+}
+
+func (s *contextStore) PutObjectStream(key string, body io.Reader) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return PutObjectStream(s.inner, key, &contextReader{ctx: s.ctx, r: body})
+}
+
+func (s *contextStore) GetObjectStream(key string) (io.ReadCloser, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	body, err := GetObjectStream(s.inner, key)
+	if err != nil {
+		return nil, err
+	}
+	return &contextReadCloser{contextReader: contextReader{ctx: s.ctx, r: body}, closer: body}, nil
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+type contextReadCloser struct {
+	contextReader
+	closer io.Closer
+}
+
+func (r *contextReadCloser) Close() error {
+	return r.closer.Close()
+}
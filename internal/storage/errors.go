@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/aws/smithy-go"
+	"github.com/pkg/sftp"
 )
 
 var ErrTransient = errors.New("transient storage error")
@@ -31,6 +32,17 @@ func IsNotFound(err error) bool {
 			return true
 		}
 	}
+
+	var statusErr *sftp.StatusError
+	if errors.As(err, &statusErr) && statusErr.FxCode() == sftp.ErrSSHFxNoSuchFile {
+		return true
+	}
+
+	var httpErr httpStatusCodeProvider
+	if errors.As(err, &httpErr) {
+		statusCode := httpErr.HTTPStatusCode()
+		return statusCode == 404 || statusCode == 410
+	}
 	return false
 }
 
@@ -63,6 +75,24 @@ func IsTransient(err error) bool {
 		return true
 	}
 
+	if isSFTPConnectionError(err) {
+		return true
+	}
+
+	return false
+}
+
+// isSFTPConnectionError reports whether err means the SFTP session is gone,
+// as opposed to the server rejecting the request.
+func isSFTPConnectionError(err error) bool {
+	if errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, sftp.ErrSSHFxNoConnection) {
+		return true
+	}
+	var statusErr *sftp.StatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.FxCode()
+		return code == sftp.ErrSSHFxConnectionLost || code == sftp.ErrSSHFxNoConnection
+	}
 	return false
 }
 
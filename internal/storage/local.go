@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -16,7 +18,21 @@ func NewLocalClient(rootDir string) *LocalClient {
 	return &LocalClient{rootDir: rootDir}
 }
 
+// PutObject writes through a temporary file like PutObjectStream, so an
+// object that exists is always complete.
 func (c *LocalClient) PutObject(key string, data []byte) error {
+	return c.PutObjectStream(key, bytes.NewReader(data))
+}
+
+func (c *LocalClient) GetObject(key string) ([]byte, error) {
+	fullPath, err := c.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(fullPath)
+}
+
+func (c *LocalClient) PutObjectStream(key string, body io.Reader) error {
 	fullPath, err := c.objectPath(key)
 	if err != nil {
 		return err
@@ -24,15 +40,49 @@ func (c *LocalClient) PutObject(key string, data []byte) error {
 	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
 		return err
 	}
-	return os.WriteFile(fullPath, data, 0o600)
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".tmp-"+filepath.Base(fullPath)+"-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := io.Copy(tmp, body); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
 }
 
-func (c *LocalClient) GetObject(key string) ([]byte, error) {
+func (c *LocalClient) GetObjectStream(key string) (io.ReadCloser, error) {
 	fullPath, err := c.objectPath(key)
 	if err != nil {
 		return nil, err
 	}
-	return os.ReadFile(fullPath)
+	return os.Open(fullPath)
+}
+
+func (c *LocalClient) ObjectExists(key string) (bool, error) {
+	fullPath, err := c.objectPath(key)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.Mode().IsRegular(), nil
 }
 
 func (c *LocalClient) DeleteObject(key string) error {
@@ -0,0 +1,389 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Destination is one named object store a backup set is written to.
+type Destination struct {
+	Name  string
+	Store ObjectStore
+}
+
+// DestinationStatus reports whether a destination of a ReplicatedStore has
+// taken every write so far. A failed destination is skipped by later writes,
+// so it never records a snapshot whose objects it is missing.
+type DestinationStatus struct {
+	Name   string
+	Failed bool
+	Err    error
+}
+
+// ReplicatedStore writes every object to each of its destinations and reads
+// from the first destination that has it. A destination whose write fails
+// while another succeeds is marked failed and left out of later writes; a
+// write only fails when no destination takes it.
+type ReplicatedStore struct {
+	destinations []Destination
+	state        *replicationState
+}
+
+// replicationState is shared by the WithContext views of a ReplicatedStore.
+type replicationState struct {
+	mu     sync.Mutex
+	failed map[string]error
+}
+
+func NewReplicatedStore(destinations []Destination) *ReplicatedStore {
+	return &ReplicatedStore{
+		destinations: append([]Destination(nil), destinations...),
+		state:        &replicationState{failed: make(map[string]error)},
+	}
+}
+
+// Destinations returns the destinations still taking writes, in order.
+func (s *ReplicatedStore) Destinations() []Destination {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	active := make([]Destination, 0, len(s.destinations))
+	for _, dest := range s.destinations {
+		if _, failed := s.state.failed[dest.Name]; !failed {
+			active = append(active, dest)
+		}
+	}
+	return active
+}
+
+// MarkFailed leaves the named destination out of later writes.
+func (s *ReplicatedStore) MarkFailed(name string, err error) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	if _, failed := s.state.failed[name]; !failed {
+		s.state.failed[name] = err
+	}
+}
+
+// Statuses reports every destination in order.
+func (s *ReplicatedStore) Statuses() []DestinationStatus {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	statuses := make([]DestinationStatus, 0, len(s.destinations))
+	for _, dest := range s.destinations {
+		err, failed := s.state.failed[dest.Name]
+		statuses = append(statuses, DestinationStatus{Name: dest.Name, Failed: failed, Err: err})
+	}
+	return statuses
+}
+
+// DestinationStatuses reports the destinations of a ReplicatedStore, or nil
+// for a store with a single destination.
+func DestinationStatuses(store ObjectStore) []DestinationStatus {
+	if replicated, ok := store.(*ReplicatedStore); ok {
+		return replicated.Statuses()
+	}
+	return nil
+}
+
+// WithContext binds every destination to ctx. The view shares destination
+// failures with s.
+func (s *ReplicatedStore) WithContext(ctx context.Context) ObjectStore {
+	return s.mapDestinations(func(store ObjectStore) ObjectStore {
+		return WithContext(ctx, store)
+	})
+}
+
+// mapDestinations returns a view of s whose destination stores are wrapped by
+// wrap. The view shares destination failures with s.
+func (s *ReplicatedStore) mapDestinations(wrap func(ObjectStore) ObjectStore) *ReplicatedStore {
+	clone := &ReplicatedStore{
+		destinations: make([]Destination, len(s.destinations)),
+		state:        s.state,
+	}
+	for i, dest := range s.destinations {
+		clone.destinations[i] = Destination{Name: dest.Name, Store: wrap(dest.Store)}
+	}
+	return clone
+}
+
+func (s *ReplicatedStore) PutObject(key string, data []byte) error {
+	return s.write(func(store ObjectStore) error {
+		return store.PutObject(key, data)
+	})
+}
+
+// PutObjectStream reads body once and streams it to every destination at the
+// pace of the slowest one.
+func (s *ReplicatedStore) PutObjectStream(key string, body io.Reader) error {
+	active := s.Destinations()
+	if len(active) <= 1 {
+		return s.write(func(store ObjectStore) error {
+			return PutObjectStream(store, key, body)
+		})
+	}
+
+	readers := make([]*io.PipeReader, len(active))
+	writers := make([]*io.PipeWriter, len(active))
+	for i := range active {
+		readers[i], writers[i] = io.Pipe()
+	}
+	errs := make([]error, len(active))
+	var wg sync.WaitGroup
+	for i, dest := range active {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = PutObjectStream(dest.Store, key, readers[i])
+			// Unblock the fan-out if the destination stopped reading early.
+			readers[i].CloseWithError(errReplicaStopped)
+		}()
+	}
+	sourceErr := fanOut(body, writers)
+	wg.Wait()
+	if sourceErr != nil {
+		return sourceErr
+	}
+	return s.settle(active, errs)
+}
+
+var errReplicaStopped = errors.New("replica stopped reading")
+
+// fanOut copies body to every writer, dropping writers whose reader has gone,
+// and closes them all. Only a failure to read body is returned; it is passed
+// to the writers so no destination stores a truncated object.
+func fanOut(body io.Reader, writers []*io.PipeWriter) error {
+	live := append([]*io.PipeWriter(nil), writers...)
+	buf := make([]byte, 256*1024)
+	var sourceErr error
+	for len(live) > 0 {
+		n, err := body.Read(buf)
+		if n > 0 {
+			kept := live[:0]
+			for _, w := range live {
+				if _, werr := w.Write(buf[:n]); werr == nil {
+					kept = append(kept, w)
+				}
+			}
+			live = kept
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			sourceErr = err
+			break
+		}
+	}
+	for _, w := range writers {
+		_ = w.CloseWithError(sourceErr)
+	}
+	return sourceErr
+}
+
+func (s *ReplicatedStore) DeleteObject(key string) error {
+	return s.write(func(store ObjectStore) error {
+		return store.DeleteObject(key)
+	})
+}
+
+// GetObject reads from the first destination that returns the object.
+func (s *ReplicatedStore) GetObject(key string) ([]byte, error) {
+	var errs []error
+	for _, dest := range s.readOrder() {
+		data, err := dest.Store.GetObject(key)
+		if err == nil {
+			return data, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, readError(errs)
+}
+
+// GetObjectStream opens the object on the first destination that has it.
+// Failures while reading the body are not retried on another destination.
+func (s *ReplicatedStore) GetObjectStream(key string) (io.ReadCloser, error) {
+	var errs []error
+	for _, dest := range s.readOrder() {
+		body, err := GetObjectStream(dest.Store, key)
+		if err == nil {
+			return body, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, readError(errs)
+}
+
+// ObjectExists reports true only when every destination taking writes has
+// key, so an upload is skipped only when no replica would miss it.
+func (s *ReplicatedStore) ObjectExists(key string) (bool, error) {
+	for _, dest := range s.Destinations() {
+		exists, err := ObjectExists(dest.Store, key)
+		if err != nil || !exists {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ListKeys returns the union of the keys of every destination taking writes.
+func (s *ReplicatedStore) ListKeys() ([]string, error) {
+	return s.listKeys(func(store ObjectStore) ([]string, error) {
+		return store.ListKeys()
+	})
+}
+
+func (s *ReplicatedStore) ListKeysWithPrefix(prefix string) ([]string, error) {
+	return s.listKeys(func(store ObjectStore) ([]string, error) {
+		if lister, ok := store.(PrefixKeyLister); ok {
+			return lister.ListKeysWithPrefix(prefix)
+		}
+		keys, err := store.ListKeys()
+		if err != nil {
+			return nil, err
+		}
+		filtered := keys[:0]
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				filtered = append(filtered, key)
+			}
+		}
+		return filtered, nil
+	})
+}
+
+func (s *ReplicatedStore) listKeys(list func(ObjectStore) ([]string, error)) ([]string, error) {
+	active := s.Destinations()
+	if len(active) == 0 {
+		return nil, s.noDestinationsError()
+	}
+	seen := make(map[string]struct{})
+	errs := make([]error, len(active))
+	for i, dest := range active {
+		keys, err := list(dest.Store)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		for _, key := range keys {
+			seen[key] = struct{}{}
+		}
+	}
+	if err := s.settle(active, errs); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Close closes every destination.
+func (s *ReplicatedStore) Close() error {
+	var errs []error
+	for _, dest := range s.destinations {
+		if err := Close(dest.Store); err != nil {
+			errs = append(errs, fmt.Errorf("destination %s: %w", dest.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// write applies op to every destination taking writes, concurrently.
+func (s *ReplicatedStore) write(op func(ObjectStore) error) error {
+	active := s.Destinations()
+	if len(active) == 0 {
+		return s.noDestinationsError()
+	}
+	errs := make([]error, len(active))
+	var wg sync.WaitGroup
+	for i, dest := range active {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = op(dest.Store)
+		}()
+	}
+	wg.Wait()
+	return s.settle(active, errs)
+}
+
+// settle marks the destinations that failed an operation another destination
+// completed. When none completed it, the errors are returned instead so the
+// caller can retry. Cancellation never marks a destination failed.
+func (s *ReplicatedStore) settle(active []Destination, errs []error) error {
+	succeeded := false
+	var failures []error
+	for i, err := range errs {
+		if err == nil {
+			succeeded = true
+			continue
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		failures = append(failures, fmt.Errorf("destination %s: %w", active[i].Name, err))
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	if !succeeded {
+		return errors.Join(failures...)
+	}
+	for i, err := range errs {
+		if err != nil {
+			s.MarkFailed(active[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *ReplicatedStore) noDestinationsError() error {
+	var failures []error
+	for _, status := range s.Statuses() {
+		if status.Failed {
+			failures = append(failures, fmt.Errorf("destination %s: %w", status.Name, status.Err))
+		}
+	}
+	return fmt.Errorf("no destination is taking writes: %w", errors.Join(failures...))
+}
+
+// readOrder lists destinations taking writes before failed ones, which may
+// still hold older objects.
+func (s *ReplicatedStore) readOrder() []Destination {
+	active := s.Destinations()
+	if len(active) == len(s.destinations) {
+		return active
+	}
+	order := append([]Destination(nil), active...)
+	for _, status := range s.Statuses() {
+		if status.Failed {
+			for _, dest := range s.destinations {
+				if dest.Name == status.Name {
+					order = append(order, dest)
+				}
+			}
+		}
+	}
+	return order
+}
+
+// readError prefers an error other than not found, since the object may
+// exist on a destination that could not be read.
+func readError(errs []error) error {
+	for _, err := range errs {
+		if !IsNotFound(err) {
+			return err
+		}
+	}
+	if len(errs) == 0 {
+		return errors.New("no destinations configured")
+	}
+	return errs[0]
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	appconfig "baxter/internal/config"
+)
+
+// failingPutStore rejects every write, as an unreachable destination would.
+type failingPutStore struct {
+	ObjectStore
+	err error
+}
+
+func (s *failingPutStore) PutObject(string, []byte) error {
+	return s.err
+}
+
+func (s *failingPutStore) PutObjectStream(_ string, body io.Reader) error {
+	_, _ = io.Copy(io.Discard, body)
+	return s.err
+}
+
+func newTestReplicatedStore(t *testing.T, names ...string) (*ReplicatedStore, map[string]*LocalClient) {
+	t.Helper()
+	locals := make(map[string]*LocalClient, len(names))
+	destinations := make([]Destination, 0, len(names))
+	for _, name := range names {
+		local := NewLocalClient(filepath.Join(t.TempDir(), name))
+		locals[name] = local
+		destinations = append(destinations, Destination{Name: name, Store: local})
+	}
+	return NewReplicatedStore(destinations), locals
+}
+
+func TestReplicatedStoreWritesEveryDestinationAndFallsBackOnRead(t *testing.T) {
+	store, locals := newTestReplicatedStore(t, "primary", "nas")
+
+	if err := store.PutObject("objects/aa/one", []byte("one")); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+	if err := store.PutObjectStream("objects/bb/two", strings.NewReader("two")); err != nil {
+		t.Fatalf("put object stream: %v", err)
+	}
+	for name, local := range locals {
+		keys, err := local.ListKeys()
+		if err != nil {
+			t.Fatalf("list %s: %v", name, err)
+		}
+		if want := []string{"objects/aa/one", "objects/bb/two"}; !reflect.DeepEqual(keys, want) {
+			t.Fatalf("unexpected keys on %s: got %v want %v", name, keys, want)
+		}
+		got, err := local.GetObject("objects/bb/two")
+		if err != nil || string(got) != "two" {
+			t.Fatalf("unexpected streamed object on %s: %q %v", name, got, err)
+		}
+	}
+
+	// Only the replica still has the object; reads fall back to it.
+	if err := locals["primary"].DeleteObject("objects/aa/one"); err != nil {
+		t.Fatalf("delete from primary: %v", err)
+	}
+	got, err := store.GetObject("objects/aa/one")
+	if err != nil || string(got) != "one" {
+		t.Fatalf("expected fallback read, got %q %v", got, err)
+	}
+	if exists, err := store.ObjectExists("objects/aa/one"); err != nil || exists {
+		t.Fatalf("object missing on one destination must not count as stored: exists=%t err=%v", exists, err)
+	}
+	if err := locals["nas"].PutObject("objects/cc/three", []byte("three")); err != nil {
+		t.Fatalf("put on replica: %v", err)
+	}
+	keys, err := store.ListKeysWithPrefix("objects/")
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if want := []string{"objects/aa/one", "objects/bb/two", "objects/cc/three"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected union of destination keys, got %v want %v", keys, want)
+	}
+
+	if err := store.DeleteObject("objects/aa/one"); err != nil {
+		t.Fatalf("delete object: %v", err)
+	}
+	if _, err := store.GetObject("objects/aa/one"); !IsNotFound(err) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+	for _, status := range store.Statuses() {
+		if status.Failed {
+			t.Fatalf("unexpected failed destination: %+v", status)
+		}
+	}
+}
+
+func TestReplicatedStoreSkipsDestinationAfterFailedWrite(t *testing.T) {
+	primary := NewLocalClient(filepath.Join(t.TempDir(), "primary"))
+	replica := NewLocalClient(filepath.Join(t.TempDir(), "nas"))
+	unreachable := errors.New("connection refused")
+	store := NewReplicatedStore([]Destination{
+		{Name: "primary", Store: primary},
+		{Name: "nas", Store: &failingPutStore{ObjectStore: replica, err: unreachable}},
+	})
+
+	if err := store.PutObjectStream("objects/aa/one", strings.NewReader("one")); err != nil {
+		t.Fatalf("put should succeed while one destination takes it: %v", err)
+	}
+	statuses := store.Statuses()
+	if len(statuses) != 2 || statuses[0].Failed || !statuses[1].Failed || !errors.Is(statuses[1].Err, unreachable) {
+		t.Fatalf("unexpected statuses: %+v", statuses)
+	}
+	if active := store.Destinations(); len(active) != 1 || active[0].Name != "primary" {
+		t.Fatalf("expected only primary to take writes, got %+v", active)
+	}
+
+	// Later writes and existence checks ignore the failed destination.
+	if err := store.PutObject("objects/bb/two", []byte("two")); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+	if exists, err := store.ObjectExists("objects/bb/two"); err != nil || !exists {
+		t.Fatalf("expected object on every writable destination: exists=%t err=%v", exists, err)
+	}
+	if keys, _ := replica.ListKeys(); len(keys) != 0 {
+		t.Fatalf("failed destination received writes: %v", keys)
+	}
+}
+
+func TestReplicatedStoreFailsWriteNoDestinationTakes(t *testing.T) {
+	unreachable := errors.New("connection refused")
+	store := NewReplicatedStore([]Destination{
+		{Name: "primary", Store: &failingPutStore{ObjectStore: NewLocalClient(t.TempDir()), err: unreachable}},
+		{Name: "nas", Store: &failingPutStore{ObjectStore: NewLocalClient(t.TempDir()), err: unreachable}},
+	})
+
+	err := store.PutObject("objects/aa/one", []byte("one"))
+	if !errors.Is(err, unreachable) || !strings.Contains(err.Error(), "destination nas") {
+		t.Fatalf("expected both destination errors, got %v", err)
+	}
+	// Nothing is marked failed, so the caller can retry on every destination.
+	if active := store.Destinations(); len(active) != 2 {
+		t.Fatalf("expected both destinations to stay writable, got %+v", active)
+	}
+}
+
+func TestReplicatedStoreStreamSourceErrorFailsEveryDestination(t *testing.T) {
+	store, locals := newTestReplicatedStore(t, "primary", "nas")
+	sourceErr := errors.New("source file changed")
+
+	err := store.PutObjectStream("objects/aa/one", io.MultiReader(strings.NewReader("partial"), &errReader{err: sourceErr}))
+	if !errors.Is(err, sourceErr) {
+		t.Fatalf("expected source error, got %v", err)
+	}
+	for name, local := range locals {
+		if exists, _ := local.ObjectExists("objects/aa/one"); exists {
+			t.Fatalf("%s stored a truncated object", name)
+		}
+	}
+	if active := store.Destinations(); len(active) != 2 {
+		t.Fatalf("a source failure must not mark destinations failed: %+v", store.Statuses())
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestNewFromConfigReplicatesToConfiguredDestinations(t *testing.T) {
+	cfg := appconfig.DefaultConfig()
+	replicaDir := t.TempDir()
+	cfg.Destinations = []appconfig.DestinationConfig{{Name: "drive", LocalPath: replicaDir}}
+
+	store, err := NewFromConfig(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("new from config: %v", err)
+	}
+	replicated, ok := store.(*ReplicatedStore)
+	if !ok {
+		t.Fatalf("expected ReplicatedStore, got %T", store)
+	}
+	var names []string
+	for _, dest := range replicated.Destinations() {
+		names = append(names, dest.Name)
+	}
+	if want := []string{"primary", "drive"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected destinations: got %v want %v", names, want)
+	}
+
+	single, err := NewDestinationFromConfig(cfg, t.TempDir(), "drive")
+	if err != nil {
+		t.Fatalf("new destination from config: %v", err)
+	}
+	if local, ok := single.(*LocalClient); !ok || local.rootDir != replicaDir {
+		t.Fatalf("expected local client under %s, got %#v", replicaDir, single)
+	}
+	if _, err := NewDestinationFromConfig(cfg, t.TempDir(), "offsite"); err == nil || !strings.Contains(err.Error(), "primary, drive") {
+		t.Fatalf("expected unknown destination error naming the configured ones, got %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"sort"
@@ -32,6 +33,7 @@ const (
 
 type s3API interface {
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
 	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
 }
@@ -74,13 +76,7 @@ type S3Client struct {
 	sleepFn                   func(time.Duration)
 	deleteTimeout             time.Duration
 	listPageTimeout           time.Duration
-}
-
-func NewFromConfig(cfg appconfig.S3Config, localRootDir string) (ObjectStore, error) {
-	if cfg.Bucket == "" {
-		return NewLocalClient(localRootDir), nil
-	}
-	return NewS3Client(cfg)
+	ctx                       context.Context
 }
 
 func NewS3Client(cfg appconfig.S3Config) (*S3Client, error) {
@@ -153,6 +149,24 @@ func NewS3Client(cfg appconfig.S3Config) (*S3Client, error) {
 	}, nil
 }
 
+// WithContext returns a copy of the client whose requests, retries included,
+// are bound to ctx.
+func (c *S3Client) WithContext(ctx context.Context) ObjectStore {
+	if c == nil {
+		return c
+	}
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+func (c *S3Client) requestContext() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
 func (c *S3Client) PutObject(key string, data []byte) error {
 	if c == nil {
 		return errors.New("s3 client is not configured")
@@ -171,7 +185,7 @@ func (c *S3Client) PutObject(key string, data []byte) error {
 
 	contentLength := int64(len(data))
 	err = c.retryWithBackoff(func() error {
-		_, err := c.uploader.UploadObject(context.Background(), &transfermanager.UploadObjectInput{
+		_, err := c.uploader.UploadObject(c.requestContext(), &transfermanager.UploadObjectInput{
 			Bucket:        &c.bucket,
 			Key:           &objectKey,
 			Body:          bytes.NewReader(data),
@@ -203,7 +217,7 @@ func (c *S3Client) GetObject(key string) ([]byte, error) {
 
 	var payload []byte
 	err = c.retryWithBackoff(func() error {
-		out, err := c.api.GetObject(context.Background(), &s3.GetObjectInput{
+		out, err := c.api.GetObject(c.requestContext(), &s3.GetObjectInput{
 			Bucket: &c.bucket,
 			Key:    &objectKey,
 		})
@@ -225,6 +239,112 @@ func (c *S3Client) GetObject(key string) ([]byte, error) {
 	return payload, nil
 }
 
+// PutObjectStream uploads body without knowing its length up front. The body
+// cannot be replayed, so transient failures are returned to the caller rather
+// than retried here.
+func (c *S3Client) PutObjectStream(key string, body io.Reader) error {
+	if c == nil {
+		return errors.New("s3 client is not configured")
+	}
+	if c.uploader == nil {
+		return errors.New("s3 uploader is not configured")
+	}
+	if c.bucket == "" {
+		return errors.New("s3 bucket is not configured")
+	}
+
+	objectKey, err := c.prefixedKey(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.uploader.UploadObject(c.requestContext(), &transfermanager.UploadObjectInput{
+		Bucket: &c.bucket,
+		Key:    &objectKey,
+		Body:   body,
+	})
+	if err != nil {
+		return wrapStorageOperationError("put object", err)
+	}
+	return nil
+}
+
+// GetObjectStream retries opening the object but returns the body unread;
+// failures while reading it surface from the returned reader.
+func (c *S3Client) GetObjectStream(key string) (io.ReadCloser, error) {
+	if c == nil {
+		return nil, errors.New("s3 client is not configured")
+	}
+	if c.api == nil {
+		return nil, errors.New("s3 api client is not configured")
+	}
+	if c.bucket == "" {
+		return nil, errors.New("s3 bucket is not configured")
+	}
+
+	objectKey, err := c.prefixedKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser
+	err = c.retryWithBackoff(func() error {
+		out, err := c.api.GetObject(c.requestContext(), &s3.GetObjectInput{
+			Bucket: &c.bucket,
+			Key:    &objectKey,
+		})
+		if err != nil {
+			return err
+		}
+		body = out.Body
+		return nil
+	})
+	if err != nil {
+		return nil, wrapStorageOperationError("get object", err)
+	}
+	return body, nil
+}
+
+// ObjectExists issues a HEAD request for key; a missing object is reported as
+// false rather than as an error.
+func (c *S3Client) ObjectExists(key string) (bool, error) {
+	if c == nil {
+		return false, errors.New("s3 client is not configured")
+	}
+	if c.api == nil {
+		return false, errors.New("s3 api client is not configured")
+	}
+	if c.bucket == "" {
+		return false, errors.New("s3 bucket is not configured")
+	}
+
+	objectKey, err := c.prefixedKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	exists := false
+	err = c.retryWithBackoff(func() error {
+		_, err := c.api.HeadObject(c.requestContext(), &s3.HeadObjectInput{
+			Bucket: &c.bucket,
+			Key:    &objectKey,
+		})
+		if IsNotFound(err) {
+			exists = false
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		exists = true
+		return nil
+	})
+	if err != nil {
+		return false, wrapStorageOperationError("head object", err)
+	}
+	return exists, nil
+}
+
 func (c *S3Client) DeleteObject(key string) error {
 	if c == nil {
 		return errors.New("s3 client is not configured")
@@ -241,7 +361,7 @@ func (c *S3Client) DeleteObject(key string) error {
 		return err
 	}
 
-	ctx := context.Background()
+	ctx := c.requestContext()
 	cancel := func() {}
 	if c.deleteTimeout > 0 {
 		ctx, cancel = context.WithTimeout(ctx, c.deleteTimeout)
@@ -299,7 +419,7 @@ func (c *S3Client) listKeys(prefix string) ([]string, error) {
 	}
 
 	for paginator.HasMorePages() {
-		ctx := context.Background()
+		ctx := c.requestContext()
 		cancel := func() {}
 		if c.listPageTimeout > 0 {
 			ctx, cancel = context.WithTimeout(ctx, c.listPageTimeout)
@@ -415,13 +535,21 @@ func validateEndpoint(endpoint string) error {
 }
 
 func (c *S3Client) retryWithBackoff(op func() error) error {
-	maxAttempts := c.operationMaxAttempts
+	return retryWithBackoff(c.requestContext(), c.operationMaxAttempts, c.retryBaseDelay, c.retryMaxDelay, c.sleepFn, op)
+}
+
+// retryWithBackoff runs op until it succeeds, fails with an error IsTransient
+// does not accept, or maxAttempts is reached, sleeping between attempts.
+func retryWithBackoff(ctx context.Context, maxAttempts int, baseDelay, maxDelay time.Duration, sleepFn func(time.Duration), op func() error) error {
 	if maxAttempts <= 0 {
 		maxAttempts = 1
 	}
 
 	var lastErr error
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		err := op()
 		if err == nil {
 			return nil
@@ -430,22 +558,21 @@ func (c *S3Client) retryWithBackoff(op func() error) error {
 		if !IsTransient(err) || attempt == maxAttempts {
 			break
 		}
-		if c.sleepFn != nil {
-			c.sleepFn(c.retryDelay(attempt))
+		if sleepFn != nil && ctx.Err() == nil {
+			sleepFn(retryDelay(attempt, baseDelay, maxDelay))
 		}
 	}
 	return lastErr
 }
 
-func (c *S3Client) retryDelay(attempt int) time.Duration {
+func retryDelay(attempt int, baseDelay, maxDelay time.Duration) time.Duration {
 	if attempt < 1 {
 		attempt = 1
 	}
-	delay := c.retryBaseDelay
+	delay := baseDelay
 	if delay <= 0 {
 		return 0
 	}
-	maxDelay := c.retryMaxDelay
 	if maxDelay <= 0 {
 		maxDelay = delay
 	}
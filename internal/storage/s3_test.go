@@ -41,6 +41,7 @@ func (f *fakeUploader) UploadObject(ctx context.Context, input *transfermanager.
 
 type fakeS3API struct {
 	getFn    func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	headFn   func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 	deleteFn func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
 	listFn   func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
 }
@@ -52,6 +53,13 @@ func (f *fakeS3API) GetObject(ctx context.Context, params *s3.GetObjectInput, op
 	return f.getFn(ctx, params, optFns...)
 }
 
+func (f *fakeS3API) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if f.headFn == nil {
+		return nil, errors.New("unexpected head object call")
+	}
+	return f.headFn(ctx, params, optFns...)
+}
+
 func (f *fakeS3API) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
 	if f.deleteFn == nil {
 		return nil, errors.New("unexpected delete object call")
@@ -121,7 +129,7 @@ func TestAWSListObjectsV2PaginatorNilInner(t *testing.T) {
 }
 
 func TestNewFromConfigReturnsLocalClientWhenBucketEmpty(t *testing.T) {
-	store, err := NewFromConfig(appconfig.S3Config{}, t.TempDir())
+	store, err := NewFromConfig(appconfig.DefaultConfig(), t.TempDir())
 	if err != nil {
 		t.Fatalf("new from config: %v", err)
 	}
@@ -299,6 +307,37 @@ func TestS3PutObjectRetriesTransientErrors(t *testing.T) {
 	}
 }
 
+func TestS3WithContextBindsRequestsAndStopsRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	uploader := &fakeUploader{
+		uploadFn: func(reqCtx context.Context, _ *transfermanager.UploadObjectInput, _ ...func(*transfermanager.Options)) (*transfermanager.UploadObjectOutput, error) {
+			if reqCtx != ctx {
+				t.Error("upload did not use the bound context")
+			}
+			cancel()
+			return nil, timeoutNetErr{}
+		},
+	}
+	c := &S3Client{
+		uploader:             uploader,
+		bucket:               "bucket",
+		prefix:               "baxter/",
+		operationMaxAttempts: 3,
+		sleepFn:              func(time.Duration) {},
+	}
+
+	err := c.WithContext(ctx).PutObject("path/item", []byte("payload"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if uploader.callCount != 1 {
+		t.Fatalf("expected no retries after cancellation, got %d calls", uploader.callCount)
+	}
+	if c.ctx != nil {
+		t.Fatal("WithContext must not modify the original client")
+	}
+}
+
 func TestS3PutObjectFailsFastForNonRetryableErrors(t *testing.T) {
 	uploader := &fakeUploader{
 		err: errors.New("access denied"),
@@ -405,6 +444,72 @@ func TestS3GetObjectRetriesTransientErrors(t *testing.T) {
 	}
 }
 
+func TestS3PutObjectStreamSendsBodyWithoutLength(t *testing.T) {
+	uploader := &fakeUploader{}
+	c := &S3Client{
+		uploader: uploader,
+		bucket:   "bucket",
+		prefix:   "baxter/",
+	}
+
+	if err := c.PutObjectStream("folder/file", strings.NewReader("payload")); err != nil {
+		t.Fatalf("put object stream failed: %v", err)
+	}
+	if got := *uploader.lastInput.Key; got != "baxter/folder/file" {
+		t.Fatalf("key mismatch: got %q", got)
+	}
+	if uploader.lastInput.ContentLength != nil {
+		t.Fatalf("expected unknown content length, got %d", *uploader.lastInput.ContentLength)
+	}
+	body, err := io.ReadAll(uploader.lastInput.Body)
+	if err != nil {
+		t.Fatalf("read upload body: %v", err)
+	}
+	if string(body) != "payload" {
+		t.Fatalf("body mismatch: got %q", string(body))
+	}
+
+	uploader.err = timeoutNetErr{}
+	if err := c.PutObjectStream("folder/file", strings.NewReader("payload")); !IsTransient(err) {
+		t.Fatalf("expected transient stream upload error, got: %v", err)
+	}
+	if uploader.callCount != 2 {
+		t.Fatalf("stream uploads should not be retried: got %d calls", uploader.callCount)
+	}
+}
+
+func TestS3GetObjectStreamRetriesOpenAndReturnsBody(t *testing.T) {
+	attempts := 0
+	c := &S3Client{
+		bucket:               "bucket",
+		prefix:               "baxter/",
+		operationMaxAttempts: 3,
+		sleepFn:              func(time.Duration) {},
+		api: &fakeS3API{
+			getFn: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				attempts++
+				if attempts < 2 {
+					return nil, timeoutNetErr{}
+				}
+				return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("payload"))}, nil
+			},
+		},
+	}
+
+	body, err := c.GetObjectStream("key")
+	if err != nil {
+		t.Fatalf("get object stream failed: %v", err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read object stream: %v", err)
+	}
+	if string(got) != "payload" || attempts != 2 {
+		t.Fatalf("unexpected stream result: payload=%q attempts=%d", string(got), attempts)
+	}
+}
+
 func TestS3GetObjectClassifiesNotFoundAndTransient(t *testing.T) {
 	c := &S3Client{
 		bucket:               "bucket",
@@ -440,6 +545,40 @@ func TestS3GetObjectClassifiesNotFoundAndTransient(t *testing.T) {
 	}
 }
 
+func TestS3ObjectExistsReportsMissingObjectsWithoutError(t *testing.T) {
+	present := map[string]bool{"baxter/present": true}
+	c := &S3Client{
+		bucket:               "bucket",
+		prefix:               "baxter/",
+		operationMaxAttempts: 2,
+		sleepFn:              func(time.Duration) {},
+		api: &fakeS3API{
+			headFn: func(_ context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				if present[*input.Key] {
+					return &s3.HeadObjectOutput{}, nil
+				}
+				return nil, &smithy.GenericAPIError{Code: "NotFound", Message: "missing"}
+			},
+		},
+	}
+
+	if exists, err := c.ObjectExists("present"); err != nil || !exists {
+		t.Fatalf("expected present object, got exists=%v err=%v", exists, err)
+	}
+	if exists, err := c.ObjectExists("missing"); err != nil || exists {
+		t.Fatalf("expected missing object without error, got exists=%v err=%v", exists, err)
+	}
+
+	c.api = &fakeS3API{
+		headFn: func(_ context.Context, _ *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return nil, timeoutNetErr{}
+		},
+	}
+	if _, err := c.ObjectExists("present"); !errors.Is(err, ErrTransient) {
+		t.Fatalf("expected transient head error, got %v", err)
+	}
+}
+
 func TestS3DeleteObjectSuccessAndErrors(t *testing.T) {
 	c := &S3Client{
 		bucket: "bucket",
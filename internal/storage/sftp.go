@@ -0,0 +1,490 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	appconfig "baxter/internal/config"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultSFTPDialTimeout = 15 * time.Second
+	sftpTempFilePrefix     = ".tmp-"
+	sftpPosixRenameExt     = "posix-rename@openssh.com"
+)
+
+// SFTPClient stores objects as files under a directory on an SSH server. It
+// connects on first use and keeps one session open, reconnecting when the
+// session is lost; call Close to release it.
+type SFTPClient struct {
+	dial                 func() (*sftpSession, error)
+	root                 string
+	operationMaxAttempts int
+	retryBaseDelay       time.Duration
+	retryMaxDelay        time.Duration
+	sleepFn              func(time.Duration)
+
+	mu      sync.Mutex
+	session *sftpSession
+}
+
+type sftpSession struct {
+	client *sftp.Client
+	conn   io.Closer
+}
+
+func (s *sftpSession) close() error {
+	err := s.client.Close()
+	if connErr := s.conn.Close(); err == nil {
+		err = connErr
+	}
+	return err
+}
+
+// sftpSessionError marks a failure caused by the session going away, which a
+// retry on a fresh connection can recover from.
+type sftpSessionError struct {
+	err error
+}
+
+func (e *sftpSessionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sftpSessionError) Unwrap() error {
+	return e.err
+}
+
+func (e *sftpSessionError) Is(target error) bool {
+	return target == ErrTransient
+}
+
+func NewSFTPClient(cfg appconfig.SFTPConfig) (*SFTPClient, error) {
+	host := strings.TrimSpace(cfg.Host)
+	if host == "" {
+		return nil, errors.New("sftp host is required")
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+	user := strings.TrimSpace(cfg.User)
+	if user == "" {
+		return nil, errors.New("sftp user is required")
+	}
+	root := strings.TrimSpace(cfg.Path)
+	if root == "" {
+		return nil, errors.New("sftp path is required")
+	}
+
+	keyData, err := os.ReadFile(strings.TrimSpace(cfg.IdentityFile))
+	if err != nil {
+		return nil, fmt.Errorf("read sftp identity file: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("parse sftp identity file: %w", err)
+	}
+	hostKeyCallback, hostKeyAlgorithms, err := sftpHostKeyCallback(cfg.HostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	sshConfig := &ssh.ClientConfig{
+		User:              user,
+		Auth:              []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		Timeout:           defaultSFTPDialTimeout,
+	}
+
+	return &SFTPClient{
+		dial: func() (*sftpSession, error) {
+			conn, err := ssh.Dial("tcp", addr, sshConfig)
+			if err != nil {
+				return nil, fmt.Errorf("connect to sftp server %s: %w", addr, err)
+			}
+			client, err := sftp.NewClient(conn)
+			if err != nil {
+				_ = conn.Close()
+				return nil, fmt.Errorf("start sftp session: %w", err)
+			}
+			return &sftpSession{client: client, conn: conn}, nil
+		},
+		root:                 path.Clean(root),
+		operationMaxAttempts: defaultOperationMaxAttempts,
+		retryBaseDelay:       defaultRetryBaseDelay,
+		retryMaxDelay:        defaultRetryMaxDelay,
+		sleepFn:              time.Sleep,
+	}, nil
+}
+
+// sftpHostKeyCallback accepts only the pinned host key, given either as a
+// public key in authorized_keys form or as its SHA256 fingerprint. For a full
+// key the server is asked to present a key of that type.
+func sftpHostKeyCallback(pinned string) (ssh.HostKeyCallback, []string, error) {
+	pinned = strings.TrimSpace(pinned)
+	if pinned == "" {
+		return nil, nil, errors.New("sftp host key is required")
+	}
+	if strings.HasPrefix(pinned, "SHA256:") {
+		return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			if got := ssh.FingerprintSHA256(key); got != pinned {
+				return fmt.Errorf("sftp host key mismatch: server presented %s", got)
+			}
+			return nil
+		}, nil, nil
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pinned))
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse sftp host key: %w", err)
+	}
+	algorithms := []string{key.Type()}
+	if key.Type() == ssh.KeyAlgoRSA {
+		algorithms = []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	fixed := ssh.FixedHostKey(key)
+	return func(hostname string, remote net.Addr, presented ssh.PublicKey) error {
+		if err := fixed(hostname, remote, presented); err != nil {
+			return fmt.Errorf("sftp host key mismatch: server presented %s", ssh.FingerprintSHA256(presented))
+		}
+		return nil
+	}, algorithms, nil
+}
+
+// Close ends the current session, if any. The client reconnects if it is
+// used again.
+func (c *SFTPClient) Close() error {
+	c.mu.Lock()
+	session := c.session
+	c.session = nil
+	c.mu.Unlock()
+	if session == nil {
+		return nil
+	}
+	return session.close()
+}
+
+func (c *SFTPClient) PutObject(key string, data []byte) error {
+	objectPath, err := c.objectPath(key)
+	if err != nil {
+		return err
+	}
+	err = c.retryWithBackoff(func() error {
+		return c.withSession(func(client *sftp.Client) error {
+			return putSFTPFile(client, objectPath, bytes.NewReader(data))
+		})
+	})
+	if err != nil {
+		return wrapStorageOperationError("put object", err)
+	}
+	return nil
+}
+
+func (c *SFTPClient) GetObject(key string) ([]byte, error) {
+	objectPath, err := c.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+	var payload []byte
+	err = c.retryWithBackoff(func() error {
+		return c.withSession(func(client *sftp.Client) error {
+			f, err := client.Open(objectPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			buf := new(bytes.Buffer)
+			if _, err := io.Copy(buf, f); err != nil {
+				return fmt.Errorf("read object body: %w", err)
+			}
+			payload = buf.Bytes()
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, wrapStorageOperationError("get object", err)
+	}
+	return payload, nil
+}
+
+// PutObjectStream uploads body through a temporary file like PutObject. The
+// body cannot be replayed, so a lost session is returned to the caller rather
+// than retried here.
+func (c *SFTPClient) PutObjectStream(key string, body io.Reader) error {
+	objectPath, err := c.objectPath(key)
+	if err != nil {
+		return err
+	}
+	err = c.withSession(func(client *sftp.Client) error {
+		return putSFTPFile(client, objectPath, body)
+	})
+	if err != nil {
+		return wrapStorageOperationError("put object", err)
+	}
+	return nil
+}
+
+// GetObjectStream retries opening the object but returns the file unread;
+// failures while reading it surface from the returned reader.
+func (c *SFTPClient) GetObjectStream(key string) (io.ReadCloser, error) {
+	objectPath, err := c.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+	var body io.ReadCloser
+	err = c.retryWithBackoff(func() error {
+		return c.withSession(func(client *sftp.Client) error {
+			f, err := client.Open(objectPath)
+			if err != nil {
+				return err
+			}
+			body = f
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, wrapStorageOperationError("get object", err)
+	}
+	return body, nil
+}
+
+func (c *SFTPClient) ObjectExists(key string) (bool, error) {
+	objectPath, err := c.objectPath(key)
+	if err != nil {
+		return false, err
+	}
+	exists := false
+	err = c.retryWithBackoff(func() error {
+		return c.withSession(func(client *sftp.Client) error {
+			info, err := client.Stat(objectPath)
+			if IsNotFound(err) {
+				exists = false
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			exists = info.Mode().IsRegular()
+			return nil
+		})
+	})
+	if err != nil {
+		return false, wrapStorageOperationError("stat object", err)
+	}
+	return exists, nil
+}
+
+func (c *SFTPClient) DeleteObject(key string) error {
+	objectPath, err := c.objectPath(key)
+	if err != nil {
+		return err
+	}
+	err = c.retryWithBackoff(func() error {
+		return c.withSession(func(client *sftp.Client) error {
+			if err := client.Remove(objectPath); err != nil && !IsNotFound(err) {
+				return err
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("delete object: %w", err)
+	}
+	return nil
+}
+
+func (c *SFTPClient) ListKeys() ([]string, error) {
+	return c.listKeys("")
+}
+
+func (c *SFTPClient) ListKeysWithPrefix(prefix string) ([]string, error) {
+	return c.listKeys(prefix)
+}
+
+// listKeys walks the directory holding prefix rather than the whole store.
+// Temporary files left by interrupted uploads are not objects and are skipped.
+func (c *SFTPClient) listKeys(prefix string) ([]string, error) {
+	relativePrefix, err := normalizeListKeyPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	startDir := c.root
+	if i := strings.LastIndex(relativePrefix, "/"); i >= 0 {
+		startDir = path.Join(c.root, relativePrefix[:i])
+	}
+
+	var keys []string
+	err = c.retryWithBackoff(func() error {
+		keys = make([]string, 0)
+		return c.withSession(func(client *sftp.Client) error {
+			walker := client.Walk(startDir)
+			for walker.Step() {
+				if err := walker.Err(); err != nil {
+					if walker.Path() == startDir && IsNotFound(err) {
+						return nil
+					}
+					return err
+				}
+				info := walker.Stat()
+				if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), sftpTempFilePrefix) {
+					continue
+				}
+				key, ok := c.keyForPath(walker.Path())
+				if !ok || (relativePrefix != "" && !strings.HasPrefix(key, relativePrefix)) {
+					continue
+				}
+				keys = append(keys, key)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list objects: %w", err)
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (c *SFTPClient) objectPath(key string) (string, error) {
+	cleaned, err := normalizeObjectKey(key)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(c.root, cleaned), nil
+}
+
+func (c *SFTPClient) keyForPath(p string) (string, bool) {
+	rel := p
+	if c.root != "." {
+		rootPrefix := c.root
+		if !strings.HasSuffix(rootPrefix, "/") {
+			rootPrefix += "/"
+		}
+		if !strings.HasPrefix(p, rootPrefix) {
+			return "", false
+		}
+		rel = strings.TrimPrefix(p, rootPrefix)
+	}
+	key, err := normalizeObjectKey(rel)
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+func (c *SFTPClient) currentSession() (*sftpSession, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil {
+		return c.session, nil
+	}
+	if c.dial == nil {
+		return nil, errors.New("sftp client is not configured")
+	}
+	session, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.session = session
+	return session, nil
+}
+
+// withSession runs op on the current session, connecting first if needed.
+// Errors showing the session is gone drop it, so the next attempt reconnects,
+// and are reported as transient.
+func (c *SFTPClient) withSession(op func(*sftp.Client) error) error {
+	session, err := c.currentSession()
+	if err != nil {
+		return err
+	}
+	err = op(session.client)
+	if err == nil || !sftpSessionLost(err) {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.session == session {
+		c.session = nil
+	}
+	c.mu.Unlock()
+	_ = session.close()
+	return &sftpSessionError{err: err}
+}
+
+func sftpSessionLost(err error) bool {
+	return isSFTPConnectionError(err) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+func (c *SFTPClient) retryWithBackoff(op func() error) error {
+	return retryWithBackoff(context.Background(), c.operationMaxAttempts, c.retryBaseDelay, c.retryMaxDelay, c.sleepFn, op)
+}
+
+// putSFTPFile writes body to a temporary file beside objectPath and renames it
+// into place, so an object that exists is always complete.
+func putSFTPFile(client *sftp.Client, objectPath string, body io.Reader) error {
+	dir := path.Dir(objectPath)
+	if err := client.MkdirAll(dir); err != nil {
+		return err
+	}
+
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return err
+	}
+	tmpPath := path.Join(dir, sftpTempFilePrefix+path.Base(objectPath)+"-"+hex.EncodeToString(suffix[:]))
+	f, err := client.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		_ = f.Close()
+		_ = client.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = client.Remove(tmpPath)
+		return err
+	}
+	if err := renameSFTPFile(client, tmpPath, objectPath); err != nil {
+		_ = client.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// renameSFTPFile replaces to with from. Plain SFTP rename refuses to replace
+// an existing file, so without the OpenSSH extension the target is removed
+// first.
+func renameSFTPFile(client *sftp.Client, from, to string) error {
+	if _, ok := client.HasExtension(sftpPosixRenameExt); ok {
+		return client.PosixRename(from, to)
+	}
+	if err := client.Remove(to); err != nil && !IsNotFound(err) {
+		return err
+	}
+	return client.Rename(from, to)
+}
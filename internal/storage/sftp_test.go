@@ -0,0 +1,349 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	appconfig "baxter/internal/config"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSFTPClientStoresListsAndDeletesObjects(t *testing.T) {
+	server := startTestSFTPServer(t)
+	root := filepath.Join(server.dir, "backups")
+	client := newTestSFTPClient(t, server, ssh.FingerprintSHA256(server.hostKey), root)
+
+	if err := client.PutObject("objects/aa/one", []byte("one")); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+	if err := client.PutObjectStream("objects/bb/two", strings.NewReader("two")); err != nil {
+		t.Fatalf("put object stream: %v", err)
+	}
+	if err := client.PutObject("manifest.json.enc", []byte("manifest")); err != nil {
+		t.Fatalf("put manifest: %v", err)
+	}
+	// Replacing an object goes through the same temporary file and rename.
+	if err := client.PutObject("objects/aa/one", []byte("one again")); err != nil {
+		t.Fatalf("replace object: %v", err)
+	}
+	// A temporary file left by an interrupted upload is not an object.
+	if err := os.WriteFile(filepath.Join(root, "objects", "aa", sftpTempFilePrefix+"three-0011"), []byte("partial"), 0o600); err != nil {
+		t.Fatalf("write partial upload: %v", err)
+	}
+
+	got, err := client.GetObject("objects/aa/one")
+	if err != nil {
+		t.Fatalf("get object: %v", err)
+	}
+	if string(got) != "one again" {
+		t.Fatalf("unexpected object body: %q", got)
+	}
+	body, err := client.GetObjectStream("objects/bb/two")
+	if err != nil {
+		t.Fatalf("get object stream: %v", err)
+	}
+	streamed, err := io.ReadAll(body)
+	_ = body.Close()
+	if err != nil || string(streamed) != "two" {
+		t.Fatalf("unexpected streamed body %q: %v", streamed, err)
+	}
+
+	keys, err := client.ListKeys()
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if want := []string{"manifest.json.enc", "objects/aa/one", "objects/bb/two"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("unexpected keys: got %v want %v", keys, want)
+	}
+	keys, err = client.ListKeysWithPrefix("objects/a")
+	if err != nil {
+		t.Fatalf("list keys with prefix: %v", err)
+	}
+	if want := []string{"objects/aa/one"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("unexpected prefixed keys: got %v want %v", keys, want)
+	}
+
+	if exists, err := client.ObjectExists("objects/bb/two"); err != nil || !exists {
+		t.Fatalf("expected object to exist: exists=%t err=%v", exists, err)
+	}
+	if err := client.DeleteObject("objects/bb/two"); err != nil {
+		t.Fatalf("delete object: %v", err)
+	}
+	if err := client.DeleteObject("objects/bb/two"); err != nil {
+		t.Fatalf("delete missing object: %v", err)
+	}
+	if exists, err := client.ObjectExists("objects/bb/two"); err != nil || exists {
+		t.Fatalf("expected object to be gone: exists=%t err=%v", exists, err)
+	}
+	if _, err := client.GetObject("objects/bb/two"); !IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestSFTPClientListsEmptyStoreBeforeFirstUpload(t *testing.T) {
+	server := startTestSFTPServer(t)
+	client := newTestSFTPClient(t, server, string(ssh.MarshalAuthorizedKey(server.hostKey)), filepath.Join(server.dir, "missing"))
+
+	keys, err := client.ListKeys()
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected no keys, got %v", keys)
+	}
+}
+
+func TestSFTPClientRejectsUnpinnedHostKey(t *testing.T) {
+	server := startTestSFTPServer(t)
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	otherPublic, err := ssh.NewPublicKey(otherKey)
+	if err != nil {
+		t.Fatalf("public key: %v", err)
+	}
+
+	for _, pinned := range []string{string(ssh.MarshalAuthorizedKey(otherPublic)), ssh.FingerprintSHA256(otherPublic)} {
+		client := newTestSFTPClient(t, server, pinned, server.dir)
+		err := client.PutObject("objects/aa/one", []byte("one"))
+		if err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+			t.Fatalf("expected host key mismatch for %q, got %v", pinned, err)
+		}
+		if IsTransient(err) {
+			t.Fatalf("host key mismatch must not be retried: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(server.dir, "objects")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written to an unverified server, stat err=%v", err)
+	}
+}
+
+func TestSFTPClientReconnectsAfterSessionLoss(t *testing.T) {
+	server := startTestSFTPServer(t)
+	client := newTestSFTPClient(t, server, ssh.FingerprintSHA256(server.hostKey), server.dir)
+	var sleeps int
+	client.sleepFn = func(time.Duration) { sleeps++ }
+
+	if err := client.PutObject("objects/aa/one", []byte("one")); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+	server.dropConnections()
+
+	got, err := client.GetObject("objects/aa/one")
+	if err != nil {
+		t.Fatalf("get object after reconnect: %v", err)
+	}
+	if string(got) != "one" {
+		t.Fatalf("unexpected object body: %q", got)
+	}
+	if sleeps == 0 {
+		t.Fatal("expected the lost session to be retried after a backoff")
+	}
+	if server.connectionCount() != 2 {
+		t.Fatalf("expected one reconnect, got %d connections", server.connectionCount())
+	}
+}
+
+func TestSFTPErrorClassification(t *testing.T) {
+	if !IsTransient(sftp.ErrSSHFxConnectionLost) {
+		t.Fatal("expected lost connection to be transient")
+	}
+	if !IsTransient(&sftpSessionError{err: io.EOF}) {
+		t.Fatal("expected lost session to be transient")
+	}
+	if IsTransient(sftp.ErrSSHFxPermissionDenied) {
+		t.Fatal("expected permission denied not to be transient")
+	}
+	if !IsNotFound(wrapStorageOperationError("get object", os.ErrNotExist)) {
+		t.Fatal("expected missing file to be not found")
+	}
+}
+
+func TestNewFromConfigReturnsSFTPClientWhenHostSet(t *testing.T) {
+	server := startTestSFTPServer(t)
+	cfg := appconfig.DefaultConfig()
+	cfg.SFTP = server.config(ssh.FingerprintSHA256(server.hostKey), server.dir)
+
+	store, err := NewFromConfig(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("new from config: %v", err)
+	}
+	defer Close(store)
+	if _, ok := store.(*SFTPClient); !ok {
+		t.Fatalf("expected SFTPClient, got %T", store)
+	}
+}
+
+type testSFTPServer struct {
+	dir          string
+	port         int
+	hostKey      ssh.PublicKey
+	identityFile string
+
+	mu    sync.Mutex
+	conns []net.Conn
+	total int
+}
+
+// startTestSFTPServer serves a temporary directory over SFTP on a loopback
+// port, accepting only the key written to identityFile.
+func startTestSFTPServer(t *testing.T) *testSFTPServer {
+	t.Helper()
+
+	_, hostPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivate)
+	if err != nil {
+		t.Fatalf("host signer: %v", err)
+	}
+	_, userPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate user key: %v", err)
+	}
+	userSigner, err := ssh.NewSignerFromKey(userPrivate)
+	if err != nil {
+		t.Fatalf("user signer: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(userPrivate, "")
+	if err != nil {
+		t.Fatalf("marshal user key: %v", err)
+	}
+	identityFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(identityFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("write identity file: %v", err)
+	}
+
+	authorized := userSigner.PublicKey().Marshal()
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == "backup" && bytes.Equal(key.Marshal(), authorized) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized")
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &testSFTPServer{
+		dir:          t.TempDir(),
+		port:         listener.Addr().(*net.TCPAddr).Port,
+		hostKey:      hostSigner.PublicKey(),
+		identityFile: identityFile,
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+		server.dropConnections()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.conns = append(server.conns, conn)
+			server.total++
+			server.mu.Unlock()
+			go server.serve(conn, serverConfig)
+		}
+	}()
+	return server
+}
+
+func (s *testSFTPServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range channelRequests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(s.dir))
+				if err != nil {
+					_ = channel.Close()
+					return
+				}
+				go func() {
+					_ = server.Serve()
+					_ = server.Close()
+				}()
+			}
+		}()
+	}
+}
+
+func (s *testSFTPServer) dropConnections() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.mu.Unlock()
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
+
+func (s *testSFTPServer) connectionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+func (s *testSFTPServer) config(hostKey string, dir string) appconfig.SFTPConfig {
+	return appconfig.SFTPConfig{
+		Host:         "127.0.0.1",
+		Port:         s.port,
+		User:         "backup",
+		IdentityFile: s.identityFile,
+		HostKey:      hostKey,
+		Path:         dir,
+	}
+}
+
+func newTestSFTPClient(t *testing.T, server *testSFTPServer, hostKey string, dir string) *SFTPClient {
+	t.Helper()
+
+	client, err := NewSFTPClient(server.config(hostKey, dir))
+	if err != nil {
+		t.Fatalf("new sftp client: %v", err)
+	}
+	client.sleepFn = nil
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
@@ -1,5 +1,82 @@
 package storage
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	appconfig "baxter/internal/config"
+)
+
+// NewFromConfig returns the object store selected by cfg: S3 when a bucket is
+// set, SFTP when a host is set, WebDAV when a URL is set, and otherwise local
+// storage under localRootDir. With replica destinations configured it returns
+// a ReplicatedStore writing to all of them.
+func NewFromConfig(cfg *appconfig.Config, localRootDir string) (ObjectStore, error) {
+	primary, err := newPrimaryStore(cfg, localRootDir)
+	if err != nil || len(cfg.Destinations) == 0 {
+		return primary, err
+	}
+
+	destinations := []Destination{{Name: appconfig.PrimaryDestinationName, Store: primary}}
+	for _, dest := range cfg.Destinations {
+		store, err := newDestinationStore(dest)
+		if err != nil {
+			for _, created := range destinations {
+				_ = Close(created.Store)
+			}
+			return nil, fmt.Errorf("destination %s: %w", dest.Name, err)
+		}
+		destinations = append(destinations, Destination{Name: dest.Name, Store: store})
+	}
+	return NewReplicatedStore(destinations), nil
+}
+
+// NewDestinationFromConfig returns the single store of the destination called
+// name, either "primary" or a configured replica.
+func NewDestinationFromConfig(cfg *appconfig.Config, localRootDir string, name string) (ObjectStore, error) {
+	name = strings.TrimSpace(name)
+	if name == appconfig.PrimaryDestinationName {
+		return newPrimaryStore(cfg, localRootDir)
+	}
+	for _, dest := range cfg.Destinations {
+		if dest.Name == name {
+			return newDestinationStore(dest)
+		}
+	}
+	return nil, fmt.Errorf("unknown destination %q (configured: %s)", name, strings.Join(cfg.DestinationNames(), ", "))
+}
+
+func newPrimaryStore(cfg *appconfig.Config, localRootDir string) (ObjectStore, error) {
+	switch {
+	case strings.TrimSpace(cfg.S3.Bucket) != "":
+		return NewS3Client(cfg.S3)
+	case strings.TrimSpace(cfg.SFTP.Host) != "":
+		return NewSFTPClient(cfg.SFTP)
+	case strings.TrimSpace(cfg.WebDAV.URL) != "":
+		return NewWebDAVClient(cfg.WebDAV)
+	default:
+		return NewLocalClient(localRootDir), nil
+	}
+}
+
+func newDestinationStore(dest appconfig.DestinationConfig) (ObjectStore, error) {
+	switch {
+	case strings.TrimSpace(dest.S3.Bucket) != "":
+		return NewS3Client(dest.S3)
+	case strings.TrimSpace(dest.SFTP.Host) != "":
+		return NewSFTPClient(dest.SFTP)
+	case strings.TrimSpace(dest.WebDAV.URL) != "":
+		return NewWebDAVClient(dest.WebDAV)
+	case strings.TrimSpace(dest.LocalPath) != "":
+		return NewLocalClient(dest.LocalPath), nil
+	default:
+		return nil, errors.New("no storage backend set")
+	}
+}
+
 type ObjectStore interface {
 	PutObject(key string, data []byte) error
 	GetObject(key string) ([]byte, error)
@@ -10,3 +87,70 @@ type ObjectStore interface {
 type PrefixKeyLister interface {
 	ListKeysWithPrefix(prefix string) ([]string, error)
 }
+
+// StreamObjectStore is implemented by stores that can transfer object bodies
+// without buffering them in memory.
+type StreamObjectStore interface {
+	PutObjectStream(key string, body io.Reader) error
+	GetObjectStream(key string) (io.ReadCloser, error)
+}
+
+// ObjectExistsStore is implemented by stores that can check for an object
+// without transferring its body.
+type ObjectExistsStore interface {
+	ObjectExists(key string) (bool, error)
+}
+
+// ObjectExists reports whether key is present in the store. Stores without a
+// cheap existence check report false, so callers fall back to uploading.
+func ObjectExists(store ObjectStore, key string) (bool, error) {
+	if checker, ok := store.(ObjectExistsStore); ok {
+		return checker.ObjectExists(key)
+	}
+	return false, nil
+}
+
+// PutObjectStream uploads body through the store's streaming API, falling
+// back to a buffered PutObject for stores that do not implement one.
+func PutObjectStream(store ObjectStore, key string, body io.Reader) error {
+	if streamer, ok := store.(StreamObjectStore); ok {
+		return streamer.PutObjectStream(key, body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	return store.PutObject(key, data)
+}
+
+// GetObjectStream opens an object body through the store's streaming API,
+// falling back to a buffered GetObject for stores that do not implement one.
+func GetObjectStream(store ObjectStore, key string) (io.ReadCloser, error) {
+	if streamer, ok := store.(StreamObjectStore); ok {
+		return streamer.GetObjectStream(key)
+	}
+	data, err := store.GetObject(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Close releases connections held by stores that keep them open, such as
+// SFTPClient. It is a no-op for other stores.
+func Close(store ObjectStore) error {
+	if closer, ok := store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// CopyObject streams the object under key from src to dst unchanged.
+func CopyObject(dst ObjectStore, src ObjectStore, key string) error {
+	body, err := GetObjectStream(src, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return PutObjectStream(dst, key, body)
+}
@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"io"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -56,3 +60,103 @@ func TestLocalClientPutGetListDelete(t *testing.T) {
 		t.Fatalf("keys after delete mismatch: got %v want %v", keys, want)
 	}
 }
+
+func TestLocalClientStreamRoundTrip(t *testing.T) {
+	c := NewLocalClient(t.TempDir())
+
+	if err := c.PutObjectStream("a/streamed", strings.NewReader("streamed body")); err != nil {
+		t.Fatalf("put stream failed: %v", err)
+	}
+	body, err := GetObjectStream(c, "a/streamed")
+	if err != nil {
+		t.Fatalf("get stream failed: %v", err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read stream failed: %v", err)
+	}
+	if string(got) != "streamed body" {
+		t.Fatalf("unexpected object body: %q", string(got))
+	}
+
+	keys, err := c.ListKeys()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if want := []string{"a/streamed"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("keys mismatch: got %v want %v", keys, want)
+	}
+}
+
+type bufferedOnlyStore struct {
+	ObjectStore
+}
+
+func TestStreamHelpersFallBackToBufferedStores(t *testing.T) {
+	store := bufferedOnlyStore{ObjectStore: NewLocalClient(t.TempDir())}
+	if _, ok := ObjectStore(store).(StreamObjectStore); ok {
+		t.Fatal("test store should not implement streaming")
+	}
+
+	if err := PutObjectStream(store, "fallback", strings.NewReader("buffered")); err != nil {
+		t.Fatalf("put stream fallback failed: %v", err)
+	}
+	body, err := GetObjectStream(store, "fallback")
+	if err != nil {
+		t.Fatalf("get stream fallback failed: %v", err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read stream fallback failed: %v", err)
+	}
+	if string(got) != "buffered" {
+		t.Fatalf("unexpected object body: %q", string(got))
+	}
+}
+
+func TestObjectExistsUsesStoreCheckOrReportsMissing(t *testing.T) {
+	local := NewLocalClient(t.TempDir())
+	if err := local.PutObject("dir/a", []byte("payload")); err != nil {
+		t.Fatalf("seed object: %v", err)
+	}
+
+	if exists, err := ObjectExists(local, "dir/a"); err != nil || !exists {
+		t.Fatalf("expected local object to exist, got exists=%v err=%v", exists, err)
+	}
+	for _, key := range []string{"dir/b", "dir"} {
+		if exists, err := ObjectExists(local, key); err != nil || exists {
+			t.Fatalf("expected %q to be missing, got exists=%v err=%v", key, exists, err)
+		}
+	}
+	if exists, err := ObjectExists(bufferedOnlyStore{ObjectStore: local}, "dir/a"); err != nil || exists {
+		t.Fatalf("stores without an existence check should report missing, got exists=%v err=%v", exists, err)
+	}
+}
+
+func TestWithContextStopsBufferedAndStreamingOperations(t *testing.T) {
+	local := NewLocalClient(t.TempDir())
+	if err := local.PutObject("a", []byte("payload")); err != nil {
+		t.Fatalf("seed object: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	store := WithContext(ctx, bufferedOnlyStore{ObjectStore: local})
+
+	body, err := GetObjectStream(store, "a")
+	if err != nil {
+		t.Fatalf("open stream before cancel: %v", err)
+	}
+	defer body.Close()
+
+	cancel()
+	if _, err := io.ReadAll(body); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected stream read to observe cancellation, got %v", err)
+	}
+	if err := store.PutObject("b", []byte("x")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected put to observe cancellation, got %v", err)
+	}
+	if _, err := local.GetObject("b"); err == nil {
+		t.Fatal("cancelled put reached the underlying store")
+	}
+}
@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	appconfig "baxter/internal/config"
+)
+
+// throttleChunkSize caps how much a throttled read transfers before waiting
+// for the limiter, so pacing stays smooth at low rates.
+const throttleChunkSize = 32 << 10
+
+// BandwidthLimiter paces transfers to and from object stores with one token
+// bucket per direction, shared by every store it throttles so concurrent
+// uploads split the limit between them. Limits follow the time windows of a
+// bandwidth config unless an override is set.
+type BandwidthLimiter struct {
+	mu       sync.Mutex
+	schedule appconfig.BandwidthConfig
+	override *bandwidthOverride
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+
+	// limits is the schedule's answer for the minute limitsAt.
+	limits   appconfig.BandwidthLimits
+	window   string
+	limitsAt time.Time
+
+	upload   tokenBucket
+	download tokenBucket
+}
+
+type bandwidthOverride struct {
+	limits appconfig.BandwidthLimits
+	until  time.Time
+}
+
+// BandwidthStatus describes the limits in effect.
+type BandwidthStatus struct {
+	Limits appconfig.BandwidthLimits
+	// Window is the schedule window in effect, "" outside every window or
+	// while an override is set.
+	Window        string
+	Override      bool
+	OverrideUntil time.Time
+}
+
+func NewBandwidthLimiter(cfg appconfig.BandwidthConfig) *BandwidthLimiter {
+	return &BandwidthLimiter{
+		schedule: cfg,
+		now:      time.Now,
+		sleep:    sleepContext,
+	}
+}
+
+// SetSchedule replaces the configured limits, e.g. after a config reload. An
+// override stays in effect.
+func (l *BandwidthLimiter) SetSchedule(cfg appconfig.BandwidthConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.schedule = cfg
+	l.limitsAt = time.Time{}
+}
+
+// SetOverride applies limits instead of the schedule until the given time,
+// or until ClearOverride when until is zero.
+func (l *BandwidthLimiter) SetOverride(limits appconfig.BandwidthLimits, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.override = &bandwidthOverride{limits: limits, until: until}
+}
+
+// ClearOverride returns to the scheduled limits.
+func (l *BandwidthLimiter) ClearOverride() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.override = nil
+}
+
+func (l *BandwidthLimiter) Status() BandwidthStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.currentLocked(l.now())
+}
+
+func (l *BandwidthLimiter) currentLocked(now time.Time) BandwidthStatus {
+	if l.override != nil && !l.override.until.IsZero() && !now.Before(l.override.until) {
+		l.override = nil
+	}
+	if l.override != nil {
+		return BandwidthStatus{Limits: l.override.limits, Override: true, OverrideUntil: l.override.until}
+	}
+	minute := now.Truncate(time.Minute)
+	if !l.limitsAt.Equal(minute) {
+		l.limits, l.window = l.schedule.LimitsAt(now)
+		l.limitsAt = minute
+	}
+	return BandwidthStatus{Limits: l.limits, Window: l.window}
+}
+
+// wait blocks until n bytes may be transferred in the given direction.
+func (l *BandwidthLimiter) wait(ctx context.Context, upload bool, n int) error {
+	l.mu.Lock()
+	now := l.now()
+	limits := l.currentLocked(now).Limits
+	var delay time.Duration
+	if upload {
+		delay = l.upload.reserve(n, limits.Upload, now)
+	} else {
+		delay = l.download.reserve(n, limits.Download, now)
+	}
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	return l.sleep(ctx, delay)
+}
+
+// tokenBucket holds up to one second of transfer at its rate. Taking more
+// than it holds leaves it in debt, which later callers wait out first.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) reserve(n int, rate int64, now time.Time) time.Duration {
+	if rate <= 0 {
+		*b = tokenBucket{}
+		return 0
+	}
+	burst := float64(rate)
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(burst, b.tokens+elapsed.Seconds()*float64(rate))
+	}
+	b.last = now
+	b.tokens = min(b.tokens, burst) - float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(rate) * float64(time.Second))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Throttle returns a view of store whose transfers are paced by l. Each
+// destination of a ReplicatedStore is paced, so the view is still a
+// ReplicatedStore; local storage is not paced. Stores are wrapped even while
+// no limit applies, so an override set during a run paces its transfers at
+// once.
+func (l *BandwidthLimiter) Throttle(store ObjectStore) ObjectStore {
+	switch s := store.(type) {
+	case nil:
+		return nil
+	case *ReplicatedStore:
+		return s.mapDestinations(l.Throttle)
+	case *LocalClient, *throttledStore:
+		return store
+	default:
+		return &throttledStore{ctx: context.Background(), inner: store, limiter: l}
+	}
+}
+
+type throttledStore struct {
+	ctx     context.Context
+	inner   ObjectStore
+	limiter *BandwidthLimiter
+}
+
+// WithContext binds the inner store to ctx and stops waiting for the limiter
+// once ctx is done.
+func (s *throttledStore) WithContext(ctx context.Context) ObjectStore {
+	return &throttledStore{ctx: ctx, inner: WithContext(ctx, s.inner), limiter: s.limiter}
+}
+
+// PutObject waits for the whole object before handing it to the inner store,
+// which keeps its own retries.
+func (s *throttledStore) PutObject(key string, data []byte) error {
+	if err := s.limiter.wait(s.ctx, true, len(data)); err != nil {
+		return err
+	}
+	return s.inner.PutObject(key, data)
+}
+
+// GetObject reads the object from the inner store, which keeps its own
+// retries, and then waits for its size.
+func (s *throttledStore) GetObject(key string) ([]byte, error) {
+	data, err := s.inner.GetObject(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.limiter.wait(s.ctx, false, len(data)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *throttledStore) DeleteObject(key string) error {
+	return s.inner.DeleteObject(key)
+}
+
+func (s *throttledStore) ListKeys() ([]string, error) {
+	return s.inner.ListKeys()
+}
+
+func (s *throttledStore) ListKeysWithPrefix(prefix string) ([]string, error) {
+	if lister, ok := s.inner.(PrefixKeyLister); ok {
+		return lister.ListKeysWithPrefix(prefix)
+	}
+	keys, err := s.inner.ListKeys()
+	if err != nil {
+		return nil, err
+	}
+	matching := keys[:0]
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			matching = append(matching, key)
+		}
+	}
+	return matching, nil
+}
+
+func (s *throttledStore) ObjectExists(key string) (bool, error) {
+	return ObjectExists(s.inner, key)
+}
+
+func (s *throttledStore) PutObjectStream(key string, body io.Reader) error {
+	return PutObjectStream(s.inner, key, &throttledReader{ctx: s.ctx, limiter: s.limiter, upload: true, r: body})
+}
+
+func (s *throttledStore) GetObjectStream(key string) (io.ReadCloser, error) {
+	body, err := GetObjectStream(s.inner, key)
+	if err != nil {
+		return nil, err
+	}
+	return &throttledReadCloser{throttledReader: throttledReader{ctx: s.ctx, limiter: s.limiter, r: body}, closer: body}, nil
+}
+
+func (s *throttledStore) Close() error {
+	return Close(s.inner)
+}
+
+type throttledReader struct {
+	ctx     context.Context
+	limiter *BandwidthLimiter
+	upload  bool
+	r       io.Reader
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunkSize {
+		p = p[:throttleChunkSize]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, r.upload, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type throttledReadCloser struct {
+	throttledReader
+	closer io.Closer
+}
+
+func (r *throttledReadCloser) Close() error {
+	return r.closer.Close()
+}
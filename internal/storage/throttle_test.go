@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	appconfig "baxter/internal/config"
+)
+
+// remoteStore stands in for a network store; local storage is not throttled.
+type remoteStore struct {
+	*LocalClient
+}
+
+// newTestLimiter returns a limiter on a fake clock that advances by the
+// delays it asks to wait, and the delays it waited.
+func newTestLimiter(cfg appconfig.BandwidthConfig, start time.Time) (*BandwidthLimiter, *time.Time, *[]time.Duration) {
+	now := start
+	var waits []time.Duration
+	limiter := NewBandwidthLimiter(cfg)
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		now = now.Add(d)
+		return nil
+	}
+	return limiter, &now, &waits
+}
+
+func TestThrottledStorePacesUploadsAndDownloads(t *testing.T) {
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	limiter, now, waits := newTestLimiter(appconfig.BandwidthConfig{UploadLimit: "64KiB/s", DownloadLimit: "128KiB/s"}, start)
+	store := limiter.Throttle(&remoteStore{NewLocalClient(filepath.Join(t.TempDir(), "objects"))})
+
+	payload := bytes.Repeat([]byte("x"), 256<<10)
+	if err := store.PutObject("a", payload); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	// The first second of transfer is a burst; the rest is paced.
+	if elapsed := now.Sub(start); elapsed != 3*time.Second {
+		t.Fatalf("upload took %s, want 3s (waits %v)", elapsed, *waits)
+	}
+
+	uploaded := *now
+	got, err := store.GetObject("a")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatal("downloaded payload differs")
+	}
+	if elapsed := now.Sub(uploaded); elapsed != time.Second {
+		t.Fatalf("download took %s, want 1s", elapsed)
+	}
+}
+
+func TestBandwidthLimiterFollowsScheduleAndOverride(t *testing.T) {
+	cfg := appconfig.BandwidthConfig{
+		UploadLimit: "10MB/s",
+		Windows:     []appconfig.BandwidthWindow{{Start: "09:00", End: "18:00", UploadLimit: "2MB/s"}},
+	}
+	limiter, now, _ := newTestLimiter(cfg, time.Date(2026, 3, 2, 8, 59, 0, 0, time.UTC))
+
+	if got := limiter.Status(); got.Limits.Upload != 10_000_000 || got.Window != "" {
+		t.Fatalf("unexpected status before window: %+v", got)
+	}
+	*now = now.Add(time.Minute)
+	if got := limiter.Status(); got.Limits.Upload != 2_000_000 || got.Window != "09:00-18:00" {
+		t.Fatalf("unexpected status in window: %+v", got)
+	}
+
+	until := now.Add(time.Hour)
+	limiter.SetOverride(appconfig.BandwidthLimits{Upload: 500_000}, until)
+	want := BandwidthStatus{Limits: appconfig.BandwidthLimits{Upload: 500_000}, Override: true, OverrideUntil: until}
+	if got := limiter.Status(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected status with override: got %+v want %+v", got, want)
+	}
+	*now = until
+	if got := limiter.Status(); got.Override || got.Limits.Upload != 2_000_000 {
+		t.Fatalf("expected override to expire, got %+v", got)
+	}
+
+	limiter.SetOverride(appconfig.BandwidthLimits{}, time.Time{})
+	limiter.SetSchedule(appconfig.BandwidthConfig{UploadLimit: "1MB/s"})
+	if got := limiter.Status(); !got.Override || got.Limits.Upload != 0 {
+		t.Fatalf("expected override to outlast schedule change, got %+v", got)
+	}
+	limiter.ClearOverride()
+	if got := limiter.Status(); got.Override || got.Limits.Upload != 1_000_000 {
+		t.Fatalf("expected new schedule after clearing override, got %+v", got)
+	}
+}
+
+func TestThrottleWrapsReplicatedDestinations(t *testing.T) {
+	remote := &remoteStore{NewLocalClient(filepath.Join(t.TempDir(), "remote"))}
+	local := NewLocalClient(filepath.Join(t.TempDir(), "local"))
+	replicated := NewReplicatedStore([]Destination{{Name: "primary", Store: local}, {Name: "offsite", Store: remote}})
+	limiter := NewBandwidthLimiter(appconfig.BandwidthConfig{DownloadLimit: "1MB/s"})
+
+	throttled, ok := limiter.Throttle(replicated).(*ReplicatedStore)
+	if !ok {
+		t.Fatal("expected throttling to keep the replicated store")
+	}
+	destinations := throttled.Destinations()
+	if destinations[0].Store != local {
+		t.Fatalf("expected local destination to be left alone, got %T", destinations[0].Store)
+	}
+	if _, ok := destinations[1].Store.(*throttledStore); !ok {
+		t.Fatalf("expected remote destination to be throttled, got %T", destinations[1].Store)
+	}
+
+	throttled.MarkFailed("offsite", errors.New("unreachable"))
+	if statuses := replicated.Statuses(); !statuses[1].Failed {
+		t.Fatalf("expected the throttled view to share failures, got %+v", statuses)
+	}
+}
+
+// countingRemoteStore fails streamed transfers, so a throttled store must use
+// the buffered methods that carry the inner store's retries.
+type countingRemoteStore struct {
+	*remoteStore
+	puts, gets int
+}
+
+func (s *countingRemoteStore) PutObject(key string, data []byte) error {
+	s.puts++
+	return s.remoteStore.PutObject(key, data)
+}
+
+func (s *countingRemoteStore) GetObject(key string) ([]byte, error) {
+	s.gets++
+	return s.remoteStore.GetObject(key)
+}
+
+func (s *countingRemoteStore) PutObjectStream(string, io.Reader) error {
+	return errors.New("streamed put")
+}
+
+func (s *countingRemoteStore) GetObjectStream(string) (io.ReadCloser, error) {
+	return nil, errors.New("streamed get")
+}
+
+func TestThrottledStoreKeepsBufferedTransfers(t *testing.T) {
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	limiter, now, _ := newTestLimiter(appconfig.BandwidthConfig{UploadLimit: "64KiB/s"}, start)
+	inner := &countingRemoteStore{remoteStore: &remoteStore{NewLocalClient(filepath.Join(t.TempDir(), "objects"))}}
+	store := limiter.Throttle(inner)
+
+	payload := bytes.Repeat([]byte("x"), 128<<10)
+	if err := store.PutObject("a", payload); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if _, err := store.GetObject("a"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if inner.puts != 1 || inner.gets != 1 {
+		t.Fatalf("expected buffered transfers, got puts=%d gets=%d", inner.puts, inner.gets)
+	}
+	if elapsed := now.Sub(start); elapsed != time.Second {
+		t.Fatalf("upload took %s, want 1s", elapsed)
+	}
+}
+
+func TestThrottleAppliesOverrideSetDuringAnUnlimitedRun(t *testing.T) {
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	limiter, now, waits := newTestLimiter(appconfig.BandwidthConfig{}, start)
+	store := limiter.Throttle(&remoteStore{NewLocalClient(filepath.Join(t.TempDir(), "objects"))})
+
+	payload := bytes.Repeat([]byte("x"), 128<<10)
+	if err := store.PutObject("a", payload); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if len(*waits) != 0 {
+		t.Fatalf("expected no pacing without limits, waited %v", *waits)
+	}
+
+	limiter.SetOverride(appconfig.BandwidthLimits{Upload: 64 << 10}, time.Time{})
+	overridden := *now
+	if err := store.PutObject("b", payload); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := store.PutObject("c", payload); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	// A burst of one second, then 192KiB more at 64KiB/s.
+	if elapsed := now.Sub(overridden); elapsed != 3*time.Second {
+		t.Fatalf("uploads after the override took %s, want 3s (waits %v)", elapsed, *waits)
+	}
+	if start != overridden {
+		t.Fatalf("unexpected wait before the override: %s", overridden.Sub(start))
+	}
+}
+
+func TestThrottledStoreStopsWaitingWhenContextIsDone(t *testing.T) {
+	limiter := NewBandwidthLimiter(appconfig.BandwidthConfig{UploadLimit: "1KB/s"})
+	store := limiter.Throttle(&remoteStore{NewLocalClient(filepath.Join(t.TempDir(), "objects"))})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// 4 KB at 1 KB/s waits about three seconds past the burst.
+	started := time.Now()
+	err := WithContext(ctx, store).PutObject("a", bytes.Repeat([]byte("x"), 4096))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to be cut short, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("put waited %s after the context was done", elapsed)
+	}
+}
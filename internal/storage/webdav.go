@@ -0,0 +1,495 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appconfig "baxter/internal/config"
+	"baxter/internal/crypto"
+)
+
+const webdavPasswordEnv = "BAXTER_WEBDAV_PASSWORD"
+
+const webdavPropfindBody = `<?xml version="1.0" encoding="utf-8"?>` +
+	`<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/></d:prop></d:propfind>`
+
+var webdavPasswordFromKeychain = crypto.PassphraseFromKeychain
+
+// WebDAVClient stores objects as files under a WebDAV collection, creating
+// parent collections with MKCOL as needed. It relies on the server storing a
+// PUT body atomically, as Nextcloud, ownCloud and Apache mod_dav do.
+type WebDAVClient struct {
+	httpClient           *http.Client
+	baseURL              *url.URL
+	username             string
+	password             string
+	collections          *webdavCollectionCache
+	operationMaxAttempts int
+	retryBaseDelay       time.Duration
+	retryMaxDelay        time.Duration
+	sleepFn              func(time.Duration)
+	ctx                  context.Context
+}
+
+// webdavCollectionCache remembers collections known to exist, so uploads
+// only issue MKCOL for a directory once per client.
+type webdavCollectionCache struct {
+	mu    sync.Mutex
+	known map[string]bool
+}
+
+func (c *webdavCollectionCache) has(collection string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.known[collection]
+}
+
+func (c *webdavCollectionCache) add(collection string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.known[collection] = true
+}
+
+// WebDAVStatusError is a WebDAV response with an unexpected status. Its
+// HTTPStatusCode drives IsNotFound and IsTransient.
+type WebDAVStatusError struct {
+	Method     string
+	Key        string
+	StatusCode int
+}
+
+func (e *WebDAVStatusError) Error() string {
+	return fmt.Sprintf("webdav %s %s: %d %s", e.Method, e.Key, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *WebDAVStatusError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+func NewWebDAVClient(cfg appconfig.WebDAVConfig) (*WebDAVClient, error) {
+	rawURL := strings.TrimSpace(cfg.URL)
+	if rawURL == "" {
+		return nil, errors.New("webdav url is required")
+	}
+	if !strings.HasSuffix(rawURL, "/") {
+		rawURL += "/"
+	}
+	baseURL, err := url.Parse(rawURL)
+	if err != nil || baseURL.Host == "" {
+		return nil, errors.New("webdav url must be a valid http(s) URL")
+	}
+	if scheme := strings.ToLower(baseURL.Scheme); scheme != "http" && scheme != "https" {
+		return nil, errors.New("webdav url must use http or https")
+	}
+
+	password, err := webdavPassword(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebDAVClient{
+		httpClient:           &http.Client{},
+		baseURL:              baseURL,
+		username:             strings.TrimSpace(cfg.Username),
+		password:             password,
+		collections:          &webdavCollectionCache{known: make(map[string]bool)},
+		operationMaxAttempts: defaultOperationMaxAttempts,
+		retryBaseDelay:       defaultRetryBaseDelay,
+		retryMaxDelay:        defaultRetryMaxDelay,
+		sleepFn:              time.Sleep,
+	}, nil
+}
+
+func webdavPassword(cfg appconfig.WebDAVConfig) (string, error) {
+	if password := os.Getenv(webdavPasswordEnv); password != "" {
+		return password, nil
+	}
+	if strings.TrimSpace(cfg.KeychainService) == "" {
+		return "", nil
+	}
+	password, err := webdavPasswordFromKeychain(cfg.KeychainService, cfg.KeychainAccount)
+	if err != nil {
+		return "", fmt.Errorf("no %s set and keychain lookup failed: %w", webdavPasswordEnv, err)
+	}
+	return password, nil
+}
+
+// WithContext returns a copy of the client whose requests, retries included,
+// are bound to ctx.
+func (c *WebDAVClient) WithContext(ctx context.Context) ObjectStore {
+	if c == nil {
+		return c
+	}
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+func (c *WebDAVClient) requestContext() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+func (c *WebDAVClient) PutObject(key string, data []byte) error {
+	objectKey, err := normalizeObjectKey(key)
+	if err != nil {
+		return err
+	}
+	err = c.retryWithBackoff(func() error {
+		return c.put(objectKey, bytes.NewReader(data), int64(len(data)))
+	})
+	if err != nil {
+		return wrapStorageOperationError("put object", err)
+	}
+	return nil
+}
+
+func (c *WebDAVClient) GetObject(key string) ([]byte, error) {
+	objectKey, err := normalizeObjectKey(key)
+	if err != nil {
+		return nil, err
+	}
+	var payload []byte
+	err = c.retryWithBackoff(func() error {
+		body, err := c.get(objectKey)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		buf := new(bytes.Buffer)
+		if _, err := buf.ReadFrom(body); err != nil {
+			return fmt.Errorf("read object body: %w", err)
+		}
+		payload = buf.Bytes()
+		return nil
+	})
+	if err != nil {
+		return nil, wrapStorageOperationError("get object", err)
+	}
+	return payload, nil
+}
+
+// PutObjectStream uploads body with chunked transfer encoding. The body
+// cannot be replayed, so transient failures are returned to the caller rather
+// than retried here.
+func (c *WebDAVClient) PutObjectStream(key string, body io.Reader) error {
+	objectKey, err := normalizeObjectKey(key)
+	if err != nil {
+		return err
+	}
+	if err := c.put(objectKey, body, -1); err != nil {
+		return wrapStorageOperationError("put object", err)
+	}
+	return nil
+}
+
+// GetObjectStream retries opening the object but returns the body unread;
+// failures while reading it surface from the returned reader.
+func (c *WebDAVClient) GetObjectStream(key string) (io.ReadCloser, error) {
+	objectKey, err := normalizeObjectKey(key)
+	if err != nil {
+		return nil, err
+	}
+	var body io.ReadCloser
+	err = c.retryWithBackoff(func() error {
+		opened, err := c.get(objectKey)
+		if err != nil {
+			return err
+		}
+		body = opened
+		return nil
+	})
+	if err != nil {
+		return nil, wrapStorageOperationError("get object", err)
+	}
+	return body, nil
+}
+
+// ObjectExists issues a HEAD request for key; a missing object is reported as
+// false rather than as an error.
+func (c *WebDAVClient) ObjectExists(key string) (bool, error) {
+	objectKey, err := normalizeObjectKey(key)
+	if err != nil {
+		return false, err
+	}
+	exists := false
+	err = c.retryWithBackoff(func() error {
+		resp, err := c.do(http.MethodHead, objectKey, nil, -1, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			exists = false
+		case resp.StatusCode/100 == 2:
+			exists = true
+		default:
+			return &WebDAVStatusError{Method: http.MethodHead, Key: objectKey, StatusCode: resp.StatusCode}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, wrapStorageOperationError("head object", err)
+	}
+	return exists, nil
+}
+
+func (c *WebDAVClient) DeleteObject(key string) error {
+	objectKey, err := normalizeObjectKey(key)
+	if err != nil {
+		return err
+	}
+	err = c.retryWithBackoff(func() error {
+		resp, err := c.do(http.MethodDelete, objectKey, nil, -1, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+			return &WebDAVStatusError{Method: http.MethodDelete, Key: objectKey, StatusCode: resp.StatusCode}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete object: %w", err)
+	}
+	return nil
+}
+
+func (c *WebDAVClient) ListKeys() ([]string, error) {
+	return c.listKeys("")
+}
+
+func (c *WebDAVClient) ListKeysWithPrefix(prefix string) ([]string, error) {
+	return c.listKeys(prefix)
+}
+
+// listKeys walks collections with depth-1 PROPFIND requests, since many
+// servers refuse infinite depth, starting at the collection holding prefix.
+func (c *WebDAVClient) listKeys(prefix string) ([]string, error) {
+	relativePrefix, err := normalizeListKeyPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	start := ""
+	if i := strings.LastIndex(relativePrefix, "/"); i >= 0 {
+		start = relativePrefix[:i+1]
+	}
+
+	keys := make([]string, 0)
+	pending := []string{start}
+	for len(pending) > 0 {
+		collection := pending[0]
+		pending = pending[1:]
+
+		var children []webdavChild
+		err := c.retryWithBackoff(func() error {
+			var err error
+			children, err = c.propfind(collection)
+			return err
+		})
+		if err != nil {
+			if collection == start && IsNotFound(err) {
+				break
+			}
+			return nil, fmt.Errorf("list objects: %w", wrapStorageOperationError("propfind", err))
+		}
+		for _, child := range children {
+			if relativePrefix != "" && !strings.HasPrefix(child.key, relativePrefix) && !strings.HasPrefix(relativePrefix, child.key) {
+				continue
+			}
+			if child.collection {
+				pending = append(pending, child.key)
+				continue
+			}
+			if strings.HasPrefix(child.key, relativePrefix) {
+				keys = append(keys, child.key)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+type webdavChild struct {
+	// key is relative to the base collection; collections end in "/".
+	key        string
+	collection bool
+}
+
+type webdavMultistatus struct {
+	Responses []webdavResponse `xml:"DAV: response"`
+}
+
+type webdavResponse struct {
+	Href      string           `xml:"DAV: href"`
+	Propstats []webdavPropstat `xml:"DAV: propstat"`
+}
+
+type webdavPropstat struct {
+	Status       string `xml:"DAV: status"`
+	ResourceType struct {
+		Collection *struct{} `xml:"DAV: collection"`
+	} `xml:"DAV: prop>resourcetype"`
+}
+
+// propfind lists the members of collection, given relative to the base
+// collection with a trailing slash ("" for the base itself).
+func (c *WebDAVClient) propfind(collection string) ([]webdavChild, error) {
+	resp, err := c.do("PROPFIND", collection, strings.NewReader(webdavPropfindBody), int64(len(webdavPropfindBody)), map[string]string{
+		"Depth":        "1",
+		"Content-Type": `application/xml; charset="utf-8"`,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, &WebDAVStatusError{Method: "PROPFIND", Key: collection, StatusCode: resp.StatusCode}
+	}
+
+	var multistatus webdavMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&multistatus); err != nil {
+		return nil, fmt.Errorf("decode propfind response: %w", err)
+	}
+	children := make([]webdavChild, 0, len(multistatus.Responses))
+	for _, response := range multistatus.Responses {
+		key, ok := c.keyForHref(response.Href)
+		if !ok {
+			continue
+		}
+		isCollection := false
+		for _, propstat := range response.Propstats {
+			if propstat.ResourceType.Collection != nil {
+				isCollection = true
+			}
+		}
+		if isCollection && key != "" && !strings.HasSuffix(key, "/") {
+			key += "/"
+		}
+		// The collection itself is listed too, with or without a slash.
+		if key == collection || key == "" {
+			continue
+		}
+		if !isCollection {
+			normalized, err := normalizeObjectKey(key)
+			if err != nil {
+				continue
+			}
+			key = normalized
+		}
+		children = append(children, webdavChild{key: key, collection: isCollection})
+	}
+	return children, nil
+}
+
+// keyForHref converts a response href, either a path or a full URL, into a
+// key relative to the base collection.
+func (c *WebDAVClient) keyForHref(href string) (string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+	hrefPath := parsed.Path
+	basePath := c.baseURL.Path
+	if !strings.HasPrefix(hrefPath, basePath) {
+		return "", false
+	}
+	return strings.TrimPrefix(hrefPath, basePath), true
+}
+
+func (c *WebDAVClient) put(objectKey string, body io.Reader, contentLength int64) error {
+	if err := c.ensureCollections(objectKey); err != nil {
+		return err
+	}
+	resp, err := c.do(http.MethodPut, objectKey, body, contentLength, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return &WebDAVStatusError{Method: http.MethodPut, Key: objectKey, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+func (c *WebDAVClient) get(objectKey string) (io.ReadCloser, error) {
+	resp, err := c.do(http.MethodGet, objectKey, nil, -1, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &WebDAVStatusError{Method: http.MethodGet, Key: objectKey, StatusCode: resp.StatusCode}
+	}
+	return resp.Body, nil
+}
+
+// ensureCollections creates the base collection and every parent of
+// objectKey, top down. MKCOL answers 405 for a collection that already exists.
+func (c *WebDAVClient) ensureCollections(objectKey string) error {
+	collections := []string{""}
+	dir := path.Dir(objectKey)
+	if dir != "." {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			collections = append(collections, strings.Join(parts[:i+1], "/")+"/")
+		}
+	}
+	for _, collection := range collections {
+		if c.collections.has(collection) {
+			continue
+		}
+		resp, err := c.do("MKCOL", collection, nil, -1, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusMethodNotAllowed {
+			return &WebDAVStatusError{Method: "MKCOL", Key: collection, StatusCode: resp.StatusCode}
+		}
+		c.collections.add(collection)
+	}
+	return nil
+}
+
+// do sends one request for a key or collection relative to the base URL. A
+// negative contentLength sends the body chunked.
+func (c *WebDAVClient) do(method string, relative string, body io.Reader, contentLength int64, headers map[string]string) (*http.Response, error) {
+	target := c.baseURL.ResolveReference(&url.URL{Path: relative})
+	req, err := http.NewRequestWithContext(c.requestContext(), method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil && contentLength >= 0 {
+		req.ContentLength = contentLength
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return c.httpClient.Do(req)
+}
+
+func (c *WebDAVClient) retryWithBackoff(op func() error) error {
+	return retryWithBackoff(c.requestContext(), c.operationMaxAttempts, c.retryBaseDelay, c.retryMaxDelay, c.sleepFn, op)
+}
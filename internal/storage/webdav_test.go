@@ -0,0 +1,408 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	appconfig "baxter/internal/config"
+)
+
+func TestWebDAVClientStoresListsAndDeletesObjects(t *testing.T) {
+	server := startTestWebDAVServer(t)
+	client := newTestWebDAVClient(t, server)
+
+	if err := client.PutObject("objects/aa/one", []byte("one")); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+	if err := client.PutObjectStream("objects/bb/two", strings.NewReader("two")); err != nil {
+		t.Fatalf("put object stream: %v", err)
+	}
+	if err := client.PutObject("manifest.json.enc", []byte("manifest")); err != nil {
+		t.Fatalf("put manifest: %v", err)
+	}
+	if err := client.PutObject("objects/aa/with space", []byte("spaced")); err != nil {
+		t.Fatalf("put object with space: %v", err)
+	}
+	if !server.hasCollection("/dav/baxter/objects/aa/") {
+		t.Fatal("expected parent collections to be created with MKCOL")
+	}
+
+	got, err := client.GetObject("objects/aa/one")
+	if err != nil {
+		t.Fatalf("get object: %v", err)
+	}
+	if string(got) != "one" {
+		t.Fatalf("unexpected object body: %q", got)
+	}
+	body, err := client.GetObjectStream("objects/bb/two")
+	if err != nil {
+		t.Fatalf("get object stream: %v", err)
+	}
+	streamed, err := io.ReadAll(body)
+	_ = body.Close()
+	if err != nil || string(streamed) != "two" {
+		t.Fatalf("unexpected streamed body %q: %v", streamed, err)
+	}
+
+	keys, err := client.ListKeys()
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if want := []string{"manifest.json.enc", "objects/aa/one", "objects/aa/with space", "objects/bb/two"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("unexpected keys: got %v want %v", keys, want)
+	}
+	keys, err = client.ListKeysWithPrefix("objects/a")
+	if err != nil {
+		t.Fatalf("list keys with prefix: %v", err)
+	}
+	if want := []string{"objects/aa/one", "objects/aa/with space"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("unexpected prefixed keys: got %v want %v", keys, want)
+	}
+	keys, err = client.ListKeysWithPrefix("objects/cc/")
+	if err != nil {
+		t.Fatalf("list keys under missing collection: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected no keys under a missing collection, got %v", keys)
+	}
+
+	if exists, err := client.ObjectExists("objects/bb/two"); err != nil || !exists {
+		t.Fatalf("expected object to exist: exists=%t err=%v", exists, err)
+	}
+	if err := client.DeleteObject("objects/bb/two"); err != nil {
+		t.Fatalf("delete object: %v", err)
+	}
+	if err := client.DeleteObject("objects/bb/two"); err != nil {
+		t.Fatalf("delete missing object: %v", err)
+	}
+	if exists, err := client.ObjectExists("objects/bb/two"); err != nil || exists {
+		t.Fatalf("expected object to be gone: exists=%t err=%v", exists, err)
+	}
+	if _, err := client.GetObject("objects/bb/two"); !IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestWebDAVClientListsEmptyStoreBeforeFirstUpload(t *testing.T) {
+	server := startTestWebDAVServer(t)
+	client := newTestWebDAVClient(t, server)
+
+	keys, err := client.ListKeys()
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected no keys, got %v", keys)
+	}
+}
+
+func TestWebDAVClientRetriesTransientStatus(t *testing.T) {
+	server := startTestWebDAVServer(t)
+	client := newTestWebDAVClient(t, server)
+	var sleeps int
+	client.sleepFn = func(time.Duration) { sleeps++ }
+
+	server.failNext(http.MethodPut, http.StatusServiceUnavailable, 2)
+	if err := client.PutObject("objects/aa/one", []byte("one")); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+	if sleeps != 2 {
+		t.Fatalf("expected two backoffs, got %d", sleeps)
+	}
+
+	server.failNext(http.MethodGet, http.StatusServiceUnavailable, defaultOperationMaxAttempts)
+	if _, err := client.GetObject("objects/aa/one"); !IsTransient(err) {
+		t.Fatalf("expected transient error after exhausting retries, got %v", err)
+	}
+	if sleeps != 2+defaultOperationMaxAttempts-1 {
+		t.Fatalf("expected retries up to the attempt limit, got %d backoffs", sleeps)
+	}
+}
+
+func TestWebDAVClientDoesNotRetryRejectedCredentials(t *testing.T) {
+	server := startTestWebDAVServer(t)
+	t.Setenv(webdavPasswordEnv, "wrong")
+	client, err := NewWebDAVClient(server.config())
+	if err != nil {
+		t.Fatalf("new webdav client: %v", err)
+	}
+	var sleeps int
+	client.sleepFn = func(time.Duration) { sleeps++ }
+
+	err = client.PutObject("objects/aa/one", []byte("one"))
+	var statusErr *WebDAVStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %v", err)
+	}
+	if sleeps != 0 || IsTransient(err) {
+		t.Fatalf("expected rejected credentials not to be retried: sleeps=%d err=%v", sleeps, err)
+	}
+}
+
+func TestWebDAVClientStopsWhenContextCanceled(t *testing.T) {
+	server := startTestWebDAVServer(t)
+	client := newTestWebDAVClient(t, server)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	store := client.WithContext(ctx)
+	if err := store.PutObject("objects/aa/one", []byte("one")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+}
+
+func TestWebDAVErrorClassification(t *testing.T) {
+	for _, code := range []int{http.StatusNotFound, http.StatusGone} {
+		if !IsNotFound(&WebDAVStatusError{Method: http.MethodGet, StatusCode: code}) {
+			t.Fatalf("expected %d to be not found", code)
+		}
+	}
+	for _, code := range []int{http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable} {
+		if !IsTransient(&WebDAVStatusError{Method: http.MethodPut, StatusCode: code}) {
+			t.Fatalf("expected %d to be transient", code)
+		}
+	}
+	for _, code := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusInsufficientStorage} {
+		err := &WebDAVStatusError{Method: http.MethodPut, StatusCode: code}
+		if IsNotFound(err) {
+			t.Fatalf("expected %d not to be not found", code)
+		}
+		if code < 500 && IsTransient(err) {
+			t.Fatalf("expected %d not to be transient", code)
+		}
+	}
+}
+
+func TestNewFromConfigReturnsWebDAVClientWhenURLSet(t *testing.T) {
+	server := startTestWebDAVServer(t)
+	t.Setenv(webdavPasswordEnv, server.password)
+	cfg := appconfig.DefaultConfig()
+	cfg.WebDAV = server.config()
+
+	store, err := NewFromConfig(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("new from config: %v", err)
+	}
+	defer Close(store)
+	if _, ok := store.(*WebDAVClient); !ok {
+		t.Fatalf("expected WebDAVClient, got %T", store)
+	}
+}
+
+func TestNewWebDAVClientReadsPasswordFromKeychain(t *testing.T) {
+	t.Setenv(webdavPasswordEnv, "")
+	original := webdavPasswordFromKeychain
+	t.Cleanup(func() { webdavPasswordFromKeychain = original })
+	webdavPasswordFromKeychain = func(service, account string) (string, error) {
+		if service != "baxter-webdav" || account != "me" {
+			return "", fmt.Errorf("unexpected keychain item %s/%s", service, account)
+		}
+		return "from-keychain", nil
+	}
+
+	client, err := NewWebDAVClient(appconfig.WebDAVConfig{
+		URL:             "https://cloud.example.com/dav",
+		Username:        "me",
+		KeychainService: "baxter-webdav",
+		KeychainAccount: "me",
+	})
+	if err != nil {
+		t.Fatalf("new webdav client: %v", err)
+	}
+	if client.password != "from-keychain" {
+		t.Fatalf("expected keychain password, got %q", client.password)
+	}
+	if client.baseURL.Path != "/dav/" {
+		t.Fatalf("expected base collection path with trailing slash, got %q", client.baseURL.Path)
+	}
+}
+
+// testWebDAVServer is an in-memory WebDAV stand-in covering the methods the
+// client uses. Like real servers it refuses a PUT or MKCOL whose parent
+// collection is missing and answers only depth-1 PROPFIND.
+type testWebDAVServer struct {
+	*httptest.Server
+	username string
+	password string
+	root     string
+
+	mu          sync.Mutex
+	files       map[string][]byte
+	collections map[string]bool
+	failures    map[string][]int
+}
+
+func startTestWebDAVServer(t *testing.T) *testWebDAVServer {
+	t.Helper()
+
+	server := &testWebDAVServer{
+		username:    "backup",
+		password:    "secret",
+		root:        "/dav/baxter/",
+		files:       make(map[string][]byte),
+		collections: map[string]bool{"/": true, "/dav/": true},
+		failures:    make(map[string][]int),
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *testWebDAVServer) config() appconfig.WebDAVConfig {
+	return appconfig.WebDAVConfig{
+		URL:      s.URL + strings.TrimSuffix(s.root, "/"),
+		Username: s.username,
+	}
+}
+
+func (s *testWebDAVServer) failNext(method string, status int, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.failures[method] = append(s.failures[method], status)
+	}
+}
+
+func (s *testWebDAVServer) hasCollection(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.collections[name]
+}
+
+func (s *testWebDAVServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != s.username || pass != s.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pending := s.failures[r.Method]; len(pending) > 0 {
+		s.failures[r.Method] = pending[1:]
+		w.WriteHeader(pending[0])
+		return
+	}
+
+	name := r.URL.Path
+	parent := path.Dir(strings.TrimSuffix(name, "/")) + "/"
+	if parent == "//" {
+		parent = "/"
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if !s.collections[parent] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.files[name] = data
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet, http.MethodHead:
+		data, ok := s.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case http.MethodDelete:
+		if _, ok := s.files[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.files, name)
+		w.WriteHeader(http.StatusNoContent)
+	case "MKCOL":
+		collection := strings.TrimSuffix(name, "/") + "/"
+		switch {
+		case s.collections[collection]:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case !s.collections[parent]:
+			w.WriteHeader(http.StatusConflict)
+		default:
+			s.collections[collection] = true
+			w.WriteHeader(http.StatusCreated)
+		}
+	case "PROPFIND":
+		s.propfind(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *testWebDAVServer) propfind(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Depth") != "1" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	collection := strings.TrimSuffix(r.URL.Path, "/") + "/"
+	if !s.collections[collection] {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	type member struct {
+		name       string
+		collection bool
+	}
+	// Servers list the collection itself first, here without its slash.
+	members := []member{{name: strings.TrimSuffix(collection, "/"), collection: true}}
+	for name := range s.collections {
+		if name != collection && strings.HasPrefix(name, collection) && !strings.Contains(strings.TrimSuffix(strings.TrimPrefix(name, collection), "/"), "/") {
+			members = append(members, member{name: name, collection: true})
+		}
+	}
+	for name := range s.files {
+		if strings.HasPrefix(name, collection) && !strings.Contains(strings.TrimPrefix(name, collection), "/") {
+			members = append(members, member{name: name})
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].name < members[j].name })
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?><d:multistatus xmlns:d="DAV:">`)
+	for _, m := range members {
+		resourceType := "<d:resourcetype/>"
+		if m.collection {
+			resourceType = "<d:resourcetype><d:collection/></d:resourcetype>"
+		}
+		href := (&url.URL{Path: m.name}).EscapedPath()
+		fmt.Fprintf(&b, `<d:response><d:href>%s</d:href><d:propstat><d:prop>%s</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, href, resourceType)
+	}
+	b.WriteString(`</d:multistatus>`)
+
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, b.String())
+}
+
+func newTestWebDAVClient(t *testing.T, server *testWebDAVServer) *WebDAVClient {
+	t.Helper()
+
+	t.Setenv(webdavPasswordEnv, server.password)
+	client, err := NewWebDAVClient(server.config())
+	if err != nil {
+		t.Fatalf("new webdav client: %v", err)
+	}
+	client.sleepFn = nil
+	return client
+}